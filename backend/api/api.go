@@ -0,0 +1,10 @@
+// Package api описание HTTP API сервиса в формате OpenAPI 3.
+// Спецификация отдается по /openapi.json и проверяется тестами обработчиков на соответствие маршрутам.
+package api
+
+import _ "embed"
+
+// Spec спецификация OpenAPI в JSON
+//
+//go:embed openapi.json
+var Spec []byte
@@ -0,0 +1,146 @@
+// Package client типизированный Go клиент HTTP API сервиса обработки облаков точек.
+// Покрывает загрузку файла с отслеживанием прогресса, постановку задачи, ожидание ее завершения и скачивание результата.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// defaultPollInterval как часто WaitJob опрашивает состояние задачи
+const defaultPollInterval = time.Second
+
+// Client клиент API. Безопасен для одновременного использования из нескольких горутин.
+type Client struct {
+	baseURL      *url.URL
+	httpClient   *http.Client
+	apiKey       string
+	bearerToken  string
+	language     string
+	pollInterval time.Duration
+}
+
+// Option настройка клиента
+type Option func(*Client)
+
+// WithAPIKey передает API ключ в заголовке X-API-Key
+func WithAPIKey(key string) Option {
+	return func(c *Client) { c.apiKey = key }
+}
+
+// WithBearerToken передает JWT или API ключ в заголовке Authorization
+func WithBearerToken(token string) Option {
+	return func(c *Client) { c.bearerToken = token }
+}
+
+// WithHTTPClient задает HTTP клиент; по умолчанию http.DefaultClient.
+// Таймаут клиента ограничивает и скачивание результата, поэтому длительные операции лучше ограничивать контекстом.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) { c.httpClient = httpClient }
+}
+
+// WithLanguage задает язык сообщений об ошибках (Accept-Language): ru или en
+func WithLanguage(lang string) Option {
+	return func(c *Client) { c.language = lang }
+}
+
+// WithPollInterval задает интервал опроса состояния задачи в WaitJob
+func WithPollInterval(d time.Duration) Option {
+	return func(c *Client) { c.pollInterval = d }
+}
+
+// New создает клиент сервиса по адресу baseURL, например http://localhost:8000
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimRight(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("неверный адрес сервиса: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("неверный адрес сервиса %q: нужна схема http или https", baseURL)
+	}
+	c := &Client{
+		baseURL:      u,
+		httpClient:   http.DefaultClient,
+		pollInterval: defaultPollInterval,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// newRequest создает запрос к API с учетными данными клиента
+func (c *Client) newRequest(ctx context.Context, method string, path string, body io.Reader) (*http.Request, error) {
+	u := *c.baseURL
+	u.Path += path
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+	if c.bearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.bearerToken)
+	}
+	if c.language != "" {
+		req.Header.Set("Accept-Language", c.language)
+	}
+	return req, nil
+}
+
+// send выполняет запрос и возвращает ответ с ожидаемым статусом; иначе — *Error
+func (c *Client) send(req *http.Request, wantStatus int) (*http.Response, error) {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != wantStatus {
+		defer resp.Body.Close()
+		return nil, decodeError(resp)
+	}
+	return resp, nil
+}
+
+// doJSON выполняет запрос с JSON телом in и разбирает JSON ответ в out, если он не nil
+func (c *Client) doJSON(ctx context.Context, method string, path string, in any, wantStatus int, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := c.newRequest(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.send(req, wantStatus)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		return nil
+	}
+	return decodeJSON(resp, out)
+}
+
+// decodeJSON разбирает JSON тело ответа
+func decodeJSON(resp *http.Response, out any) error {
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("неверный ответ %s %s: %w", resp.Request.Method, resp.Request.URL.Path, err)
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"lct/internal/handlers"
+	"lct/internal/repository/memory"
+	"lct/internal/service/usecase"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const testKey = "test-bootstrap-key-0123456789abcdef"
+
+type testServer struct {
+	rabbit *memory.RabbitClient
+	client *Client
+}
+
+// newTestServer поднимает API на фейках хранилищ и брокера; фейковый CV worker возвращает копию файла
+func newTestServer(t *testing.T, opts ...Option) *testServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	repo := memory.NewRepository()
+	storage := memory.NewObjectStorage("testbucket")
+	rabbit := memory.NewRabbitClient(storage)
+	service := usecase.NewService(repo, storage, rabbit, usecase.Config{
+		ModelVersion:      "test-model",
+		ProcessingTimeout: time.Second,
+		BootstrapAPIKey:   testKey,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go service.RunOutboxRelay(ctx, 10*time.Millisecond)
+	go service.RunResultConsumer(ctx)
+
+	router := gin.New()
+	handlers.NewMinioHandler(service, handlers.AuthConfig{}, handlers.LimitsConfig{}).RegisterRoutes(router)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	opts = append([]Option{WithAPIKey(testKey), WithPollInterval(5 * time.Millisecond)}, opts...)
+	c, err := New(server.URL, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testServer{rabbit: rabbit, client: c}
+}
+
+func TestUploadProcessAndDownload(t *testing.T) {
+	ts := newTestServer(t)
+	ctx := context.Background()
+	content := bytes.Repeat([]byte("point "), 1000)
+
+	var uploaded int64
+	file, err := ts.client.Upload(ctx, "scan.pcd", bytes.NewReader(content), int64(len(content)), func(done, total int64) {
+		if total != int64(len(content)) {
+			t.Errorf("total = %d", total)
+		}
+		uploaded = done
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uploaded != int64(len(content)) || file.Filename != "scan.pcd" || file.Size != int64(len(content)) {
+		t.Fatalf("file = %+v, uploaded = %d", file, uploaded)
+	}
+
+	job, err := ts.client.CreateJob(ctx, file.ID, JobRequest{Params: &ProcessingParams{Threshold: Ptr(0.5)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var statuses []string
+	job, err = ts.client.WaitJob(ctx, job.ID, func(j *Job) { statuses = append(statuses, j.Status) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != JobStatusDone || job.Params == nil || *job.Params.Threshold != 0.5 || *job.Params.GridDivisions != 25 {
+		t.Errorf("job = %+v", job)
+	}
+	if len(statuses) == 0 || statuses[len(statuses)-1] != JobStatusDone {
+		t.Errorf("statuses = %v", statuses)
+	}
+
+	var result bytes.Buffer
+	var downloaded int64
+	n, err := ts.client.DownloadResult(ctx, job.ID, &result, func(done, total int64) { downloaded = done })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(content)) || downloaded != n || !bytes.Equal(result.Bytes(), content) {
+		t.Errorf("downloaded %d bytes, progress %d", n, downloaded)
+	}
+
+	list, err := ts.client.ListFiles(ctx, FileQuery{Name: "SCAN", CreatedAfter: time.Now().Add(-time.Hour)})
+	if err != nil || list.Total != 1 || len(list.Files) != 1 || list.Files[0].ID != file.ID {
+		t.Errorf("list = %+v, err = %v", list, err)
+	}
+
+	artifacts, err := ts.client.Artifacts(ctx, file.ID)
+	if err != nil || len(artifacts) != 1 || artifacts[0].JobID == nil || *artifacts[0].JobID != job.ID {
+		t.Errorf("artifacts = %+v, err = %v", artifacts, err)
+	}
+
+	// Повтор с теми же параметрами берется из кэша
+	cached, err := ts.client.CreateJob(ctx, file.ID, JobRequest{Params: &ProcessingParams{Threshold: Ptr(0.5)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cached.CacheHit || cached.Status != JobStatusDone {
+		t.Errorf("cached job = %+v", cached)
+	}
+
+	if err := ts.client.DeleteFile(ctx, file.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ts.client.GetFile(ctx, file.ID); !IsCode(err, CodeNotFound) {
+		t.Errorf("GetFile after delete: err = %v, want not_found", err)
+	}
+}
+
+func TestWaitJobReportsFailedJob(t *testing.T) {
+	ts := newTestServer(t)
+	ts.rabbit.Worker = func(body []byte) ([]byte, error) {
+		return []byte(`{"error": "CUDA out of memory"}`), nil
+	}
+	ctx := context.Background()
+
+	file, err := ts.client.Upload(ctx, "scan.pcd", strings.NewReader("data"), -1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, err := ts.client.CreateJob(ctx, file.ID, JobRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, err = ts.client.WaitJob(ctx, job.ID, nil)
+	if !errors.Is(err, ErrJobFailed) || job == nil || job.Status != JobStatusFailed {
+		t.Fatalf("job = %+v, err = %v", job, err)
+	}
+	_, err = ts.client.DownloadResult(ctx, job.ID, &bytes.Buffer{}, nil)
+	if !IsCode(err, CodeJobNotDone) {
+		t.Errorf("err = %v, want job_not_done", err)
+	}
+}
+
+func TestErrorsAreDecoded(t *testing.T) {
+	ts := newTestServer(t, WithAPIKey(""), WithLanguage("en"))
+
+	_, err := ts.client.GetJob(context.Background(), 1)
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("err = %v, want *Error", err)
+	}
+	if apiErr.StatusCode != http.StatusUnauthorized || apiErr.Code != CodeUnauthenticated || apiErr.Message != "Authentication required" || apiErr.RequestID == "" {
+		t.Errorf("error = %+v", apiErr)
+	}
+}
+
+func TestWaitJobStopsWithContext(t *testing.T) {
+	ts := newTestServer(t)
+	// Воркер не отвечает, задача остается в pending
+	ts.rabbit.Worker = func(body []byte) ([]byte, error) {
+		return nil, errors.New("worker is down")
+	}
+	ctx := context.Background()
+	file, err := ts.client.Upload(ctx, "scan.pcd", strings.NewReader("data"), 4, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, err := ts.client.CreateJob(ctx, file.ID, JobRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err := ts.client.WaitJob(waitCtx, job.ID, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want deadline exceeded", err)
+	}
+}
+
+func TestNewRejectsBadURL(t *testing.T) {
+	for _, u := range []string{"localhost:8000", "ftp://host", "://"} {
+		if _, err := New(u); err == nil {
+			t.Errorf("New(%q) succeeded", u)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Коды ошибок API, которые клиенту обычно нужно различать
+const (
+	CodeNotFound           = "not_found"
+	CodeUnauthenticated    = "unauthenticated"
+	CodeForbidden          = "forbidden"
+	CodeJobNotDone         = "job_not_done"
+	CodeResultDeleted      = "result_deleted"
+	CodeRateLimited        = "rate_limited"
+	CodeTooManyPendingJobs = "too_many_pending_jobs"
+	CodeJobQuotaExceeded   = "job_quota_exceeded"
+	CodeShuttingDown       = "shutting_down"
+	CodeWorkerFailed       = "worker_failed"
+)
+
+// maxErrorBody сколько байт тела ответа читать из ответа с ошибкой
+const maxErrorBody = 64 << 10
+
+// Error ошибка, которую вернул сервис
+type Error struct {
+	StatusCode int    `json:"status"`
+	Code       string `json:"code"`  // Машиночитаемый код, не зависит от языка
+	Message    string `json:"error"` // Сообщение на языке из WithLanguage
+	Details    string `json:"details,omitempty"`
+	RequestID  string `json:"request_id,omitempty"`
+	// RetryAfter через сколько повторить запрос, если сервис это подсказал
+	RetryAfter time.Duration `json:"-"`
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("%d %s: %s", e.StatusCode, e.Code, e.Message)
+	if e.Details != "" {
+		msg += ": " + e.Details
+	}
+	if e.RequestID != "" {
+		msg += " (request_id " + e.RequestID + ")"
+	}
+	return msg
+}
+
+// Temporary сообщает, имеет ли смысл повторить запрос позже
+func (e *Error) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode == http.StatusServiceUnavailable
+}
+
+// IsCode сообщает, является ли err ошибкой API с кодом code
+func IsCode(err error, code string) bool {
+	var e *Error
+	return errors.As(err, &e) && e.Code == code
+}
+
+// decodeError разбирает ответ с ошибкой. Ответ не в едином формате (например, от прокси) сохраняется в Details.
+func decodeError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	e := &Error{}
+	if err := json.Unmarshal(body, e); err != nil || e.Code == "" {
+		e = &Error{Code: "unknown", Message: http.StatusText(resp.StatusCode), Details: string(body)}
+	}
+	e.StatusCode = resp.StatusCode
+	if e.RequestID == "" {
+		e.RequestID = resp.Header.Get("X-Request-ID")
+	}
+	if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		e.RetryAfter = time.Duration(s) * time.Second
+	}
+	return e
+}
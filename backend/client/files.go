@@ -0,0 +1,227 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// File метаданные исходного файла
+type File struct {
+	ID        int64     `json:"id"`
+	Filename  string    `json:"filename"`
+	Size      int64     `json:"size"`
+	Bucket    string    `json:"bucket"`
+	ObjectKey string    `json:"minio_key"`
+	SHA256    string    `json:"sha256,omitempty"`
+	Status    string    `json:"status"`
+	Owner     string    `json:"owner,omitempty"`
+	Project   string    `json:"project"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Artifact производный объект исходного файла, например результат обработки
+type Artifact struct {
+	ID        int64     `json:"id"`
+	FileID    int64     `json:"file_id"`
+	JobID     *int64    `json:"job_id,omitempty"`
+	Type      string    `json:"type"`
+	Bucket    string    `json:"bucket"`
+	ObjectKey string    `json:"minio_key"`
+	Size      int64     `json:"size"`
+	SHA256    string    `json:"sha256"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ProgressFunc получает число переданных байт и общий размер; total равен -1, если размер неизвестен
+type ProgressFunc func(done int64, total int64)
+
+// progressReader вызывает ProgressFunc по мере чтения
+type progressReader struct {
+	r        io.Reader
+	done     int64
+	total    int64
+	progress ProgressFunc
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.done += int64(n)
+		p.progress(p.done, p.total)
+	}
+	return n, err
+}
+
+// withProgress оборачивает r, если задан progress
+func withProgress(r io.Reader, total int64, progress ProgressFunc) io.Reader {
+	if progress == nil {
+		return r
+	}
+	return &progressReader{r: r, total: total, progress: progress}
+}
+
+// Upload загружает файл name с содержимым r размером size байт (-1, если неизвестен).
+// Содержимое передается потоком, не загружаясь в память целиком.
+func (c *Client) Upload(ctx context.Context, name string, r io.Reader, size int64, progress ProgressFunc) (*File, error) {
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		fw, err := mw.CreateFormFile("file", name)
+		if err == nil {
+			_, err = io.Copy(fw, withProgress(r, size, progress))
+		}
+		if err == nil {
+			err = mw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	req, err := c.newRequest(ctx, http.MethodPost, "/files", pr)
+	if err != nil {
+		pr.Close()
+		return nil, err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	resp, err := c.send(req, http.StatusCreated)
+	// Запрос мог завершиться до конца чтения тела; закрытие трубы останавливает горутину записи
+	pr.Close()
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var file File
+	if err := decodeJSON(resp, &file); err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// UploadFile загружает файл с диска
+func (c *Client) UploadFile(ctx context.Context, path string, progress ProgressFunc) (*File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return c.Upload(ctx, filepath.Base(path), f, stat.Size(), progress)
+}
+
+// GetFile возвращает метаданные файла
+func (c *Client) GetFile(ctx context.Context, id int64) (*File, error) {
+	var file File
+	if err := c.doJSON(ctx, http.MethodGet, fmt.Sprintf("/files/%d", id), nil, http.StatusOK, &file); err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// FileQuery условия поиска файлов; пустые поля не ограничивают выборку
+type FileQuery struct {
+	Name          string // Подстрока имени без учета регистра
+	SHA256        string
+	Project       string // Учитывается только для администраторов
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	Limit         int // 0 — размер страницы по умолчанию сервиса
+	Offset        int
+}
+
+// FileList страница результатов поиска
+type FileList struct {
+	Files []File
+	Total int64 // Сколько всего файлов подходит под условия
+}
+
+// ListFiles ищет файлы от новых к старым
+func (c *Client) ListFiles(ctx context.Context, q FileQuery) (*FileList, error) {
+	values := url.Values{}
+	set := func(key, value string) {
+		if value != "" {
+			values.Set(key, value)
+		}
+	}
+	set("name", q.Name)
+	set("sha256", q.SHA256)
+	set("project", q.Project)
+	if !q.CreatedAfter.IsZero() {
+		set("created_after", q.CreatedAfter.Format(time.RFC3339))
+	}
+	if !q.CreatedBefore.IsZero() {
+		set("created_before", q.CreatedBefore.Format(time.RFC3339))
+	}
+	if q.Limit > 0 {
+		set("limit", strconv.Itoa(q.Limit))
+	}
+	if q.Offset > 0 {
+		set("offset", strconv.Itoa(q.Offset))
+	}
+
+	req, err := c.newRequest(ctx, http.MethodGet, "/files", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.URL.RawQuery = values.Encode()
+	resp, err := c.send(req, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	list := &FileList{}
+	if err := decodeJSON(resp, &list.Files); err != nil {
+		return nil, err
+	}
+	list.Total, _ = strconv.ParseInt(resp.Header.Get("X-Total-Count"), 10, 64)
+	return list, nil
+}
+
+// DeleteFile удаляет файл вместе с задачами и результатами
+func (c *Client) DeleteFile(ctx context.Context, id int64) error {
+	return c.doJSON(ctx, http.MethodDelete, fmt.Sprintf("/files/%d", id), nil, http.StatusNoContent, nil)
+}
+
+// Artifacts возвращает производные объекты файла
+func (c *Client) Artifacts(ctx context.Context, fileID int64) ([]Artifact, error) {
+	var artifacts []Artifact
+	if err := c.doJSON(ctx, http.MethodGet, fmt.Sprintf("/files/%d/artifacts", fileID), nil, http.StatusOK, &artifacts); err != nil {
+		return nil, err
+	}
+	return artifacts, nil
+}
+
+// DownloadFile записывает в w исходный файл и возвращает число записанных байт
+func (c *Client) DownloadFile(ctx context.Context, id int64, w io.Writer, progress ProgressFunc) (int64, error) {
+	return c.download(ctx, fmt.Sprintf("/files/%d/download", id), w, progress)
+}
+
+// download записывает в w тело ответа на GET path
+func (c *Client) download(ctx context.Context, path string, w io.Writer, progress ProgressFunc) (int64, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := c.send(req, http.StatusOK)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	n, err := io.Copy(w, withProgress(resp.Body, resp.ContentLength, progress))
+	if err != nil {
+		return n, fmt.Errorf("ошибка скачивания %s: %w", path, err)
+	}
+	if resp.ContentLength >= 0 && n != resp.ContentLength {
+		return n, fmt.Errorf("ошибка скачивания %s: получено %d байт из %d", path, n, resp.ContentLength)
+	}
+	return n, nil
+}
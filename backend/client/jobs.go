@@ -0,0 +1,140 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Статусы задачи обработки
+const (
+	JobStatusPending     = "pending"
+	JobStatusDone        = "done"
+	JobStatusFailed      = "failed"
+	JobStatusTimeout     = "timeout"
+	JobStatusInterrupted = "interrupted" // Прервана перезапуском сервиса и будет возобновлена
+)
+
+// ErrJobFailed задача завершилась ошибкой или не уложилась во время
+var ErrJobFailed = errors.New("задача не выполнена")
+
+// ProcessingParams параметры обработки; nil поля принимают значения по умолчанию сервиса
+type ProcessingParams struct {
+	Threshold             *float64 `json:"threshold,omitempty"`
+	VoxelSize             *float64 `json:"voxel_size,omitempty"`
+	UseDownsample         *bool    `json:"use_downsample,omitempty"`
+	EdgeDistanceThreshold *float64 `json:"edge_distance_threshold,omitempty"`
+	ZUpperStaticThreshold *float64 `json:"z_upper_static_threshold,omitempty"`
+	GroundHeightThreshold *float64 `json:"ground_height_threshold,omitempty"`
+	GridDivisions         *int     `json:"grid_divisions,omitempty"`
+}
+
+// Ptr возвращает указатель на v; удобен для заполнения ProcessingParams
+func Ptr[T any](v T) *T {
+	return &v
+}
+
+// JobRequest запрос на обработку файла
+type JobRequest struct {
+	Params *ProcessingParams `json:"params,omitempty"`
+	// ReuseResult false заставляет обработать файл заново, не используя кэш результатов
+	ReuseResult *bool `json:"reuse_result,omitempty"`
+}
+
+// Job задача обработки файла
+type Job struct {
+	ID            int64             `json:"id"`
+	FileID        int64             `json:"file_id"`
+	CorrelationID string            `json:"correlation_id"`
+	Status        string            `json:"status"`
+	Params        *ProcessingParams `json:"params,omitempty"`
+	ParamsHash    string            `json:"params_hash,omitempty"`
+	ModelVersion  string            `json:"model_version,omitempty"`
+	CacheHit      bool              `json:"cache_hit"`
+	ArtifactID    *int64            `json:"artifact_id,omitempty"`
+	Error         string            `json:"error,omitempty"`
+	Owner         string            `json:"owner,omitempty"`
+	Project       string            `json:"project"`
+	CreatedAt     time.Time         `json:"created_at"`
+	FinishedAt    *time.Time        `json:"finished_at,omitempty"`
+	// Attempts сколько раз задача отдавалась CV worker'у; задача с истекшей арендой возвращается в очередь
+	Attempts       int        `json:"attempts"`
+	LeaseExpiresAt *time.Time `json:"lease_expires_at,omitempty"`
+	HeartbeatAt    *time.Time `json:"heartbeat_at,omitempty"`
+}
+
+// Finished сообщает, завершена ли задача. Прерванная задача не завершена: сервис возобновит ее после перезапуска.
+func (j *Job) Finished() bool {
+	switch j.Status {
+	case JobStatusDone, JobStatusFailed, JobStatusTimeout:
+		return true
+	}
+	return false
+}
+
+// CreateJob ставит файл в обработку и возвращает задачу, не дожидаясь результата.
+// Если результат с теми же параметрами уже есть в кэше, задача сразу выполнена.
+func (c *Client) CreateJob(ctx context.Context, fileID int64, req JobRequest) (*Job, error) {
+	var job Job
+	if err := c.doJSON(ctx, http.MethodPost, fmt.Sprintf("/files/%d/jobs", fileID), req, http.StatusAccepted, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// GetJob возвращает состояние задачи
+func (c *Client) GetJob(ctx context.Context, id int64) (*Job, error) {
+	var job Job
+	if err := c.doJSON(ctx, http.MethodGet, fmt.Sprintf("/jobs/%d", id), nil, http.StatusOK, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// WaitJob опрашивает задачу, пока она не завершится, и вызывает onUpdate при каждой смене статуса.
+// Временные ошибки сервиса (429, 503) не прерывают ожидание. Если задача не выполнена, вместе с ней
+// возвращается ошибка ErrJobFailed.
+func (c *Client) WaitJob(ctx context.Context, id int64, onUpdate func(*Job)) (*Job, error) {
+	var status string
+	for {
+		job, err := c.GetJob(ctx, id)
+		var apiErr *Error
+		switch {
+		case err == nil:
+			if job.Status != status && onUpdate != nil {
+				onUpdate(job)
+			}
+			status = job.Status
+			if job.Finished() {
+				if job.Status != JobStatusDone {
+					return job, fmt.Errorf("%w: задача %d в состоянии %s: %s", ErrJobFailed, job.ID, job.Status, job.Error)
+				}
+				return job, nil
+			}
+		case errors.As(err, &apiErr) && apiErr.Temporary():
+		default:
+			return nil, err
+		}
+
+		wait := c.pollInterval
+		if apiErr != nil && apiErr.RetryAfter > wait {
+			wait = apiErr.RetryAfter
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// DownloadResult записывает в w результат выполненной задачи и возвращает число записанных байт.
+// Пока задача не выполнена, возвращает ошибку с кодом CodeJobNotDone.
+func (c *Client) DownloadResult(ctx context.Context, jobID int64, w io.Writer, progress ProgressFunc) (int64, error) {
+	return c.download(ctx, fmt.Sprintf("/jobs/%d/result", jobID), w, progress)
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"lct/client"
+	"lct/internal/pointcloud"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// defaultStateFile имя файла состояния пакетной обработки в обрабатываемом каталоге
+const defaultStateFile = ".lidarctl-batch.json"
+
+// Этапы обработки файла в пакете
+const (
+	stageUploaded = "uploaded" // Файл загружен, задача не создана
+	stageQueued   = "queued"   // Задача создана, результат не скачан
+	stageDone     = "done"     // Результат сохранен в Output
+	stageFailed   = "failed"   // Задача завершилась ошибкой; при следующем запуске создается заново
+)
+
+// batchItem состояние обработки одного файла. Size, ModTime и Request определяют, актуально ли
+// сохраненное состояние: если файл или параметры изменились, файл обрабатывается с начала.
+type batchItem struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	Request string    `json:"request"`
+	Stage   string    `json:"stage"`
+	FileID  int64     `json:"file_id,omitempty"`
+	JobID   int64     `json:"job_id,omitempty"`
+	Output  string    `json:"output,omitempty"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// batchState состояние пакетной обработки, сохраняемое после каждого шага
+type batchState struct {
+	path  string
+	mu    sync.Mutex
+	Items map[string]*batchItem `json:"items"` // По имени файла в каталоге
+}
+
+func loadBatchState(path string) (*batchState, error) {
+	s := &batchState{path: path, Items: make(map[string]*batchItem)}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("файл состояния %s: %w", path, err)
+	}
+	if s.Items == nil {
+		s.Items = make(map[string]*batchItem)
+	}
+	return s, nil
+}
+
+// update меняет состояние файла name и сохраняет его на диск
+func (s *batchState) update(name string, item batchItem) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Items[name] = &item
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Запись через временный файл, чтобы прерванный запуск не оставил испорченное состояние
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+func (s *batchState) get(name string) (batchItem, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.Items[name]
+	if !ok {
+		return batchItem{}, false
+	}
+	return *item, true
+}
+
+// batch пакетная обработка каталога
+type batch struct {
+	a       *app
+	dir     string
+	outDir  string
+	format  pointcloud.Format
+	req     client.JobRequest
+	reqKey  string
+	state   *batchState
+	mu      sync.Mutex
+	summary map[string]int
+}
+
+func runBatch(ctx context.Context, a *app, args []string) error {
+	fs := a.newFlagSet("batch")
+	params := addParamFlags(fs)
+	concurrency := fs.Int("concurrency", 4, "сколько файлов обрабатывать одновременно")
+	pattern := fs.String("pattern", "*.pcd", "шаблон имен файлов каталога")
+	outDir := fs.String("out", "", "каталог результатов; по умолчанию DIR/processed")
+	format := fs.String("format", string(pointcloud.FormatPLY), "формат результатов: ply, pcd или xyz")
+	statePath := fs.String("state", "", "файл состояния для возобновления; по умолчанию DIR/"+defaultStateFile)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || *concurrency < 1 {
+		fs.Usage()
+		return errUsage
+	}
+	f, err := pointcloud.ParseFormat(*format)
+	if err != nil {
+		return err
+	}
+	req, err := params.request()
+	if err != nil {
+		return err
+	}
+	reqKey, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	dir := fs.Arg(0)
+	paths, err := filepath.Glob(filepath.Join(dir, *pattern))
+	if err != nil {
+		return err
+	}
+	sort.Strings(paths)
+	if *outDir == "" {
+		*outDir = filepath.Join(dir, "processed")
+	}
+	if err := os.MkdirAll(*outDir, 0o755); err != nil {
+		return err
+	}
+	if *statePath == "" {
+		*statePath = filepath.Join(dir, defaultStateFile)
+	}
+	state, err := loadBatchState(*statePath)
+	if err != nil {
+		return err
+	}
+
+	b := &batch{a: a, dir: dir, outDir: *outDir, format: f, req: req, reqKey: string(reqKey), state: state, summary: map[string]int{}}
+	// Индикаторы прогресса параллельных передач перемешались бы, поэтому выводятся только итоги по файлам
+	quiet := *a
+	quiet.quiet = true
+	b.a = &quiet
+
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < *concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range jobs {
+				b.process(ctx, path)
+			}
+		}()
+	}
+	for _, path := range paths {
+		if info, err := os.Stat(path); err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		select {
+		case jobs <- path:
+		case <-ctx.Done():
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	fmt.Fprintf(a.stderr, "обработано: %d, пропущено: %d, ошибок: %d\n", b.summary["processed"], b.summary["skipped"], b.summary["failed"])
+	if ctx.Err() != nil {
+		return fmt.Errorf("прервано; повторный запуск продолжит с того же места: %w", ctx.Err())
+	}
+	if b.summary["failed"] > 0 {
+		return fmt.Errorf("не обработано файлов: %d; повторный запуск обработает их заново", b.summary["failed"])
+	}
+	return nil
+}
+
+func (b *batch) count(key string) {
+	b.mu.Lock()
+	b.summary[key]++
+	b.mu.Unlock()
+}
+
+// process доводит файл до сохраненного результата, продолжая с этапа из состояния
+func (b *batch) process(ctx context.Context, path string) {
+	if ctx.Err() != nil {
+		return
+	}
+	name := filepath.Base(path)
+	skipped, err := b.resume(ctx, path, name)
+	switch {
+	case skipped:
+		b.count("skipped")
+	case err != nil && ctx.Err() != nil:
+		// Прерванный файл продолжится при следующем запуске
+	case err != nil:
+		b.count("failed")
+		fmt.Fprintf(b.a.stderr, "%s: %v\n", name, err)
+	default:
+		b.count("processed")
+	}
+}
+
+func (b *batch) resume(ctx context.Context, path string, name string) (skipped bool, err error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	item, ok := b.state.get(name)
+	if !ok || item.Size != info.Size() || !item.ModTime.Equal(info.ModTime()) || item.Request != b.reqKey {
+		item = batchItem{Size: info.Size(), ModTime: info.ModTime(), Request: b.reqKey}
+	}
+	if item.Stage == stageDone {
+		if _, err := os.Stat(item.Output); err == nil {
+			return true, nil
+		}
+		// Результат удален — скачать заново
+		item.Stage = stageQueued
+	}
+	save := func() error { return b.state.update(name, item) }
+	fail := func(err error) error {
+		item.Stage, item.Error = stageFailed, err.Error()
+		if serr := save(); serr != nil {
+			return serr
+		}
+		return err
+	}
+
+	// Загруженный ранее файл мог быть удален из сервиса
+	if item.FileID != 0 {
+		if _, err := b.a.client.GetFile(ctx, item.FileID); client.IsCode(err, client.CodeNotFound) {
+			item.FileID, item.JobID = 0, 0
+		} else if err != nil {
+			return false, err
+		}
+	}
+	if item.FileID == 0 {
+		file, err := b.a.upload(ctx, path)
+		if err != nil {
+			return false, err
+		}
+		item.FileID, item.JobID, item.Stage, item.Error = file.ID, 0, stageUploaded, ""
+		if err := save(); err != nil {
+			return false, err
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		if item.JobID == 0 || item.Stage == stageFailed {
+			job, err := b.a.client.CreateJob(ctx, item.FileID, b.req)
+			if err != nil {
+				return false, err
+			}
+			item.JobID, item.Stage, item.Error = job.ID, stageQueued, ""
+			if err := save(); err != nil {
+				return false, err
+			}
+		}
+		_, err = b.a.client.WaitJob(ctx, item.JobID, nil)
+		// Задача из прошлого запуска могла быть удалена вместе с файлом; создаем новую один раз
+		if client.IsCode(err, client.CodeNotFound) && attempt == 0 {
+			item.JobID = 0
+			continue
+		}
+		break
+	}
+	if errors.Is(err, client.ErrJobFailed) {
+		return false, fail(err)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	out := resultPath(path, b.outDir, b.format)
+	if err := b.a.saveResult(ctx, item.JobID, out, b.format); err != nil {
+		return false, err
+	}
+	item.Stage, item.Output = stageDone, out
+	return false, save()
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"lct/client"
+	"lct/internal/pointcloud"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+func runUpload(ctx context.Context, a *app, args []string) error {
+	fs := a.newFlagSet("upload")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errUsage
+	}
+	for _, path := range fs.Args() {
+		file, err := a.upload(ctx, path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		fmt.Fprintf(a.stdout, "%d\t%s\n", file.ID, path)
+	}
+	return nil
+}
+
+// upload загружает файл с индикатором прогресса
+func (a *app) upload(ctx context.Context, path string) (*client.File, error) {
+	p := a.progress("загрузка " + filepath.Base(path))
+	defer p.done()
+	return a.client.UploadFile(ctx, path, p.update)
+}
+
+func runProcess(ctx context.Context, a *app, args []string) error {
+	fs := a.newFlagSet("process")
+	params := addParamFlags(fs)
+	wait := fs.Bool("wait", false, "дождаться завершения и скачать результат")
+	out := fs.String("o", "", "куда сохранить результат с -wait; - — в stdout. По умолчанию рядом с исходным файлом")
+	format := fs.String("format", string(pointcloud.FormatPLY), "формат результата: ply, pcd или xyz")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errUsage
+	}
+	f, err := pointcloud.ParseFormat(*format)
+	if err != nil {
+		return err
+	}
+	req, err := params.request()
+	if err != nil {
+		return err
+	}
+
+	// Аргумент — ID загруженного файла или путь к новому
+	arg := fs.Arg(0)
+	fileID, err := strconv.ParseInt(arg, 10, 64)
+	outPath := *out
+	if err != nil {
+		file, err := a.upload(ctx, arg)
+		if err != nil {
+			return err
+		}
+		fileID = file.ID
+		if outPath == "" {
+			outPath = resultPath(arg, "", f)
+		}
+	}
+
+	job, err := a.client.CreateJob(ctx, fileID, req)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.stdout, "%d\t%s\n", job.ID, job.Status)
+	if !*wait {
+		return nil
+	}
+
+	if _, err := a.client.WaitJob(ctx, job.ID, a.printStatus); err != nil {
+		return err
+	}
+	if outPath == "" {
+		outPath = fmt.Sprintf("job-%d.%s", job.ID, f)
+	}
+	return a.saveResult(ctx, job.ID, outPath, f)
+}
+
+// resultPath путь результата обработки src в формате f в каталоге dir (пустой — рядом с src)
+func resultPath(src string, dir string, f pointcloud.Format) string {
+	base := strings.TrimSuffix(filepath.Base(src), filepath.Ext(src))
+	if dir == "" {
+		dir = filepath.Dir(src)
+	}
+	return filepath.Join(dir, base+"_processed."+string(f))
+}
+
+// printStatus выводит смену статуса задачи
+func (a *app) printStatus(job *client.Job) {
+	line := fmt.Sprintf("задача %d: %s", job.ID, job.Status)
+	if job.CacheHit {
+		line += " (из кэша)"
+	}
+	if job.Error != "" {
+		line += ": " + job.Error
+	}
+	fmt.Fprintln(a.stderr, line)
+}
+
+func runWatch(ctx context.Context, a *app, args []string) error {
+	fs := a.newFlagSet("watch")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errUsage
+	}
+	ids := make([]int64, fs.NArg())
+	for i, arg := range fs.Args() {
+		id, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			return fmt.Errorf("неверный ID задачи %q", arg)
+		}
+		ids[i] = id
+	}
+
+	var (
+		mu     sync.Mutex
+		failed []string
+		wg     sync.WaitGroup
+	)
+	for _, id := range ids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			job, err := a.client.WaitJob(ctx, id, func(job *client.Job) {
+				mu.Lock()
+				defer mu.Unlock()
+				fmt.Fprintf(a.stdout, "%s\t%d\t%s\n", time.Now().Format(time.TimeOnly), job.ID, job.Status)
+			})
+			if err != nil {
+				mu.Lock()
+				defer mu.Unlock()
+				if job == nil {
+					failed = append(failed, fmt.Sprintf("задача %d: %v", id, err))
+				} else {
+					failed = append(failed, fmt.Sprintf("задача %d: %s %s", id, job.Status, job.Error))
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if len(failed) > 0 {
+		return errors.New(strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+func runDownload(ctx context.Context, a *app, args []string) error {
+	fs := a.newFlagSet("download")
+	format := fs.String("format", string(pointcloud.FormatPLY), "формат результата: ply, pcd или xyz")
+	out := fs.String("o", "", "куда сохранить результат; - — в stdout. По умолчанию job-ID.формат")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errUsage
+	}
+	jobID, err := strconv.ParseInt(fs.Arg(0), 10, 64)
+	if err != nil {
+		return fmt.Errorf("неверный ID задачи %q", fs.Arg(0))
+	}
+	f, err := pointcloud.ParseFormat(*format)
+	if err != nil {
+		return err
+	}
+	path := *out
+	if path == "" {
+		path = fmt.Sprintf("job-%d.%s", jobID, f)
+	}
+	return a.saveResult(ctx, jobID, path, f)
+}
+
+// saveResult скачивает результат задачи в формате f. Файл пишется во временный и переименовывается
+// только после успешного скачивания, поэтому прерванное скачивание не оставляет неполный результат.
+func (a *app) saveResult(ctx context.Context, jobID int64, path string, f pointcloud.Format) error {
+	if path == "-" {
+		return a.downloadConverted(ctx, jobID, a.stdout, f)
+	}
+	tmp := path + ".part"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	err = a.downloadConverted(ctx, jobID, out, f)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.stderr, "результат задачи %d сохранен в %s\n", jobID, path)
+	return nil
+}
+
+// downloadConverted скачивает результат и на лету переводит его в формат f
+func (a *app) downloadConverted(ctx context.Context, jobID int64, w io.Writer, f pointcloud.Format) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	pr, pw := io.Pipe()
+	downloaded := make(chan error, 1)
+	go func() {
+		p := a.progress(fmt.Sprintf("скачивание результата задачи %d", jobID))
+		_, err := a.client.DownloadResult(ctx, jobID, pw, p.update)
+		p.done()
+		pw.CloseWithError(err)
+		downloaded <- err
+	}()
+
+	err := pointcloud.Convert(w, pr, f)
+	if err != nil {
+		// Остановить скачивание, если конвертация не удалась
+		cancel()
+		pr.CloseWithError(err)
+	}
+	if derr := <-downloaded; derr != nil {
+		return derr
+	}
+	return err
+}
+
+func runFiles(ctx context.Context, a *app, args []string) error {
+	fs := a.newFlagSet("files")
+	name := fs.String("name", "", "подстрока имени файла")
+	sha := fs.String("sha256", "", "SHA-256 содержимого")
+	project := fs.String("project", "", "проект (только для администраторов)")
+	since := fs.Duration("since", 0, "загруженные за последний период, например 24h")
+	limit := fs.Int("limit", 50, "сколько файлов показать")
+	offset := fs.Int("offset", 0, "сколько первых найденных файлов пропустить")
+	asJSON := fs.Bool("json", false, "вывести JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	q := client.FileQuery{Name: *name, SHA256: *sha, Project: *project, Limit: *limit, Offset: *offset}
+	if *since > 0 {
+		q.CreatedAfter = time.Now().Add(-*since)
+	}
+	list, err := a.client.ListFiles(ctx, q)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(a.stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(list.Files)
+	}
+	tw := tabwriter.NewWriter(a.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tИМЯ\tРАЗМЕР\tПРОЕКТ\tЗАГРУЖЕН")
+	for _, f := range list.Files {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", f.ID, f.Filename, formatSize(f.Size), f.Project, f.CreatedAt.Local().Format(time.DateTime))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.stderr, "показано %d из %d\n", len(list.Files), list.Total)
+	return nil
+}
+
+func runProfiles(ctx context.Context, a *app, args []string) error {
+	fs := a.newFlagSet("profiles")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	path := profilesPath()
+	profiles, err := loadProfiles(path)
+	if err != nil {
+		return err
+	}
+	for _, name := range profileNames(profiles) {
+		data, err := json.Marshal(profiles[name])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(a.stdout, "%s\t%s\n", name, data)
+	}
+	if path != "" {
+		fmt.Fprintf(a.stderr, "свои профили: %s\n", path)
+	}
+	return nil
+}
+
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d Б", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cБ", float64(n)/float64(div), []rune("КМГТ")[exp])
+}
@@ -0,0 +1,138 @@
+// Command lidarctl консольный клиент сервиса обработки облаков точек: загрузка файлов, запуск обработки
+// с профилем или параметрами, отслеживание задач, скачивание результатов и пакетная обработка каталога.
+//
+// Адрес сервиса и учетные данные берутся из флагов или переменных окружения LIDARCTL_URL и LIDARCTL_API_KEY;
+// по умолчанию используется локальный docker-compose (http://localhost:8000).
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"lct/client"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"time"
+)
+
+const defaultURL = "http://localhost:8000"
+
+// command подкоманда lidarctl
+type command struct {
+	usage string // Аргументы после имени подкоманды
+	about string
+	run   func(ctx context.Context, app *app, args []string) error
+}
+
+// commands заполняется в init: подкоманды сами обращаются к нему за справкой
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"upload":   {"FILE...", "загрузить файлы и вывести их ID", runUpload},
+		"process":  {"[флаги] FILE|FILE_ID", "поставить файл в обработку; с -wait дождаться и скачать результат", runProcess},
+		"watch":    {"JOB_ID...", "следить за задачами до их завершения", runWatch},
+		"download": {"[-format ply|pcd|xyz] [-o PATH] JOB_ID", "скачать результат задачи в нужном формате", runDownload},
+		"files":    {"[флаги]", "найти загруженные файлы", runFiles},
+		"batch":    {"[флаги] DIR", "обработать все файлы каталога с возобновлением после прерывания", runBatch},
+		"profiles": {"", "показать профили параметров обработки", runProfiles},
+	}
+}
+
+// app общие для подкоманд клиент и потоки вывода
+type app struct {
+	client *client.Client
+	stdout io.Writer
+	stderr io.Writer
+	// quiet отключает индикаторы прогресса
+	quiet bool
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	os.Exit(run(ctx, os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run разбирает общие флаги и выполняет подкоманду; возвращает код завершения
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("lidarctl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	baseURL := fs.String("url", envOr("LIDARCTL_URL", defaultURL), "адрес сервиса (LIDARCTL_URL)")
+	apiKey := fs.String("api-key", os.Getenv("LIDARCTL_API_KEY"), "API ключ (LIDARCTL_API_KEY)")
+	lang := fs.String("lang", "", "язык сообщений об ошибках сервиса: ru или en")
+	poll := fs.Duration("poll", 2*time.Second, "интервал опроса состояния задач")
+	quiet := fs.Bool("q", false, "не показывать прогресс")
+	fs.Usage = func() { usage(fs) }
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	name := fs.Arg(0)
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(stderr, "неизвестная команда %q\n", name)
+		fs.Usage()
+		return 2
+	}
+
+	c, err := client.New(*baseURL, client.WithAPIKey(*apiKey), client.WithLanguage(*lang), client.WithPollInterval(*poll))
+	if err != nil {
+		fmt.Fprintln(stderr, "lidarctl:", err)
+		return 2
+	}
+	a := &app{client: c, stdout: stdout, stderr: stderr, quiet: *quiet}
+	if err := cmd.run(ctx, a, fs.Args()[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) || errors.Is(err, errUsage) {
+			return 2
+		}
+		fmt.Fprintf(stderr, "lidarctl %s: %v\n", name, err)
+		return 1
+	}
+	return 0
+}
+
+// errUsage неверные аргументы подкоманды; сообщение уже выведено
+var errUsage = errors.New("неверные аргументы")
+
+func usage(fs *flag.FlagSet) {
+	out := fs.Output()
+	fmt.Fprintln(out, "Использование: lidarctl [общие флаги] КОМАНДА [аргументы]")
+	fmt.Fprintln(out, "\nКоманды:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(out, "  %-9s %s\n", name, commands[name].about)
+	}
+	fmt.Fprintln(out, "\nОбщие флаги:")
+	fs.PrintDefaults()
+	fmt.Fprintln(out, "\nСправка по команде: lidarctl КОМАНДА -h")
+}
+
+// newFlagSet создает набор флагов подкоманды с ее описанием в справке
+func (a *app) newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(a.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(a.stderr, "Использование: lidarctl %s %s\n%s\n", name, commands[name].usage, commands[name].about)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
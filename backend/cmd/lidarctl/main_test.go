@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"lct/internal/handlers"
+	"lct/internal/repository/memory"
+	"lct/internal/service/usecase"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const testKey = "test-bootstrap-key-0123456789abcdef"
+
+// testPLY облако из двух точек; фейковый CV worker возвращает его без изменений
+const testPLY = "ply\nformat ascii 1.0\nelement vertex 2\nproperty float x\nproperty float y\nproperty float z\nend_header\n1 2 3\n4.5 5 6\n"
+
+type testEnv struct {
+	url    string
+	rabbit *memory.RabbitClient
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	repo := memory.NewRepository()
+	storage := memory.NewObjectStorage("testbucket")
+	rabbit := memory.NewRabbitClient(storage)
+	service := usecase.NewService(repo, storage, rabbit, usecase.Config{
+		ModelVersion:      "test-model",
+		ProcessingTimeout: time.Second,
+		BootstrapAPIKey:   testKey,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go service.RunOutboxRelay(ctx, 10*time.Millisecond)
+	go service.RunResultConsumer(ctx)
+
+	router := gin.New()
+	handlers.NewMinioHandler(service, handlers.AuthConfig{}, handlers.LimitsConfig{}).RegisterRoutes(router)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return &testEnv{url: server.URL, rabbit: rabbit}
+}
+
+// lidarctl запускает команду и возвращает код завершения, stdout и stderr
+func (env *testEnv) lidarctl(t *testing.T, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	args = append([]string{"-url", env.url, "-api-key", testKey, "-poll", "5ms", "-q"}, args...)
+	code := run(context.Background(), args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func writeScans(t *testing.T, dir string, names ...string) {
+	t.Helper()
+	for i, name := range names {
+		// Содержимое различается, чтобы дедупликация и кэш результатов не склеили файлы
+		content := testPLY + strings.Repeat("\n", i)
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestProcessWaitsAndConverts(t *testing.T) {
+	env := newTestEnv(t)
+	dir := t.TempDir()
+	writeScans(t, dir, "scan.pcd")
+
+	code, stdout, stderr := env.lidarctl(t, "process", "-wait", "-format", "xyz", "-profile", "fast", "-threshold", "0.5", filepath.Join(dir, "scan.pcd"))
+	if code != 0 {
+		t.Fatalf("exit code = %d, stderr = %s", code, stderr)
+	}
+	if !strings.HasPrefix(stdout, "1\t") {
+		t.Errorf("stdout = %q, want job id", stdout)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "scan_processed.xyz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "1 2 3\n4.5 5 6\n" {
+		t.Errorf("result = %q", data)
+	}
+
+	var msg struct {
+		Params struct {
+			Threshold     float64 `json:"threshold"`
+			VoxelSize     float64 `json:"voxel_size"`
+			GridDivisions int     `json:"grid_divisions"`
+		} `json:"params"`
+	}
+	if err := json.Unmarshal(env.rabbit.Messages()[0], &msg); err != nil {
+		t.Fatal(err)
+	}
+	// Флаг заменяет порог, остальное — из профиля
+	if msg.Params.Threshold != 0.5 || msg.Params.VoxelSize != 0.1 || msg.Params.GridDivisions != 10 {
+		t.Errorf("params = %+v", msg.Params)
+	}
+
+	code, _, stderr = env.lidarctl(t, "download", "-format", "pcd", "-o", filepath.Join(dir, "again.pcd"), "1")
+	if code != 0 {
+		t.Fatalf("download: exit code = %d, stderr = %s", code, stderr)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "again.pcd")); !strings.Contains(string(data), "POINTS 2\nDATA ascii\n1 2 3\n") {
+		t.Errorf("pcd = %q", data)
+	}
+}
+
+func TestFailedJobExitsWithError(t *testing.T) {
+	env := newTestEnv(t)
+	env.rabbit.Worker = func(body []byte) ([]byte, error) {
+		return []byte(`{"error": "CUDA out of memory"}`), nil
+	}
+	dir := t.TempDir()
+	writeScans(t, dir, "scan.pcd")
+
+	code, _, stderr := env.lidarctl(t, "process", "-wait", filepath.Join(dir, "scan.pcd"))
+	if code != 1 || !strings.Contains(stderr, "CUDA out of memory") {
+		t.Errorf("exit code = %d, stderr = %s", code, stderr)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "scan_processed.ply")); !os.IsNotExist(err) {
+		t.Errorf("result of failed job exists: %v", err)
+	}
+}
+
+func TestUploadAndListFiles(t *testing.T) {
+	env := newTestEnv(t)
+	dir := t.TempDir()
+	writeScans(t, dir, "street.pcd", "park.pcd")
+
+	code, stdout, stderr := env.lidarctl(t, "upload", filepath.Join(dir, "street.pcd"), filepath.Join(dir, "park.pcd"))
+	if code != 0 || strings.Count(stdout, "\n") != 2 {
+		t.Fatalf("upload: exit code = %d, stdout = %q, stderr = %s", code, stdout, stderr)
+	}
+
+	code, stdout, stderr = env.lidarctl(t, "files", "-name", "STREET", "-since", "1h")
+	if code != 0 {
+		t.Fatalf("files: exit code = %d, stderr = %s", code, stderr)
+	}
+	if !strings.Contains(stdout, "street.pcd") || strings.Contains(stdout, "park.pcd") || !strings.Contains(stderr, "показано 1 из 1") {
+		t.Errorf("stdout = %q, stderr = %q", stdout, stderr)
+	}
+}
+
+func TestBatchResumesAfterFailures(t *testing.T) {
+	env := newTestEnv(t)
+	dir := t.TempDir()
+	writeScans(t, dir, "a.pcd", "b.pcd", "c.pcd")
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("skip me"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Первая задача завершается ошибкой
+	process := env.rabbit.Worker
+	var calls atomic.Int32
+	env.rabbit.Worker = func(body []byte) ([]byte, error) {
+		if calls.Add(1) == 1 {
+			return []byte(`{"error": "CUDA out of memory"}`), nil
+		}
+		return process(body)
+	}
+
+	code, _, stderr := env.lidarctl(t, "batch", "-concurrency", "2", "-format", "xyz", dir)
+	if code != 1 || !strings.Contains(stderr, "обработано: 2, пропущено: 0, ошибок: 1") {
+		t.Fatalf("first run: exit code = %d, stderr = %s", code, stderr)
+	}
+
+	// Второй запуск повторяет только неудачный файл
+	code, _, stderr = env.lidarctl(t, "batch", "-concurrency", "2", "-format", "xyz", dir)
+	if code != 0 || !strings.Contains(stderr, "обработано: 1, пропущено: 2, ошибок: 0") {
+		t.Fatalf("second run: exit code = %d, stderr = %s", code, stderr)
+	}
+	if n := len(env.rabbit.Messages()); n != 4 {
+		t.Errorf("published %d messages, want 4", n)
+	}
+	for _, name := range []string{"a", "b", "c"} {
+		data, err := os.ReadFile(filepath.Join(dir, "processed", name+"_processed.xyz"))
+		if err != nil || string(data) != "1 2 3\n4.5 5 6\n" {
+			t.Errorf("%s: result = %q, err = %v", name, data, err)
+		}
+	}
+
+	// Удаленный результат скачивается заново без повторной обработки
+	os.Remove(filepath.Join(dir, "processed", "b_processed.xyz"))
+	code, _, stderr = env.lidarctl(t, "batch", "-format", "xyz", dir)
+	if code != 0 || !strings.Contains(stderr, "обработано: 1, пропущено: 2, ошибок: 0") {
+		t.Fatalf("third run: exit code = %d, stderr = %s", code, stderr)
+	}
+	if n := len(env.rabbit.Messages()); n != 4 {
+		t.Errorf("published %d messages after re-download, want 4", n)
+	}
+
+	// Другие параметры требуют новой обработки
+	code, _, stderr = env.lidarctl(t, "batch", "-format", "xyz", "-threshold", "0.6", dir)
+	if code != 0 || !strings.Contains(stderr, "обработано: 3") {
+		t.Fatalf("new params: exit code = %d, stderr = %s", code, stderr)
+	}
+}
+
+func TestUsageErrors(t *testing.T) {
+	env := newTestEnv(t)
+	for _, args := range [][]string{
+		{},
+		{"unknown"},
+		{"process"},
+		{"download", "-format", "las", "1"},
+		{"batch", "-concurrency", "0", "."},
+	} {
+		if code, _, _ := env.lidarctl(t, args...); code == 0 {
+			t.Errorf("%v: exit code 0", args)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"lct/client"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// builtinProfiles готовые наборы параметров обработки. Отсутствующие параметры принимают значения по умолчанию сервиса.
+var builtinProfiles = map[string]client.ProcessingParams{
+	// Параметры сервиса по умолчанию
+	"default": {},
+	// Быстрый предпросмотр: крупные воксели и грубая сетка
+	"fast": {
+		UseDownsample: client.Ptr(true),
+		VoxelSize:     client.Ptr(0.1),
+		GridDivisions: client.Ptr(10),
+	},
+	// Максимальная детализация: без прореживания, мелкая сетка
+	"precise": {
+		UseDownsample: client.Ptr(false),
+		GridDivisions: client.Ptr(50),
+	},
+	// Осторожное удаление: точка считается динамической только при высокой уверенности модели
+	"conservative": {
+		Threshold: client.Ptr(0.7),
+	},
+}
+
+// profilesPath файл пользовательских профилей: JSON объект с параметрами по имени профиля.
+// Профили из файла дополняют встроенные и заменяют одноименные.
+func profilesPath() string {
+	if p := os.Getenv("LIDARCTL_PROFILES"); p != "" {
+		return p
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "lidarctl", "profiles.json")
+}
+
+// loadProfiles возвращает встроенные профили вместе с профилями из path; отсутствие файла не ошибка
+func loadProfiles(path string) (map[string]client.ProcessingParams, error) {
+	profiles := make(map[string]client.ProcessingParams, len(builtinProfiles))
+	for name, p := range builtinProfiles {
+		profiles[name] = p
+	}
+	if path == "" {
+		return profiles, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return profiles, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var custom map[string]client.ProcessingParams
+	if err := json.Unmarshal(data, &custom); err != nil {
+		return nil, fmt.Errorf("профили %s: %w", path, err)
+	}
+	for name, p := range custom {
+		profiles[name] = p
+	}
+	return profiles, nil
+}
+
+// paramFlags флаги параметров обработки. Параметры собираются по порядку: профиль, файл -params, отдельные флаги.
+type paramFlags struct {
+	fs         *flag.FlagSet
+	profile    *string
+	paramsFile *string
+	noCache    *bool
+	threshold  *float64
+	voxelSize  *float64
+	downsample *bool
+	edgeDist   *float64
+	zUpper     *float64
+	ground     *float64
+	grid       *int
+}
+
+func addParamFlags(fs *flag.FlagSet) *paramFlags {
+	return &paramFlags{
+		fs:         fs,
+		profile:    fs.String("profile", "default", "профиль параметров обработки (см. lidarctl profiles)"),
+		paramsFile: fs.String("params", "", "JSON файл с параметрами обработки"),
+		noCache:    fs.Bool("no-cache", false, "обработать заново, не используя кэш результатов"),
+		threshold:  fs.Float64("threshold", 0, "порог вероятности динамической точки, (0, 1]"),
+		voxelSize:  fs.Float64("voxel-size", 0, "размер вокселя прореживания"),
+		downsample: fs.Bool("downsample", true, "прореживать облако перед обработкой"),
+		edgeDist:   fs.Float64("edge-distance", 0, "расстояние от края, ближе которого точки не удаляются"),
+		zUpper:     fs.Float64("z-upper", 0, "высота, выше которой точки считаются статичными"),
+		ground:     fs.Float64("ground-height", 0, "высота земли, ниже которой точки считаются статичными"),
+		grid:       fs.Int("grid", 0, "число делений сетки"),
+	}
+}
+
+// request собирает запрос на обработку
+func (f *paramFlags) request() (client.JobRequest, error) {
+	profiles, err := loadProfiles(profilesPath())
+	if err != nil {
+		return client.JobRequest{}, err
+	}
+	params, ok := profiles[*f.profile]
+	if !ok {
+		return client.JobRequest{}, fmt.Errorf("неизвестный профиль %q, доступны %v", *f.profile, profileNames(profiles))
+	}
+	if *f.paramsFile != "" {
+		data, err := os.ReadFile(*f.paramsFile)
+		if err != nil {
+			return client.JobRequest{}, err
+		}
+		var fromFile client.ProcessingParams
+		if err := json.Unmarshal(data, &fromFile); err != nil {
+			return client.JobRequest{}, fmt.Errorf("параметры %s: %w", *f.paramsFile, err)
+		}
+		params = overlay(params, fromFile)
+	}
+	// Отдельные флаги учитываются, только если заданы явно
+	f.fs.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case "threshold":
+			params.Threshold = client.Ptr(*f.threshold)
+		case "voxel-size":
+			params.VoxelSize = client.Ptr(*f.voxelSize)
+		case "downsample":
+			params.UseDownsample = client.Ptr(*f.downsample)
+		case "edge-distance":
+			params.EdgeDistanceThreshold = client.Ptr(*f.edgeDist)
+		case "z-upper":
+			params.ZUpperStaticThreshold = client.Ptr(*f.zUpper)
+		case "ground-height":
+			params.GroundHeightThreshold = client.Ptr(*f.ground)
+		case "grid":
+			params.GridDivisions = client.Ptr(*f.grid)
+		}
+	})
+
+	req := client.JobRequest{Params: &params}
+	if *f.noCache {
+		req.ReuseResult = client.Ptr(false)
+	}
+	return req, nil
+}
+
+// overlay возвращает base, в котором заданные поля заменены полями top
+func overlay(base, top client.ProcessingParams) client.ProcessingParams {
+	if top.Threshold != nil {
+		base.Threshold = top.Threshold
+	}
+	if top.VoxelSize != nil {
+		base.VoxelSize = top.VoxelSize
+	}
+	if top.UseDownsample != nil {
+		base.UseDownsample = top.UseDownsample
+	}
+	if top.EdgeDistanceThreshold != nil {
+		base.EdgeDistanceThreshold = top.EdgeDistanceThreshold
+	}
+	if top.ZUpperStaticThreshold != nil {
+		base.ZUpperStaticThreshold = top.ZUpperStaticThreshold
+	}
+	if top.GroundHeightThreshold != nil {
+		base.GroundHeightThreshold = top.GroundHeightThreshold
+	}
+	if top.GridDivisions != nil {
+		base.GridDivisions = top.GridDivisions
+	}
+	return base
+}
+
+func profileNames(profiles map[string]client.ProcessingParams) []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParamsLayerProfileFileAndFlags(t *testing.T) {
+	dir := t.TempDir()
+	profiles := filepath.Join(dir, "profiles.json")
+	if err := os.WriteFile(profiles, []byte(`{"night": {"threshold": 0.8, "grid_divisions": 30}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("LIDARCTL_PROFILES", profiles)
+	paramsFile := filepath.Join(dir, "params.json")
+	if err := os.WriteFile(paramsFile, []byte(`{"grid_divisions": 40, "voxel_size": 0.2}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	pf := addParamFlags(fs)
+	if err := fs.Parse([]string{"-profile", "night", "-params", paramsFile, "-voxel-size", "0.3", "-no-cache"}); err != nil {
+		t.Fatal(err)
+	}
+	req, err := pf.request()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := req.Params
+	if *p.Threshold != 0.8 || *p.GridDivisions != 40 || *p.VoxelSize != 0.3 || p.UseDownsample != nil {
+		t.Errorf("params = threshold %v, grid %v, voxel %v, downsample %v", *p.Threshold, *p.GridDivisions, *p.VoxelSize, p.UseDownsample)
+	}
+	if req.ReuseResult == nil || *req.ReuseResult {
+		t.Error("-no-cache did not disable result reuse")
+	}
+
+	// Встроенный профиль не меняется параметрами запуска
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	pf = addParamFlags(fs)
+	if err := fs.Parse([]string{"-profile", "fast", "-params", paramsFile}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pf.request(); err != nil {
+		t.Fatal(err)
+	}
+	if *builtinProfiles["fast"].GridDivisions != 10 || *builtinProfiles["fast"].VoxelSize != 0.1 {
+		t.Errorf("builtin profile changed: %+v", builtinProfiles["fast"])
+	}
+
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	pf = addParamFlags(fs)
+	fs.Parse([]string{"-profile", "missing"})
+	if _, err := pf.request(); err == nil {
+		t.Error("unknown profile accepted")
+	}
+}
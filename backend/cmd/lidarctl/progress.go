@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// progressInterval как часто обновлять индикатор прогресса
+const progressInterval = 200 * time.Millisecond
+
+// progress индикатор передачи в stderr, перерисовываемый в одной строке
+type progress struct {
+	a       *app
+	label   string
+	last    time.Time
+	printed bool
+}
+
+func (a *app) progress(label string) *progress {
+	return &progress{a: a, label: label}
+}
+
+// update подходит как client.ProgressFunc
+func (p *progress) update(done, total int64) {
+	if p.a.quiet {
+		return
+	}
+	now := time.Now()
+	if now.Sub(p.last) < progressInterval && done != total {
+		return
+	}
+	p.last = now
+	p.printed = true
+	if total > 0 {
+		fmt.Fprintf(p.a.stderr, "\r%s: %3d%% (%s из %s)", p.label, done*100/total, formatSize(done), formatSize(total))
+	} else {
+		fmt.Fprintf(p.a.stderr, "\r%s: %s", p.label, formatSize(done))
+	}
+}
+
+// done завершает строку индикатора
+func (p *progress) done() {
+	if p.printed {
+		fmt.Fprintln(p.a.stderr)
+	}
+}
@@ -0,0 +1,107 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadConfigLayers(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.yaml")
+	os.WriteFile(file, []byte("port: 9000\nminio_bucket_name: from-file\nmodel_version: from-file\n"), 0o600)
+	secret := filepath.Join(dir, "password")
+	os.WriteFile(secret, []byte("s3cr3t\n"), 0o600)
+
+	t.Setenv("CONFIG_FILE", file)
+	t.Setenv("MINIO_BUCKET_NAME", "from-env")
+	t.Setenv("MINIO_ROOT_PASSWORD_FILE", secret)
+	t.Setenv("MINIO_ROOT_USER", "") // пустые значения из docker-compose не перекрывают значения по умолчанию
+
+	cfg, err := LoadConfig([]string{"-model-version", "from-flag", "-processing-timeout", "90"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Port != "9000" {
+		t.Errorf("Port = %q, want value from file", cfg.Port)
+	}
+	if cfg.BucketName != "from-env" {
+		t.Errorf("BucketName = %q, want env to override file", cfg.BucketName)
+	}
+	if cfg.ModelVersion != "from-flag" {
+		t.Errorf("ModelVersion = %q, want flag to override file", cfg.ModelVersion)
+	}
+	if cfg.MinioRootPassword != "s3cr3t" {
+		t.Errorf("MinioRootPassword = %q, want value from _FILE", cfg.MinioRootPassword)
+	}
+	if cfg.MinioRootUser != "root" {
+		t.Errorf("MinioRootUser = %q, want default", cfg.MinioRootUser)
+	}
+	if cfg.ProcessingTimeout != 90*time.Second {
+		t.Errorf("ProcessingTimeout = %s, want 90s", cfg.ProcessingTimeout)
+	}
+}
+
+func TestLoadConfigTOML(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.toml")
+	os.WriteFile(file, []byte("storage_backend = \"local\"\nminio_use_ssl = true\nprocessing_timeout = \"2m\"\nrate_limit_rps = 0.5\nmax_pending_jobs = 20\n"), 0o600)
+
+	cfg, err := LoadConfig([]string{"-config", file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.StorageBackend != "local" || !cfg.MinioUseSSL || cfg.ProcessingTimeout != 2*time.Minute ||
+		cfg.RateLimitRPS != 0.5 || cfg.MaxPendingJobs != 20 {
+		t.Errorf("cfg = %s", cfg)
+	}
+}
+
+func TestLoadConfigRejectsUnknownFileKey(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(file, []byte("bucket: typo\n"), 0o600)
+
+	if _, err := LoadConfig([]string{"-config", file}); err == nil || !strings.Contains(err.Error(), `"bucket"`) {
+		t.Errorf("err = %v, want unknown key error", err)
+	}
+}
+
+func TestValidateReportsAllErrors(t *testing.T) {
+	cfg := Default()
+	cfg.Port = "http"
+	cfg.DatabaseURL = "mysql://db"
+	cfg.StorageBackend = "ftp"
+	cfg.RabbitMQExchangeType = "headers"
+	cfg.TracingExporter = "jaeger"
+	cfg.TracingSampleRatio = 2
+	cfg.LogLevel = "verbose"
+	cfg.LogFormat = "xml"
+	cfg.JobMaxAttempts = 0
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	for _, want := range []string{"PORT", "DATABASE_URL", "STORAGE_BACKEND", "RABBITMQ_EXCHANGE_TYPE", "TRACING_EXPORTER", "TRACING_SAMPLE_RATIO", "LOG_LEVEL", "LOG_FORMAT", "JOB_MAX_ATTEMPTS"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not mention %s: %v", want, err)
+		}
+	}
+}
+
+func TestStringRedactsSecrets(t *testing.T) {
+	cfg := Default()
+	cfg.MinioRootPassword = "minio-secret"
+	cfg.DatabaseURL = "postgresql://postgres:db-secret@db:5432/postgres"
+
+	out := cfg.String()
+	for _, secret := range []string{"minio-secret", "db-secret", "guest:guest"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("String() leaks %q: %s", secret, out)
+		}
+	}
+	if !strings.Contains(out, "db:5432") {
+		t.Errorf("String() hides non-secret parts: %s", out)
+	}
+}
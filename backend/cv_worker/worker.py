@@ -4,6 +4,7 @@ import uuid
 import signal
 import sys
 import gc
+import threading
 import logging
 import traceback
 import os
@@ -32,6 +33,115 @@ minio_client = Minio(
     secure=False
 )
 
+# Exchange задач: direct и topic доставляют только задачи арендаторов из ROUTING_KEYS, fanout — все задачи
+EXCHANGE_TYPE = os.environ.get("RABBITMQ_EXCHANGE_TYPE") or "direct"
+ROUTING_KEYS = [key.strip() for key in os.environ.get("ROUTING_KEYS", "").split(",") if key.strip()]
+DEFAULT_BUCKET = os.environ.get("MINIO_BUCKET_NAME") or "defaultbucket"
+
+# Трассировка: контекст W3C Trace Context приходит в заголовках задачи и возвращается в заголовках ответа,
+# поэтому ответ попадает в трассу запроса. Собственные span'ы worker пишет, если задан коллектор OTLP.
+TRACE_HEADERS = ("traceparent", "tracestate")
+tracer = None
+if os.environ.get("OTEL_EXPORTER_OTLP_ENDPOINT"):
+    try:
+        from opentelemetry import trace, propagate
+        from opentelemetry.sdk.resources import Resource
+        from opentelemetry.sdk.trace import TracerProvider
+        from opentelemetry.sdk.trace.export import BatchSpanProcessor
+        from opentelemetry.exporter.otlp.proto.http.trace_exporter import OTLPSpanExporter
+
+        provider = TracerProvider(resource=Resource.create({"service.name": os.environ.get("OTEL_SERVICE_NAME", "lct-cv-worker")}))
+        provider.add_span_processor(BatchSpanProcessor(OTLPSpanExporter()))
+        trace.set_tracer_provider(provider)
+        tracer = trace.get_tracer("lct.cv_worker")
+    except ImportError:
+        logger.warning("opentelemetry is not installed, worker spans are not exported")
+
+def trace_headers(properties):
+    """Заголовки трассировки из свойств сообщения"""
+    headers = properties.headers or {}
+    result = {}
+    for key in TRACE_HEADERS:
+        value = headers.get(key)
+        if isinstance(value, bytes):
+            value = value.decode()
+        if isinstance(value, str) and value:
+            result[key] = value
+    return result
+
+# Heartbeat'ы продлевают аренду задачи в backend: если их нет дольше JOB_LEASE_TIMEOUT (worker убит по OOM или завис),
+# backend возвращает задачу в очередь. Heartbeat не отправляется, если обработка не продвигалась дольше HEARTBEAT_STALL_TIMEOUT.
+HEARTBEAT_INTERVAL = float(os.environ.get("HEARTBEAT_INTERVAL") or 30)
+HEARTBEAT_STALL_TIMEOUT = float(os.environ.get("HEARTBEAT_STALL_TIMEOUT") or 300)
+
+class Heartbeat:
+    """Фоновая отправка heartbeat'ов текущей задачи в очередь результатов по отдельному соединению:
+    основной поток занят обработкой и не обслуживает свое соединение"""
+
+    def __init__(self, reply_to, correlation_id, headers):
+        self.reply_to = reply_to
+        self.correlation_id = correlation_id
+        self.headers = headers
+        self.last_progress = time.monotonic()
+        self.stopped = threading.Event()
+        self.thread = threading.Thread(target=self.run, daemon=True)
+
+    def start(self):
+        if self.reply_to and self.correlation_id:
+            self.thread.start()
+        return self
+
+    def progress(self):
+        """Отмечает, что обработка продвигается"""
+        self.last_progress = time.monotonic()
+
+    def stop(self):
+        self.stopped.set()
+        if self.thread.is_alive():
+            self.thread.join(timeout=5)
+
+    def run(self):
+        connection = None
+        try:
+            # Первый heartbeat сразу: по нему backend узнает, что задачу взяли, и сменяет JOB_PICKUP_TIMEOUT на JOB_LEASE_TIMEOUT
+            while True:
+                stalled = time.monotonic() - self.last_progress
+                if stalled > HEARTBEAT_STALL_TIMEOUT:
+                    logger.warning(f"No progress for {stalled:.0f} seconds, heartbeats suspended")
+                else:
+                    try:
+                        if connection is None or connection.is_closed:
+                            connection = pika.BlockingConnection(pika.ConnectionParameters(host='rabbitmq'))
+                            channel = connection.channel()
+                        channel.basic_publish(
+                            exchange='',
+                            routing_key=self.reply_to,
+                            properties=pika.BasicProperties(
+                                correlation_id=self.correlation_id,
+                                type="heartbeat",
+                                headers=self.headers or None,
+                            ),
+                            body=b"{}",
+                        )
+                    except Exception as e:
+                        logger.warning(f"Failed to send heartbeat: {e}")
+                        connection = None
+                if self.stopped.wait(HEARTBEAT_INTERVAL):
+                    return
+        finally:
+            if connection is not None and connection.is_open:
+                try:
+                    connection.close()
+                except Exception:
+                    pass
+
+# Heartbeat текущей задачи; обработка отмечает в нем свой прогресс
+current_heartbeat = None
+
+def report_progress():
+    if current_heartbeat is not None:
+        current_heartbeat.progress()
+
 # Создаём папку для временных файлов
 os.makedirs("/tmp/files", exist_ok=True)
 
@@ -55,11 +165,16 @@ class RobustRabbitMQClient:
 
             self.channel.exchange_declare(
                 exchange="pcd_files",
-                exchange_type="fanout",
+                exchange_type=EXCHANGE_TYPE,
                 durable=True,
             )
             self.channel.queue_declare(queue="file_metadata_queue", durable=True)
-            self.channel.queue_bind(exchange="pcd_files", queue="file_metadata_queue")
+            if EXCHANGE_TYPE == "fanout":
+                self.channel.queue_bind(exchange="pcd_files", queue="file_metadata_queue")
+            else:
+                # Пустой ключ — арендаторы без собственного ключа маршрутизации
+                for routing_key in ROUTING_KEYS or [""]:
+                    self.channel.queue_bind(exchange="pcd_files", queue="file_metadata_queue", routing_key=routing_key)
 
             logger.info("Connected to RabbitMQ successfully")
             return True
@@ -68,15 +183,15 @@ class RobustRabbitMQClient:
             logger.error(f"Failed to connect to RabbitMQ: {e}")
             return False
 
-    def safe_publish(self, routing_key, body, correlation_id=None):
+    def safe_publish(self, routing_key, body, correlation_id=None, headers=None):
         """Безопасная отправка сообщения с переподключением при необходимости"""
         try:
             if not self.connection or self.connection.is_closed:
                 self.connect()
 
             properties = None
-            if correlation_id:
-                properties = pika.BasicProperties(correlation_id=correlation_id)
+            if correlation_id or headers:
+                properties = pika.BasicProperties(correlation_id=correlation_id, headers=headers or None)
 
             self.channel.basic_publish(
                 exchange='',
@@ -370,6 +485,7 @@ def remove_dynamic_points_with_threshold(ply_file_path, model_path, output_file_
         optimize_memory()
 
         logger.info(f"Обработан батч {batch_idx + 1}/{total_batches}")
+        report_progress()
 
     all_dynamic_probs = np.concatenate(all_dynamic_probs)
 
@@ -519,7 +635,9 @@ def process_file_safe(data):
         if not os.path.exists(model_path):
             raise FileNotFoundError(f"Model file {model_path} not found")
 
-        new_key = f"processed/{uuid.uuid4()}.ply"
+        # Бакет и префикс арендатора; старые сообщения без них обрабатываются в общем бакете
+        bucket = data.get('bucket') or DEFAULT_BUCKET
+        new_key = f"{data.get('output_prefix', '')}processed/{uuid.uuid4()}.ply"
 
         input_path = f"/tmp/files/{filename}"
         output_path = f"/tmp/files/{uuid.uuid4()}_processed.ply"
@@ -529,7 +647,8 @@ def process_file_safe(data):
 
         # Скачивание файла
         logger.info(f"Downloading file {minio_key} from MinIO")
-        minio_client.fget_object("defaultbucket", minio_key, input_path)
+        minio_client.fget_object(bucket, minio_key, input_path)
+        report_progress()
 
         file_size = os.path.getsize(input_path) / (1024 * 1024)
         logger.info(f"File size: {file_size:.2f} MB")
@@ -540,21 +659,30 @@ def process_file_safe(data):
             logger.warning(f"Low memory detected: {available_memory} kB")
             optimize_memory()
 
+        # Параметры обработки приходят от backend, значения по умолчанию совпадают с прежними
+        params = data.get('params') or {}
+
         # Обработка
         result_path = remove_dynamic_points_with_threshold(
             input_path,
             model_path,
-            voxel_size=0.05,
+            voxel_size=params.get('voxel_size', 0.05),
             output_file_path=output_path,
-            threshold=0.4
+            threshold=params.get('threshold', 0.4),
+            use_downsample=params.get('use_downsample', True),
+            edge_distance_threshold=params.get('edge_distance_threshold', 6.0),
+            z_upper_static_threshold=params.get('z_upper_static_threshold', 6.5),
+            ground_height_threshold=params.get('ground_height_threshold', 0.55),
+            grid_divisions=params.get('grid_divisions', 25)
         )
 
         if not os.path.exists(result_path):
             raise FileNotFoundError(f"Output file not created at {result_path}")
 
         # Загрузка результата
+        report_progress()
         logger.info("Uploading result to MinIO")
-        minio_client.fput_object("defaultbucket", new_key, result_path)
+        minio_client.fput_object(bucket, new_key, result_path)
 
         result_data = data.copy()
         result_data['minio_key'] = new_key
@@ -585,18 +713,32 @@ def process_file_safe(data):
 
 def callback(ch, method, properties, body):
     """Callback с улучшенной обработкой ошибок"""
-    global rabbitmq_client
+    global rabbitmq_client, current_heartbeat
 
     delivery_tag = method.delivery_tag
     reply_to = properties.reply_to
     correlation_id = properties.correlation_id
 
+    # Без коллектора контекст задачи возвращается как есть, с коллектором — контекст span'а обработки
+    headers = trace_headers(properties)
+    span = None
+    if tracer is not None:
+        span = tracer.start_span(
+            "file_metadata_queue process",
+            context=propagate.extract(headers),
+            kind=trace.SpanKind.CONSUMER,
+            attributes={"messaging.system": "rabbitmq", "messaging.message.conversation_id": correlation_id or ""},
+        )
+        headers = {}
+        propagate.inject(headers, context=trace.set_span_in_context(span))
+
     try:
         logger.info(" [x] Received message")
 
-        # Немедленно подтверждаем получение сообщения
+        # Немедленно подтверждаем получение сообщения; если worker погибнет, задачу вернет в очередь backend по истекшей аренде
         if not rabbitmq_client.safe_ack(delivery_tag):
             logger.warning("Failed to ack message, but continuing processing")
+        current_heartbeat = Heartbeat(reply_to, correlation_id, headers).start()
 
         # Парсим данные
         data = json.loads(body)
@@ -610,7 +752,8 @@ def callback(ch, method, properties, body):
         success = rabbitmq_client.safe_publish(
             routing_key=reply_to,
             body=json.dumps(processed_data),
-            correlation_id=correlation_id
+            correlation_id=correlation_id,
+            headers=headers
         )
 
         if success:
@@ -627,11 +770,21 @@ def callback(ch, method, properties, body):
             'error': str(e),
             'minio_key': data.get('minio_key', '') if 'data' in locals() else ''
         }
+        if span is not None:
+            span.record_exception(e)
+            span.set_status(trace.Status(trace.StatusCode.ERROR, str(e)))
         rabbitmq_client.safe_publish(
             routing_key=reply_to,
             body=json.dumps(error_response),
-            correlation_id=correlation_id
+            correlation_id=correlation_id,
+            headers=headers
         )
+    finally:
+        if current_heartbeat is not None:
+            current_heartbeat.stop()
+            current_heartbeat = None
+        if span is not None:
+            span.end()
 
 def main():
     """Основная функция с обработкой ошибок"""
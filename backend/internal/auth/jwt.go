@@ -0,0 +1,105 @@
+package auth
+
+import (
+	stderrors "errors"
+	"fmt"
+	"lct/internal/domain/errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// jwtLeeway допустимое расхождение часов при проверке exp/nbf
+const jwtLeeway = 30 * time.Second
+
+// JWTConfig параметры проверки bearer токенов.
+// Задается либо общий секрет (HS256/384/512), либо файл JWKS с открытыми ключами.
+type JWTConfig struct {
+	Secret   string
+	JWKSFile string
+	Issuer   string // Если задан, iss токена должен совпадать
+	Audience string // Если задан, aud токена должен содержать значение
+}
+
+// JWTVerifier проверяет подпись и срок действия JWT
+type JWTVerifier struct {
+	secret  []byte
+	keys    *keySet
+	methods []string
+	parser  *jwt.Parser
+}
+
+// NewJWTVerifier создает проверку токенов. Возвращает nil, если JWT не настроен.
+func NewJWTVerifier(cfg JWTConfig) (*JWTVerifier, error) {
+	if cfg.Secret == "" && cfg.JWKSFile == "" {
+		return nil, nil
+	}
+	if cfg.Secret != "" && cfg.JWKSFile != "" {
+		return nil, stderrors.New("секрет JWT и файл JWKS задаются только по отдельности")
+	}
+
+	v := &JWTVerifier{}
+	if cfg.Secret != "" {
+		v.secret = []byte(cfg.Secret)
+		v.methods = []string{"HS256", "HS384", "HS512"}
+	} else {
+		keys, err := loadKeySet(cfg.JWKSFile)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось загрузить JWKS: %w", err)
+		}
+		v.keys = keys
+		v.methods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(v.methods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(jwtLeeway),
+	}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+	v.parser = jwt.NewParser(opts...)
+	return v, nil
+}
+
+// jwtClaims claims токена; role и project задают права клиента
+type jwtClaims struct {
+	jwt.RegisteredClaims
+	Role    string `json:"role"`
+	Project string `json:"project"`
+}
+
+// Verify проверяет токен и возвращает клиента из claims sub, role и project.
+// Токен без role получает роль viewer; всем ролям, кроме admin, нужен project.
+func (v *JWTVerifier) Verify(token string) (*Principal, error) {
+	claims := &jwtClaims{}
+	if _, err := v.parser.ParseWithClaims(token, claims, v.key); err != nil {
+		return nil, fmt.Errorf("%w: %v", errors.ErrUnauthenticated, err)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: в токене нет субъекта (sub)", errors.ErrUnauthenticated)
+	}
+	if claims.Role == "" {
+		claims.Role = RoleViewer
+	}
+	if err := ValidateRole(claims.Role); err != nil {
+		return nil, fmt.Errorf("%w: %v", errors.ErrUnauthenticated, err)
+	}
+	if claims.Role != RoleAdmin && claims.Project == "" {
+		return nil, fmt.Errorf("%w: в токене нет проекта", errors.ErrUnauthenticated)
+	}
+	return &Principal{Subject: claims.Subject, Method: MethodJWT, Role: claims.Role, Project: claims.Project}, nil
+}
+
+// key выбирает ключ проверки подписи: общий секрет или ключ из JWKS по kid
+func (v *JWTVerifier) key(token *jwt.Token) (any, error) {
+	if v.secret != nil {
+		return v.secret, nil
+	}
+	kid, _ := token.Header["kid"].(string)
+	return v.keys.lookup(kid)
+}
@@ -0,0 +1,198 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// writeJWKS сохраняет открытые ключи в JWKS файл и возвращает путь к нему
+func writeJWKS(t *testing.T, keys ...map[string]string) string {
+	t.Helper()
+	data, err := json.Marshal(map[string]any{"keys": keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key any, claims jwt.Claims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func validClaims() *jwtClaims {
+	return &jwtClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "alice",
+			Audience:  jwt.ClaimStrings{"lct"},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+		Role:    RoleOperator,
+		Project: "alpha",
+	}
+}
+
+func TestJWKSVerifiesRSAAndECKeys(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := writeJWKS(t,
+		map[string]string{
+			"kid": "rsa-1", "kty": "RSA", "use": "sig",
+			"n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes()),
+		},
+		map[string]string{
+			"kid": "ec-1", "kty": "EC", "crv": "P-256",
+			"x": b64(ecKey.X.FillBytes(make([]byte, 32))), "y": b64(ecKey.Y.FillBytes(make([]byte, 32))),
+		},
+	)
+	v, err := NewJWTVerifier(JWTConfig{JWKSFile: path, Audience: "lct"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, token := range map[string]string{
+		"rsa": sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, validClaims()),
+		"ec":  sign(t, jwt.SigningMethodES256, "ec-1", ecKey, validClaims()),
+	} {
+		p, err := v.Verify(token)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if p.Subject != "alice" || p.Method != MethodJWT || p.Role != RoleOperator || p.Project != "alpha" {
+			t.Errorf("%s: principal = %+v", name, p)
+		}
+	}
+
+	// Подпись ключом RSA с kid ключа EC не проходит
+	if _, err := v.Verify(sign(t, jwt.SigningMethodRS256, "ec-1", rsaKey, validClaims())); err == nil {
+		t.Error("token signed with another key was accepted")
+	}
+	if _, err := v.Verify(sign(t, jwt.SigningMethodRS256, "unknown", rsaKey, validClaims())); err == nil {
+		t.Error("token with unknown kid was accepted")
+	}
+	// Открытый ключ нельзя использовать как HMAC секрет
+	if _, err := v.Verify(sign(t, jwt.SigningMethodHS256, "rsa-1", rsaKey.N.Bytes(), validClaims())); err == nil {
+		t.Error("HS256 token was accepted by JWKS verifier")
+	}
+
+	wrongAudience := validClaims()
+	wrongAudience.Audience = jwt.ClaimStrings{"other"}
+	if _, err := v.Verify(sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, wrongAudience)); err == nil {
+		t.Error("token for another audience was accepted")
+	}
+	noExpiry := validClaims()
+	noExpiry.ExpiresAt = nil
+	if _, err := v.Verify(sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, noExpiry)); err == nil {
+		t.Error("token without exp was accepted")
+	}
+}
+
+func TestJWTRoleClaims(t *testing.T) {
+	secret := "test-jwt-secret-0123456789abcdef0123"
+	v, err := NewJWTVerifier(JWTConfig{Secret: secret})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	noRole := validClaims()
+	noRole.Role = ""
+	p, err := v.Verify(sign(t, jwt.SigningMethodHS256, "", []byte(secret), noRole))
+	if err != nil || p.Role != RoleViewer {
+		t.Errorf("token without role: principal = %+v, err = %v, want viewer", p, err)
+	}
+
+	admin := validClaims()
+	admin.Role, admin.Project = RoleAdmin, ""
+	if _, err := v.Verify(sign(t, jwt.SigningMethodHS256, "", []byte(secret), admin)); err != nil {
+		t.Errorf("admin token without project: %v", err)
+	}
+
+	unknown := validClaims()
+	unknown.Role = "root"
+	if _, err := v.Verify(sign(t, jwt.SigningMethodHS256, "", []byte(secret), unknown)); err == nil {
+		t.Error("token with unknown role was accepted")
+	}
+	noProject := validClaims()
+	noProject.Project = ""
+	if _, err := v.Verify(sign(t, jwt.SigningMethodHS256, "", []byte(secret), noProject)); err == nil {
+		t.Error("operator token without project was accepted")
+	}
+}
+
+func TestJWKSRejectsInvalidKeys(t *testing.T) {
+	for name, key := range map[string]map[string]string{
+		"unknown type":  {"kid": "a", "kty": "oct", "k": "c2VjcmV0"},
+		"unknown curve": {"kid": "a", "kty": "EC", "crv": "P-192", "x": "AQ", "y": "AQ"},
+		"off curve":     {"kid": "a", "kty": "EC", "crv": "P-256", "x": "AQ", "y": "AQ"},
+		"empty modulus": {"kid": "a", "kty": "RSA", "n": "", "e": "AQAB"},
+	} {
+		if _, err := NewJWTVerifier(JWTConfig{JWKSFile: writeJWKS(t, key)}); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestNewJWTVerifierWithoutConfigIsDisabled(t *testing.T) {
+	v, err := NewJWTVerifier(JWTConfig{})
+	if err != nil || v != nil {
+		t.Fatalf("NewJWTVerifier() = %v, %v, want nil, nil", v, err)
+	}
+	if _, err := NewJWTVerifier(JWTConfig{Secret: "s", JWKSFile: "f"}); err == nil {
+		t.Error("secret and jwks file together were accepted")
+	}
+}
+
+func TestAPIKeyRoundTrip(t *testing.T) {
+	key, prefix, err := GenerateAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok := APIKeyPrefix(key)
+	if !ok || got != prefix {
+		t.Fatalf("APIKeyPrefix(%q) = %q, %v, want %q", key, got, ok, prefix)
+	}
+	if !MatchAPIKey(key, HashAPIKey(key)) {
+		t.Error("key does not match its own hash")
+	}
+	if MatchAPIKey(key+"x", HashAPIKey(key)) {
+		t.Error("modified key matches")
+	}
+	for _, bad := range []string{"", "lct_", "lct_abc", "lct__secret", "xyz_abc_secret"} {
+		if _, ok := APIKeyPrefix(bad); ok {
+			t.Errorf("APIKeyPrefix(%q) accepted", bad)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+// Package auth содержит проверку учетных данных клиентов: API ключей и JWT bearer токенов.
+package auth
+
+import "context"
+
+// Способы аутентификации
+const (
+	MethodAPIKey = "api_key"
+	MethodJWT    = "jwt"
+	MethodNone   = "none" // Аутентификация отключена конфигурацией
+)
+
+// Principal клиент, от имени которого выполняется запрос
+type Principal struct {
+	Subject  string `json:"subject"`              // Идентификатор клиента: sub токена или api-key:<id>
+	Name     string `json:"name,omitempty"`       // Имя API ключа
+	Method   string `json:"method"`               // Способ аутентификации
+	APIKeyID int64  `json:"api_key_id,omitempty"` // ID API ключа, если клиент пришел с ключом
+	Role     string `json:"role"`                 // Роль, определяющая разрешенные операции
+	Project  string `json:"project,omitempty"`    // Проект клиента; администратор видит все проекты
+}
+
+type principalKey struct{}
+
+// WithPrincipal сохраняет клиента в контексте запроса
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext возвращает клиента из контекста запроса, nil если запрос не аутентифицирован
+func FromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
@@ -0,0 +1,61 @@
+package auth
+
+import "fmt"
+
+// Роли клиентов
+const (
+	RoleViewer   = "viewer"   // Чтение файлов, задач и результатов своего проекта
+	RoleOperator = "operator" // Загрузка и обработка файлов своего проекта
+	RoleAdmin    = "admin"    // Все операции во всех проектах, включая служебные
+)
+
+// DefaultProject проект файлов и задач, созданных до появления проектов
+const DefaultProject = "default"
+
+// Permission операция, доступ к которой определяется ролью
+type Permission string
+
+const (
+	PermDownload Permission = "download" // Чтение файлов, задач, артефактов и результатов
+	PermUpload   Permission = "upload"
+	PermProcess  Permission = "process"
+	PermDelete   Permission = "delete"
+	PermAdmin    Permission = "admin" // Кэш, сверка хранилища, API ключи
+)
+
+var rolePermissions = map[string][]Permission{
+	RoleViewer:   {PermDownload},
+	RoleOperator: {PermDownload, PermUpload, PermProcess, PermDelete},
+	RoleAdmin:    {PermDownload, PermUpload, PermProcess, PermDelete, PermAdmin},
+}
+
+// ValidateRole проверяет, что роль известна
+func ValidateRole(role string) error {
+	if _, ok := rolePermissions[role]; !ok {
+		return fmt.Errorf("неизвестная роль %q, ожидается %s, %s или %s", role, RoleViewer, RoleOperator, RoleAdmin)
+	}
+	return nil
+}
+
+// Can сообщает, разрешена ли операция роли клиента
+func (p *Principal) Can(perm Permission) bool {
+	for _, allowed := range rolePermissions[p.Role] {
+		if allowed == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// CanAccessProject сообщает, видит ли клиент файлы и задачи проекта
+func (p *Principal) CanAccessProject(project string) bool {
+	return p.Role == RoleAdmin || p.Project == project
+}
+
+// HomeProject проект, в который попадают файлы, загруженные клиентом
+func (p *Principal) HomeProject() string {
+	if p.Project == "" {
+		return DefaultProject
+	}
+	return p.Project
+}
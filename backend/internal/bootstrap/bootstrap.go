@@ -0,0 +1,144 @@
+// Package bootstrap подключает сервис к зависимостям при запуске: каждая зависимость проверяется
+// повторными попытками с экспоненциальной задержкой и разбросом, пока не подключится или не истечет
+// отведенное время. Необязательные зависимости после этого продолжают подключаться в фоне,
+// и сервис работает без них в деградированном режиме.
+package bootstrap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"lct/internal/logging"
+	"log/slog"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Значения Backoff по умолчанию
+const (
+	DefaultInitial = 500 * time.Millisecond
+	DefaultMax     = 15 * time.Second
+	DefaultJitter  = 0.2
+)
+
+// Backoff экспоненциальная задержка между попытками: Initial, 2·Initial, 4·Initial … но не больше Max,
+// каждая со случайным разбросом ±Jitter, чтобы экземпляры, запущенные вместе, не стучались в зависимость одновременно.
+// Нулевые поля заменяются значениями по умолчанию.
+type Backoff struct {
+	Initial time.Duration
+	Max     time.Duration
+	Jitter  float64 // Доля от 0 до 1
+}
+
+// Delay задержка после неудачной попытки номер attempt, начиная с 1
+func (b Backoff) Delay(attempt int) time.Duration {
+	initial, max, jitter := b.Initial, b.Max, b.Jitter
+	if initial <= 0 {
+		initial = DefaultInitial
+	}
+	if max <= 0 {
+		max = DefaultMax
+	}
+	if jitter <= 0 || jitter > 1 {
+		jitter = DefaultJitter
+	}
+	delay := max
+	if attempt <= 30 {
+		if d := initial << (attempt - 1); d > 0 && d < max {
+			delay = d
+		}
+	}
+	return time.Duration(float64(delay) * (1 + jitter*(2*rand.Float64()-1)))
+}
+
+// Policy как долго и как часто пытаться подключиться к зависимости при запуске
+type Policy struct {
+	Backoff Backoff
+	MaxWait time.Duration // Сколько всего ждать подключения; 0 — без ограничения
+}
+
+// ErrGaveUp зависимость не подключилась за Policy.MaxWait
+var ErrGaveUp = errors.New("зависимость не подключилась за отведенное время")
+
+// Retry вызывает connect, пока он не вернет nil. Возвращает ошибку последней попытки вместе с ErrGaveUp,
+// если истек MaxWait, или ошибку ctx, если он отменен. Попытка, начатая до истечения MaxWait, не прерывается:
+// connect сам ограничивает время одной попытки.
+func Retry(ctx context.Context, name string, policy Policy, connect func(ctx context.Context) error) error {
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		err := connect(ctx)
+		if err == nil {
+			if attempt > 1 {
+				slog.InfoContext(ctx, "Зависимость подключена", slog.String("dependency", name), slog.Int("attempts", attempt), slog.Duration("waited", time.Since(start)))
+			}
+			return nil
+		}
+		if ctx.Err() != nil {
+			return fmt.Errorf("%s: %w", name, ctx.Err())
+		}
+
+		delay := policy.Backoff.Delay(attempt)
+		if policy.MaxWait > 0 {
+			left := policy.MaxWait - time.Since(start)
+			if left <= 0 {
+				return fmt.Errorf("%s: %w (%s, попыток: %d): %w", name, ErrGaveUp, policy.MaxWait, attempt, err)
+			}
+			delay = min(delay, left)
+		}
+		slog.WarnContext(ctx, "Зависимость недоступна, повторная попытка", slog.String("dependency", name),
+			slog.Int("attempt", attempt), slog.Duration("retry_in", delay), logging.Err(err))
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s: %w", name, ctx.Err())
+		case <-time.After(delay):
+		}
+	}
+}
+
+// Dependency внешняя зависимость, без которой сервис полностью или частично не работает
+type Dependency struct {
+	Name    string
+	Connect func(ctx context.Context) error // Одна попытка подключения
+	// Optional разрешает запуститься без зависимости: если она не подключилась за MaxWait,
+	// Start не возвращает ошибку, а подключение продолжается в фоне, пока не отменен ctx
+	Optional bool
+}
+
+// Start параллельно подключает зависимости и ждет обязательные. Возвращает ошибки всех обязательных зависимостей,
+// не подключившихся за MaxWait. Необязательные, не успевшие подключиться, перечисляются в degraded.
+func Start(ctx context.Context, policy Policy, deps ...Dependency) (degraded []string, err error) {
+	var (
+		mu   sync.Mutex
+		errs []error
+		wg   sync.WaitGroup
+	)
+	for _, dep := range deps {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := Retry(ctx, dep.Name, policy, dep.Connect)
+			if err == nil {
+				return
+			}
+			if !dep.Optional || ctx.Err() != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+				return
+			}
+
+			mu.Lock()
+			degraded = append(degraded, dep.Name)
+			mu.Unlock()
+			slog.WarnContext(ctx, "Запуск без зависимости, подключение продолжится в фоне", slog.String("dependency", dep.Name), logging.Err(err))
+			go func() {
+				if err := Retry(ctx, dep.Name, Policy{Backoff: policy.Backoff}, dep.Connect); err == nil {
+					slog.InfoContext(ctx, "Сервис вышел из деградированного режима", slog.String("dependency", dep.Name))
+				}
+			}()
+		}()
+	}
+	wg.Wait()
+	return degraded, errors.Join(errs...)
+}
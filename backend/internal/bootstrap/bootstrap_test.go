@@ -0,0 +1,98 @@
+package bootstrap
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var fast = Backoff{Initial: time.Millisecond, Max: 4 * time.Millisecond}
+
+// failing возвращает подключение, которое не удается первые n раз
+func failing(n int32) (func(ctx context.Context) error, *atomic.Int32) {
+	var calls atomic.Int32
+	return func(ctx context.Context) error {
+		if calls.Add(1) <= n {
+			return errors.New("connection refused")
+		}
+		return nil
+	}, &calls
+}
+
+func TestBackoffGrowsUpToMax(t *testing.T) {
+	b := Backoff{Initial: 100 * time.Millisecond, Max: time.Second, Jitter: 0.2}
+	for attempt, want := range map[int]time.Duration{
+		1:   100 * time.Millisecond,
+		2:   200 * time.Millisecond,
+		4:   800 * time.Millisecond,
+		5:   time.Second,
+		100: time.Second,
+	} {
+		for i := 0; i < 20; i++ {
+			got := b.Delay(attempt)
+			if got < want*8/10 || got > want*12/10 {
+				t.Fatalf("Delay(%d) = %s, want %s ±20%%", attempt, got, want)
+			}
+		}
+	}
+}
+
+func TestRetryUntilConnected(t *testing.T) {
+	connect, calls := failing(3)
+	if err := Retry(context.Background(), "db", Policy{Backoff: fast, MaxWait: time.Second}, connect); err != nil {
+		t.Fatal(err)
+	}
+	if n := calls.Load(); n != 4 {
+		t.Errorf("attempts = %d, want 4", n)
+	}
+}
+
+func TestRetryGivesUpAfterMaxWait(t *testing.T) {
+	connect, _ := failing(1 << 30)
+	start := time.Now()
+	err := Retry(context.Background(), "db", Policy{Backoff: fast, MaxWait: 30 * time.Millisecond}, connect)
+	if !errors.Is(err, ErrGaveUp) {
+		t.Fatalf("err = %v, want ErrGaveUp", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("gave up after %s", elapsed)
+	}
+}
+
+func TestStartWaitsForRequiredAndContinuesOptionalInBackground(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	db, _ := failing(2)
+	broker, brokerCalls := failing(1 << 30)
+	policy := Policy{Backoff: fast, MaxWait: 30 * time.Millisecond}
+
+	degraded, err := Start(ctx, policy,
+		Dependency{Name: "postgres", Connect: db},
+		Dependency{Name: "rabbitmq", Connect: broker, Optional: true},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(degraded) != 1 || degraded[0] != "rabbitmq" {
+		t.Errorf("degraded = %v, want [rabbitmq]", degraded)
+	}
+
+	// Подключение к необязательной зависимости продолжается после запуска
+	calls := brokerCalls.Load()
+	time.Sleep(50 * time.Millisecond)
+	if brokerCalls.Load() <= calls {
+		t.Error("optional dependency is not retried in background")
+	}
+}
+
+func TestStartFailsWithoutRequired(t *testing.T) {
+	broker, _ := failing(1 << 30)
+	_, err := Start(context.Background(), Policy{Backoff: fast, MaxWait: 20 * time.Millisecond},
+		Dependency{Name: "rabbitmq", Connect: broker},
+	)
+	if !errors.Is(err, ErrGaveUp) {
+		t.Fatalf("err = %v, want ErrGaveUp", err)
+	}
+}
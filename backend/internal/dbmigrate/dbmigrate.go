@@ -0,0 +1,184 @@
+// Package dbmigrate применяет миграции схемы PostgreSQL, встроенные в бинарник, и сообщает состояние схемы.
+// Up, Down и Force выполняются под advisory lock'ом PostgreSQL, который берет драйвер golang-migrate,
+// поэтому реплики, запущенные одновременно, применяют миграции по очереди, а не наперегонки.
+package dbmigrate
+
+import (
+	"errors"
+	"fmt"
+	"lct/migrations"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+var (
+	// ErrSchemaBehind в базе применены не все миграции, которые знает сервис
+	ErrSchemaBehind = errors.New("схема базы отстает от версии сервиса")
+	// ErrDirty последняя миграция прервана, схема в неизвестном состоянии и требует ручного вмешательства
+	ErrDirty = errors.New("последняя миграция не завершилась")
+)
+
+// Migration одна миграция из бинарника
+type Migration struct {
+	Version uint
+	Name    string
+}
+
+// Status состояние схемы относительно миграций бинарника
+type Status struct {
+	Version uint // Примененная версия; 0 — миграции не применялись
+	Dirty   bool // Миграция Version прервана
+	Latest  uint // Последняя версия, известная сервису
+	Pending []Migration
+}
+
+// Check возвращает ErrDirty, если последняя миграция прервана, и ErrSchemaBehind, если есть непримененные миграции.
+// Схема новее сервиса (например, после отката сервиса без отката базы) ошибкой не считается.
+func (s Status) Check() error {
+	if s.Dirty {
+		return fmt.Errorf("%w: версия %d; исправьте схему и выполните migrate force", ErrDirty, s.Version)
+	}
+	if len(s.Pending) > 0 {
+		return fmt.Errorf("%w: версия %d, требуется %d; выполните migrate up", ErrSchemaBehind, s.Version, s.Latest)
+	}
+	return nil
+}
+
+// Migrator применяет встроенные миграции к одной базе
+type Migrator struct {
+	m          *migrate.Migrate
+	migrations []Migration // По возрастанию версий
+}
+
+// New подключается к базе databaseURL. lockTimeout ограничивает ожидание advisory lock'а,
+// пока миграции применяет другая реплика; 0 — значение golang-migrate по умолчанию.
+func New(databaseURL string, lockTimeout time.Duration) (*Migrator, error) {
+	src, err := iofs.New(migrations.FS, ".")
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать миграции: %w", err)
+	}
+	list, err := readMigrations(src)
+	if err != nil {
+		return nil, err
+	}
+	m, err := migrate.NewWithSourceInstance("iofs", src, databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось подключиться к базе для миграций: %w", err)
+	}
+	return newMigrator(m, list, lockTimeout), nil
+}
+
+func newMigrator(m *migrate.Migrate, list []Migration, lockTimeout time.Duration) *Migrator {
+	if lockTimeout > 0 {
+		m.LockTimeout = lockTimeout
+	}
+	m.Log = logger{}
+	return &Migrator{m: m, migrations: list}
+}
+
+// readMigrations перечисляет миграции источника по возрастанию версий
+func readMigrations(src source.Driver) ([]Migration, error) {
+	var list []Migration
+	version, err := src.First()
+	for err == nil {
+		r, name, rerr := src.ReadUp(version)
+		if rerr != nil {
+			return nil, fmt.Errorf("миграция %d: %w", version, rerr)
+		}
+		r.Close()
+		list = append(list, Migration{Version: version, Name: name})
+		version, err = src.Next(version)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("не удалось прочитать миграции: %w", err)
+	}
+	return list, nil
+}
+
+// Close закрывает соединение с базой
+func (m *Migrator) Close() error {
+	srcErr, dbErr := m.m.Close()
+	return errors.Join(srcErr, dbErr)
+}
+
+// Migrations возвращает все миграции бинарника
+func (m *Migrator) Migrations() []Migration {
+	return m.migrations
+}
+
+// Status возвращает примененную версию и миграции, которые еще предстоит применить
+func (m *Migrator) Status() (Status, error) {
+	version, dirty, err := m.m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		version, err = 0, nil
+	}
+	if err != nil {
+		return Status{}, fmt.Errorf("не удалось получить версию схемы: %w", err)
+	}
+	s := Status{Version: version, Dirty: dirty}
+	for _, mig := range m.migrations {
+		s.Latest = mig.Version
+		if mig.Version > version {
+			s.Pending = append(s.Pending, mig)
+		}
+	}
+	return s, nil
+}
+
+// Up применяет n следующих миграций, все при n <= 0. Отсутствие новых миграций ошибкой не считается.
+func (m *Migrator) Up(n int) error {
+	var err error
+	if n <= 0 {
+		err = m.m.Up()
+	} else {
+		err = m.m.Steps(n)
+	}
+	return migrateErr(err)
+}
+
+// Down откатывает n последних миграций, все при n <= 0
+func (m *Migrator) Down(n int) error {
+	var err error
+	if n <= 0 {
+		err = m.m.Down()
+	} else {
+		err = m.m.Steps(-n)
+	}
+	return migrateErr(err)
+}
+
+// Force записывает версию схемы без применения миграций и снимает признак прерванной миграции.
+// Используется после ручного исправления схемы; -1 означает, что миграции не применялись.
+func (m *Migrator) Force(version int) error {
+	return m.m.Force(version)
+}
+
+// migrateErr переводит ответы golang-migrate: отсутствие изменений — не ошибка
+func migrateErr(err error) error {
+	var short migrate.ErrShortLimit
+	switch {
+	case errors.Is(err, migrate.ErrNoChange):
+		return nil
+	case errors.As(err, &short):
+		return fmt.Errorf("миграций меньше, чем запрошено: не хватило %d", short.Short)
+	}
+	return err
+}
+
+// logger пишет сообщения golang-migrate о применении миграций в slog
+type logger struct{}
+
+func (logger) Printf(format string, v ...interface{}) {
+	slog.Info("Миграции: " + strings.TrimSpace(fmt.Sprintf(format, v...)))
+}
+
+func (logger) Verbose() bool {
+	return false
+}
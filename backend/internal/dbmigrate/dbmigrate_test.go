@@ -0,0 +1,130 @@
+package dbmigrate
+
+import (
+	"errors"
+	"io/fs"
+	"lct/migrations"
+	"strings"
+	"testing"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/stub"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+// newStubMigrator мигратор встроенных миграций поверх базы-заглушки, которая только запоминает версию
+func newStubMigrator(t *testing.T) (*Migrator, *stub.Stub) {
+	t.Helper()
+	src, err := iofs.New(migrations.FS, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err := readMigrations(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := stub.WithInstance(nil, &stub.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := migrate.NewWithInstance("iofs", src, "stub", db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newMigrator(m, list, 0), db.(*stub.Stub)
+}
+
+func TestEveryMigrationHasDown(t *testing.T) {
+	ups, err := fs.Glob(migrations.FS, "*.up.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ups) == 0 {
+		t.Fatal("no migrations embedded")
+	}
+	for _, up := range ups {
+		down := strings.TrimSuffix(up, ".up.sql") + ".down.sql"
+		data, err := fs.ReadFile(migrations.FS, down)
+		if err != nil {
+			t.Errorf("%s: %v", up, err)
+			continue
+		}
+		if strings.TrimSpace(stripComments(string(data))) == "" {
+			t.Errorf("%s is empty", down)
+		}
+	}
+}
+
+func stripComments(sql string) string {
+	var b strings.Builder
+	for _, line := range strings.Split(sql, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "--") {
+			b.WriteString(line + "\n")
+		}
+	}
+	return b.String()
+}
+
+func TestUpDownAndStatus(t *testing.T) {
+	m, db := newStubMigrator(t)
+	all := len(m.Migrations())
+
+	status, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Version != 0 || len(status.Pending) != all || !errors.Is(status.Check(), ErrSchemaBehind) {
+		t.Fatalf("fresh status = %+v, check = %v", status, status.Check())
+	}
+
+	if err := m.Up(2); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ = m.Status(); status.Version != 2 || len(status.Pending) != all-2 {
+		t.Errorf("after up 2: %+v", status)
+	}
+
+	if err := m.Up(0); err != nil {
+		t.Fatal(err)
+	}
+	status, _ = m.Status()
+	if status.Version != status.Latest || status.Check() != nil {
+		t.Errorf("after up: %+v, check = %v", status, status.Check())
+	}
+	// Повторный up ничего не меняет и ошибкой не считается
+	if err := m.Up(0); err != nil {
+		t.Errorf("repeated up: %v", err)
+	}
+
+	if err := m.Down(1); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ = m.Status(); len(status.Pending) != 1 {
+		t.Errorf("after down 1: %+v", status)
+	}
+	if err := m.Down(0); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ = m.Status(); status.Version != 0 {
+		t.Errorf("after down: %+v", status)
+	}
+	if err := m.Down(1); err == nil {
+		t.Error("down below the first migration succeeded")
+	}
+
+	// Прерванная миграция останавливает запуск, пока версию не исправят вручную
+	db.SetVersion(3, true)
+	status, _ = m.Status()
+	if !errors.Is(status.Check(), ErrDirty) {
+		t.Errorf("dirty check = %v", status.Check())
+	}
+	if err := m.Up(0); err == nil {
+		t.Error("up over dirty schema succeeded")
+	}
+	if err := m.Force(int(status.Latest)); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ = m.Status(); status.Check() != nil {
+		t.Errorf("after force: %+v", status)
+	}
+}
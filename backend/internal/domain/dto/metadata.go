@@ -1,5 +1,27 @@
 package dto
 
+import "lct/internal/repository/schema"
+
+// ProcessResult результат обработки исходного файла CV worker'ом или из кэша
+type ProcessResult struct {
+	Job      *schema.Job
+	Artifact *schema.Artifact
+	FileName string
+}
+
+// ReconcileReport результат сверки записей файлов с объектами хранилища
+type ReconcileReport struct {
+	RemovedUploads []int64  `json:"removed_uploads"` // Удаленные зависшие загрузки в состоянии pending
+	RemovedObjects []string `json:"removed_objects"` // Удаленные объекты, на которые ничто не ссылается
+	MissingObjects []string `json:"missing_objects"` // Объекты, на которые ссылаются записи, но которых нет в хранилище
+}
+
+// NewAPIKey созданный API ключ. Key возвращается клиенту один раз и больше нигде не хранится.
+type NewAPIKey struct {
+	schema.APIKey
+	Key string `json:"key"`
+}
+
 // FileMetadataDto DTO для метаданных файла
 //type FileMetadataDto struct {
 //	ID               int64  `json:"id"`
@@ -24,3 +46,27 @@ package dto
 //	MinioPath string `json:"minio_path,omitempty"`
 //	Error     string `json:"error,omitempty"`
 //}
+
+// Итоги проверки готовности
+const (
+	ReadinessOK           = "ok"
+	ReadinessDegraded     = "degraded" // Недоступна только необязательная зависимость
+	ReadinessUnavailable  = "unavailable"
+	ReadinessShuttingDown = "shutting_down"
+	CheckOK               = "ok"
+	CheckFailed           = "failed"
+)
+
+// Readiness результат проверки готовности сервиса к работе
+type Readiness struct {
+	Status string                     `json:"status"` // ok, degraded или unavailable
+	Checks map[string]DependencyCheck `json:"checks"` // По имени зависимости
+}
+
+// DependencyCheck результат проверки одной зависимости
+type DependencyCheck struct {
+	Status    string                 `json:"status"`            // ok или failed
+	LatencyMs float64                `json:"latency_ms"`        // Сколько длилась проверка
+	Error     string                 `json:"error,omitempty"`   // Почему зависимость недоступна
+	Details   map[string]interface{} `json:"details,omitempty"` // Подробности, например состояние очереди
+}
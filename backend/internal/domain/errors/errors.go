@@ -1,7 +1,140 @@
+// Package errors ошибки предметной области.
+// Каждая ошибка относится к виду (Kind), по которому обработчики выбирают HTTP статус, и несет машиночитаемый код:
+// клиенты различают ошибки по коду, а не по тексту, который зависит от языка.
 package errors
 
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+)
+
+// ErrorResponse тело ответа с ошибкой, единое для всех эндпоинтов
 type ErrorResponse struct {
-	Error   string      `json:"error"`
-	Status  int         `json:"code,omitempty"`
-	Details interface{} `json:"details,omitempty"`
+	Code      string `json:"code"`                 // Машиночитаемый код ошибки, не зависит от языка
+	Error     string `json:"error"`                // Сообщение на языке клиента
+	Status    int    `json:"status"`               // HTTP статус ответа
+	Details   string `json:"details,omitempty"`    // Подробности: какой параметр неверен, какая запись не найдена
+	RequestID string `json:"request_id,omitempty"` // ID запроса для поиска в логах
+}
+
+// Kind вид ошибки
+type Kind string
+
+const (
+	KindInternal        Kind = "internal"        // Непредвиденная ошибка сервиса
+	KindInvalid         Kind = "invalid"         // Некорректный запрос клиента
+	KindNotFound        Kind = "not_found"       // Запись не найдена или недоступна клиенту
+	KindConflict        Kind = "conflict"        // Запись уже существует
+	KindUnauthenticated Kind = "unauthenticated" // Нет действительных учетных данных
+	KindForbidden       Kind = "forbidden"       // Роль клиента не разрешает операцию
+	KindQuotaExceeded   Kind = "quota_exceeded"  // Исчерпана квота арендатора
+	KindRateLimited     Kind = "rate_limited"    // Превышен лимит запросов или заполнена очередь
+	KindUnavailable     Kind = "unavailable"     // Сервис или его зависимость временно недоступны
+	KindTimeout         Kind = "timeout"         // Операция не уложилась в отведенное время
+	KindWorkerFailed    Kind = "worker_failed"   // CV worker не смог обработать файл
+)
+
+// Error ошибка предметной области. Ошибки с одинаковым кодом равны для errors.Is,
+// поэтому ошибка, созданная Wrap или Errorf, по-прежнему совпадает со своим образцом.
+type Error struct {
+	Kind    Kind
+	Code    string // Машиночитаемый код, он же ключ локализованного сообщения
+	Message string // Сообщение по умолчанию
+	Err     error  // Причина: подробности или исходная ошибка
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return e.Message
+	}
+	return e.Message + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// Wrap возвращает ошибку того же вида и кода с причиной err
+func (e *Error) Wrap(err error) error {
+	return e.wrap(err)
+}
+
+// Errorf возвращает ошибку того же вида и кода с подробностями, отформатированными как в fmt.Errorf
+func (e *Error) Errorf(format string, args ...any) error {
+	return e.Wrap(fmt.Errorf(format, args...))
+}
+
+func (e *Error) wrap(err error) *Error {
+	wrapped := *e
+	wrapped.Err = err
+	return &wrapped
+}
+
+func newError(kind Kind, code string, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+var (
+	// ErrInternal непредвиденная ошибка; подробности клиенту не показываются
+	ErrInternal = newError(KindInternal, "internal", "внутренняя ошибка сервиса")
+	// ErrInvalidArgument некорректные параметры запроса
+	ErrInvalidArgument = newError(KindInvalid, "invalid_argument", "некорректные параметры запроса")
+	// ErrInvalidParams параметры обработки вне допустимых значений
+	ErrInvalidParams = newError(KindInvalid, "invalid_params", "неверные параметры обработки")
+	// ErrNotFound запись не найдена или принадлежит другому проекту
+	ErrNotFound = newError(KindNotFound, "not_found", "не найдено")
+	// ErrResultDeleted результат задачи удален вместе с файлом, из которого он был получен
+	ErrResultDeleted = newError(KindNotFound, "result_deleted", "результат задачи удален")
+	// ErrConflict запись уже существует или уже изменена другим запросом
+	ErrConflict = newError(KindConflict, "conflict", "запись уже существует")
+	// ErrJobNotDone результат запрошен у задачи, которая еще выполняется или завершилась ошибкой
+	ErrJobNotDone = newError(KindConflict, "job_not_done", "задача не выполнена")
+	// ErrUnauthenticated учетные данные отсутствуют или недействительны
+	ErrUnauthenticated = newError(KindUnauthenticated, "unauthenticated", "требуется аутентификация")
+	// ErrForbidden роль клиента не разрешает операцию
+	ErrForbidden = newError(KindForbidden, "forbidden", "недостаточно прав")
+	// ErrStorageQuotaExceeded загрузка превысит квоту хранилища арендатора
+	ErrStorageQuotaExceeded = newError(KindQuotaExceeded, "storage_quota_exceeded", "превышена квота хранилища")
+	// ErrJobQuotaExceeded арендатор исчерпал квоту обработок на текущий месяц
+	ErrJobQuotaExceeded = newError(KindQuotaExceeded, "job_quota_exceeded", "превышена квота обработки")
+	// ErrRateLimited клиент превысил лимит запросов
+	ErrRateLimited = newError(KindRateLimited, "rate_limited", "слишком много запросов")
+	// ErrTooManyPendingJobs в очереди CV worker'а уже максимальное число задач
+	ErrTooManyPendingJobs = newError(KindRateLimited, "too_many_pending_jobs", "слишком много задач в очереди обработки")
+	// ErrUnavailable зависимость сервиса (база, хранилище, брокер) временно недоступна
+	ErrUnavailable = newError(KindUnavailable, "unavailable", "сервис временно недоступен")
+	// ErrShuttingDown сервис останавливается и не принимает новые задачи
+	ErrShuttingDown = newError(KindUnavailable, "shutting_down", "сервис останавливается")
+	// ErrJobInterrupted ожидание результата прервано остановкой сервиса, задача будет возобновлена
+	ErrJobInterrupted = newError(KindUnavailable, "job_interrupted", "обработка прервана остановкой сервиса")
+	// ErrTimeout операция не уложилась в отведенное время
+	ErrTimeout = newError(KindTimeout, "timeout", "превышено время ожидания")
+	// ErrProcessingTimeout CV worker не ответил за отведенное время
+	ErrProcessingTimeout = newError(KindTimeout, "processing_timeout", "timeout ожидания обработки файла")
+	// ErrWorkerFailed CV worker вернул ошибку обработки
+	ErrWorkerFailed = newError(KindWorkerFailed, "worker_failed", "ошибка обработки файла")
+)
+
+// As возвращает ошибку предметной области из цепочки err. Истекший контекст считается ErrTimeout,
+// прочие ошибки без вида — ErrInternal с исходной ошибкой в качестве причины.
+func As(err error) *Error {
+	var e *Error
+	if stderrors.As(err, &e) {
+		return e
+	}
+	if stderrors.Is(err, context.DeadlineExceeded) {
+		return ErrTimeout.wrap(err)
+	}
+	return ErrInternal.wrap(err)
+}
+
+// KindOf возвращает вид ошибки
+func KindOf(err error) Kind {
+	return As(err).Kind
 }
@@ -0,0 +1,79 @@
+package errors
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"testing"
+)
+
+func TestWrappedErrorsMatchTheirSentinel(t *testing.T) {
+	cause := stderrors.New("connection refused")
+	tests := []struct {
+		name string
+		err  error
+		want *Error
+	}{
+		{"fmt wrap", fmt.Errorf("%w: файл с id 7", ErrNotFound), ErrNotFound},
+		{"Errorf", ErrInvalidArgument.Errorf("неверный формат ID %q", "x"), ErrInvalidArgument},
+		{"Wrap", ErrUnavailable.Wrap(cause), ErrUnavailable},
+		{"nested", fmt.Errorf("process: %w", ErrWorkerFailed.Errorf("CUDA out of memory")), ErrWorkerFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !stderrors.Is(tt.err, tt.want) {
+				t.Errorf("errors.Is(%v, %s) = false", tt.err, tt.want.Code)
+			}
+			if got := As(tt.err); got.Code != tt.want.Code || got.Kind != tt.want.Kind {
+				t.Errorf("As() = %s/%s, want %s/%s", got.Kind, got.Code, tt.want.Kind, tt.want.Code)
+			}
+		})
+	}
+
+	if !stderrors.Is(ErrUnavailable.Wrap(cause), cause) {
+		t.Error("Wrap hides the cause from errors.Is")
+	}
+	if stderrors.Is(ErrShuttingDown, ErrUnavailable) {
+		t.Error("errors of one kind with different codes must not match")
+	}
+}
+
+func TestAsClassifiesForeignErrors(t *testing.T) {
+	if got := KindOf(fmt.Errorf("query: %w", context.DeadlineExceeded)); got != KindTimeout {
+		t.Errorf("deadline kind = %s, want %s", got, KindTimeout)
+	}
+	if got := KindOf(stderrors.New("boom")); got != KindInternal {
+		t.Errorf("plain error kind = %s, want %s", got, KindInternal)
+	}
+}
+
+func TestEveryErrorHasMessages(t *testing.T) {
+	for _, e := range []*Error{
+		ErrInternal, ErrInvalidArgument, ErrInvalidParams, ErrNotFound, ErrResultDeleted, ErrConflict, ErrJobNotDone, ErrUnauthenticated,
+		ErrForbidden, ErrStorageQuotaExceeded, ErrJobQuotaExceeded, ErrRateLimited, ErrTooManyPendingJobs,
+		ErrUnavailable, ErrShuttingDown, ErrJobInterrupted, ErrTimeout, ErrProcessingTimeout, ErrWorkerFailed,
+	} {
+		for _, lang := range []string{LangRU, LangEN} {
+			if messages[e.Code][lang] == "" {
+				t.Errorf("no %s message for %s", lang, e.Code)
+			}
+		}
+	}
+}
+
+func TestNegotiateLang(t *testing.T) {
+	tests := map[string]string{
+		"":                        LangRU,
+		"en":                      LangEN,
+		"en-US,en;q=0.9":          LangEN,
+		"de-DE,de;q=0.9,en;q=0.5": LangEN,
+		"en;q=0.3,ru;q=0.8":       LangRU,
+		"fr":                      LangRU,
+		"en;q=0":                  LangRU,
+	}
+	for header, want := range tests {
+		if got := NegotiateLang(header); got != want {
+			t.Errorf("NegotiateLang(%q) = %s, want %s", header, got, want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package errors
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Языки сообщений об ошибках
+const (
+	LangRU = "ru"
+	LangEN = "en"
+)
+
+// DefaultLang язык сообщений, если клиент не запросил поддерживаемый
+const DefaultLang = LangRU
+
+// messages сообщения для клиентов по коду ошибки и языку
+var messages = map[string]map[string]string{
+	"internal":               {LangRU: "Внутренняя ошибка сервиса", LangEN: "Internal server error"},
+	"invalid_argument":       {LangRU: "Некорректные параметры запроса", LangEN: "Invalid request parameters"},
+	"invalid_params":         {LangRU: "Неверные параметры обработки", LangEN: "Invalid processing parameters"},
+	"not_found":              {LangRU: "Не найдено", LangEN: "Not found"},
+	"result_deleted":         {LangRU: "Результат задачи удален, запустите обработку заново", LangEN: "Job result was deleted, run processing again"},
+	"conflict":               {LangRU: "Запись уже существует", LangEN: "Already exists"},
+	"job_not_done":           {LangRU: "Задача не выполнена", LangEN: "Job is not done"},
+	"unauthenticated":        {LangRU: "Требуется аутентификация", LangEN: "Authentication required"},
+	"forbidden":              {LangRU: "Недостаточно прав", LangEN: "Permission denied"},
+	"storage_quota_exceeded": {LangRU: "Превышена квота хранилища", LangEN: "Storage quota exceeded"},
+	"job_quota_exceeded":     {LangRU: "Превышена квота обработки", LangEN: "Processing quota exceeded"},
+	"rate_limited":           {LangRU: "Слишком много запросов", LangEN: "Too many requests"},
+	"too_many_pending_jobs":  {LangRU: "Очередь обработки заполнена, повторите запрос позже", LangEN: "Processing queue is full, retry later"},
+	"unavailable":            {LangRU: "Сервис временно недоступен", LangEN: "Service temporarily unavailable"},
+	"shutting_down":          {LangRU: "Сервис перезапускается, повторите запрос позже", LangEN: "Service is restarting, retry later"},
+	"job_interrupted":        {LangRU: "Сервис перезапускается, обработка будет продолжена", LangEN: "Service is restarting, processing will be resumed"},
+	"timeout":                {LangRU: "Превышено время ожидания", LangEN: "Request timed out"},
+	"processing_timeout":     {LangRU: "Timeout ожидания обработки файла", LangEN: "File processing timed out"},
+	"worker_failed":          {LangRU: "Ошибка обработки файла", LangEN: "File processing failed"},
+}
+
+// Localize возвращает сообщение об ошибке на языке lang или на языке по умолчанию
+func (e *Error) Localize(lang string) string {
+	texts, ok := messages[e.Code]
+	if !ok {
+		return e.Message
+	}
+	if text, ok := texts[lang]; ok {
+		return text
+	}
+	return texts[DefaultLang]
+}
+
+// NegotiateLang выбирает язык сообщений по заголовку Accept-Language с учетом весов q
+func NegotiateLang(acceptLanguage string) string {
+	best, bestQ := DefaultLang, -1.0
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		lang, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		if lang != LangRU && lang != LangEN {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q > 0 && q > bestQ {
+			best, bestQ = lang, q
+		}
+	}
+	return best
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	stderrors "errors"
+	"lct/internal/auth"
+	"lct/internal/domain/errors"
+	"lct/internal/logging"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// principalKey ключ клиента в gin.Context
+const principalKey = "principal"
+
+// AuthConfig настройки аутентификации запросов
+type AuthConfig struct {
+	Disabled bool              // Пропускать все запросы без учетных данных, только для локальной разработки
+	JWT      *auth.JWTVerifier // Проверка bearer токенов; nil — принимаются только API ключи
+}
+
+// Authenticate проверяет учетные данные запроса и сохраняет клиента в gin.Context и контексте запроса.
+// API ключ передается в заголовке X-API-Key или Authorization: Bearer, JWT — в Authorization: Bearer.
+func (h *Handler) Authenticate(c *gin.Context) {
+	if h.auth.Disabled {
+		setPrincipal(c, &auth.Principal{Subject: "anonymous", Method: auth.MethodNone, Role: auth.RoleAdmin})
+		return
+	}
+
+	token := credentials(c.Request)
+	if token == "" {
+		abortWithError(c, errors.ErrUnauthenticated.Errorf("не переданы учетные данные"))
+		return
+	}
+
+	var (
+		principal *auth.Principal
+		err       error
+	)
+	if isJWT(token) {
+		if h.auth.JWT == nil {
+			abortWithError(c, errors.ErrUnauthenticated.Errorf("JWT токены не принимаются"))
+			return
+		}
+		principal, err = h.auth.JWT.Verify(token)
+	} else {
+		principal, err = h.service.AuthenticateAPIKey(c.Request.Context(), token)
+	}
+	if err != nil {
+		if stderrors.Is(err, errors.ErrUnauthenticated) {
+			abortWithError(c, errors.ErrUnauthenticated.Errorf("недействительные учетные данные"))
+			return
+		}
+		// Причина (например, недоступная база) остается в логе, клиент получает только вид ошибки
+		slog.ErrorContext(c.Request.Context(), "Ошибка проверки учетных данных", logging.Err(err))
+		abortWithError(c, errors.ErrUnavailable.Errorf("не удалось проверить учетные данные"))
+		return
+	}
+	setPrincipal(c, principal)
+}
+
+// Principal возвращает клиента, от имени которого выполняется запрос
+func Principal(c *gin.Context) *auth.Principal {
+	p, _ := c.Get(principalKey)
+	principal, _ := p.(*auth.Principal)
+	return principal
+}
+
+func setPrincipal(c *gin.Context, p *auth.Principal) {
+	c.Set(principalKey, p)
+	c.Request = c.Request.WithContext(auth.WithPrincipal(c.Request.Context(), p))
+	c.Next()
+}
+
+// credentials извлекает API ключ или токен из заголовков запроса
+func credentials(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// isJWT отличает JWT (header.payload.signature) от API ключа
+func isJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// CreateAPIKey обработчик создания API ключа; ключ целиком возвращается только в этом ответе
+func (h *Handler) CreateAPIKey(c *gin.Context) {
+	var req struct {
+		Name    string `json:"name"`
+		Role    string `json:"role"`
+		Project string `json:"project"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, errors.ErrInvalidArgument.Errorf("неверный формат запроса: %w", err))
+		return
+	}
+	if strings.TrimSpace(req.Name) == "" {
+		abortWithError(c, errors.ErrInvalidArgument.Errorf("не указано имя ключа"))
+		return
+	}
+
+	key, err := h.service.CreateAPIKey(c.Request.Context(), strings.TrimSpace(req.Name), req.Role, strings.TrimSpace(req.Project))
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, key)
+}
+
+// ListAPIKeys обработчик получения списка API ключей без их значений
+func (h *Handler) ListAPIKeys(c *gin.Context) {
+	keys, err := h.service.ListAPIKeys(c.Request.Context())
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, keys)
+}
+
+// RevokeAPIKey обработчик отзыва API ключа
+func (h *Handler) RevokeAPIKey(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.RevokeAPIKey(c.Request.Context(), id); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
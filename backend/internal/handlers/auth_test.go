@@ -0,0 +1,273 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"lct/internal/auth"
+	"lct/internal/domain/dto"
+	"lct/internal/repository/schema"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func signTestToken(t *testing.T, claims jwt.Claims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testJWTSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestHealthCheckDoesNotRequireCredentials(t *testing.T) {
+	env := newTestEnv(t)
+	if w := env.serve(httptest.NewRequest(http.MethodGet, "/health", nil)); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+}
+
+func TestRequestsWithoutValidCredentialsAreRejected(t *testing.T) {
+	env := newTestEnv(t)
+	expired := signTestToken(t, jwt.RegisteredClaims{
+		Subject:   "alice",
+		Issuer:    "test-issuer",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Hour)),
+	})
+	wrongIssuer := signTestToken(t, jwt.RegisteredClaims{
+		Subject:   "alice",
+		Issuer:    "someone-else",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	})
+	forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Subject:   "alice",
+		Issuer:    "test-issuer",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}).SignedString([]byte("another-secret-0123456789abcdef0123"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]func(*http.Request){
+		"no credentials":  func(*http.Request) {},
+		"unknown api key": func(r *http.Request) { r.Header.Set("X-API-Key", "lct_000000000000_secret") },
+		"malformed key":   func(r *http.Request) { r.Header.Set("Authorization", "Bearer garbage") },
+		"basic scheme":    func(r *http.Request) { r.Header.Set("Authorization", "Basic "+testBootstrapKey) },
+		"expired jwt":     func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+expired) },
+		"wrong issuer":    func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+wrongIssuer) },
+		"forged jwt":      func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+forged) },
+		"jwt without project": func(r *http.Request) {
+			r.Header.Set("Authorization", "Bearer "+signTestToken(t, jwt.MapClaims{
+				"sub": "alice", "iss": "test-issuer", "exp": time.Now().Add(time.Hour).Unix(), "role": auth.RoleOperator,
+			}))
+		},
+	}
+	for name, setup := range cases {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/jobs/1", nil)
+			setup(req)
+			w := env.serve(req)
+			if w.Code != http.StatusUnauthorized {
+				t.Fatalf("status = %d, want 401, body = %s", w.Code, w.Body)
+			}
+			if w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("WWW-Authenticate header is missing")
+			}
+		})
+	}
+}
+
+func TestValidJWTIsAccepted(t *testing.T) {
+	env := newTestEnv(t)
+	token := signTestToken(t, jwt.MapClaims{
+		"sub":     "alice",
+		"iss":     "test-issuer",
+		"exp":     time.Now().Add(time.Hour).Unix(),
+		"role":    auth.RoleViewer,
+		"project": "alpha",
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/jobs/1", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	// Задачи нет, но запрос прошел аутентификацию
+	if w := env.serve(req); w.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404, body = %s", w.Code, w.Body)
+	}
+}
+
+func TestAPIKeyLifecycle(t *testing.T) {
+	env := newTestEnv(t)
+
+	body := bytes.NewBufferString(`{"name": "ci", "role": "admin"}`)
+	w := env.do(httptest.NewRequest(http.MethodPost, "/admin/api-keys", body))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: status = %d, body = %s", w.Code, w.Body)
+	}
+	var created dto.NewAPIKey
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	if created.Key == "" || created.ID == 0 {
+		t.Fatalf("created = %+v", created)
+	}
+
+	// Ключ работает в обоих заголовках
+	for _, setHeader := range []func(*http.Request){
+		func(r *http.Request) { r.Header.Set("X-API-Key", created.Key) },
+		func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+created.Key) },
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/admin/api-keys", nil)
+		setHeader(req)
+		w := env.serve(req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("list: status = %d, body = %s", w.Code, w.Body)
+		}
+		var keys []schema.APIKey
+		if err := json.Unmarshal(w.Body.Bytes(), &keys); err != nil {
+			t.Fatal(err)
+		}
+		if len(keys) != 1 || keys[0].Name != "ci" || keys[0].Role != auth.RoleAdmin || keys[0].LastUsedAt == nil {
+			t.Fatalf("keys = %+v", keys)
+		}
+		if bytes.Contains(w.Body.Bytes(), []byte(created.Key)) {
+			t.Fatal("list response leaks the key")
+		}
+	}
+
+	w = env.do(httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/admin/api-keys/%d", created.ID), nil))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("revoke: status = %d, body = %s", w.Code, w.Body)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/api-keys", nil)
+	req.Header.Set("X-API-Key", created.Key)
+	if w := env.serve(req); w.Code != http.StatusUnauthorized {
+		t.Fatalf("revoked key: status = %d, want 401", w.Code)
+	}
+}
+
+func TestAPIKeyWithWrongSecretIsRejected(t *testing.T) {
+	env := newTestEnv(t)
+	created, err := env.service.CreateAPIKey(adminContext(), "ci", auth.RoleAdmin, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/api-keys", nil)
+	req.Header.Set("X-API-Key", "lct_"+created.Prefix+"_wrongsecret")
+	if w := env.serve(req); w.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want 401", w.Code)
+	}
+}
+
+func TestAuthenticationFailsClosedWhenStoreIsDown(t *testing.T) {
+	env := newTestEnv(t)
+	created, err := env.service.CreateAPIKey(adminContext(), "ci", auth.RoleAdmin, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.repo.FailOn("GetAPIKeyByPrefix", fmt.Errorf("connection refused"))
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/api-keys", nil)
+	req.Header.Set("X-API-Key", created.Key)
+	if w := env.serve(req); w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", w.Code)
+	}
+}
+
+// adminContext контекст администратора для подготовки данных через сервис
+func adminContext() context.Context {
+	return auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "test", Role: auth.RoleAdmin})
+}
+
+// withKey добавляет API ключ к запросу
+func withKey(req *http.Request, key string) *http.Request {
+	req.Header.Set("X-API-Key", key)
+	return req
+}
+
+func TestRolesAndProjectsOverHTTP(t *testing.T) {
+	env := newTestEnv(t)
+	for _, id := range []string{"alpha", "beta"} {
+		if err := env.service.CreateTenant(adminContext(), &schema.Tenant{ID: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	keys := make(map[string]string)
+	for _, k := range []struct{ name, role, project string }{
+		{"alpha-operator", auth.RoleOperator, "alpha"},
+		{"alpha-viewer", auth.RoleViewer, "alpha"},
+		{"beta-operator", auth.RoleOperator, "beta"},
+	} {
+		created, err := env.service.CreateAPIKey(adminContext(), k.name, k.role, k.project)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[k.name] = created.Key
+	}
+
+	content := []byte("scan")
+	w := env.serve(withKey(multipartRequest(t, "/files/upload_file", "scan.pcd", content, nil), keys["alpha-operator"]))
+	if w.Code != http.StatusOK {
+		t.Fatalf("upload as operator: status = %d, body = %s", w.Code, w.Body)
+	}
+
+	cases := []struct {
+		key    string
+		method string
+		url    string
+		want   int
+	}{
+		{"alpha-viewer", http.MethodGet, "/files/1", http.StatusOK},
+		{"alpha-viewer", http.MethodGet, "/files/1/download", http.StatusOK},
+		{"alpha-viewer", http.MethodDelete, "/files/1", http.StatusForbidden},
+		{"alpha-viewer", http.MethodGet, "/admin/api-keys", http.StatusForbidden},
+		{"alpha-operator", http.MethodPost, "/admin/reconcile", http.StatusForbidden},
+		{"beta-operator", http.MethodGet, "/files/1", http.StatusNotFound},
+		{"beta-operator", http.MethodGet, "/files/1/download", http.StatusNotFound},
+		{"beta-operator", http.MethodGet, "/files/1/artifacts", http.StatusNotFound},
+		{"beta-operator", http.MethodDelete, "/files/1", http.StatusNotFound},
+		{"alpha-operator", http.MethodDelete, "/files/1", http.StatusNoContent},
+		{"alpha-viewer", http.MethodGet, "/files/1", http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		w := env.serve(withKey(httptest.NewRequest(tc.method, tc.url, nil), keys[tc.key]))
+		if w.Code != tc.want {
+			t.Errorf("%s %s as %s: status = %d, want %d, body = %s", tc.method, tc.url, tc.key, w.Code, tc.want, w.Body)
+		}
+	}
+
+	w = env.serve(withKey(multipartRequest(t, "/files/upload_file", "scan.pcd", content, nil), keys["alpha-viewer"]))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("upload as viewer: status = %d, want 403", w.Code)
+	}
+}
+
+func TestCreateAPIKeyValidatesRole(t *testing.T) {
+	env := newTestEnv(t)
+	for _, body := range []string{
+		`{"name": "ci", "role": "superuser"}`,
+		`{"name": "ci", "role": "operator"}`,
+	} {
+		w := env.do(httptest.NewRequest(http.MethodPost, "/admin/api-keys", bytes.NewBufferString(body)))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", body, w.Code)
+		}
+	}
+}
+
+func TestAuthDisabledAllowsAnonymousRequests(t *testing.T) {
+	env := newTestEnv(t)
+	env.router = gin.New()
+	NewMinioHandler(env.service, AuthConfig{Disabled: true}, LimitsConfig{}).RegisterRoutes(env.router)
+
+	if w := env.serve(httptest.NewRequest(http.MethodGet, "/admin/api-keys", nil)); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"lct/internal/domain/errors"
+	"lct/internal/logging"
+	"lct/internal/metrics"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// shutdownRetryAfter через сколько предлагать повторить запрос, прерванный перезапуском сервиса
+const shutdownRetryAfter = 30 * time.Second
+
+// kindStatus HTTP статус по виду ошибки
+var kindStatus = map[errors.Kind]int{
+	errors.KindInternal:        http.StatusInternalServerError,
+	errors.KindInvalid:         http.StatusBadRequest,
+	errors.KindNotFound:        http.StatusNotFound,
+	errors.KindConflict:        http.StatusConflict,
+	errors.KindUnauthenticated: http.StatusUnauthorized,
+	errors.KindForbidden:       http.StatusForbidden,
+	errors.KindQuotaExceeded:   http.StatusTooManyRequests,
+	errors.KindRateLimited:     http.StatusTooManyRequests,
+	errors.KindUnavailable:     http.StatusServiceUnavailable,
+	errors.KindTimeout:         http.StatusGatewayTimeout,
+	errors.KindWorkerFailed:    http.StatusBadGateway,
+}
+
+// HandleErrors отвечает на ошибку, с которой обработчик прервал запрос, единым телом errors.ErrorResponse.
+// Статус выбирается по виду ошибки, сообщение — на языке из Accept-Language. Если ответ уже начат
+// (например, ошибка при передаче файла), ошибка остается только в логе запроса.
+func HandleErrors(c *gin.Context) {
+	c.Next()
+	if len(c.Errors) == 0 || c.Writer.Written() {
+		return
+	}
+	writeError(c, c.Errors.Last().Err)
+}
+
+// abortWithError прерывает обработку запроса; ответ пишет HandleErrors
+func abortWithError(c *gin.Context, err error) {
+	_ = c.Error(err)
+	c.Abort()
+}
+
+func writeError(c *gin.Context, err error) {
+	e := errors.As(err)
+	status := httpStatus(e)
+	setErrorHeaders(c, e)
+
+	resp := errors.ErrorResponse{
+		Code:      e.Code,
+		Error:     e.Localize(errors.NegotiateLang(c.GetHeader("Accept-Language"))),
+		Status:    status,
+		RequestID: logging.RequestID(c.Request.Context()),
+	}
+	// Подробности непредвиденных ошибок остаются в логе, клиенту достаточно request_id
+	if e.Kind != errors.KindInternal {
+		resp.Details = details(err, e)
+	}
+	c.JSON(status, resp)
+}
+
+func httpStatus(e *errors.Error) int {
+	// Загрузка, не помещающаяся в квоту, в отличие от исчерпанных обработок не пройдет и позже
+	if e.Code == errors.ErrStorageQuotaExceeded.Code {
+		return http.StatusInsufficientStorage
+	}
+	if status, ok := kindStatus[e.Kind]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// setErrorHeaders подсказывает клиенту, когда повторить запрос и как пройти аутентификацию.
+// Retry-After, уже выставленный обработчиком, не меняется.
+func setErrorHeaders(c *gin.Context, e *errors.Error) {
+	if e.Kind == errors.KindUnauthenticated {
+		c.Header("WWW-Authenticate", `Bearer realm="lct"`)
+	}
+	if c.Writer.Header().Get("Retry-After") != "" {
+		return
+	}
+	var retryAfter int
+	switch e.Code {
+	case errors.ErrShuttingDown.Code, errors.ErrJobInterrupted.Code:
+		// Прерванная задача будет возобновлена после перезапуска, ее статус доступен по X-Job-ID
+		retryAfter = int(shutdownRetryAfter.Seconds())
+	case errors.ErrJobQuotaExceeded.Code:
+		retryAfter = secondsUntilNextPeriod(time.Now())
+	case errors.ErrTooManyPendingJobs.Code:
+		metrics.RateLimited.WithLabelValues(metrics.ReasonPendingJobs).Inc()
+		retryAfter = int(pendingJobsRetryAfter.Seconds())
+	default:
+		return
+	}
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+}
+
+// details текст ошибки без общего сообщения вида, которое уже есть в поле error
+func details(err error, e *errors.Error) string {
+	text := err.Error()
+	if text == e.Message {
+		return ""
+	}
+	return strings.TrimPrefix(text, e.Message+": ")
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"encoding/json"
+	"lct/internal/domain/errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func decodeError(t *testing.T, w *httptest.ResponseRecorder) errors.ErrorResponse {
+	t.Helper()
+	var resp errors.ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("error body is not JSON: %v: %s", err, w.Body)
+	}
+	return resp
+}
+
+func TestErrorsShareOneSchema(t *testing.T) {
+	env := newTestEnv(t)
+
+	tests := []struct {
+		name   string
+		req    *http.Request
+		status int
+		code   string
+	}{
+		{"bad id", httptest.NewRequest(http.MethodGet, "/jobs/abc", nil), http.StatusBadRequest, "invalid_argument"},
+		{"missing job", httptest.NewRequest(http.MethodGet, "/jobs/404", nil), http.StatusNotFound, "not_found"},
+		{"missing file", httptest.NewRequest(http.MethodGet, "/files/404", nil), http.StatusNotFound, "not_found"},
+		{"missing api key", httptest.NewRequest(http.MethodDelete, "/admin/api-keys/404", nil), http.StatusNotFound, "not_found"},
+		{"no upload", httptest.NewRequest(http.MethodPost, "/files/upload_file", nil), http.StatusBadRequest, "invalid_argument"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.req.Header.Set(requestIDHeader, "req-"+tt.code)
+			w := env.do(tt.req)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d, body = %s", w.Code, tt.status, w.Body)
+			}
+			resp := decodeError(t, w)
+			if resp.Code != tt.code || resp.Status != tt.status || resp.Error == "" || resp.RequestID != "req-"+tt.code {
+				t.Errorf("response = %+v", resp)
+			}
+			if tt.code == "not_found" && resp.Details == "" {
+				t.Error("not found response does not say what is missing")
+			}
+		})
+	}
+}
+
+func TestErrorMessagesFollowAcceptLanguage(t *testing.T) {
+	env := newTestEnv(t)
+
+	for lang, want := range map[string]string{"": "Не найдено", "en-US,en;q=0.9": "Not found", "ru": "Не найдено"} {
+		req := httptest.NewRequest(http.MethodGet, "/jobs/404", nil)
+		if lang != "" {
+			req.Header.Set("Accept-Language", lang)
+		}
+		resp := decodeError(t, env.do(req))
+		if resp.Error != want || resp.Code != "not_found" {
+			t.Errorf("Accept-Language %q: error = %q, code = %q, want %q", lang, resp.Error, resp.Code, want)
+		}
+	}
+}
+
+func TestUnauthenticatedErrorCode(t *testing.T) {
+	env := newTestEnv(t)
+
+	w := env.serve(httptest.NewRequest(http.MethodGet, "/jobs/1", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want 401", w.Code)
+	}
+	if resp := decodeError(t, w); resp.Code != "unauthenticated" {
+		t.Errorf("code = %q, want unauthenticated", resp.Code)
+	}
+	if w.Header().Get("WWW-Authenticate") == "" {
+		t.Error("WWW-Authenticate header is missing")
+	}
+}
@@ -9,18 +9,18 @@ import (
 	//"github.com/minio/minio-go/v7/pkg/credentials"
 	"io"
 
-	amqp "github.com/rabbitmq/amqp091-go"
-
 	//"lct/config"
+	"lct/internal/domain/dto"
 	"lct/internal/domain/errors"
 	//"lct/internal/handlers/responses"
-	minio2 "lct/internal/repository/minio"
+	"lct/internal/logging"
+	"lct/internal/repository/schema"
 	"lct/internal/service"
-	"log"
+	"log/slog"
 	"net/http"
-	"os"
 
-	//"strconv"
+	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -29,15 +29,55 @@ import (
 
 type Handler struct {
 	service service.ServiceInt
+	auth    AuthConfig
+	limits  limiters
+	ready   atomic.Bool // Готов ли сервис принимать трафик; сбрасывается в начале остановки
 }
 
-func NewMinioHandler(service service.ServiceInt) *Handler {
-	return &Handler{
+func NewMinioHandler(service service.ServiceInt, auth AuthConfig, limits LimitsConfig) *Handler {
+	h := &Handler{
 		service: service,
+		auth:    auth,
+		limits:  newLimiters(limits),
+	}
+	h.ready.Store(true)
+	return h
+}
+
+// SetReady переключает готовность сервиса, которую отдают HealthCheck и Readyz
+func (h *Handler) SetReady(ready bool) {
+	h.ready.Store(ready)
+}
+
+// Livez отвечает, пока процесс жив и обслуживает запросы. Зависимости не проверяются:
+// их недоступность лечится не перезапуском сервиса, а снятием его с балансировки через /readyz.
+func (h *Handler) Livez(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readyz проверяет PostgreSQL, хранилище, RabbitMQ и наличие CV worker'ов и отдает результат по каждой зависимости.
+// Если хоть одна обязательная недоступна или сервис останавливается, отвечает 503, чтобы трафик на него не направлялся.
+func (h *Handler) Readyz(c *gin.Context) {
+	if !h.ready.Load() {
+		c.JSON(http.StatusServiceUnavailable, dto.Readiness{Status: dto.ReadinessShuttingDown, Checks: map[string]dto.DependencyCheck{}})
+		return
+	}
+	res := h.service.Readiness(c.Request.Context())
+	status := http.StatusOK
+	if res.Status == dto.ReadinessUnavailable {
+		status = http.StatusServiceUnavailable
 	}
+	c.JSON(status, res)
 }
 
 func (h *Handler) HealthCheck(c *gin.Context) {
+	if !h.ready.Load() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status":  "shutting_down",
+			"message": "service is shutting down",
+		})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"status":  "ok",
 		"message": "good",
@@ -47,7 +87,7 @@ func (h *Handler) HealthCheck(c *gin.Context) {
 func (h *Handler) CreateOne(c *gin.Context) {
 	file, err := c.FormFile("file")
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "no file received"})
+		abortWithError(c, errors.ErrInvalidArgument.Errorf("не передан файл: %w", err))
 		return
 	}
 
@@ -55,18 +95,15 @@ func (h *Handler) CreateOne(c *gin.Context) {
 
 	f, err := file.Open()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot open file"})
+		abortWithError(c, fmt.Errorf("cannot open file: %w", err))
 		return
 	}
 	defer f.Close()
 
 	ctx := c.Request.Context()
-	object, _, err := h.service.CreateOne(&ctx, f, file.Size, minio2.FileDataType{
-		FileName: file.Filename,
-		Data:     nil, // <-- не читаем всё в память
-	}, file.Filename, file.Size, objectKey)
+	object, _, err := h.service.CreateOne(ctx, f, file.Filename, file.Size, objectKey)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot save file"})
+		abortWithError(c, err)
 		return
 	}
 	defer object.Close()
@@ -75,9 +112,92 @@ func (h *Handler) CreateOne(c *gin.Context) {
 	c.Writer.Header().Set("Content-Type", "application/octet-stream")
 
 	if _, err := io.Copy(c.Writer, object); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "stream error: " + err.Error()})
+		abortWithError(c, fmt.Errorf("stream error: %w", err))
+		return
+	}
+}
+
+// UploadFile обработчик загрузки исходного файла; возвращает метаданные загруженного файла
+func (h *Handler) UploadFile(c *gin.Context) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		abortWithError(c, errors.ErrInvalidArgument.Errorf("не передан файл: %w", err))
+		return
+	}
+	f, err := file.Open()
+	if err != nil {
+		abortWithError(c, fmt.Errorf("cannot open file: %w", err))
+		return
+	}
+	defer f.Close()
+
+	ctx := c.Request.Context()
+	object, id, err := h.service.CreateOne(ctx, f, file.Filename, file.Size, uuid.New().String())
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	object.Close()
+
+	metadata, err := h.service.GetMetaDataByID(ctx, id)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.Header("Location", fmt.Sprintf("/files/%d", id))
+	c.JSON(http.StatusCreated, metadata)
+}
+
+// jobRequest тело запроса на обработку файла; отсутствующие параметры принимают значения по умолчанию
+type jobRequest struct {
+	Params      *json.RawMessage `json:"params"`
+	ReuseResult *bool            `json:"reuse_result"`
+}
+
+// CreateJob обработчик постановки файла в обработку без ожидания результата.
+// Отвечает 202 с задачей, состояние которой отслеживается через GET /jobs/:id; задача из кэша сразу выполнена.
+func (h *Handler) CreateJob(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+	var req jobRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			abortWithError(c, errors.ErrInvalidArgument.Errorf("неверный формат запроса: %w", err))
+			return
+		}
+	}
+	params := schema.DefaultProcessingParams()
+	if req.Params != nil {
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			abortWithError(c, errors.ErrInvalidParams.Wrap(err))
+			return
+		}
+	}
+	useCache := req.ReuseResult == nil || *req.ReuseResult
+
+	job, err := h.service.StartJob(c.Request.Context(), id, params, useCache)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.Header("Location", fmt.Sprintf("/jobs/%d", job.ID))
+	c.JSON(http.StatusAccepted, job)
+}
+
+// DownloadJobResult обработчик скачивания результата выполненной задачи
+func (h *Handler) DownloadJobResult(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+	result, err := h.service.JobResult(c.Request.Context(), id)
+	if err != nil {
+		abortWithError(c, err)
 		return
 	}
+	h.streamProcessedObject(c, result.Artifact.ObjectKey, result.FileName)
 }
 
 // GetFileByID обработчик для получения файла по ID после обработки CV worker
@@ -95,193 +215,307 @@ func (h *Handler) GetFileByIDAsync(c *gin.Context) {
 	//}
 	file, err := c.FormFile("file")
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "no file received"})
+		abortWithError(c, errors.ErrInvalidArgument.Errorf("не передан файл: %w", err))
 		return
 	}
 
 	objectKey := uuid.New().String()
-	log.Printf("objectkey исходного файла: %s", objectKey)
+	slog.DebugContext(c.Request.Context(), "Ключ объекта исходного файла", slog.String("object_key", objectKey))
 
 	f, err := file.Open()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot open file"})
+		abortWithError(c, fmt.Errorf("cannot open file: %w", err))
 		return
 	}
 	defer f.Close()
 
+	// Параметры обработки разбираем до загрузки, чтобы не сохранять файл при неверном запросе
+	params, err := parseProcessingParams(c)
+	if err != nil {
+		abortWithError(c, errors.ErrInvalidParams.Wrap(err))
+		return
+	}
+	// По умолчанию используем кэш результатов, reuse_result=false заставляет обработать файл заново
+	useCache := true
+	if v := c.PostForm("reuse_result"); v != "" {
+		if useCache, err = strconv.ParseBool(v); err != nil {
+			abortWithError(c, errors.ErrInvalidArgument.Errorf("reuse_result: %w", err))
+			return
+		}
+	}
+
 	ctx := c.Request.Context()
-	_, id, err := h.service.CreateOne(&ctx, f, file.Size, minio2.FileDataType{
-		FileName: file.Filename,
-		Data:     nil, // <-- не читаем всё в память
-	}, file.Filename, file.Size, objectKey)
+	object, id, err := h.service.CreateOne(ctx, f, file.Filename, file.Size, objectKey)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot save file"})
+		abortWithError(c, err)
 		return
 	}
-	//defer object.Close()
+	object.Close()
+	ctx = logging.WithFileID(ctx, id)
 
-	// Получаем метаданные файла
-	metadata, err := h.service.GetMetaDataByID(&ctx, id)
+	result, err := h.service.ProcessFile(ctx, id, params, useCache)
+	if result != nil && result.Job != nil {
+		c.Writer.Header().Set("X-Job-ID", strconv.FormatInt(result.Job.ID, 10))
+		ctx = logging.WithJobID(ctx, result.Job.ID)
+	}
 	if err != nil {
-		c.JSON(http.StatusNotFound, errors.ErrorResponse{
-			Status:  http.StatusNotFound,
-			Error:   "Файл не найден или не готов",
-			Details: err,
-		})
+		slog.ErrorContext(ctx, "Ошибка обработки файла", logging.Err(err))
+		abortWithError(c, err)
 		return
 	}
 
-	// Подключаемся к RabbitMQ
-	conn, err := amqp.DialConfig(os.Getenv("RABBITMQ_URL"), amqp.Config{
-		Heartbeat: 10 * time.Minute, // Увеличить heartbeat
-	})
+	if result.Job.CacheHit {
+		c.Writer.Header().Set("X-Cache", "HIT")
+	} else {
+		c.Writer.Header().Set("X-Cache", "MISS")
+	}
+	h.streamProcessedObject(c, result.Artifact.ObjectKey, result.FileName)
+}
+
+// streamProcessedObject отдает клиенту обработанный объект из хранилища
+func (h *Handler) streamProcessedObject(c *gin.Context, objectKey string, fileName string) {
+	h.streamObject(c, objectKey, fileName, "application/x-ply")
+}
+
+// streamObject отдает клиенту объект из хранилища как вложение
+func (h *Handler) streamObject(c *gin.Context, objectKey string, fileName string, contentType string) {
+	object, err := h.service.GetOne(c.Request.Context(), objectKey)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
-			Status:  http.StatusInternalServerError,
-			Error:   "Не удалось подключиться к RabbitMQ",
-			Details: err,
-		})
+		abortWithError(c, err)
+		return
+	}
+	defer object.Close()
+	ctx := c.Request.Context()
+	stat, err := object.Stat()
+	if err != nil {
+		slog.ErrorContext(ctx, "Ошибка при получении метаданных объекта", slog.String("object_key", objectKey), logging.Err(err))
+		abortWithError(c, fmt.Errorf("cannot get object metadata: %w", err))
 		return
 	}
-	defer conn.Close()
+	slog.DebugContext(ctx, "Размер объекта", slog.String("object_key", objectKey), slog.Int64("size", stat.Size))
+	if stat.Size == 0 {
+		abortWithError(c, fmt.Errorf("объект %s пуст", objectKey))
+		return
+	}
+
+	c.Writer.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+	c.Writer.Header().Set("Content-Type", contentType)
+	c.Writer.Header().Set("Content-Length", fmt.Sprintf("%d", stat.Size))
 
-	ch, err := conn.Channel()
+	slog.DebugContext(ctx, "Начинаем передачу файла клиенту", slog.String("object_key", objectKey))
+	if _, err := io.Copy(c.Writer, object); err != nil {
+		slog.ErrorContext(ctx, "Ошибка при передаче файла", slog.String("object_key", objectKey), logging.Err(err))
+		abortWithError(c, fmt.Errorf("stream error: %w", err))
+		return
+	}
+	slog.DebugContext(ctx, "Файл передан клиенту", slog.String("object_key", objectKey))
+}
+
+// GetArtifacts обработчик для получения списка производных объектов исходного файла
+func (h *Handler) GetArtifacts(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	artifacts, err := h.service.GetArtifacts(ctx, id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
-			Status:  http.StatusInternalServerError,
-			Error:   "Ошибка канала RabbitMQ",
-			Details: err,
-		})
+		abortWithError(c, err)
 		return
 	}
-	defer ch.Close()
 
-	err = ch.ExchangeDeclare(
-		"pcd_files", // имя exchange
-		"fanout",    // тип
-		true,        // durable
-		false,       // autoDelete
-		false,       // internal
-		false,       // noWait
-		nil,
-	)
+	c.JSON(http.StatusOK, artifacts)
+}
+
+// GetJob обработчик для получения состояния задачи обработки
+func (h *Handler) GetJob(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	job, err := h.service.GetJob(ctx, id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
-			Status:  http.StatusInternalServerError,
-			Error:   "Ошибка объявления exchange",
-			Details: err,
-		})
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// InvalidateResultCache обработчик для сброса кэша результатов по версии модели
+func (h *Handler) InvalidateResultCache(c *gin.Context) {
+	modelVersion := c.Query("model_version")
+	if modelVersion == "" {
+		abortWithError(c, errors.ErrInvalidArgument.Errorf("не указана версия модели"))
+		return
+	}
+
+	ctx := c.Request.Context()
+	n, err := h.service.InvalidateResultCache(ctx, modelVersion)
+	if err != nil {
+		abortWithError(c, err)
 		return
 	}
 
-	// Создаём уникальную reply queue
-	replyQueue, _ := ch.QueueDeclare("", false, true, true, false, nil)
-	corrID := uuid.New().String()
+	c.JSON(http.StatusOK, gin.H{"model_version": modelVersion, "invalidated": n})
+}
+
+// Reconcile обработчик для внеочередной сверки записей файлов с хранилищем
+func (h *Handler) Reconcile(c *gin.Context) {
+	report, err := h.service.Reconcile(c.Request.Context())
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
 
-	msgs, _ := ch.Consume(replyQueue.Name, "", true, false, false, false, nil)
+	c.JSON(http.StatusOK, report)
+}
 
-	// Отправляем сообщение с метаданными
-	meta := map[string]string{
-		"id":        fmt.Sprintf("%d", id),
-		"filename":  metadata.OriginalFilename,
-		"minio_key": metadata.ObjectKey,
+// GetFile обработчик для получения метаданных исходного файла
+func (h *Handler) GetFile(c *gin.Context) {
+	metadata, ok := h.fileFromParam(c)
+	if !ok {
+		return
 	}
-	body, _ := json.Marshal(meta)
+	c.JSON(http.StatusOK, metadata)
+}
 
-	err = ch.Publish(
-		"pcd_files", // exchange
-		"",          // routingKey пустой для fanout
-		false,
-		false,
-		amqp.Publishing{
-			ContentType:   "application/json",
-			Body:          body,
-			ReplyTo:       replyQueue.Name,
-			CorrelationId: corrID,
-		},
-	)
+// ListFiles обработчик поиска файлов: name — подстрока имени, sha256 — содержимое, created_after и created_before —
+// границы времени загрузки в RFC 3339, project — проект (только для администраторов), limit и offset — страница.
+// Общее число найденных файлов возвращается в заголовке X-Total-Count.
+func (h *Handler) ListFiles(c *gin.Context) {
+	filter, err := parseFileFilter(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
-			Status:  http.StatusInternalServerError,
-			Error:   "Не удалось отправить сообщение в exchange",
-			Details: err,
-		})
+		abortWithError(c, errors.ErrInvalidArgument.Wrap(err))
 		return
 	}
+	files, total, err := h.service.ListFiles(c.Request.Context(), filter)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+	c.JSON(http.StatusOK, files)
+}
 
-	// Ждём ответа от воркера
-	timeout := time.After(600 * time.Second)
-	for {
-		select {
-		case msg := <-msgs:
-			if msg.CorrelationId == corrID {
-				var response map[string]string
-				err := json.Unmarshal(msg.Body, &response)
-				if err != nil {
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot unmarshal response"})
-				}
-				log.Println(response)
-				processedMinioKey := response["minio_key"]
-				processedFileName := response["filename"]
-				//processedFileSize := response["minio_key"]
-
-				object, err := h.service.GetOne(f, file.Size, minio2.FileDataType{
-					FileName: processedFileName,
-					Data:     nil, // <-- не читаем всё в память
-				}, processedMinioKey)
-				if err != nil {
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot save file"})
-					return
-				}
-				defer object.Close()
-				stat, err := object.Stat()
-				if err != nil {
-					log.Printf("Ошибка при получении метаданных объекта: %v", err)
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot get object metadata: " + err.Error()})
-					return
-				}
-				log.Printf("Размер объекта: %d байт", stat.Size)
-				if stat.Size == 0 {
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "object is empty"})
-					return
-				}
-
-				c.Writer.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", processedFileName))
-				c.Writer.Header().Set("Content-Type", "application/x-ply")
-				c.Writer.Header().Set("Content-Length", fmt.Sprintf("%d", stat.Size))
-
-				log.Println("Начинаем передачу файла клиенту")
-				if _, err := io.Copy(c.Writer, object); err != nil {
-					log.Printf("Ошибка при передаче файла: %v", err)
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "stream error: " + err.Error()})
-					return
-				}
-				log.Println("Файл успешно передан клиенту")
-				return
-				//if err != nil {
-				//	c.JSON(http.StatusInternalServerError, errors.ErrorResponse{
-				//		Status:  http.StatusGatewayTimeout,
-				//		Error:   "Timeout ожидания обработки файла",
-				//		Details: nil,
-				//	})
-				//}
-				//c.JSON(http.StatusOK, responses.SuccessResponse{
-				//	Status:  http.StatusOK,
-				//	Message: "File processed successfully",
-				//	ID:      id,
-				//	Data:    link,
-				//})
-				//return
+// parseFileFilter читает условия поиска файлов из строки запроса
+func parseFileFilter(c *gin.Context) (schema.FileFilter, error) {
+	filter := schema.FileFilter{
+		Project: c.Query("project"),
+		Name:    c.Query("name"),
+		SHA256:  c.Query("sha256"),
+	}
+	ints := map[string]*int{"limit": &filter.Limit, "offset": &filter.Offset}
+	for name, dst := range ints {
+		if v := c.Query(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return filter, fmt.Errorf("%s: %w", name, err)
 			}
-		case <-timeout:
-			c.JSON(http.StatusGatewayTimeout, errors.ErrorResponse{
-				Status:  http.StatusGatewayTimeout,
-				Error:   "Timeout ожидания обработки файла",
-				Details: nil,
-			})
-			return
+			*dst = n
+		}
+	}
+	times := map[string]**time.Time{"created_after": &filter.CreatedAfter, "created_before": &filter.CreatedBefore}
+	for name, dst := range times {
+		if v := c.Query(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return filter, fmt.Errorf("%s: %w", name, err)
+			}
+			*dst = &t
 		}
 	}
+	return filter, nil
+}
+
+// DownloadFile обработчик для скачивания исходного файла
+func (h *Handler) DownloadFile(c *gin.Context) {
+	metadata, ok := h.fileFromParam(c)
+	if !ok {
+		return
+	}
+	h.streamObject(c, metadata.ObjectKey, metadata.OriginalFilename, "application/octet-stream")
+}
+
+// DeleteFile обработчик для удаления исходного файла вместе с его задачами и результатами
+func (h *Handler) DeleteFile(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteFile(c.Request.Context(), id); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// fileFromParam возвращает метаданные файла по ID из пути или пишет ответ с ошибкой
+func (h *Handler) fileFromParam(c *gin.Context) (*schema.FileMetadata, bool) {
+	id, ok := idParam(c)
+	if !ok {
+		return nil, false
+	}
+
+	metadata, err := h.service.GetMetaDataByID(c.Request.Context(), id)
+	if err != nil {
+		abortWithError(c, err)
+		return nil, false
+	}
+	return metadata, true
+}
+
+// idParam разбирает ID записи из пути или прерывает запрос с ошибкой
+func idParam(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		abortWithError(c, errors.ErrInvalidArgument.Errorf("неверный формат ID %q", c.Param("id")))
+		return 0, false
+	}
+	return id, true
+}
+
+// parseProcessingParams читает необязательные параметры обработки из формы, подставляя значения по умолчанию
+func parseProcessingParams(c *gin.Context) (schema.ProcessingParams, error) {
+	params := schema.DefaultProcessingParams()
+
+	floats := map[string]*float64{
+		"threshold":                &params.Threshold,
+		"voxel_size":               &params.VoxelSize,
+		"edge_distance_threshold":  &params.EdgeDistanceThreshold,
+		"z_upper_static_threshold": &params.ZUpperStaticThreshold,
+		"ground_height_threshold":  &params.GroundHeightThreshold,
+	}
+	for name, dst := range floats {
+		if v := c.PostForm(name); v != "" {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return params, fmt.Errorf("%s: %w", name, err)
+			}
+			*dst = f
+		}
+	}
+	if v := c.PostForm("use_downsample"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return params, fmt.Errorf("use_downsample: %w", err)
+		}
+		params.UseDownsample = b
+	}
+	if v := c.PostForm("grid_divisions"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return params, fmt.Errorf("grid_divisions: %w", err)
+		}
+		params.GridDivisions = n
+	}
+
+	return params, nil
 }
 
 // StartRabbitWorker - удален, используется Python CV worker
@@ -0,0 +1,455 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"io"
+	"lct/internal/auth"
+	"lct/internal/domain/dto"
+	"lct/internal/repository/memory"
+	"lct/internal/repository/schema"
+	"lct/internal/service/usecase"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
+
+type testEnv struct {
+	repo    *memory.Repository
+	storage *memory.ObjectStorage
+	rabbit  *memory.RabbitClient
+	router  *gin.Engine
+	service *usecase.Service
+}
+
+const (
+	testBootstrapKey = "test-bootstrap-key-0123456789abcdef"
+	testJWTSecret    = "test-jwt-secret-0123456789abcdef0123"
+)
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	env := &testEnv{
+		repo:    memory.NewRepository(),
+		storage: memory.NewObjectStorage("testbucket"),
+	}
+	env.rabbit = memory.NewRabbitClient(env.storage)
+	env.router = gin.New()
+	env.service = usecase.NewService(env.repo, env.storage, env.rabbit, usecase.Config{
+		ModelVersion:      "test-model",
+		ProcessingTimeout: time.Second,
+		BootstrapAPIKey:   testBootstrapKey,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go env.service.RunOutboxRelay(ctx, 10*time.Millisecond)
+	go env.service.RunResultConsumer(ctx)
+	verifier, err := auth.NewJWTVerifier(auth.JWTConfig{Secret: testJWTSecret, Issuer: "test-issuer"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	NewMinioHandler(env.service, AuthConfig{JWT: verifier}, LimitsConfig{}).RegisterRoutes(env.router)
+	return env
+}
+
+// do выполняет запрос; если учетные данные не заданы, подставляет bootstrap ключ
+func (env *testEnv) do(req *http.Request) *httptest.ResponseRecorder {
+	if req.Header.Get("Authorization") == "" && req.Header.Get("X-API-Key") == "" {
+		req.Header.Set("X-API-Key", testBootstrapKey)
+	}
+	return env.serve(req)
+}
+
+// serve выполняет запрос как есть
+func (env *testEnv) serve(req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+	return w
+}
+
+// multipartRequest собирает запрос с файлом в поле file и дополнительными полями формы
+func multipartRequest(t *testing.T, url string, fileName string, content []byte, fields map[string]string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("file", fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write(content)
+	for k, v := range fields {
+		mw.WriteField(k, v)
+	}
+	mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, url, &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func TestHealthCheck(t *testing.T) {
+	env := newTestEnv(t)
+	w := env.do(httptest.NewRequest(http.MethodGet, "/health", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+}
+
+func TestReadyzReportsEachDependency(t *testing.T) {
+	env := newTestEnv(t)
+	readyz := func() (int, dto.Readiness) {
+		t.Helper()
+		w := env.serve(httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		var res dto.Readiness
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Fatalf("body = %s: %v", w.Body, err)
+		}
+		return w.Code, res
+	}
+
+	code, res := readyz()
+	if code != http.StatusOK || res.Status != dto.ReadinessOK {
+		t.Fatalf("status = %d, body = %+v", code, res)
+	}
+	for _, name := range []string{"postgres", "storage", "rabbitmq"} {
+		if res.Checks[name].Status != dto.CheckOK {
+			t.Errorf("%s = %+v", name, res.Checks[name])
+		}
+	}
+
+	// Недоступная база и отсутствие CV worker'ов видны по отдельности
+	env.repo.FailOn("Ping", stderrors.New("connection refused"))
+	env.rabbit.Consumers = 0
+	code, res = readyz()
+	if code != http.StatusServiceUnavailable || res.Status != dto.ReadinessUnavailable {
+		t.Fatalf("status = %d, body = %+v", code, res)
+	}
+	if c := res.Checks["postgres"]; c.Status != dto.CheckFailed || c.Error != "connection refused" {
+		t.Errorf("postgres = %+v", c)
+	}
+	if c := res.Checks["rabbitmq"]; c.Status != dto.CheckFailed || c.Details["consumers"] != float64(0) {
+		t.Errorf("rabbitmq = %+v", c)
+	}
+	if c := res.Checks["storage"]; c.Status != dto.CheckOK {
+		t.Errorf("storage = %+v", c)
+	}
+
+	// Liveness не зависит от зависимостей
+	if w := env.serve(httptest.NewRequest(http.MethodGet, "/livez", nil)); w.Code != http.StatusOK {
+		t.Errorf("livez status = %d", w.Code)
+	}
+}
+
+func TestReadyzFailsWhileShuttingDown(t *testing.T) {
+	env := newTestEnv(t)
+	h := NewMinioHandler(env.service, AuthConfig{}, LimitsConfig{})
+	router := gin.New()
+	h.RegisterRoutes(router)
+	h.SetReady(false)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("readyz status = %d, want 503", w.Code)
+	}
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/livez", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("livez status = %d, want 200", w.Code)
+	}
+}
+
+func TestUploadReturnsStoredFile(t *testing.T) {
+	env := newTestEnv(t)
+	content := []byte("ply\nformat ascii 1.0\n")
+
+	w := env.do(multipartRequest(t, "/files/upload_file", "scan.ply", content, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+	if !bytes.Equal(w.Body.Bytes(), content) {
+		t.Errorf("body = %q, want %q", w.Body.Bytes(), content)
+	}
+	if got := w.Header().Get("Content-Disposition"); got != `attachment; filename="scan.ply"` {
+		t.Errorf("Content-Disposition = %q", got)
+	}
+
+	metadata, err := env.repo.GetMetaDataByID(nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata.OriginalFilename != "scan.ply" || metadata.SHA256 == "" {
+		t.Errorf("metadata = %+v", metadata)
+	}
+	if data, ok := env.storage.Data(metadata.ObjectKey); !ok || !bytes.Equal(data, content) {
+		t.Errorf("object %s not stored", metadata.ObjectKey)
+	}
+}
+
+func TestUploadWithoutFile(t *testing.T) {
+	env := newTestEnv(t)
+	req := httptest.NewRequest(http.MethodPost, "/files/upload_file", nil)
+	if w := env.do(req); w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", w.Code)
+	}
+}
+
+func TestUploadStorageFailure(t *testing.T) {
+	env := newTestEnv(t)
+	env.storage.FailOn("CreateOne", stderrors.New("minio is down"))
+
+	w := env.do(multipartRequest(t, "/files/upload_file", "scan.ply", []byte("data"), nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", w.Code)
+	}
+	if _, err := env.repo.GetMetaDataByID(nil, 1); err == nil {
+		t.Error("metadata saved for failed upload")
+	}
+}
+
+func TestUploadDeduplicatesIdenticalContent(t *testing.T) {
+	env := newTestEnv(t)
+	content := []byte("same scan")
+
+	for i := 0; i < 2; i++ {
+		if w := env.do(multipartRequest(t, "/files/upload_file", "scan.ply", content, nil)); w.Code != http.StatusOK {
+			t.Fatalf("upload %d: status = %d", i, w.Code)
+		}
+	}
+
+	first, _ := env.repo.GetMetaDataByID(nil, 1)
+	second, _ := env.repo.GetMetaDataByID(nil, 2)
+	if first.ObjectKey != second.ObjectKey {
+		t.Errorf("object keys differ: %s, %s", first.ObjectKey, second.ObjectKey)
+	}
+	if keys := env.storage.Keys(); len(keys) != 1 {
+		t.Errorf("stored objects = %v, want one", keys)
+	}
+	if n := env.repo.RefCount(first.ObjectKey); n != 2 {
+		t.Errorf("ref count = %d, want 2", n)
+	}
+}
+
+func TestDownloadProcessesFile(t *testing.T) {
+	env := newTestEnv(t)
+	content := []byte("point cloud")
+
+	w := env.do(multipartRequest(t, "/files/download", "scan.ply", content, map[string]string{"threshold": "0.5"}))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+	if !bytes.Equal(w.Body.Bytes(), content) {
+		t.Errorf("body = %q, want processed copy %q", w.Body.Bytes(), content)
+	}
+	if got := w.Header().Get("X-Cache"); got != "MISS" {
+		t.Errorf("X-Cache = %q, want MISS", got)
+	}
+	if got := w.Header().Get("Content-Type"); got != "application/x-ply" {
+		t.Errorf("Content-Type = %q", got)
+	}
+
+	messages := env.rabbit.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("published %d messages, want 1", len(messages))
+	}
+	var msg struct {
+		ID     string                  `json:"id"`
+		Params schema.ProcessingParams `json:"params"`
+	}
+	if err := json.Unmarshal(messages[0], &msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.ID != "1" || msg.Params.Threshold != 0.5 {
+		t.Errorf("message = %s", messages[0])
+	}
+
+	job, ok := env.repo.Job(1)
+	if !ok || job.Status != schema.JobStatusDone || job.CacheHit || job.ArtifactID == nil {
+		t.Errorf("job = %+v", job)
+	}
+
+	w = env.do(httptest.NewRequest(http.MethodGet, "/files/1/artifacts", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("artifacts status = %d", w.Code)
+	}
+	var artifacts []schema.Artifact
+	if err := json.Unmarshal(w.Body.Bytes(), &artifacts); err != nil {
+		t.Fatal(err)
+	}
+	if len(artifacts) != 1 || artifacts[0].Type != schema.ArtifactTypeProcessed || artifacts[0].Size != int64(len(content)) {
+		t.Errorf("artifacts = %+v", artifacts)
+	}
+}
+
+func TestDownloadUsesResultCache(t *testing.T) {
+	env := newTestEnv(t)
+	content := []byte("reference scan")
+
+	env.do(multipartRequest(t, "/files/download", "scan.ply", content, nil))
+	w := env.do(multipartRequest(t, "/files/download", "scan.ply", content, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+	if got := w.Header().Get("X-Cache"); got != "HIT" {
+		t.Errorf("X-Cache = %q, want HIT", got)
+	}
+	if n := len(env.rabbit.Messages()); n != 1 {
+		t.Errorf("published %d messages, want 1", n)
+	}
+
+	// Другие параметры или reuse_result=false требуют новой обработки
+	env.do(multipartRequest(t, "/files/download", "scan.ply", content, map[string]string{"threshold": "0.6"}))
+	env.do(multipartRequest(t, "/files/download", "scan.ply", content, map[string]string{"reuse_result": "false"}))
+	if n := len(env.rabbit.Messages()); n != 3 {
+		t.Errorf("published %d messages, want 3", n)
+	}
+}
+
+func TestDownloadWorkerError(t *testing.T) {
+	env := newTestEnv(t)
+	env.rabbit.Worker = func(body []byte) ([]byte, error) {
+		return []byte(`{"error": "CUDA out of memory"}`), nil
+	}
+
+	w := env.do(multipartRequest(t, "/files/download", "scan.ply", []byte("data"), nil))
+	if w.Code != http.StatusBadGateway {
+		t.Fatalf("status = %d, want 502", w.Code)
+	}
+	if job, _ := env.repo.Job(1); job.Status != schema.JobStatusFailed {
+		t.Errorf("job status = %s, want failed", job.Status)
+	}
+}
+
+func TestDownloadTimeout(t *testing.T) {
+	env := newTestEnv(t)
+	// Воркер не отвечает
+	env.rabbit.Worker = func(body []byte) ([]byte, error) {
+		return nil, stderrors.New("worker is down")
+	}
+
+	w := env.do(multipartRequest(t, "/files/download", "scan.ply", []byte("data"), nil))
+	if w.Code != http.StatusGatewayTimeout {
+		t.Fatalf("status = %d, want 504", w.Code)
+	}
+	if job, _ := env.repo.Job(1); job.Status != schema.JobStatusTimeout {
+		t.Errorf("job status = %s, want timeout", job.Status)
+	}
+}
+
+func TestDownloadInvalidParams(t *testing.T) {
+	env := newTestEnv(t)
+
+	for _, fields := range []map[string]string{
+		{"threshold": "abc"},
+		{"threshold": "1.5"},
+		{"threshold": "NaN"},
+		{"voxel_size": "Inf"},
+		{"edge_distance_threshold": "-Inf"},
+		{"ground_height_threshold": "nan"},
+		{"grid_divisions": "0"},
+		{"reuse_result": "maybe"},
+	} {
+		w := env.do(multipartRequest(t, "/files/download", "scan.ply", []byte("data"), fields))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%v: status = %d, want 400", fields, w.Code)
+		}
+	}
+	if n := len(env.rabbit.Messages()); n != 0 {
+		t.Errorf("published %d messages, want 0", n)
+	}
+}
+
+func TestGetArtifactsErrors(t *testing.T) {
+	env := newTestEnv(t)
+
+	if w := env.do(httptest.NewRequest(http.MethodGet, "/files/abc/artifacts", nil)); w.Code != http.StatusBadRequest {
+		t.Errorf("bad id: status = %d, want 400", w.Code)
+	}
+	if w := env.do(httptest.NewRequest(http.MethodGet, "/files/42/artifacts", nil)); w.Code != http.StatusNotFound {
+		t.Errorf("missing file: status = %d, want 404", w.Code)
+	}
+}
+
+func TestInvalidateResultCache(t *testing.T) {
+	env := newTestEnv(t)
+	content := []byte("reference scan")
+	env.do(multipartRequest(t, "/files/download", "scan.ply", content, nil))
+
+	w := env.do(httptest.NewRequest(http.MethodDelete, "/admin/cache?model_version=test-model", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+	body, _ := io.ReadAll(w.Body)
+	if !bytes.Contains(body, []byte(`"invalidated":1`)) {
+		t.Errorf("body = %s", body)
+	}
+
+	w = env.do(multipartRequest(t, "/files/download", "scan.ply", content, nil))
+	if got := w.Header().Get("X-Cache"); got != "MISS" {
+		t.Errorf("X-Cache after invalidation = %q, want MISS", got)
+	}
+}
+
+func TestListFilesSearchesWithinProject(t *testing.T) {
+	env := newTestEnv(t)
+	if err := env.service.CreateTenant(adminContext(), &schema.Tenant{ID: "alpha"}); err != nil {
+		t.Fatal(err)
+	}
+	alpha, err := env.service.CreateAPIKey(adminContext(), "alpha", auth.RoleViewer, "alpha")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"Street_01.pcd", "street_02.pcd", "park.pcd"} {
+		if w := env.do(multipartRequest(t, "/files", name, []byte(name), nil)); w.Code != http.StatusCreated {
+			t.Fatalf("upload %s: status = %d, body = %s", name, w.Code, w.Body)
+		}
+	}
+
+	list := func(req *http.Request) ([]schema.FileMetadata, string) {
+		t.Helper()
+		w := env.serve(req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, body = %s", req.URL, w.Code, w.Body)
+		}
+		var files []schema.FileMetadata
+		if err := json.Unmarshal(w.Body.Bytes(), &files); err != nil {
+			t.Fatal(err)
+		}
+		return files, w.Header().Get("X-Total-Count")
+	}
+
+	files, total := list(withKey(httptest.NewRequest(http.MethodGet, "/files?name=STREET&limit=1", nil), testBootstrapKey))
+	if total != "2" || len(files) != 1 || files[0].OriginalFilename != "street_02.pcd" {
+		t.Errorf("first page: total = %s, files = %+v", total, files)
+	}
+	files, _ = list(withKey(httptest.NewRequest(http.MethodGet, "/files?name=street&limit=1&offset=1", nil), testBootstrapKey))
+	if len(files) != 1 || files[0].OriginalFilename != "Street_01.pcd" {
+		t.Errorf("second page: files = %+v", files)
+	}
+	// Символы шаблона ищутся буквально
+	if files, total := list(withKey(httptest.NewRequest(http.MethodGet, "/files?name=%25", nil), testBootstrapKey)); total != "0" || len(files) != 0 {
+		t.Errorf("name=%%: total = %s, files = %+v", total, files)
+	}
+	// Файлы чужого проекта не видны, даже если клиент просит проект явно
+	if files, total := list(withKey(httptest.NewRequest(http.MethodGet, "/files?project=default", nil), alpha.Key)); total != "0" || len(files) != 0 {
+		t.Errorf("other project: total = %s, files = %+v", total, files)
+	}
+
+	for _, query := range []string{"limit=0x", "limit=501", "offset=-1", "created_after=yesterday"} {
+		if w := env.do(httptest.NewRequest(http.MethodGet, "/files?"+query, nil)); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", query, w.Code)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"lct/internal/auth"
+	"lct/internal/domain/errors"
+	"lct/internal/metrics"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// clientIdleTTL через сколько забывается корзина клиента без запросов; к этому времени она уже полна
+	clientIdleTTL = 10 * time.Minute
+	// pendingJobsRetryAfter через сколько предлагать повторить обработку, когда очередь CV worker'а заполнена
+	pendingJobsRetryAfter = 30 * time.Second
+	// minUploadBurst наименьший объем, который загрузка может прочитать без ожидания
+	minUploadBurst = 64 << 10
+)
+
+// LimitsConfig ограничения нагрузки на загрузку и обработку файлов
+type LimitsConfig struct {
+	RequestsPerSecond    float64 // Скорость пополнения корзины токенов клиента; 0 — без ограничения
+	Burst                int     // Сколько запросов клиент может сделать подряд
+	UploadBytesPerSecond int64   // Общая пропускная способность загрузок экземпляра; 0 — без ограничения
+}
+
+// limiters ограничители нагрузки обработчика
+type limiters struct {
+	clients *clientLimiter
+	upload  *rate.Limiter
+}
+
+func newLimiters(cfg LimitsConfig) limiters {
+	var l limiters
+	if cfg.RequestsPerSecond > 0 {
+		l.clients = newClientLimiter(rate.Limit(cfg.RequestsPerSecond), max(cfg.Burst, 1))
+	}
+	if cfg.UploadBytesPerSecond > 0 {
+		burst := int(min(max(cfg.UploadBytesPerSecond, minUploadBurst), math.MaxInt32))
+		l.upload = rate.NewLimiter(rate.Limit(cfg.UploadBytesPerSecond), burst)
+	}
+	return l
+}
+
+// LimitUploads ограничивает частоту загрузок и обработок каждого клиента корзиной токенов
+// и делит между загрузками общую пропускную способность экземпляра.
+// Клиент определяется по API ключу или субъекту JWT, без аутентификации — по IP.
+func (h *Handler) LimitUploads(c *gin.Context) {
+	if h.limits.clients != nil {
+		if ok, retryAfter := h.limits.clients.allow(clientKey(c), time.Now()); !ok {
+			metrics.RateLimited.WithLabelValues(metrics.ReasonClientRate).Inc()
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			abortWithError(c, errors.ErrRateLimited.Errorf("превышен лимит запросов клиента"))
+			return
+		}
+	}
+	if h.limits.upload != nil && c.Request.Body != nil {
+		c.Request.Body = &throttledReader{ctx: c.Request.Context(), r: c.Request.Body, limiter: h.limits.upload}
+	}
+	c.Next()
+}
+
+// clientKey ключ корзины токенов клиента
+func clientKey(c *gin.Context) string {
+	if p := Principal(c); p != nil && p.Method != auth.MethodNone {
+		return p.Method + ":" + p.Subject
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// clientLimiter корзины токенов клиентов. Корзины, которыми давно не пользовались, удаляются,
+// чтобы перебор ключей или адресов не занимал память.
+type clientLimiter struct {
+	mu        sync.Mutex
+	limit     rate.Limit
+	burst     int
+	clients   map[string]*clientBucket
+	lastSweep time.Time
+}
+
+type clientBucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+func newClientLimiter(limit rate.Limit, burst int) *clientLimiter {
+	return &clientLimiter{
+		limit:   limit,
+		burst:   burst,
+		clients: make(map[string]*clientBucket),
+	}
+}
+
+// allow берет токен из корзины клиента; если токена нет, возвращает, через сколько он появится
+func (l *clientLimiter) allow(key string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) > clientIdleTTL {
+		for k, b := range l.clients {
+			if now.Sub(b.lastSeen) > clientIdleTTL {
+				delete(l.clients, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	b, ok := l.clients[key]
+	if !ok {
+		b = &clientBucket{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.clients[key] = b
+	}
+	b.lastSeen = now
+
+	r := b.limiter.ReserveN(now, 1)
+	if delay := r.DelayFrom(now); delay > 0 {
+		r.CancelAt(now)
+		return false, delay
+	}
+	return true, 0
+}
+
+// throttledReader читает тело запроса не быстрее, чем позволяет общий ограничитель пропускной способности
+type throttledReader struct {
+	ctx     context.Context
+	r       io.ReadCloser
+	limiter *rate.Limiter
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	if burst := t.limiter.Burst(); len(p) > burst {
+		p = p[:burst]
+	}
+	n, err := t.r.Read(p)
+	if n > 0 {
+		start := time.Now()
+		if werr := t.limiter.WaitN(t.ctx, n); werr != nil {
+			return n, werr
+		}
+		if waited := time.Since(start); waited > time.Millisecond {
+			metrics.UploadThrottleSeconds.Add(waited.Seconds())
+		}
+	}
+	return n, err
+}
+
+func (t *throttledReader) Close() error {
+	return t.r.Close()
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"lct/internal/auth"
+	"lct/internal/repository/schema"
+	"lct/internal/service/usecase"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+)
+
+func TestUploadsAreRateLimitedPerClient(t *testing.T) {
+	env := newTestEnv(t)
+	env.router = gin.New()
+	NewMinioHandler(env.service, AuthConfig{}, LimitsConfig{RequestsPerSecond: 0.01, Burst: 2}).RegisterRoutes(env.router)
+
+	for i := 0; i < 2; i++ {
+		if w := env.do(multipartRequest(t, "/files/upload_file", "scan.ply", []byte("scan"), nil)); w.Code != http.StatusOK {
+			t.Fatalf("upload %d: status = %d, body = %s", i, w.Code, w.Body)
+		}
+	}
+	w := env.do(multipartRequest(t, "/files/upload_file", "scan.ply", []byte("scan"), nil))
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("over limit: status = %d, want 429", w.Code)
+	}
+	if retry, err := strconv.Atoi(w.Header().Get("Retry-After")); err != nil || retry < 1 || retry > 100 {
+		t.Errorf("Retry-After = %q", w.Header().Get("Retry-After"))
+	}
+
+	// У другого ключа своя корзина, а чтение не ограничивается
+	created, err := env.service.CreateAPIKey(adminContext(), "other", auth.RoleOperator, auth.DefaultProject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := env.serve(withKey(multipartRequest(t, "/files/upload_file", "scan.ply", []byte("scan"), nil), created.Key)); w.Code != http.StatusOK {
+		t.Errorf("upload with another key: status = %d", w.Code)
+	}
+	if w := env.do(httptest.NewRequest(http.MethodGet, "/files/1", nil)); w.Code != http.StatusOK {
+		t.Errorf("metadata request: status = %d", w.Code)
+	}
+}
+
+func TestProcessingIsRejectedWhenQueueIsFull(t *testing.T) {
+	env := newTestEnv(t)
+	env.service = usecase.NewService(env.repo, env.storage, env.rabbit, usecase.Config{
+		ModelVersion:      "test-model",
+		ProcessingTimeout: time.Second,
+		BootstrapAPIKey:   testBootstrapKey,
+		MaxPendingJobs:    1,
+	})
+	env.router = gin.New()
+	NewMinioHandler(env.service, AuthConfig{}, LimitsConfig{}).RegisterRoutes(env.router)
+
+	// Задача, которую CV worker еще не забрал
+	_, fileID, err := env.service.CreateOne(adminContext(), strings.NewReader("queued"), "queued.ply", 6, "queued")
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := &schema.Job{FileID: fileID, CorrelationID: "queued", Project: auth.DefaultProject}
+	if _, err := env.repo.EnqueueJob(context.Background(), job, &schema.OutboxMessage{CorrelationID: "queued"}); err != nil {
+		t.Fatal(err)
+	}
+
+	w := env.do(multipartRequest(t, "/files/download", "scan.ply", []byte("scan"), nil))
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429, body = %s", w.Code, w.Body)
+	}
+	if got := w.Header().Get("Retry-After"); got != "30" {
+		t.Errorf("Retry-After = %q, want 30", got)
+	}
+	if n := len(env.rabbit.Messages()); n != 0 {
+		t.Errorf("published %d messages, want none", n)
+	}
+
+	w = env.serve(httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `lct_rate_limited_requests_total{reason="pending_jobs"}`) {
+		t.Errorf("metrics: status = %d, body does not contain rejected requests counter", w.Code)
+	}
+}
+
+func TestThrottledReaderLimitsBandwidth(t *testing.T) {
+	content := bytes.Repeat([]byte("x"), 320<<10)
+	r := &throttledReader{
+		ctx:     context.Background(),
+		r:       io.NopCloser(bytes.NewReader(content)),
+		limiter: rate.NewLimiter(rate.Limit(1<<20), 64<<10),
+	}
+
+	start := time.Now()
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Fatal("content changed while throttling")
+	}
+	// Сверх первых 64 KiB без ожидания читается 256 KiB со скоростью 1 MiB/s
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("read took %s, want about 250ms", elapsed)
+	}
+}
+
+func TestClientLimiterForgetsIdleClients(t *testing.T) {
+	l := newClientLimiter(rate.Limit(1), 1)
+	now := time.Now()
+	if ok, _ := l.allow("a", now); !ok {
+		t.Fatal("first request was rejected")
+	}
+	if ok, retry := l.allow("a", now); ok || retry <= 0 {
+		t.Fatalf("second request: allowed = %v, retry = %s", ok, retry)
+	}
+
+	l.allow("b", now.Add(2*clientIdleTTL))
+	if _, ok := l.clients["a"]; ok || len(l.clients) != 1 {
+		t.Errorf("clients = %v, want only b", l.clients)
+	}
+}
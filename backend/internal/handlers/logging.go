@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"lct/internal/logging"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	// requestIDHeader заголовок с ID запроса; ID клиента или прокси сохраняется, иначе создается новый
+	requestIDHeader = "X-Request-ID"
+	// maxRequestIDLength предел длины чужого ID, чтобы он не раздувал каждую запись лога
+	maxRequestIDLength = 128
+)
+
+// RequestID назначает запросу ID, возвращает его в X-Request-ID и кладет в контекст запроса,
+// чтобы ID попал во все записи лога обработчика, сервиса и хранилищ.
+func RequestID(c *gin.Context) {
+	id := c.GetHeader(requestIDHeader)
+	if !validRequestID(id) {
+		id = uuid.New().String()
+	}
+	c.Header(requestIDHeader, id)
+	c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), id))
+	c.Next()
+}
+
+// validRequestID принимает непустой ID разумной длины из печатных ASCII символов
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// LogRequests пишет запись о каждом запросе: ошибки сервера — с уровнем error, ошибки клиента — warn, остальное — info
+func LogRequests(c *gin.Context) {
+	start := time.Now()
+	c.Next()
+
+	status := c.Writer.Status()
+	level := slog.LevelInfo
+	switch {
+	case status >= http.StatusInternalServerError:
+		level = slog.LevelError
+	case status >= http.StatusBadRequest:
+		level = slog.LevelWarn
+	}
+	attrs := []slog.Attr{
+		slog.String("method", c.Request.Method),
+		slog.String("path", c.Request.URL.Path),
+		slog.String("route", c.FullPath()),
+		slog.Int("status", status),
+		slog.Duration("duration", time.Since(start)),
+		slog.Int("bytes", c.Writer.Size()),
+		slog.String("client_ip", c.ClientIP()),
+	}
+	if p := Principal(c); p != nil {
+		attrs = append(attrs, slog.String("subject", p.Subject))
+	}
+	if len(c.Errors) > 0 {
+		attrs = append(attrs, slog.String(logging.KeyError, c.Errors.String()))
+	}
+	slog.LogAttrs(c.Request.Context(), level, "HTTP запрос", attrs...)
+}
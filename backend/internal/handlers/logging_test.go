@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"lct/internal/logging"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestIDIsEchoedOrGenerated(t *testing.T) {
+	env := newTestEnv(t)
+
+	tests := []struct {
+		name     string
+		incoming string
+		keep     bool
+	}{
+		{"client id", "client-req-17", true},
+		{"missing", "", false},
+		{"with spaces", "bad id", false},
+		{"too long", strings.Repeat("a", maxRequestIDLength+1), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/health", nil)
+			if tt.incoming != "" {
+				req.Header.Set(requestIDHeader, tt.incoming)
+			}
+			w := env.serve(req)
+
+			got := w.Header().Get(requestIDHeader)
+			if got == "" {
+				t.Fatal("response has no X-Request-ID")
+			}
+			if tt.keep && got != tt.incoming {
+				t.Errorf("X-Request-ID = %q, want %q", got, tt.incoming)
+			}
+			if !tt.keep && got == tt.incoming {
+				t.Errorf("invalid X-Request-ID %q was kept", got)
+			}
+		})
+	}
+}
+
+func TestProcessingLogsCarryRequestJobAndFileIDs(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, logging.Config{Level: "info", Format: logging.FormatJSON})
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := slog.Default()
+	slog.SetDefault(logger)
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	env := newTestEnv(t)
+	req := multipartRequest(t, "/files/download", "scan.ply", []byte("logged scan"), nil)
+	req.Header.Set(requestIDHeader, "trace-me")
+	if w := env.do(req); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+
+	var processed, access map[string]any
+	for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+		var rec map[string]any
+		if err := json.Unmarshal(line, &rec); err != nil {
+			t.Fatalf("log line is not JSON: %v: %s", err, line)
+		}
+		switch rec["msg"] {
+		case "Задача обработана":
+			processed = rec
+		case "HTTP запрос":
+			access = rec
+		}
+	}
+	if processed == nil || access == nil {
+		t.Fatalf("missing job or access record in log:\n%s", buf.String())
+	}
+	for _, key := range []string{logging.KeyRequestID, logging.KeyJobID, logging.KeyFileID} {
+		if processed[key] == nil {
+			t.Errorf("job record has no %s: %v", key, processed)
+		}
+	}
+	if processed[logging.KeyRequestID] != "trace-me" || access[logging.KeyRequestID] != "trace-me" {
+		t.Errorf("request_id not propagated: job %v, access %v", processed[logging.KeyRequestID], access[logging.KeyRequestID])
+	}
+}
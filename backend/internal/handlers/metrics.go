@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"lct/internal/metrics"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ObserveRequests считает запросы и их длительность по маршруту. Маршрут берется из шаблона (/files/:id),
+// чтобы ID не порождали новых рядов; запросы к неизвестным путям попадают в route="unmatched".
+func ObserveRequests(c *gin.Context) {
+	metrics.HTTPInFlight.Inc()
+	defer metrics.HTTPInFlight.Dec()
+	start := time.Now()
+
+	c.Next()
+
+	route := c.FullPath()
+	if route == "" {
+		route = "unmatched"
+	}
+	metrics.HTTPRequests.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+	metrics.HTTPDuration.WithLabelValues(c.Request.Method, route).Observe(time.Since(start).Seconds())
+}
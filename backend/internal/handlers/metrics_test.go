@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"lct/internal/metrics"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRequestsAreCountedByRouteTemplate(t *testing.T) {
+	env := newTestEnv(t)
+	notFound := metrics.HTTPRequests.WithLabelValues(http.MethodGet, "/files/:id", "404")
+	unmatched := metrics.HTTPRequests.WithLabelValues(http.MethodGet, "unmatched", "404")
+	before, beforeUnmatched := testutil.ToFloat64(notFound), testutil.ToFloat64(unmatched)
+	uploaded := testutil.ToFloat64(metrics.UploadBytes)
+
+	env.do(httptest.NewRequest(http.MethodGet, "/files/41", nil))
+	env.do(httptest.NewRequest(http.MethodGet, "/files/42", nil))
+	env.do(httptest.NewRequest(http.MethodGet, "/no/such/path", nil))
+	if w := env.do(multipartRequest(t, "/files/upload_file", "scan.ply", []byte("12345"), nil)); w.Code != http.StatusOK {
+		t.Fatalf("upload: status = %d", w.Code)
+	}
+
+	if got := testutil.ToFloat64(notFound) - before; got != 2 {
+		t.Errorf("GET /files/:id 404 counted %v times, want 2", got)
+	}
+	if got := testutil.ToFloat64(unmatched) - beforeUnmatched; got != 1 {
+		t.Errorf("unmatched requests counted %v times, want 1", got)
+	}
+	if got := testutil.ToFloat64(metrics.UploadBytes) - uploaded; got != 5 {
+		t.Errorf("upload bytes grew by %v, want 5", got)
+	}
+
+	w := env.serve(httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("metrics: status = %d", w.Code)
+	}
+	for _, name := range []string{
+		"lct_http_request_duration_seconds_bucket",
+		"lct_amqp_publish_total",
+		"lct_queue_messages",
+		"go_goroutines",
+	} {
+		if !strings.Contains(w.Body.String(), name) {
+			t.Errorf("metrics do not contain %s", name)
+		}
+	}
+}
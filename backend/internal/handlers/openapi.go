@@ -0,0 +1,13 @@
+package handlers
+
+import (
+	"lct/api"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OpenAPISpec отдает спецификацию OpenAPI
+func OpenAPISpec(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json", api.Spec)
+}
@@ -0,0 +1,312 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"lct/api"
+	"lct/internal/repository/schema"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+)
+
+// specServer адрес сервера из спецификации; запросы тестов строятся на нем, чтобы роутер спецификации их нашел
+const specServer = "http://localhost:8000"
+
+func init() {
+	openapi3filter.RegisterBodyDecoder("application/x-ply", openapi3filter.FileBodyDecoder)
+}
+
+func loadSpec(t *testing.T) *openapi3.T {
+	t.Helper()
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromData(api.Spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.Validate(loader.Context); err != nil {
+		t.Fatalf("invalid spec: %v", err)
+	}
+	return doc
+}
+
+func TestOpenAPISpecIsServed(t *testing.T) {
+	loadSpec(t)
+	env := newTestEnv(t)
+
+	w := env.serve(httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d", w.Code)
+	}
+	if !bytes.Equal(w.Body.Bytes(), api.Spec) {
+		t.Error("served spec differs from embedded one")
+	}
+}
+
+func TestOpenAPISpecCoversAllRoutes(t *testing.T) {
+	doc := loadSpec(t)
+	env := newTestEnv(t)
+
+	routes := map[string]bool{}
+	for _, r := range env.router.Routes() {
+		segments := strings.Split(r.Path, "/")
+		for i, s := range segments {
+			if strings.HasPrefix(s, ":") {
+				segments[i] = "{" + s[1:] + "}"
+			}
+		}
+		routes[r.Method+" "+strings.Join(segments, "/")] = true
+	}
+	operations := map[string]bool{}
+	for path, item := range doc.Paths.Map() {
+		for method := range item.Operations() {
+			operations[method+" "+path] = true
+		}
+	}
+
+	var missing, extra []string
+	for route := range routes {
+		if !operations[route] {
+			missing = append(missing, route)
+		}
+	}
+	for op := range operations {
+		if !routes[op] {
+			extra = append(extra, op)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(extra)
+	if len(missing) > 0 {
+		t.Errorf("routes missing from spec: %v", missing)
+	}
+	if len(extra) > 0 {
+		t.Errorf("spec operations without route: %v", extra)
+	}
+}
+
+// specCall запрос к обработчикам, проверяемый по спецификации
+type specCall struct {
+	method      string
+	path        string
+	body        []byte
+	contentType string
+	noAuth      bool
+	// Запрос заведомо не соответствует спецификации (например, нечисловой ID); проверяется только ответ
+	invalid bool
+	status  int
+}
+
+// specChecker выполняет запросы и проверяет запросы и ответы по спецификации
+type specChecker struct {
+	t      *testing.T
+	env    *testEnv
+	router routers.Router
+}
+
+func newSpecChecker(t *testing.T, env *testEnv) *specChecker {
+	t.Helper()
+	router, err := gorillamux.NewRouter(loadSpec(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &specChecker{t: t, env: env, router: router}
+}
+
+func (sc *specChecker) newRequest(call specCall) *http.Request {
+	req := httptest.NewRequest(call.method, specServer+call.path, bytes.NewReader(call.body))
+	if call.contentType != "" {
+		req.Header.Set("Content-Type", call.contentType)
+	}
+	return req
+}
+
+func (sc *specChecker) do(call specCall) *httptest.ResponseRecorder {
+	t := sc.t
+	t.Helper()
+	name := call.method + " " + call.path
+
+	req := sc.newRequest(call)
+	var w *httptest.ResponseRecorder
+	if call.noAuth {
+		w = sc.env.serve(req)
+	} else {
+		w = sc.env.do(req)
+	}
+	if w.Code != call.status {
+		t.Fatalf("%s: status = %d, want %d, body = %s", name, w.Code, call.status, w.Body)
+	}
+
+	// Тело исходного запроса уже прочитано обработчиком, поэтому проверяется его копия
+	vreq := sc.newRequest(call)
+	vreq.Header = req.Header.Clone()
+	route, pathParams, err := sc.router.FindRoute(vreq)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	ctx := context.Background()
+	input := &openapi3filter.RequestValidationInput{
+		Request:    vreq,
+		PathParams: pathParams,
+		Route:      route,
+		Options: &openapi3filter.Options{
+			AuthenticationFunc:    openapi3filter.NoopAuthenticationFunc,
+			IncludeResponseStatus: true,
+		},
+	}
+	if !call.invalid {
+		if err := openapi3filter.ValidateRequest(ctx, input); err != nil {
+			t.Errorf("%s: request does not match spec: %v", name, err)
+		}
+	}
+	err = openapi3filter.ValidateResponse(ctx, &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: input,
+		Status:                 w.Code,
+		Header:                 w.Header(),
+		Body:                   io.NopCloser(bytes.NewReader(w.Body.Bytes())),
+		Options:                input.Options,
+	})
+	if err != nil {
+		t.Errorf("%s: response does not match spec: %v", name, err)
+	}
+	return w
+}
+
+// upload собирает multipart тело с файлом и дополнительными полями формы
+func upload(t *testing.T, content []byte, fields map[string]string) ([]byte, string) {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("file", "scan.pcd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write(content)
+	for k, v := range fields {
+		mw.WriteField(k, v)
+	}
+	mw.Close()
+	return body.Bytes(), mw.FormDataContentType()
+}
+
+func waitJobStatus(t *testing.T, env *testEnv, id int64, status string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if job, ok := env.repo.Job(id); ok && job.Status == status {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	job, _ := env.repo.Job(id)
+	t.Fatalf("job %d: status = %s, want %s", id, job.Status, status)
+}
+
+func TestHandlersConformToOpenAPISpec(t *testing.T) {
+	env := newTestEnv(t)
+	sc := newSpecChecker(t, env)
+	const jsonType = "application/json"
+
+	sc.do(specCall{method: http.MethodGet, path: "/health", noAuth: true, status: http.StatusOK})
+	sc.do(specCall{method: http.MethodGet, path: "/livez", noAuth: true, status: http.StatusOK})
+	sc.do(specCall{method: http.MethodGet, path: "/readyz", noAuth: true, status: http.StatusOK})
+	env.rabbit.Consumers = 0
+	sc.do(specCall{method: http.MethodGet, path: "/readyz", noAuth: true, status: http.StatusServiceUnavailable})
+	env.rabbit.Consumers = 1
+	sc.do(specCall{method: http.MethodGet, path: "/metrics", noAuth: true, status: http.StatusOK})
+	sc.do(specCall{method: http.MethodGet, path: "/openapi.json", noAuth: true, status: http.StatusOK})
+
+	body, contentType := upload(t, []byte("point cloud"), nil)
+	w := sc.do(specCall{method: http.MethodPost, path: "/files", body: body, contentType: contentType, status: http.StatusCreated})
+	var file schema.FileMetadata
+	if err := json.Unmarshal(w.Body.Bytes(), &file); err != nil {
+		t.Fatal(err)
+	}
+	filePath := "/files/" + strconv.Itoa(file.ID)
+	sc.do(specCall{method: http.MethodGet, path: filePath, status: http.StatusOK})
+	sc.do(specCall{method: http.MethodGet, path: "/files?name=SCAN&limit=10&created_after=2000-01-01T00:00:00Z", status: http.StatusOK})
+	sc.do(specCall{method: http.MethodGet, path: filePath + "/download", status: http.StatusOK})
+
+	w = sc.do(specCall{method: http.MethodPost, path: filePath + "/jobs", body: []byte(`{"params": {"threshold": 0.5}}`),
+		contentType: jsonType, status: http.StatusAccepted})
+	var job schema.Job
+	if err := json.Unmarshal(w.Body.Bytes(), &job); err != nil {
+		t.Fatal(err)
+	}
+	waitJobStatus(t, env, job.ID, schema.JobStatusDone)
+	jobPath := "/jobs/" + strconv.FormatInt(job.ID, 10)
+	sc.do(specCall{method: http.MethodGet, path: jobPath, status: http.StatusOK})
+	sc.do(specCall{method: http.MethodGet, path: jobPath + "/result", status: http.StatusOK})
+	sc.do(specCall{method: http.MethodGet, path: filePath + "/artifacts", status: http.StatusOK})
+	// Пустое тело — параметры по умолчанию
+	sc.do(specCall{method: http.MethodPost, path: filePath + "/jobs", status: http.StatusAccepted})
+
+	body, contentType = upload(t, []byte("another cloud"), nil)
+	sc.do(specCall{method: http.MethodPost, path: "/files/upload_file", body: body, contentType: contentType, status: http.StatusOK})
+	body, contentType = upload(t, []byte("third cloud"), map[string]string{"threshold": "0.5", "reuse_result": "false"})
+	sc.do(specCall{method: http.MethodPost, path: "/files/download", body: body, contentType: contentType, status: http.StatusOK})
+
+	sc.do(specCall{method: http.MethodGet, path: "/usage", status: http.StatusOK})
+	sc.do(specCall{method: http.MethodPost, path: "/admin/tenants", body: []byte(`{"id": "gamma", "monthly_job_quota": 10}`),
+		contentType: jsonType, status: http.StatusCreated})
+	sc.do(specCall{method: http.MethodGet, path: "/admin/tenants", status: http.StatusOK})
+	sc.do(specCall{method: http.MethodGet, path: "/admin/tenants/gamma", status: http.StatusOK})
+	sc.do(specCall{method: http.MethodPut, path: "/admin/tenants/gamma", body: []byte(`{"storage_quota_bytes": 1024}`),
+		contentType: jsonType, status: http.StatusOK})
+	sc.do(specCall{method: http.MethodGet, path: "/admin/tenants/gamma/usage", status: http.StatusOK})
+
+	w = sc.do(specCall{method: http.MethodPost, path: "/admin/api-keys", body: []byte(`{"name": "ci", "role": "operator", "project": "gamma"}`),
+		contentType: jsonType, status: http.StatusCreated})
+	var key struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &key); err != nil {
+		t.Fatal(err)
+	}
+	sc.do(specCall{method: http.MethodGet, path: "/admin/api-keys", status: http.StatusOK})
+	sc.do(specCall{method: http.MethodDelete, path: "/admin/api-keys/" + strconv.FormatInt(key.ID, 10), status: http.StatusNoContent})
+
+	sc.do(specCall{method: http.MethodDelete, path: "/admin/cache?model_version=test-model", status: http.StatusOK})
+	sc.do(specCall{method: http.MethodPost, path: "/admin/reconcile", status: http.StatusOK})
+	sc.do(specCall{method: http.MethodDelete, path: filePath, status: http.StatusNoContent})
+
+	// Ошибки
+	sc.do(specCall{method: http.MethodGet, path: "/jobs/abc", invalid: true, status: http.StatusBadRequest})
+	sc.do(specCall{method: http.MethodGet, path: "/jobs/404", status: http.StatusNotFound})
+	sc.do(specCall{method: http.MethodGet, path: jobPath, noAuth: true, status: http.StatusUnauthorized})
+	sc.do(specCall{method: http.MethodPost, path: "/files", invalid: true, status: http.StatusBadRequest})
+	sc.do(specCall{method: http.MethodGet, path: "/files?limit=1000", invalid: true, status: http.StatusBadRequest})
+	sc.do(specCall{method: http.MethodPost, path: "/admin/tenants", body: []byte(`{"id": "gamma"}`),
+		contentType: jsonType, status: http.StatusConflict})
+}
+
+func TestJobResultOfFailedJobConformsToSpec(t *testing.T) {
+	env := newTestEnv(t)
+	env.rabbit.Worker = func(body []byte) ([]byte, error) {
+		return []byte(`{"error": "CUDA out of memory"}`), nil
+	}
+	sc := newSpecChecker(t, env)
+
+	body, contentType := upload(t, []byte("point cloud"), nil)
+	sc.do(specCall{method: http.MethodPost, path: "/files", body: body, contentType: contentType, status: http.StatusCreated})
+	sc.do(specCall{method: http.MethodPost, path: "/files/1/jobs", status: http.StatusAccepted})
+	waitJobStatus(t, env, 1, schema.JobStatusFailed)
+
+	sc.do(specCall{method: http.MethodGet, path: "/jobs/1", status: http.StatusOK})
+	w := sc.do(specCall{method: http.MethodGet, path: "/jobs/1/result", status: http.StatusConflict})
+	if resp := decodeError(t, w); resp.Code != "job_not_done" {
+		t.Errorf("code = %q, want job_not_done", resp.Code)
+	}
+}
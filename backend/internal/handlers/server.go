@@ -1,19 +1,60 @@
 package handlers
 
 import (
+	"lct/internal/metrics"
+
 	"github.com/gin-gonic/gin"
 )
 
+// RegisterProbes регистрирует общие middleware, проверки живости и готовности и метрики.
+// Процессу worker, который не обслуживает API, достаточно только их.
+func (h *Handler) RegisterProbes(router *gin.Engine) {
+	router.Use(RequestID, ObserveRequests, TraceRequests, LogRequests, HandleErrors)
+	router.GET("/livez", h.Livez)
+	router.GET("/readyz", h.Readyz)
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
+}
+
 // RegisterRoutes - метод регистрации всех роутов в системе
 func (h *Handler) RegisterRoutes(router *gin.Engine) {
+	h.RegisterProbes(router)
 	router.GET("/health", h.HealthCheck)
+	router.GET("/openapi.json", OpenAPISpec)
+
+	// Все остальные эндпоинты требуют аутентификации
+	api := router.Group("/", h.Authenticate)
 
 	// Здесь мы обозначили все эндпоинты системы с соответствующими хендлерами
-	minioRoutes := router.Group("/files")
+	minioRoutes := api.Group("/files")
 	{
-		minioRoutes.POST("/upload_file", h.CreateOne)
-		minioRoutes.POST("/download", h.GetFileByIDAsync)
+		minioRoutes.POST("", h.LimitUploads, h.UploadFile)
+		minioRoutes.GET("", h.ListFiles)
+		minioRoutes.POST("/upload_file", h.LimitUploads, h.CreateOne)
+		minioRoutes.POST("/download", h.LimitUploads, h.GetFileByIDAsync)
+		minioRoutes.GET("/:id", h.GetFile)
+		minioRoutes.GET("/:id/download", h.DownloadFile)
+		minioRoutes.DELETE("/:id", h.DeleteFile)
+		minioRoutes.GET("/:id/artifacts", h.GetArtifacts)
+		minioRoutes.POST("/:id/jobs", h.LimitUploads, h.CreateJob)
 
 	}
 
+	api.GET("/jobs/:id", h.GetJob)
+	api.GET("/jobs/:id/result", h.DownloadJobResult)
+	api.GET("/usage", h.GetUsage)
+
+	adminRoutes := api.Group("/admin")
+	{
+		adminRoutes.DELETE("/cache", h.InvalidateResultCache)
+		adminRoutes.POST("/reconcile", h.Reconcile)
+		adminRoutes.POST("/api-keys", h.CreateAPIKey)
+		adminRoutes.GET("/api-keys", h.ListAPIKeys)
+		adminRoutes.DELETE("/api-keys/:id", h.RevokeAPIKey)
+		adminRoutes.POST("/tenants", h.CreateTenant)
+		adminRoutes.GET("/tenants", h.ListTenants)
+		adminRoutes.GET("/tenants/:id", h.GetTenant)
+		adminRoutes.PUT("/tenants/:id", h.UpdateTenant)
+		adminRoutes.GET("/tenants/:id/usage", h.GetTenantUsage)
+	}
+
 }
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"lct/internal/domain/errors"
+	"lct/internal/repository/schema"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// secondsUntilNextPeriod сколько секунд осталось до начала следующего календарного месяца по UTC, когда квота обработок обновится
+func secondsUntilNextPeriod(now time.Time) int {
+	now = now.UTC()
+	next := time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+	return int(next.Sub(now).Seconds()) + 1
+}
+
+// CreateTenant обработчик регистрации арендатора
+func (h *Handler) CreateTenant(c *gin.Context) {
+	var tenant schema.Tenant
+	if err := c.ShouldBindJSON(&tenant); err != nil {
+		abortWithError(c, errors.ErrInvalidArgument.Errorf("неверный формат запроса: %w", err))
+		return
+	}
+
+	if err := h.service.CreateTenant(c.Request.Context(), &tenant); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, tenant)
+}
+
+// UpdateTenant обработчик изменения настроек арендатора; настройки заменяются целиком
+func (h *Handler) UpdateTenant(c *gin.Context) {
+	var tenant schema.Tenant
+	if err := c.ShouldBindJSON(&tenant); err != nil {
+		abortWithError(c, errors.ErrInvalidArgument.Errorf("неверный формат запроса: %w", err))
+		return
+	}
+	tenant.ID = c.Param("id")
+
+	if err := h.service.UpdateTenant(c.Request.Context(), &tenant); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tenant)
+}
+
+// GetTenant обработчик получения настроек арендатора
+func (h *Handler) GetTenant(c *gin.Context) {
+	tenant, err := h.service.GetTenant(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tenant)
+}
+
+// ListTenants обработчик получения списка арендаторов
+func (h *Handler) ListTenants(c *gin.Context) {
+	tenants, err := h.service.ListTenants(c.Request.Context())
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tenants)
+}
+
+// GetUsage обработчик получения потребления арендатора клиента
+func (h *Handler) GetUsage(c *gin.Context) {
+	h.tenantUsage(c, "")
+}
+
+// GetTenantUsage обработчик получения потребления арендатора по ID
+func (h *Handler) GetTenantUsage(c *gin.Context) {
+	h.tenantUsage(c, c.Param("id"))
+}
+
+func (h *Handler) tenantUsage(c *gin.Context, id string) {
+	usage, err := h.service.TenantUsage(c.Request.Context(), id)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, usage)
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"lct/internal/repository/schema"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestTenantAdministration(t *testing.T) {
+	env := newTestEnv(t)
+
+	body := `{"id": "gamma", "prefix": "gamma", "routing_key": "tenant.gamma", "storage_quota_bytes": 1024}`
+	w := env.do(httptest.NewRequest(http.MethodPost, "/admin/tenants", bytes.NewBufferString(body)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: status = %d, body = %s", w.Code, w.Body)
+	}
+	var created schema.Tenant
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	if created.Prefix != "gamma/" || created.RoutingKey != "tenant.gamma" || created.StorageQuotaBytes != 1024 {
+		t.Errorf("tenant = %+v", created)
+	}
+
+	w = env.do(httptest.NewRequest(http.MethodPost, "/admin/tenants", bytes.NewBufferString(body)))
+	if w.Code != http.StatusConflict {
+		t.Errorf("duplicate: status = %d, want 409", w.Code)
+	}
+	w = env.do(httptest.NewRequest(http.MethodPost, "/admin/tenants", bytes.NewBufferString(`{"id": "Bad ID"}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("invalid id: status = %d, want 400", w.Code)
+	}
+	w = env.do(httptest.NewRequest(http.MethodPut, "/admin/tenants/missing", bytes.NewBufferString(`{}`)))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("update missing: status = %d, want 404", w.Code)
+	}
+
+	w = env.do(httptest.NewRequest(http.MethodGet, "/admin/tenants", nil))
+	var tenants []schema.Tenant
+	if err := json.Unmarshal(w.Body.Bytes(), &tenants); err != nil {
+		t.Fatal(err)
+	}
+	if len(tenants) != 2 {
+		t.Errorf("tenants = %+v, want default and gamma", tenants)
+	}
+}
+
+func TestUsageAndQuotasOverHTTP(t *testing.T) {
+	env := newTestEnv(t)
+	quotas := `{"storage_quota_bytes": 10, "monthly_job_quota": 1}`
+	if w := env.do(httptest.NewRequest(http.MethodPut, "/admin/tenants/default", bytes.NewBufferString(quotas))); w.Code != http.StatusOK {
+		t.Fatalf("update: status = %d, body = %s", w.Code, w.Body)
+	}
+
+	if w := env.do(multipartRequest(t, "/files/download", "scan.ply", []byte("scan"), nil)); w.Code != http.StatusOK {
+		t.Fatalf("first processing: status = %d, body = %s", w.Code, w.Body)
+	}
+	w := env.do(multipartRequest(t, "/files/download", "scan.ply", []byte("scan"), map[string]string{"reuse_result": "false"}))
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("over job quota: status = %d, body = %s", w.Code, w.Body)
+	}
+	if retry, err := strconv.Atoi(w.Header().Get("Retry-After")); err != nil || retry <= 0 || retry > 31*24*3600+1 {
+		t.Errorf("Retry-After = %q", w.Header().Get("Retry-After"))
+	}
+
+	w = env.do(multipartRequest(t, "/files/upload_file", "big.ply", []byte("more than ten bytes"), nil))
+	if w.Code != http.StatusInsufficientStorage {
+		t.Fatalf("over storage quota: status = %d, body = %s", w.Code, w.Body)
+	}
+
+	w = env.do(httptest.NewRequest(http.MethodGet, "/usage", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("usage: status = %d, body = %s", w.Code, w.Body)
+	}
+	var usage schema.TenantUsage
+	if err := json.Unmarshal(w.Body.Bytes(), &usage); err != nil {
+		t.Fatal(err)
+	}
+	if usage.TenantID != "default" || usage.Files != 2 || usage.StorageBytes != 8 || usage.JobsThisPeriod != 1 {
+		t.Errorf("usage = %+v", usage)
+	}
+}
+
+func TestSecondsUntilNextPeriod(t *testing.T) {
+	now := time.Date(2024, time.December, 31, 23, 59, 0, 0, time.UTC)
+	if got := secondsUntilNextPeriod(now); got != 61 {
+		t.Errorf("secondsUntilNextPeriod() = %d, want 61", got)
+	}
+}
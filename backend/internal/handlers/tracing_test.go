@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"context"
+	"lct/internal/tracing"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestClientTraceReachesWorker(t *testing.T) {
+	// Без экспортера span'ы не пишутся, но контекст вызывающего все равно должен дойти до CV worker'а
+	if _, err := tracing.Setup(context.Background(), tracing.Config{Exporter: tracing.ExporterNone}); err != nil {
+		t.Fatal(err)
+	}
+	env := newTestEnv(t)
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+
+	req := multipartRequest(t, "/files/download", "scan.ply", []byte("traced scan"), nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	if w := env.do(req); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+
+	traces := env.rabbit.TraceContexts()
+	if len(traces) != 1 {
+		t.Fatalf("published %d messages, want 1", len(traces))
+	}
+	if got := traces[0]["traceparent"]; !strings.Contains(got, traceID) {
+		t.Errorf("traceparent = %q, want trace %s", got, traceID)
+	}
+}
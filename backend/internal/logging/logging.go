@@ -0,0 +1,151 @@
+// Package logging структурированные логи на log/slog.
+// Идентификаторы запроса, задачи и файла кладутся в контекст и попадают в каждую запись, сделанную с этим контекстом.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Форматы записей
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// Ключи атрибутов, общие для всех слоев
+const (
+	KeyRequestID = "request_id"
+	KeyJobID     = "job_id"
+	KeyFileID    = "file_id"
+	KeyTraceID   = "trace_id"
+	KeyError     = "error"
+)
+
+// Config параметры логирования
+type Config struct {
+	Level  string // debug, info, warn или error
+	Format string // json или text
+}
+
+// ParseLevel разбирает уровень логирования
+func ParseLevel(level string) (slog.Level, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(strings.ToLower(level))); err != nil {
+		return 0, fmt.Errorf("неизвестный уровень логирования %q, допустимо debug, info, warn или error", level)
+	}
+	return l, nil
+}
+
+// New создает логгер, дополняющий записи идентификаторами из контекста
+func New(w io.Writer, cfg Config) (*slog.Logger, error) {
+	level, err := ParseLevel(cfg.Level)
+	if err != nil {
+		return nil, err
+	}
+	opts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch cfg.Format {
+	case FormatJSON, "":
+		handler = slog.NewJSONHandler(w, opts)
+	case FormatText:
+		handler = slog.NewTextHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("неизвестный формат логов %q, допустимо json или text", cfg.Format)
+	}
+	return slog.New(contextHandler{handler}), nil
+}
+
+// Setup делает логгер логгером по умолчанию; записи пакета log тоже проходят через него с уровнем info
+func Setup(w io.Writer, cfg Config) error {
+	logger, err := New(w, cfg)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+	return nil
+}
+
+// Err атрибут с ошибкой
+func Err(err error) slog.Attr {
+	return slog.Any(KeyError, err)
+}
+
+type attrsKey struct{}
+
+// With возвращает контекст, записи с которым получат атрибуты attrs в дополнение к уже добавленным
+func With(ctx context.Context, attrs ...slog.Attr) context.Context {
+	parent, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	merged := make([]slog.Attr, 0, len(parent)+len(attrs))
+	for _, a := range parent {
+		if !containsKey(attrs, a.Key) {
+			merged = append(merged, a)
+		}
+	}
+	return context.WithValue(ctx, attrsKey{}, append(merged, attrs...))
+}
+
+// WithRequestID добавляет к записям ID запроса
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return With(ctx, slog.String(KeyRequestID, id))
+}
+
+// WithJobID добавляет к записям ID задачи обработки
+func WithJobID(ctx context.Context, id int64) context.Context {
+	return With(ctx, slog.Int64(KeyJobID, id))
+}
+
+// WithFileID добавляет к записям ID файла
+func WithFileID(ctx context.Context, id int64) context.Context {
+	return With(ctx, slog.Int64(KeyFileID, id))
+}
+
+// RequestID возвращает ID запроса из контекста или пустую строку
+func RequestID(ctx context.Context) string {
+	attrs, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	for _, a := range attrs {
+		if a.Key == KeyRequestID {
+			return a.Value.String()
+		}
+	}
+	return ""
+}
+
+func containsKey(attrs []slog.Attr, key string) bool {
+	for _, a := range attrs {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// contextHandler добавляет к записи атрибуты из контекста и ID трассы, если запись сделана внутри span'а
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if ctx != nil {
+		if attrs, ok := ctx.Value(attrsKey{}).([]slog.Attr); ok {
+			r.AddAttrs(attrs...)
+		}
+		if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+			r.AddAttrs(slog.String(KeyTraceID, sc.TraceID().String()))
+		}
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
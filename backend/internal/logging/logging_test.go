@@ -0,0 +1,80 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestContextAttrsReachEveryRecord(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, Config{Level: "info", Format: FormatJSON})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := WithRequestID(context.Background(), "req-1")
+	ctx = WithFileID(ctx, 7)
+	ctx = WithJobID(ctx, 42)
+	logger.InfoContext(ctx, "обработано", slog.String("extra", "x"))
+
+	var rec map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("record is not JSON: %v: %s", err, buf.String())
+	}
+	want := map[string]any{"msg": "обработано", KeyRequestID: "req-1", KeyFileID: 7.0, KeyJobID: 42.0, "extra": "x"}
+	for k, v := range want {
+		if rec[k] != v {
+			t.Errorf("%s = %v, want %v", k, rec[k], v)
+		}
+	}
+	if got := RequestID(ctx); got != "req-1" {
+		t.Errorf("RequestID = %q, want req-1", got)
+	}
+}
+
+func TestWithReplacesExistingKey(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, Config{Level: "info", Format: FormatJSON})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := WithJobID(WithJobID(context.Background(), 1), 2)
+	logger.InfoContext(ctx, "задача")
+
+	if n := bytes.Count(buf.Bytes(), []byte(`"`+KeyJobID+`"`)); n != 1 {
+		t.Errorf("job_id appears %d times: %s", n, buf.String())
+	}
+	if !bytes.Contains(buf.Bytes(), []byte(`"job_id":2`)) {
+		t.Errorf("record keeps stale job_id: %s", buf.String())
+	}
+}
+
+func TestLevelFiltersRecords(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, Config{Level: "WARN", Format: FormatText})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger.Info("скрыто")
+	logger.Warn("видно")
+
+	if bytes.Contains(buf.Bytes(), []byte("скрыто")) {
+		t.Errorf("info record written at warn level: %s", buf.String())
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("видно")) {
+		t.Errorf("warn record missing: %s", buf.String())
+	}
+}
+
+func TestNewRejectsUnknownSettings(t *testing.T) {
+	for _, cfg := range []Config{{Level: "verbose"}, {Level: "info", Format: "xml"}} {
+		if _, err := New(&bytes.Buffer{}, cfg); err == nil {
+			t.Errorf("New(%+v) succeeded, want error", cfg)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+// Package metrics метрики сервиса в формате Prometheus
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry реестр метрик сервиса, отдается на /metrics
+var Registry = prometheus.NewRegistry()
+
+// Причины отказа в запросе ограничителями нагрузки
+const (
+	ReasonClientRate  = "client_rate"  // Клиент исчерпал свою корзину токенов
+	ReasonPendingJobs = "pending_jobs" // В очереди CV worker'а максимальное число задач
+)
+
+// Итоги операций с хранилищами
+const (
+	ResultOK      = "ok"
+	ResultError   = "error"
+	ResultTimeout = "timeout"
+)
+
+// Итоги публикации задачи в RabbitMQ
+const (
+	PublishAck      = "ack"      // Брокер подтвердил сообщение
+	PublishNack     = "nack"     // Брокер отклонил сообщение
+	PublishReturned = "returned" // Ни одна очередь не привязана к ключу маршрутизации, брокер вернул сообщение
+	PublishError    = "error"    // Не удалось подключиться или отправить сообщение
+	PublishTimeout  = "timeout"  // Подтверждение не пришло до отмены ctx
+)
+
+// Судьба ответов CV worker'а
+const (
+	ReplyRecorded   = "recorded"   // Результат записан в задачу
+	ReplyDuplicate  = "duplicate"  // Задача уже завершена, повторный ответ пропущен
+	ReplyUnexpected = "unexpected" // Задачи с таким correlation id нет
+	ReplyRetry      = "retry"      // Ответ не записан и возвращен в очередь
+	ReplyHeartbeat  = "heartbeat"  // Heartbeat CV worker'а, продлевающий аренду задачи
+)
+
+// Судьба задач, аренда которых истекла
+const (
+	ReapRequeued = "requeued" // Задача возвращена в очередь CV worker'а
+	ReapFailed   = "failed"   // Попытки исчерпаны, задача завершена ошибкой
+)
+
+// Итоги поиска в кэше результатов
+const (
+	CacheHit  = "hit"
+	CacheMiss = "miss"
+)
+
+// Границы гистограмм длительности обработки: от секунд до таймаута по умолчанию в 10 минут и дальше
+var jobDurationBuckets = []float64{1, 5, 15, 30, 60, 120, 300, 600, 1200}
+
+var (
+	// HTTPRequests запросы по маршруту и коду ответа
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "lct_http_requests_total",
+		Help: "HTTP запросы по маршруту и коду ответа",
+	}, []string{"method", "route", "status"})
+	// HTTPDuration длительность обработки запросов по маршруту
+	HTTPDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "lct_http_request_duration_seconds",
+		Help:    "Длительность обработки HTTP запросов",
+		Buckets: []float64{.005, .01, .05, .1, .5, 1, 5, 15, 60, 300, 600},
+	}, []string{"method", "route"})
+	// HTTPInFlight запросы, обрабатываемые в данный момент
+	HTTPInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "lct_http_requests_in_flight",
+		Help: "HTTP запросы, обрабатываемые в данный момент",
+	})
+
+	// UploadBytes объем сохраненных загрузок
+	UploadBytes = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "lct_upload_bytes_total",
+		Help: "Объем загруженных файлов",
+	})
+	// Uploads число сохраненных загрузок
+	Uploads = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "lct_uploads_total",
+		Help: "Число загруженных файлов",
+	})
+
+	// StorageDuration длительность операций объектного хранилища
+	StorageDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "lct_storage_operation_duration_seconds",
+		Help:    "Длительность операций объектного хранилища",
+		Buckets: []float64{.005, .01, .05, .1, .5, 1, 5, 30, 120, 600},
+	}, []string{"backend", "operation", "result"})
+	// DBDuration длительность операций репозитория PostgreSQL
+	DBDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "lct_db_operation_duration_seconds",
+		Help:    "Длительность операций с PostgreSQL",
+		Buckets: []float64{.001, .005, .01, .05, .1, .5, 1, 5},
+	}, []string{"operation", "result"})
+
+	// AMQPPublish итоги публикации задач в RabbitMQ
+	AMQPPublish = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "lct_amqp_publish_total",
+		Help: "Публикации задач в RabbitMQ по итогу подтверждения",
+	}, []string{"outcome"})
+	// AMQPPublishDuration время от отправки задачи до подтверждения брокера
+	AMQPPublishDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "lct_amqp_publish_duration_seconds",
+		Help:    "Время публикации задачи с ожиданием подтверждения",
+		Buckets: []float64{.001, .005, .01, .05, .1, .5, 1, 5, 10},
+	})
+	// AMQPReplies ответы CV worker'а из очереди результатов
+	AMQPReplies = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "lct_amqp_replies_total",
+		Help: "Ответы CV worker'а по судьбе",
+	}, []string{"outcome"})
+	// QueueMessages сообщения, ожидающие CV worker'а в очереди задач
+	QueueMessages = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "lct_queue_messages",
+		Help: "Сообщения в очереди задач CV worker'а",
+	})
+	// QueueConsumers подписчики очереди задач
+	QueueConsumers = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "lct_queue_consumers",
+		Help: "Подписчики очереди задач CV worker'а",
+	})
+
+	// Jobs задачи всех проектов по состоянию
+	Jobs = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "lct_jobs",
+		Help: "Задачи обработки по состоянию",
+	}, []string{"status"})
+	// JobDuration время от постановки задачи в очередь до результата по итоговому состоянию
+	JobDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "lct_job_duration_seconds",
+		Help:    "Время обработки задачи от постановки в очередь до результата",
+		Buckets: jobDurationBuckets,
+	}, []string{"status"})
+	// JobsReaped задачи с истекшей арендой по судьбе
+	JobsReaped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "lct_jobs_reaped_total",
+		Help: "Задачи, аренда которых истекла без ответа CV worker'а",
+	}, []string{"outcome"})
+	// CacheLookups поиски в кэше результатов
+	CacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "lct_result_cache_lookups_total",
+		Help: "Поиски в кэше результатов",
+	}, []string{"result"})
+
+	// RateLimited запросы, отклоненные ограничителями нагрузки с 429
+	RateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "lct_rate_limited_requests_total",
+		Help: "Запросы, отклоненные ограничителями нагрузки",
+	}, []string{"reason"})
+	// UploadThrottleSeconds суммарное время, на которое загрузки приостанавливались ограничением пропускной способности
+	UploadThrottleSeconds = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "lct_upload_throttle_seconds_total",
+		Help: "Время ожидания загрузок из-за ограничения пропускной способности",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests, HTTPDuration, HTTPInFlight,
+		UploadBytes, Uploads,
+		StorageDuration, DBDuration,
+		AMQPPublish, AMQPPublishDuration, AMQPReplies, QueueMessages, QueueConsumers,
+		Jobs, JobDuration, JobsReaped, CacheLookups,
+		RateLimited, UploadThrottleSeconds,
+	)
+	// Нулевые значения, чтобы ряды были видны до первого события и правила алертов не молчали
+	for _, reason := range []string{ReasonClientRate, ReasonPendingJobs} {
+		RateLimited.WithLabelValues(reason)
+	}
+	for _, outcome := range []string{PublishAck, PublishNack, PublishReturned, PublishError, PublishTimeout} {
+		AMQPPublish.WithLabelValues(outcome)
+	}
+	for _, outcome := range []string{ReplyRecorded, ReplyDuplicate, ReplyUnexpected, ReplyRetry, ReplyHeartbeat} {
+		AMQPReplies.WithLabelValues(outcome)
+	}
+	for _, outcome := range []string{ReapRequeued, ReapFailed} {
+		JobsReaped.WithLabelValues(outcome)
+	}
+	for _, result := range []string{CacheHit, CacheMiss} {
+		CacheLookups.WithLabelValues(result)
+	}
+}
+
+// Handler отдает метрики реестра в формате Prometheus
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,109 @@
+package pointcloud
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Format формат файла облака точек
+type Format string
+
+const (
+	FormatPLY Format = "ply" // Как вернул CV worker
+	FormatPCD Format = "pcd" // PCD v0.7 в текстовом виде, цвет упакован в поле rgb
+	FormatXYZ Format = "xyz" // Текст: x y z и, если есть, r g b в каждой строке
+)
+
+// Formats поддерживаемые форматы
+var Formats = []Format{FormatPLY, FormatPCD, FormatXYZ}
+
+// ParseFormat проверяет имя формата
+func ParseFormat(s string) (Format, error) {
+	for _, f := range Formats {
+		if string(f) == s {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("неизвестный формат %q, поддерживаются %v", s, Formats)
+}
+
+// Convert записывает в dst облако точек из PLY src в формате format
+func Convert(dst io.Writer, src io.Reader, format Format) error {
+	if format == FormatPLY {
+		_, err := io.Copy(dst, src)
+		return err
+	}
+	ply, err := NewPLYReader(src)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriterSize(dst, 64<<10)
+	switch format {
+	case FormatPCD:
+		err = writePCD(w, ply)
+	case FormatXYZ:
+		err = writeXYZ(w, ply)
+	default:
+		return fmt.Errorf("неизвестный формат %q", format)
+	}
+	if err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+func writePCD(w *bufio.Writer, ply *PLYReader) error {
+	fields, size, typ, count := "x y z", "4 4 4", "F F F", "1 1 1"
+	if ply.HasColor() {
+		fields, size, typ, count = fields+" rgb", size+" 4", typ+" U", count+" 1"
+	}
+	fmt.Fprintf(w, "# .PCD v0.7 - Point Cloud Data file format\nVERSION 0.7\nFIELDS %s\nSIZE %s\nTYPE %s\nCOUNT %s\n", fields, size, typ, count)
+	fmt.Fprintf(w, "WIDTH %d\nHEIGHT 1\nVIEWPOINT 0 0 0 1 0 0 0\nPOINTS %d\nDATA ascii\n", ply.Count(), ply.Count())
+
+	return eachPoint(ply, func(pt Point) {
+		writeCoords(w, pt, ply.coordBits)
+		if ply.HasColor() {
+			w.WriteByte(' ')
+			w.WriteString(strconv.FormatUint(uint64(pt.R)<<16|uint64(pt.G)<<8|uint64(pt.B), 10))
+		}
+		w.WriteByte('\n')
+	})
+}
+
+func writeXYZ(w *bufio.Writer, ply *PLYReader) error {
+	return eachPoint(ply, func(pt Point) {
+		writeCoords(w, pt, ply.coordBits)
+		if ply.HasColor() {
+			for _, c := range []uint8{pt.R, pt.G, pt.B} {
+				w.WriteByte(' ')
+				w.WriteString(strconv.Itoa(int(c)))
+			}
+		}
+		w.WriteByte('\n')
+	})
+}
+
+func writeCoords(w *bufio.Writer, pt Point, bits int) {
+	for i, v := range []float64{pt.X, pt.Y, pt.Z} {
+		if i > 0 {
+			w.WriteByte(' ')
+		}
+		w.WriteString(strconv.FormatFloat(v, 'f', -1, bits))
+	}
+}
+
+func eachPoint(ply *PLYReader, fn func(Point)) error {
+	for {
+		pt, err := ply.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fn(pt)
+	}
+}
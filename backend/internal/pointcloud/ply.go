@@ -0,0 +1,330 @@
+// Package pointcloud чтение облаков точек в формате PLY, который возвращает CV worker,
+// и запись их в другие форматы. Точки обрабатываются потоком, облако целиком в память не загружается.
+package pointcloud
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Point точка облака; цвет заполнен, если он есть в исходном файле
+type Point struct {
+	X, Y, Z float64
+	R, G, B uint8
+}
+
+// plyFormat кодировка данных PLY
+type plyFormat int
+
+const (
+	plyASCII plyFormat = iota
+	plyBinaryLE
+	plyBinaryBE
+)
+
+// plyProperty свойство элемента PLY; у списка count — тип длины, kind — тип элементов
+type plyProperty struct {
+	name   string
+	kind   string
+	isList bool
+	count  string
+}
+
+type plyElement struct {
+	name  string
+	count int
+	props []plyProperty
+}
+
+// typeSizes размеры скалярных типов PLY в байтах; поддерживаются старые и новые имена
+var typeSizes = map[string]int{
+	"char": 1, "int8": 1, "uchar": 1, "uint8": 1,
+	"short": 2, "int16": 2, "ushort": 2, "uint16": 2,
+	"int": 4, "int32": 4, "uint": 4, "uint32": 4,
+	"float": 4, "float32": 4, "double": 8, "float64": 8,
+}
+
+// PLYReader последовательно читает вершины файла PLY
+type PLYReader struct {
+	r        *bufio.Reader
+	format   plyFormat
+	order    binary.ByteOrder
+	vertex   plyElement
+	read     int
+	index    map[string]int // Позиция свойства в строке вершины
+	hasColor bool
+	// Точность координат: 32 для float, иначе 64; нужна, чтобы не печатать шум при переводе float32 в текст
+	coordBits int
+	// Цвет записан числами с плавающей точкой в диапазоне 0..1
+	floatColor bool
+	words      *bufio.Scanner
+	row        []float64
+	buf        [8]byte
+}
+
+// NewPLYReader читает заголовок PLY и пропускает элементы, записанные до вершин
+func NewPLYReader(r io.Reader) (*PLYReader, error) {
+	p := &PLYReader{r: bufio.NewReaderSize(r, 64<<10)}
+	elements, err := p.readHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	vertex := -1
+	for i, el := range elements {
+		if el.name == "vertex" {
+			vertex = i
+			break
+		}
+	}
+	if vertex < 0 {
+		return nil, fmt.Errorf("ply: нет элемента vertex")
+	}
+	p.vertex = elements[vertex]
+	p.index = make(map[string]int, len(p.vertex.props))
+	for i, prop := range p.vertex.props {
+		if prop.isList {
+			return nil, fmt.Errorf("ply: свойство-список %s у вершин не поддерживается", prop.name)
+		}
+		p.index[prop.name] = i
+	}
+	for _, name := range []string{"x", "y", "z"} {
+		if _, ok := p.index[name]; !ok {
+			return nil, fmt.Errorf("ply: у вершин нет координаты %s", name)
+		}
+	}
+	p.coordBits = 64
+	if kind := p.vertex.props[p.index["x"]].kind; kind == "float" || kind == "float32" {
+		p.coordBits = 32
+	}
+	if ri, ok := p.index["red"]; ok {
+		_, gok := p.index["green"]
+		_, bok := p.index["blue"]
+		p.hasColor = gok && bok
+		kind := p.vertex.props[ri].kind
+		p.floatColor = kind == "float" || kind == "float32" || kind == "double" || kind == "float64"
+	}
+	p.row = make([]float64, len(p.vertex.props))
+
+	if p.format == plyASCII {
+		p.words = bufio.NewScanner(p.r)
+		p.words.Buffer(make([]byte, 64<<10), 1<<20)
+		p.words.Split(bufio.ScanWords)
+	}
+	for _, el := range elements[:vertex] {
+		for i := 0; i < el.count; i++ {
+			if err := p.skipRow(el); err != nil {
+				return nil, fmt.Errorf("ply: элемент %s: %w", el.name, err)
+			}
+		}
+	}
+	return p, nil
+}
+
+func (p *PLYReader) readHeader() ([]plyElement, error) {
+	line, err := p.headerLine()
+	if err != nil {
+		return nil, err
+	}
+	if line != "ply" {
+		return nil, fmt.Errorf("ply: файл не в формате PLY")
+	}
+
+	var elements []plyElement
+	formatSeen := false
+	for {
+		line, err := p.headerLine()
+		if err != nil {
+			return nil, err
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "end_header":
+			if !formatSeen {
+				return nil, fmt.Errorf("ply: в заголовке нет format")
+			}
+			return elements, nil
+		case "comment", "obj_info":
+		case "format":
+			if len(fields) < 2 {
+				return nil, fmt.Errorf("ply: неверная строка %q", line)
+			}
+			switch fields[1] {
+			case "ascii":
+				p.format = plyASCII
+			case "binary_little_endian":
+				p.format, p.order = plyBinaryLE, binary.LittleEndian
+			case "binary_big_endian":
+				p.format, p.order = plyBinaryBE, binary.BigEndian
+			default:
+				return nil, fmt.Errorf("ply: неизвестный формат %s", fields[1])
+			}
+			formatSeen = true
+		case "element":
+			if len(fields) != 3 {
+				return nil, fmt.Errorf("ply: неверная строка %q", line)
+			}
+			count, err := strconv.Atoi(fields[2])
+			if err != nil || count < 0 {
+				return nil, fmt.Errorf("ply: неверное число элементов %q", line)
+			}
+			elements = append(elements, plyElement{name: fields[1], count: count})
+		case "property":
+			if len(elements) == 0 {
+				return nil, fmt.Errorf("ply: свойство вне элемента %q", line)
+			}
+			prop, err := parseProperty(fields)
+			if err != nil {
+				return nil, fmt.Errorf("ply: %w в строке %q", err, line)
+			}
+			el := &elements[len(elements)-1]
+			el.props = append(el.props, prop)
+		default:
+			return nil, fmt.Errorf("ply: неизвестная строка заголовка %q", line)
+		}
+	}
+}
+
+func parseProperty(fields []string) (plyProperty, error) {
+	if len(fields) == 5 && fields[1] == "list" {
+		if typeSizes[fields[2]] == 0 || typeSizes[fields[3]] == 0 {
+			return plyProperty{}, fmt.Errorf("неизвестный тип")
+		}
+		return plyProperty{name: fields[4], isList: true, count: fields[2], kind: fields[3]}, nil
+	}
+	if len(fields) != 3 || typeSizes[fields[1]] == 0 {
+		return plyProperty{}, fmt.Errorf("неверное свойство")
+	}
+	return plyProperty{name: fields[2], kind: fields[1]}, nil
+}
+
+// maxHeaderLine ограничивает строку заголовка, чтобы двоичный мусор не читался в память целиком
+const maxHeaderLine = 4096
+
+func (p *PLYReader) headerLine() (string, error) {
+	var sb strings.Builder
+	for {
+		b, err := p.r.ReadByte()
+		if err == io.EOF {
+			return "", fmt.Errorf("ply: заголовок не завершен")
+		}
+		if err != nil {
+			return "", err
+		}
+		if b == '\n' {
+			return strings.TrimRight(sb.String(), "\r"), nil
+		}
+		if sb.Len() >= maxHeaderLine {
+			return "", fmt.Errorf("ply: слишком длинная строка заголовка")
+		}
+		sb.WriteByte(b)
+	}
+}
+
+// Count число вершин в файле
+func (p *PLYReader) Count() int {
+	return p.vertex.count
+}
+
+// HasColor сообщает, есть ли у вершин цвет
+func (p *PLYReader) HasColor() bool {
+	return p.hasColor
+}
+
+// Next возвращает следующую вершину или io.EOF, когда вершины закончились
+func (p *PLYReader) Next() (Point, error) {
+	if p.read >= p.vertex.count {
+		return Point{}, io.EOF
+	}
+	for i, prop := range p.vertex.props {
+		v, err := p.value(prop.kind)
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return Point{}, fmt.Errorf("ply: вершина %d: %w", p.read, err)
+		}
+		p.row[i] = v
+	}
+	p.read++
+
+	pt := Point{X: p.row[p.index["x"]], Y: p.row[p.index["y"]], Z: p.row[p.index["z"]]}
+	if p.hasColor {
+		pt.R = p.color(p.row[p.index["red"]])
+		pt.G = p.color(p.row[p.index["green"]])
+		pt.B = p.color(p.row[p.index["blue"]])
+	}
+	return pt, nil
+}
+
+func (p *PLYReader) color(v float64) uint8 {
+	if p.floatColor {
+		v *= 255
+	}
+	return uint8(math.Round(math.Max(0, math.Min(255, v))))
+}
+
+func (p *PLYReader) skipRow(el plyElement) error {
+	for _, prop := range el.props {
+		if !prop.isList {
+			if _, err := p.value(prop.kind); err != nil {
+				return err
+			}
+			continue
+		}
+		n, err := p.value(prop.count)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < int(n); i++ {
+			if _, err := p.value(prop.kind); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// value читает одно скалярное значение типа kind
+func (p *PLYReader) value(kind string) (float64, error) {
+	if p.format == plyASCII {
+		if !p.words.Scan() {
+			if err := p.words.Err(); err != nil {
+				return 0, err
+			}
+			return 0, io.EOF
+		}
+		return strconv.ParseFloat(p.words.Text(), 64)
+	}
+
+	b := p.buf[:typeSizes[kind]]
+	if _, err := io.ReadFull(p.r, b); err != nil {
+		return 0, err
+	}
+	switch kind {
+	case "char", "int8":
+		return float64(int8(b[0])), nil
+	case "uchar", "uint8":
+		return float64(b[0]), nil
+	case "short", "int16":
+		return float64(int16(p.order.Uint16(b))), nil
+	case "ushort", "uint16":
+		return float64(p.order.Uint16(b)), nil
+	case "int", "int32":
+		return float64(int32(p.order.Uint32(b))), nil
+	case "uint", "uint32":
+		return float64(p.order.Uint32(b)), nil
+	case "float", "float32":
+		return float64(math.Float32frombits(p.order.Uint32(b))), nil
+	default:
+		return math.Float64frombits(p.order.Uint64(b)), nil
+	}
+}
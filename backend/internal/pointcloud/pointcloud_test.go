@@ -0,0 +1,117 @@
+package pointcloud
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+// binaryPLY собирает PLY в формате, в котором его записывает Open3D: double координаты и uchar цвет,
+// с элементом, предшествующим вершинам, и гранями после них
+func binaryPLY(t *testing.T, order binary.ByteOrder, format string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	buf.WriteString("ply\nformat " + format + " 1.0\ncomment written by test\n")
+	buf.WriteString("element camera 1\nproperty float fov\nproperty list uchar int ids\n")
+	buf.WriteString("element vertex 2\nproperty double x\nproperty double y\nproperty double z\n")
+	buf.WriteString("property uchar red\nproperty uchar green\nproperty uchar blue\n")
+	buf.WriteString("element face 1\nproperty list uchar int vertex_indices\nend_header\n")
+	w := func(v any) {
+		if err := binary.Write(&buf, order, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// camera: fov и список из двух чисел
+	w(float32(60))
+	w(uint8(2))
+	w(int32(7))
+	w(int32(8))
+	w(1.5)
+	w(-2.25)
+	w(0.0)
+	w([]uint8{255, 128, 0})
+	w(10.0)
+	w(20.0)
+	w(30.0)
+	w([]uint8{1, 2, 3})
+	w(uint8(3))
+	w([]int32{0, 1, 1})
+	return buf.Bytes()
+}
+
+func TestConvertBinaryPLY(t *testing.T) {
+	for _, tc := range []struct {
+		format string
+		order  binary.ByteOrder
+	}{{"binary_little_endian", binary.LittleEndian}, {"binary_big_endian", binary.BigEndian}} {
+		data := binaryPLY(t, tc.order, tc.format)
+
+		var xyz bytes.Buffer
+		if err := Convert(&xyz, bytes.NewReader(data), FormatXYZ); err != nil {
+			t.Fatalf("%s: %v", tc.format, err)
+		}
+		if want := "1.5 -2.25 0 255 128 0\n10 20 30 1 2 3\n"; xyz.String() != want {
+			t.Errorf("%s: xyz = %q, want %q", tc.format, xyz.String(), want)
+		}
+	}
+}
+
+func TestConvertToPCD(t *testing.T) {
+	var pcd bytes.Buffer
+	if err := Convert(&pcd, bytes.NewReader(binaryPLY(t, binary.LittleEndian, "binary_little_endian")), FormatPCD); err != nil {
+		t.Fatal(err)
+	}
+	out := pcd.String()
+	for _, want := range []string{"FIELDS x y z rgb\n", "TYPE F F F U\n", "WIDTH 2\n", "POINTS 2\nDATA ascii\n",
+		"1.5 -2.25 0 16744448\n", "10 20 30 66051\n"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("pcd does not contain %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestConvertASCIIPLYWithFloatColors(t *testing.T) {
+	src := "ply\r\nformat ascii 1.0\r\nelement vertex 2\r\nproperty float x\r\nproperty float y\r\nproperty float z\r\n" +
+		"property float red\r\nproperty float green\r\nproperty float blue\r\nend_header\r\n" +
+		"0.1 0.2 0.3 1 0.5 0\r\n4 5 6 0 0 0\r\n"
+	var xyz bytes.Buffer
+	if err := Convert(&xyz, strings.NewReader(src), FormatXYZ); err != nil {
+		t.Fatal(err)
+	}
+	if want := "0.1 0.2 0.3 255 128 0\n4 5 6 0 0 0\n"; xyz.String() != want {
+		t.Errorf("xyz = %q, want %q", xyz.String(), want)
+	}
+}
+
+func TestConvertPLYIsCopied(t *testing.T) {
+	src := []byte("not even a ply")
+	var out bytes.Buffer
+	if err := Convert(&out, bytes.NewReader(src), FormatPLY); err != nil || !bytes.Equal(out.Bytes(), src) {
+		t.Errorf("out = %q, err = %v", out.Bytes(), err)
+	}
+}
+
+func TestConvertRejectsBrokenInput(t *testing.T) {
+	for name, src := range map[string]string{
+		"not ply":       "pcd\n",
+		"no header end": "ply\nformat ascii 1.0\nelement vertex 1\n",
+		"no vertex":     "ply\nformat ascii 1.0\nelement face 0\nproperty list uchar int vertex_indices\nend_header\n",
+		"no z":          "ply\nformat ascii 1.0\nelement vertex 1\nproperty float x\nproperty float y\nend_header\n1 2\n",
+		"truncated":     "ply\nformat binary_little_endian 1.0\nelement vertex 2\nproperty double x\nproperty double y\nproperty double z\nend_header\n" + strings.Repeat("\x00", 30),
+		"bad format":    "ply\nformat binary_middle_endian 1.0\nend_header\n",
+	} {
+		if err := Convert(&bytes.Buffer{}, strings.NewReader(src), FormatXYZ); err == nil {
+			t.Errorf("%s: converted without error", name)
+		}
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	if f, err := ParseFormat("pcd"); err != nil || f != FormatPCD {
+		t.Errorf("pcd: %v, %v", f, err)
+	}
+	if _, err := ParseFormat("las"); err == nil {
+		t.Error("las accepted")
+	}
+}
@@ -0,0 +1,80 @@
+package instrumented
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"lct/internal/domain/errors"
+	"lct/internal/metrics"
+	"lct/internal/repository/memory"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// observed возвращает число наблюдений в ряду гистограммы
+func observed(t *testing.T, o prometheus.Observer) uint64 {
+	t.Helper()
+	var m dto.Metric
+	if err := o.(prometheus.Metric).Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	return m.GetHistogram().GetSampleCount()
+}
+
+func TestResultClassifiesExpectedErrorsAsOK(t *testing.T) {
+	for err, want := range map[error]string{
+		nil: metrics.ResultOK,
+		fmt.Errorf("%w: файл", errors.ErrNotFound):          metrics.ResultOK,
+		fmt.Errorf("%w: квота", errors.ErrJobQuotaExceeded): metrics.ResultOK,
+		fmt.Errorf("query: %w", context.DeadlineExceeded):   metrics.ResultTimeout,
+		stderrors.New("connection refused"):                 metrics.ResultError,
+	} {
+		if got := result(err); got != want {
+			t.Errorf("result(%v) = %s, want %s", err, got, want)
+		}
+	}
+}
+
+func TestRepositoryOperationsAreObserved(t *testing.T) {
+	repo := memory.NewRepository()
+	repo.FailOn("GetJob", stderrors.New("connection refused"))
+	failed := metrics.DBDuration.WithLabelValues("GetJob", metrics.ResultError)
+	before := observed(t, failed)
+
+	if _, err := NewRepository(repo).GetJob(context.Background(), 1); err == nil {
+		t.Fatal("GetJob() error = nil, want injected fault")
+	}
+	if got := observed(t, failed) - before; got != 1 {
+		t.Errorf("failed GetJob observed %d times, want 1", got)
+	}
+}
+
+func TestStorageOperationsAreObservedInEveryBucket(t *testing.T) {
+	created := metrics.StorageDuration.WithLabelValues("memory", "CreateOne", metrics.ResultOK)
+	failed := metrics.StorageDuration.WithLabelValues("memory", "GetOne", metrics.ResultError)
+	beforeCreated, beforeFailed := observed(t, created), observed(t, failed)
+
+	ctx := context.Background()
+	storage := NewObjectStorage(memory.NewObjectStorage("shared"), "memory").WithBucket("tenant")
+	if storage.Bucket() != "tenant" {
+		t.Fatalf("Bucket() = %s, want tenant", storage.Bucket())
+	}
+	object, err := storage.CreateOne(ctx, strings.NewReader("data"), 4, "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	object.Close()
+	if _, err := storage.GetOne(ctx, "missing"); err == nil {
+		t.Fatal("GetOne() of missing object: error = nil")
+	}
+
+	if got := observed(t, created) - beforeCreated; got != 1 {
+		t.Errorf("CreateOne observed %d times, want 1", got)
+	}
+	if got := observed(t, failed) - beforeFailed; got != 1 {
+		t.Errorf("failed GetOne observed %d times, want 1", got)
+	}
+}
@@ -0,0 +1,83 @@
+package instrumented
+
+import (
+	"context"
+	"io"
+	"lct/internal/metrics"
+	"lct/internal/repository"
+	"lct/internal/tracing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ObjectStorage снимает метрики и трассирует операции объектного хранилища и передает вызовы дальше.
+// Время чтения объекта, полученного через GetOne, не учитывается: оно зависит от клиента, которому объект отдается.
+type ObjectStorage struct {
+	next    repository.ObjectStorage
+	backend string
+}
+
+var _ repository.ObjectStorage = (*ObjectStorage)(nil)
+
+// NewObjectStorage оборачивает хранилище метриками и трассировкой; backend попадает в метку и атрибут span'а
+func NewObjectStorage(next repository.ObjectStorage, backend string) *ObjectStorage {
+	return &ObjectStorage{next: next, backend: backend}
+}
+
+// observe открывает span операции с хранилищем; возвращенная функция закрывает его и записывает длительность операции
+func (s *ObjectStorage) observe(ctx context.Context, operation string) (context.Context, func(*error)) {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "storage."+operation, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("storage.backend", s.backend), attribute.String("storage.bucket", s.next.Bucket())))
+	return ctx, func(err *error) {
+		res := result(*err)
+		metrics.StorageDuration.WithLabelValues(s.backend, operation, res).Observe(time.Since(start).Seconds())
+		endSpan(span, res, *err)
+	}
+}
+
+func (s *ObjectStorage) Init(ctx context.Context) (err error) {
+	ctx, done := s.observe(ctx, "Init")
+	defer done(&err)
+	return s.next.Init(ctx)
+}
+
+func (s *ObjectStorage) Bucket() string {
+	return s.next.Bucket()
+}
+
+func (s *ObjectStorage) Ping(ctx context.Context) (err error) {
+	ctx, done := s.observe(ctx, "Ping")
+	defer done(&err)
+	return s.next.Ping(ctx)
+}
+
+func (s *ObjectStorage) CreateOne(ctx context.Context, r io.Reader, size int64, objectKey string) (_ repository.Object, err error) {
+	ctx, done := s.observe(ctx, "CreateOne")
+	defer done(&err)
+	return s.next.CreateOne(ctx, r, size, objectKey)
+}
+
+func (s *ObjectStorage) GetOne(ctx context.Context, objectKey string) (_ repository.Object, err error) {
+	ctx, done := s.observe(ctx, "GetOne")
+	defer done(&err)
+	return s.next.GetOne(ctx, objectKey)
+}
+
+func (s *ObjectStorage) DeleteOne(ctx context.Context, objectKey string) (err error) {
+	ctx, done := s.observe(ctx, "DeleteOne")
+	defer done(&err)
+	return s.next.DeleteOne(ctx, objectKey)
+}
+
+func (s *ObjectStorage) List(ctx context.Context) (_ []repository.ObjectInfo, err error) {
+	ctx, done := s.observe(ctx, "List")
+	defer done(&err)
+	return s.next.List(ctx)
+}
+
+func (s *ObjectStorage) WithBucket(bucket string) repository.ObjectStorage {
+	return &ObjectStorage{next: s.next.WithBucket(bucket), backend: s.backend}
+}
@@ -0,0 +1,318 @@
+// Package instrumented обертки хранилищ, снимающие метрики длительности и ошибок каждой операции
+// и открывающие span трассировки на каждый вызов
+package instrumented
+
+import (
+	"context"
+	stderrors "errors"
+	"lct/internal/domain/errors"
+	"lct/internal/metrics"
+	"lct/internal/repository"
+	"lct/internal/repository/schema"
+	"lct/internal/tracing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Repository снимает метрики и трассирует операции с PostgreSQL и передает вызовы дальше
+type Repository struct {
+	next repository.Repository
+}
+
+var _ repository.Repository = (*Repository)(nil)
+
+// NewRepository оборачивает репозиторий метриками и трассировкой
+func NewRepository(next repository.Repository) *Repository {
+	return &Repository{next: next}
+}
+
+// observeDB открывает span операции с базой; возвращенная функция закрывает его и записывает длительность операции.
+// Ожидаемые ответы репозитория (нет записи, конфликт, квота) ошибками базы не считаются.
+func observeDB(ctx context.Context, operation string) (context.Context, func(*error)) {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "db."+operation, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("db.system.name", "postgresql"), attribute.String("db.operation.name", operation)))
+	return ctx, func(err *error) {
+		res := result(*err)
+		metrics.DBDuration.WithLabelValues(operation, res).Observe(time.Since(start).Seconds())
+		endSpan(span, res, *err)
+	}
+}
+
+// endSpan закрывает span операции; ожидаемые ответы хранилищ не отмечают span ошибкой
+func endSpan(span trace.Span, res string, err error) {
+	if res == metrics.ResultOK {
+		span.End()
+		return
+	}
+	tracing.End(span, err)
+}
+
+// result итог операции для метки result
+func result(err error) string {
+	switch {
+	case err == nil,
+		stderrors.Is(err, errors.ErrNotFound),
+		stderrors.Is(err, errors.ErrConflict),
+		stderrors.Is(err, errors.ErrStorageQuotaExceeded),
+		stderrors.Is(err, errors.ErrJobQuotaExceeded):
+		return metrics.ResultOK
+	case stderrors.Is(err, context.DeadlineExceeded):
+		return metrics.ResultTimeout
+	}
+	return metrics.ResultError
+}
+
+func (r *Repository) Ping(ctx context.Context) (err error) {
+	ctx, done := observeDB(ctx, "Ping")
+	defer done(&err)
+	return r.next.Ping(ctx)
+}
+
+func (r *Repository) CreatePendingFile(ctx context.Context, file *schema.FileMetadata) (_ int64, err error) {
+	ctx, done := observeDB(ctx, "CreatePendingFile")
+	defer done(&err)
+	return r.next.CreatePendingFile(ctx, file)
+}
+
+func (r *Repository) CommitFile(ctx context.Context, id int64, sha256 string) (_ string, err error) {
+	ctx, done := observeDB(ctx, "CommitFile")
+	defer done(&err)
+	return r.next.CommitFile(ctx, id, sha256)
+}
+
+func (r *Repository) DeletePendingFile(ctx context.Context, id int64) (_ bool, err error) {
+	ctx, done := observeDB(ctx, "DeletePendingFile")
+	defer done(&err)
+	return r.next.DeletePendingFile(ctx, id)
+}
+
+func (r *Repository) GetMetaDataByID(ctx context.Context, id int64) (_ *schema.FileMetadata, err error) {
+	ctx, done := observeDB(ctx, "GetMetaDataByID")
+	defer done(&err)
+	return r.next.GetMetaDataByID(ctx, id)
+}
+
+func (r *Repository) DeleteFile(ctx context.Context, id int64) (_ string, _ int, err error) {
+	ctx, done := observeDB(ctx, "DeleteFile")
+	defer done(&err)
+	return r.next.DeleteFile(ctx, id)
+}
+
+func (r *Repository) ObjectBucket(ctx context.Context, objectKey string, project string) (_ string, err error) {
+	ctx, done := observeDB(ctx, "ObjectBucket")
+	defer done(&err)
+	return r.next.ObjectBucket(ctx, objectKey, project)
+}
+
+func (r *Repository) ListFiles(ctx context.Context, filter schema.FileFilter) (_ []schema.FileMetadata, _ int64, err error) {
+	ctx, done := observeDB(ctx, "ListFiles")
+	defer done(&err)
+	return r.next.ListFiles(ctx, filter)
+}
+
+func (r *Repository) ListPendingFiles(ctx context.Context, olderThan time.Duration) (_ []schema.FileMetadata, err error) {
+	ctx, done := observeDB(ctx, "ListPendingFiles")
+	defer done(&err)
+	return r.next.ListPendingFiles(ctx, olderThan)
+}
+
+func (r *Repository) ListReferencedObjectKeys(ctx context.Context, bucket string) (_ []string, err error) {
+	ctx, done := observeDB(ctx, "ListReferencedObjectKeys")
+	defer done(&err)
+	return r.next.ListReferencedObjectKeys(ctx, bucket)
+}
+
+func (r *Repository) CreateJob(ctx context.Context, job *schema.Job) (_ int64, err error) {
+	ctx, done := observeDB(ctx, "CreateJob")
+	defer done(&err)
+	return r.next.CreateJob(ctx, job)
+}
+
+func (r *Repository) EnqueueJob(ctx context.Context, job *schema.Job, msg *schema.OutboxMessage) (_ int64, err error) {
+	ctx, done := observeDB(ctx, "EnqueueJob")
+	defer done(&err)
+	return r.next.EnqueueJob(ctx, job, msg)
+}
+
+func (r *Repository) CompleteJob(ctx context.Context, jobID int64, artifactID int64) (err error) {
+	ctx, done := observeDB(ctx, "CompleteJob")
+	defer done(&err)
+	return r.next.CompleteJob(ctx, jobID, artifactID)
+}
+
+func (r *Repository) FinishJob(ctx context.Context, jobID int64, status string, errMsg string) (err error) {
+	ctx, done := observeDB(ctx, "FinishJob")
+	defer done(&err)
+	return r.next.FinishJob(ctx, jobID, status, errMsg)
+}
+
+func (r *Repository) GetJob(ctx context.Context, jobID int64) (_ *schema.Job, err error) {
+	ctx, done := observeDB(ctx, "GetJob")
+	defer done(&err)
+	return r.next.GetJob(ctx, jobID)
+}
+
+func (r *Repository) GetJobByCorrelationID(ctx context.Context, correlationID string) (_ *schema.Job, err error) {
+	ctx, done := observeDB(ctx, "GetJobByCorrelationID")
+	defer done(&err)
+	return r.next.GetJobByCorrelationID(ctx, correlationID)
+}
+
+func (r *Repository) ListJobsByStatus(ctx context.Context, status string) (_ []schema.Job, err error) {
+	ctx, done := observeDB(ctx, "ListJobsByStatus")
+	defer done(&err)
+	return r.next.ListJobsByStatus(ctx, status)
+}
+
+func (r *Repository) CountJobsByStatus(ctx context.Context, status string) (_ int64, err error) {
+	ctx, done := observeDB(ctx, "CountJobsByStatus")
+	defer done(&err)
+	return r.next.CountJobsByStatus(ctx, status)
+}
+
+func (r *Repository) JobStatusCounts(ctx context.Context) (_ map[string]int64, err error) {
+	ctx, done := observeDB(ctx, "JobStatusCounts")
+	defer done(&err)
+	return r.next.JobStatusCounts(ctx)
+}
+
+func (r *Repository) RequeueJob(ctx context.Context, jobID int64, from string, msg *schema.OutboxMessage) (err error) {
+	ctx, done := observeDB(ctx, "RequeueJob")
+	defer done(&err)
+	return r.next.RequeueJob(ctx, jobID, from, msg)
+}
+
+func (r *Repository) ResumeJob(ctx context.Context, jobID int64, lease time.Duration) (_ bool, err error) {
+	ctx, done := observeDB(ctx, "ResumeJob")
+	defer done(&err)
+	return r.next.ResumeJob(ctx, jobID, lease)
+}
+
+func (r *Repository) RecordHeartbeat(ctx context.Context, correlationID string, lease time.Duration) (_ *schema.Job, err error) {
+	ctx, done := observeDB(ctx, "RecordHeartbeat")
+	defer done(&err)
+	return r.next.RecordHeartbeat(ctx, correlationID, lease)
+}
+
+func (r *Repository) ClaimExpiredJobs(ctx context.Context, limit int, lease time.Duration) (_ []schema.Job, err error) {
+	ctx, done := observeDB(ctx, "ClaimExpiredJobs")
+	defer done(&err)
+	return r.next.ClaimExpiredJobs(ctx, limit, lease)
+}
+
+func (r *Repository) ClaimOutbox(ctx context.Context, limit int, lease time.Duration) (_ []schema.OutboxMessage, err error) {
+	ctx, done := observeDB(ctx, "ClaimOutbox")
+	defer done(&err)
+	return r.next.ClaimOutbox(ctx, limit, lease)
+}
+
+func (r *Repository) MarkOutboxSent(ctx context.Context, id int64, lease time.Duration) (err error) {
+	ctx, done := observeDB(ctx, "MarkOutboxSent")
+	defer done(&err)
+	return r.next.MarkOutboxSent(ctx, id, lease)
+}
+
+func (r *Repository) MarkOutboxFailed(ctx context.Context, id int64, errMsg string, retryIn time.Duration) (err error) {
+	ctx, done := observeDB(ctx, "MarkOutboxFailed")
+	defer done(&err)
+	return r.next.MarkOutboxFailed(ctx, id, errMsg, retryIn)
+}
+
+func (r *Repository) SaveArtifact(ctx context.Context, artifact *schema.Artifact) (_ int64, err error) {
+	ctx, done := observeDB(ctx, "SaveArtifact")
+	defer done(&err)
+	return r.next.SaveArtifact(ctx, artifact)
+}
+
+func (r *Repository) GetArtifact(ctx context.Context, id int64) (_ *schema.Artifact, err error) {
+	ctx, done := observeDB(ctx, "GetArtifact")
+	defer done(&err)
+	return r.next.GetArtifact(ctx, id)
+}
+
+func (r *Repository) GetArtifactsByFileID(ctx context.Context, fileID int64) (_ []schema.Artifact, err error) {
+	ctx, done := observeDB(ctx, "GetArtifactsByFileID")
+	defer done(&err)
+	return r.next.GetArtifactsByFileID(ctx, fileID)
+}
+
+func (r *Repository) FindCachedResult(ctx context.Context, tenant string, inputSHA256 string, paramsHash string, modelVersion string) (_ *schema.Artifact, err error) {
+	ctx, done := observeDB(ctx, "FindCachedResult")
+	defer done(&err)
+	return r.next.FindCachedResult(ctx, tenant, inputSHA256, paramsHash, modelVersion)
+}
+
+func (r *Repository) SaveCachedResult(ctx context.Context, tenant string, inputSHA256 string, paramsHash string, modelVersion string, artifactID int64) (err error) {
+	ctx, done := observeDB(ctx, "SaveCachedResult")
+	defer done(&err)
+	return r.next.SaveCachedResult(ctx, tenant, inputSHA256, paramsHash, modelVersion, artifactID)
+}
+
+func (r *Repository) InvalidateResultCache(ctx context.Context, modelVersion string) (_ int64, err error) {
+	ctx, done := observeDB(ctx, "InvalidateResultCache")
+	defer done(&err)
+	return r.next.InvalidateResultCache(ctx, modelVersion)
+}
+
+func (r *Repository) CreateAPIKey(ctx context.Context, key *schema.APIKey) (_ int64, err error) {
+	ctx, done := observeDB(ctx, "CreateAPIKey")
+	defer done(&err)
+	return r.next.CreateAPIKey(ctx, key)
+}
+
+func (r *Repository) GetAPIKeyByPrefix(ctx context.Context, prefix string) (_ *schema.APIKey, err error) {
+	ctx, done := observeDB(ctx, "GetAPIKeyByPrefix")
+	defer done(&err)
+	return r.next.GetAPIKeyByPrefix(ctx, prefix)
+}
+
+func (r *Repository) ListAPIKeys(ctx context.Context) (_ []schema.APIKey, err error) {
+	ctx, done := observeDB(ctx, "ListAPIKeys")
+	defer done(&err)
+	return r.next.ListAPIKeys(ctx)
+}
+
+func (r *Repository) RevokeAPIKey(ctx context.Context, id int64) (err error) {
+	ctx, done := observeDB(ctx, "RevokeAPIKey")
+	defer done(&err)
+	return r.next.RevokeAPIKey(ctx, id)
+}
+
+func (r *Repository) TouchAPIKey(ctx context.Context, id int64) (err error) {
+	ctx, done := observeDB(ctx, "TouchAPIKey")
+	defer done(&err)
+	return r.next.TouchAPIKey(ctx, id)
+}
+
+func (r *Repository) CreateTenant(ctx context.Context, tenant *schema.Tenant) (err error) {
+	ctx, done := observeDB(ctx, "CreateTenant")
+	defer done(&err)
+	return r.next.CreateTenant(ctx, tenant)
+}
+
+func (r *Repository) GetTenant(ctx context.Context, id string) (_ *schema.Tenant, err error) {
+	ctx, done := observeDB(ctx, "GetTenant")
+	defer done(&err)
+	return r.next.GetTenant(ctx, id)
+}
+
+func (r *Repository) ListTenants(ctx context.Context) (_ []schema.Tenant, err error) {
+	ctx, done := observeDB(ctx, "ListTenants")
+	defer done(&err)
+	return r.next.ListTenants(ctx)
+}
+
+func (r *Repository) UpdateTenant(ctx context.Context, tenant *schema.Tenant) (err error) {
+	ctx, done := observeDB(ctx, "UpdateTenant")
+	defer done(&err)
+	return r.next.UpdateTenant(ctx, tenant)
+}
+
+func (r *Repository) GetTenantUsage(ctx context.Context, id string) (_ *schema.TenantUsage, err error) {
+	ctx, done := observeDB(ctx, "GetTenantUsage")
+	defer done(&err)
+	return r.next.GetTenantUsage(ctx, id)
+}
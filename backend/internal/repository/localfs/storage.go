@@ -0,0 +1,190 @@
+package localfs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"lct/internal/repository"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// localStorage реализация интерфейса repository.ObjectStorage поверх локальной файловой системы.
+// Объект с ключом key хранится в файле <root>/<bucket>/<key>, поэтому ключи вида processed/<uuid>.ply
+// раскладываются так же, как в Minio.
+type localStorage struct {
+	root   string
+	bucket string
+}
+
+// localObject файл, открытый на чтение
+type localObject struct {
+	*os.File
+	key string
+}
+
+func (o localObject) Stat() (repository.ObjectInfo, error) {
+	info, err := o.File.Stat()
+	if err != nil {
+		return repository.ObjectInfo{}, err
+	}
+	return repository.ObjectInfo{Key: o.key, Size: info.Size(), LastModified: info.ModTime()}, nil
+}
+
+// NewLocalStorage создает хранилище в каталоге root
+func NewLocalStorage(root string, bucket string) repository.ObjectStorage {
+	return &localStorage{root: root, bucket: bucket}
+}
+
+func (l *localStorage) Bucket() string {
+	return l.bucket
+}
+
+// WithBucket возвращает хранилище бакета в соседнем каталоге того же корня
+func (l *localStorage) WithBucket(bucket string) repository.ObjectStorage {
+	return &localStorage{root: l.root, bucket: bucket}
+}
+
+// contextReader прекращает чтение источника после отмены контекста
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
+
+// Init создает каталог бакета, если его нет
+func (l *localStorage) Init(ctx context.Context) error {
+	dir := filepath.Join(l.root, l.bucket)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("не удалось создать каталог хранилища %s: %w", dir, err)
+	}
+	slog.InfoContext(ctx, "Локальное хранилище", slog.String("path", dir))
+	return nil
+}
+
+// Ping проверяет, что каталог бакета существует
+func (l *localStorage) Ping(ctx context.Context) error {
+	dir := filepath.Join(l.root, l.bucket)
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("каталог хранилища %s недоступен: %w", dir, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s не каталог", dir)
+	}
+	return nil
+}
+
+// CreateOne атомарно записывает объект: данные пишутся во временный файл в том же каталоге,
+// который после fsync переименовывается в итоговый. Читатели никогда не видят частично записанный объект.
+// Отмена ctx во время записи оставляет итоговый объект нетронутым.
+func (l *localStorage) CreateOne(ctx context.Context, r io.Reader, size int64, objectKey string) (repository.Object, error) {
+	path, err := l.path(objectKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("ошибка при создании объекта %s: %w", objectKey, err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при создании объекта %s: %w", objectKey, err)
+	}
+	defer os.Remove(tmp.Name()) // после успешного rename файла с этим именем уже нет
+
+	written, err := io.Copy(tmp, contextReader{ctx: ctx, r: r})
+	if err == nil && size >= 0 && written != size {
+		err = fmt.Errorf("записано %d байт из %d", written, size)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при создании объекта %s: %w", objectKey, err)
+	}
+
+	slog.DebugContext(ctx, "Файл сохранен в локальное хранилище", slog.String("object_key", objectKey))
+	return l.GetOne(ctx, objectKey)
+}
+
+func (l *localStorage) GetOne(ctx context.Context, objectKey string) (repository.Object, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при получении объекта %s: %w", objectKey, err)
+	}
+	path, err := l.path(objectKey)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении объекта %s: %w", objectKey, err)
+	}
+	return localObject{File: f, key: objectKey}, nil
+}
+
+func (l *localStorage) DeleteOne(ctx context.Context, objectKey string) error {
+	path, err := l.path(objectKey)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("ошибка при удалении объекта %s: %w", objectKey, err)
+	}
+	return nil
+}
+
+// List перечисляет объекты бакета. Временные файлы незавершенных записей не считаются объектами.
+func (l *localStorage) List(ctx context.Context) ([]repository.ObjectInfo, error) {
+	dir := filepath.Join(l.root, l.bucket)
+	var objects []repository.ObjectInfo
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".upload-") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		objects = append(objects, repository.ObjectInfo{Key: filepath.ToSlash(rel), Size: info.Size(), LastModified: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении списка объектов: %w", err)
+	}
+	return objects, nil
+}
+
+// path переводит ключ объекта в путь внутри каталога бакета, не позволяя выйти за его пределы
+func (l *localStorage) path(objectKey string) (string, error) {
+	clean := filepath.Clean("/" + filepath.FromSlash(objectKey))
+	if objectKey == "" || clean == string(filepath.Separator) || strings.HasPrefix(filepath.Base(clean), ".upload-") {
+		return "", fmt.Errorf("недопустимый ключ объекта %q", objectKey)
+	}
+	return filepath.Join(l.root, l.bucket, clean), nil
+}
@@ -0,0 +1,44 @@
+// Package memory содержит in-memory реализации репозитория, объектного хранилища и RabbitMQ
+// для тестов обработчиков и сервисного слоя без Postgres, Minio и брокера.
+package memory
+
+import (
+	"lct/internal/repository"
+	"lct/internal/repository/rabbitmq"
+	"sync"
+)
+
+var (
+	_ repository.Repository    = (*Repository)(nil)
+	_ repository.ObjectStorage = (*ObjectStorage)(nil)
+	_ rabbitmq.Client          = (*RabbitClient)(nil)
+)
+
+// Faults позволяет заставить любой метод фейка вернуть заданную ошибку
+type Faults struct {
+	mu   sync.Mutex
+	errs map[string]error
+}
+
+// FailOn заставляет метод с именем method возвращать err, пока не будет вызван Clear
+func (f *Faults) FailOn(method string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.errs == nil {
+		f.errs = make(map[string]error)
+	}
+	f.errs[method] = err
+}
+
+// Clear отменяет внедренную ошибку для метода
+func (f *Faults) Clear(method string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.errs, method)
+}
+
+func (f *Faults) check(method string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.errs[method]
+}
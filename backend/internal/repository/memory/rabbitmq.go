@@ -0,0 +1,150 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"lct/internal/repository/rabbitmq"
+	"lct/internal/tracing"
+	"sync"
+	"time"
+)
+
+// RabbitClient in-memory реализация rabbitmq.Client, изображающая CV worker
+type RabbitClient struct {
+	Faults
+
+	// Worker обрабатывает сообщение вместо CV worker'а и возвращает тело ответа; ошибка означает, что ответа не будет.
+	// По умолчанию копирует исходный объект в <output_prefix>processed/<n>.ply в бакете из сообщения, как это делает worker.py.
+	Worker func(body []byte) ([]byte, error)
+	// Consumers сколько CV worker'ов QueueStats сообщает подписанными на очередь задач
+	Consumers int
+
+	mu       sync.Mutex
+	storage  *ObjectStorage
+	messages [][]byte
+	traces   []map[string]string
+	outputs  int // Сколько результатов записал воркер; номер входит в ключ результата
+	// Очередь результатов: ответы ждут, пока их не заберет ConsumeReplies, как в долговечной очереди брокера
+	replies chan queuedReply
+}
+
+// queuedReply ответ в очереди результатов с контекстом трассировки, который CV worker копирует из задачи
+type queuedReply struct {
+	rabbitmq.Reply
+	trace map[string]string
+}
+
+// NewRabbitClient создает брокер, который «обрабатывает» файлы из storage
+func NewRabbitClient(storage *ObjectStorage) *RabbitClient {
+	r := &RabbitClient{storage: storage, Consumers: 1, replies: make(chan queuedReply, 1024)}
+	r.Worker = r.copyWorker
+	return r
+}
+
+func (r *RabbitClient) Connect(ctx context.Context) error {
+	return r.Faults.check("Connect")
+}
+
+func (r *RabbitClient) ReplyQueue() string {
+	return "memory.results"
+}
+
+// Publish «доставляет» сообщение воркеру, который отвечает асинхронно, как настоящий CV worker
+func (r *RabbitClient) Publish(ctx context.Context, msg rabbitmq.Message) error {
+	if err := r.Faults.check("Publish"); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.messages = append(r.messages, append([]byte(nil), msg.Body...))
+	trace := tracing.Inject(ctx)
+	r.traces = append(r.traces, trace)
+	worker := r.Worker
+	r.mu.Unlock()
+
+	go func() {
+		reply, err := worker(msg.Body)
+		if err != nil {
+			return
+		}
+		r.replies <- queuedReply{Reply: rabbitmq.Reply{CorrelationID: msg.CorrelationID, Body: reply}, trace: trace}
+	}()
+	return nil
+}
+
+// Reply кладет ответ в очередь результатов, как это делает CV worker
+func (r *RabbitClient) Reply(correlationID string, body []byte) {
+	r.replies <- queuedReply{Reply: rabbitmq.Reply{CorrelationID: correlationID, Body: body}}
+}
+
+// Heartbeat кладет в очередь результатов heartbeat задачи, как CV worker во время обработки
+func (r *RabbitClient) Heartbeat(correlationID string) {
+	r.replies <- queuedReply{Reply: rabbitmq.Reply{CorrelationID: correlationID, Type: rabbitmq.ReplyTypeHeartbeat}}
+}
+
+// ConsumeReplies передает ответы handle, пока не отменен ctx. Ответ, который handle не записал,
+// возвращается в очередь с небольшой задержкой, как после nack брокеру.
+// Несколько потребителей разбирают одну очередь, как экземпляры сервиса общую очередь результатов.
+func (r *RabbitClient) ConsumeReplies(ctx context.Context, handle rabbitmq.ReplyHandler) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case reply := <-r.replies:
+			if err := handle(tracing.Extract(ctx, reply.trace), reply.Reply); err != nil {
+				reply.Redelivered = true
+				time.AfterFunc(10*time.Millisecond, func() { r.replies <- reply })
+			}
+		}
+	}
+}
+
+// QueueStats очередь задач всегда пуста: воркер забирает сообщение сразу после публикации
+func (r *RabbitClient) QueueStats(ctx context.Context) (rabbitmq.QueueStats, error) {
+	if err := r.Faults.check("QueueStats"); err != nil {
+		return rabbitmq.QueueStats{}, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return rabbitmq.QueueStats{Name: "memory.tasks", Consumers: r.Consumers}, nil
+}
+
+// Messages возвращает все опубликованные сообщения
+func (r *RabbitClient) Messages() [][]byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([][]byte(nil), r.messages...)
+}
+
+// TraceContexts возвращает контекст трассировки каждого опубликованного сообщения, как его получил бы CV worker
+func (r *RabbitClient) TraceContexts() []map[string]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]map[string]string(nil), r.traces...)
+}
+
+func (r *RabbitClient) copyWorker(body []byte) ([]byte, error) {
+	var msg map[string]interface{}
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return nil, err
+	}
+	key, _ := msg["minio_key"].(string)
+	storage := r.storage
+	if bucket, _ := msg["bucket"].(string); bucket != "" {
+		storage = storage.InBucket(bucket)
+	}
+	data, ok := storage.Data(key)
+	if !ok {
+		return json.Marshal(map[string]string{"error": "object not found: " + key, "minio_key": key})
+	}
+
+	prefix, _ := msg["output_prefix"].(string)
+	r.mu.Lock()
+	r.outputs++
+	newKey := fmt.Sprintf("%sprocessed/%d.ply", prefix, r.outputs)
+	r.mu.Unlock()
+	storage.Put(newKey, data)
+
+	msg["minio_key"] = newKey
+	return json.Marshal(msg)
+}
@@ -0,0 +1,1051 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"lct/internal/auth"
+	"lct/internal/domain/errors"
+	"lct/internal/repository/schema"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type fileRow struct {
+	metadata schema.FileMetadata
+}
+
+type objectRow struct {
+	tenant   string
+	bucket   string
+	sha256   string
+	size     int64
+	refCount int
+}
+
+type outboxRow struct {
+	message       schema.OutboxMessage
+	nextAttemptAt time.Time
+}
+
+type cacheKey struct {
+	tenant       string
+	inputSHA256  string
+	paramsHash   string
+	modelVersion string
+}
+
+// Repository in-memory реализация repository.Repository.
+// Идентификаторы выдаются последовательно с 1, как SERIAL в Postgres.
+type Repository struct {
+	Faults
+
+	Bucket string           // Бакет файлов и артефактов, для которых бакет не указан
+	Now    func() time.Time // Часы для created_at/finished_at
+
+	mu        sync.Mutex
+	files     map[int64]*fileRow
+	jobs      map[int64]*schema.Job
+	artifacts map[int64]*schema.Artifact
+	objects   map[string]*objectRow
+	cache     map[cacheKey]int64
+	outbox    map[int64]*outboxRow
+	apiKeys   map[int64]*schema.APIKey
+	tenants   map[string]*schema.Tenant
+	lastID    map[string]int64
+}
+
+// NewRepository создает пустой репозиторий с арендатором default, как после миграций
+func NewRepository() *Repository {
+	r := &Repository{
+		Bucket:    "defaultbucket",
+		Now:       time.Now,
+		files:     make(map[int64]*fileRow),
+		jobs:      make(map[int64]*schema.Job),
+		artifacts: make(map[int64]*schema.Artifact),
+		objects:   make(map[string]*objectRow),
+		cache:     make(map[cacheKey]int64),
+		outbox:    make(map[int64]*outboxRow),
+		apiKeys:   make(map[int64]*schema.APIKey),
+		tenants:   make(map[string]*schema.Tenant),
+		lastID:    make(map[string]int64),
+	}
+	r.tenants[auth.DefaultProject] = &schema.Tenant{ID: auth.DefaultProject, Name: auth.DefaultProject, CreatedAt: r.Now()}
+	return r
+}
+
+func (r *Repository) nextID(table string) int64 {
+	r.lastID[table]++
+	return r.lastID[table]
+}
+
+func (r *Repository) Ping(ctx context.Context) error {
+	return r.Faults.check("Ping")
+}
+
+func (r *Repository) CreatePendingFile(ctx context.Context, file *schema.FileMetadata) (int64, error) {
+	if err := r.Faults.check("CreatePendingFile"); err != nil {
+		return 0, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tenant, ok := r.tenants[file.Project]
+	if !ok {
+		return 0, fmt.Errorf("%w: арендатор %s", errors.ErrNotFound, file.Project)
+	}
+	if tenant.StorageQuotaBytes > 0 {
+		_, used := r.storageUsage(tenant.ID)
+		if used+file.Size > tenant.StorageQuotaBytes {
+			return 0, fmt.Errorf("%w: арендатор %s занимает %d из %d байт, файл %d байт",
+				errors.ErrStorageQuotaExceeded, tenant.ID, used, tenant.StorageQuotaBytes, file.Size)
+		}
+	}
+
+	if file.Bucket == "" {
+		file.Bucket = r.Bucket
+	}
+	id := r.nextID("files")
+	file.ID = int(id)
+	file.Status = schema.FileStatusPending
+	file.CreatedAt = r.Now()
+	r.files[id] = &fileRow{metadata: *file}
+	return id, nil
+}
+
+func (r *Repository) CommitFile(ctx context.Context, id int64, sha256 string) (string, error) {
+	if err := r.Faults.check("CommitFile"); err != nil {
+		return "", err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	row, ok := r.files[id]
+	if !ok || row.metadata.Status != schema.FileStatusPending {
+		return "", fmt.Errorf("%w: загрузка файла с id %d", errors.ErrNotFound, id)
+	}
+
+	key := row.metadata.ObjectKey
+	found := false
+	for k, obj := range r.objects {
+		if obj.tenant == row.metadata.Project && obj.sha256 == sha256 {
+			obj.refCount++
+			key, found = k, true
+			break
+		}
+	}
+	if !found {
+		r.objects[key] = &objectRow{
+			tenant:   row.metadata.Project,
+			bucket:   row.metadata.Bucket,
+			sha256:   sha256,
+			size:     row.metadata.Size,
+			refCount: 1,
+		}
+	}
+
+	row.metadata.Status = schema.FileStatusReady
+	row.metadata.ObjectKey = key
+	row.metadata.SHA256 = sha256
+	return key, nil
+}
+
+func (r *Repository) DeletePendingFile(ctx context.Context, id int64) (bool, error) {
+	if err := r.Faults.check("DeletePendingFile"); err != nil {
+		return false, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	row, ok := r.files[id]
+	if !ok || row.metadata.Status != schema.FileStatusPending {
+		return false, nil
+	}
+	delete(r.files, id)
+	return true, nil
+}
+
+func (r *Repository) GetMetaDataByID(ctx context.Context, id int64) (*schema.FileMetadata, error) {
+	if err := r.Faults.check("GetMetaDataByID"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	row, ok := r.files[id]
+	if !ok || row.metadata.Status != schema.FileStatusReady {
+		return nil, fmt.Errorf("%w: файл с id %d", errors.ErrNotFound, id)
+	}
+	metadata := row.metadata
+	return &metadata, nil
+}
+
+// DeleteFile удаляет файл и, как каскад в Postgres, его задачи, артефакты, записи кэша и сообщения outbox
+func (r *Repository) DeleteFile(ctx context.Context, id int64) (string, int, error) {
+	if err := r.Faults.check("DeleteFile"); err != nil {
+		return "", 0, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	row, ok := r.files[id]
+	if !ok || row.metadata.Status != schema.FileStatusReady {
+		return "", 0, fmt.Errorf("%w: файл с id %d", errors.ErrNotFound, id)
+	}
+	delete(r.files, id)
+
+	for artifactID, artifact := range r.artifacts {
+		if artifact.FileID != id {
+			continue
+		}
+		delete(r.artifacts, artifactID)
+		for key, cachedID := range r.cache {
+			if cachedID == artifactID {
+				delete(r.cache, key)
+			}
+		}
+		for _, job := range r.jobs {
+			if job.ArtifactID != nil && *job.ArtifactID == artifactID {
+				job.ArtifactID = nil
+			}
+		}
+	}
+	for jobID, job := range r.jobs {
+		if job.FileID != id {
+			continue
+		}
+		delete(r.jobs, jobID)
+		for outboxID, msg := range r.outbox {
+			if msg.message.JobID == jobID {
+				delete(r.outbox, outboxID)
+			}
+		}
+	}
+
+	key := row.metadata.ObjectKey
+	refCount := 0
+	if obj, ok := r.objects[key]; ok {
+		obj.refCount--
+		refCount = obj.refCount
+		if refCount <= 0 {
+			delete(r.objects, key)
+			refCount = 0
+		}
+	}
+	return key, refCount, nil
+}
+
+func (r *Repository) ObjectBucket(ctx context.Context, objectKey string, project string) (string, error) {
+	if err := r.Faults.check("ObjectBucket"); err != nil {
+		return "", err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	inProject := func(p string) bool { return project == "" || p == project }
+	for _, row := range r.files {
+		if row.metadata.ObjectKey == objectKey && row.metadata.Status == schema.FileStatusReady && inProject(row.metadata.Project) {
+			return row.metadata.Bucket, nil
+		}
+	}
+	for _, artifact := range r.artifacts {
+		if artifact.ObjectKey != objectKey {
+			continue
+		}
+		if file, ok := r.files[artifact.FileID]; ok && inProject(file.metadata.Project) {
+			return artifact.Bucket, nil
+		}
+		for _, job := range r.jobs {
+			if job.ArtifactID != nil && *job.ArtifactID == artifact.ID && job.Project == project {
+				return artifact.Bucket, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+func (r *Repository) ListFiles(ctx context.Context, filter schema.FileFilter) ([]schema.FileMetadata, int64, error) {
+	if err := r.Faults.check("ListFiles"); err != nil {
+		return nil, 0, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	name := strings.ToLower(filter.Name)
+	var files []schema.FileMetadata
+	for _, row := range r.files {
+		f := row.metadata
+		switch {
+		case f.Status != schema.FileStatusReady,
+			filter.Project != "" && f.Project != filter.Project,
+			name != "" && !strings.Contains(strings.ToLower(f.OriginalFilename), name),
+			filter.SHA256 != "" && f.SHA256 != filter.SHA256,
+			filter.CreatedAfter != nil && f.CreatedAt.Before(*filter.CreatedAfter),
+			filter.CreatedBefore != nil && !f.CreatedAt.Before(*filter.CreatedBefore):
+			continue
+		}
+		files = append(files, f)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].ID > files[j].ID })
+
+	total := int64(len(files))
+	files = files[min(filter.Offset, len(files)):]
+	if filter.Limit > 0 && len(files) > filter.Limit {
+		files = files[:filter.Limit]
+	}
+	return files, total, nil
+}
+
+func (r *Repository) ListPendingFiles(ctx context.Context, olderThan time.Duration) ([]schema.FileMetadata, error) {
+	if err := r.Faults.check("ListPendingFiles"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cutoff := r.Now().Add(-olderThan)
+	var files []schema.FileMetadata
+	for _, row := range r.files {
+		if row.metadata.Status == schema.FileStatusPending && !row.metadata.CreatedAt.After(cutoff) {
+			files = append(files, row.metadata)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].ID < files[j].ID })
+	return files, nil
+}
+
+func (r *Repository) ListReferencedObjectKeys(ctx context.Context, bucket string) ([]string, error) {
+	if err := r.Faults.check("ListReferencedObjectKeys"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	seen := make(map[string]bool)
+	for key, obj := range r.objects {
+		if obj.bucket == bucket {
+			seen[key] = true
+		}
+	}
+	for _, artifact := range r.artifacts {
+		if artifact.Bucket == bucket {
+			seen[artifact.ObjectKey] = true
+		}
+	}
+	keys := make([]string, 0, len(seen))
+	for key := range seen {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// File возвращает запись файла в любом состоянии
+func (r *Repository) File(id int64) (schema.FileMetadata, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	row, ok := r.files[id]
+	if !ok {
+		return schema.FileMetadata{}, false
+	}
+	return row.metadata, true
+}
+
+// RefCount возвращает счетчик ссылок объекта, 0 если объект не зарегистрирован
+func (r *Repository) RefCount(objectKey string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if obj, ok := r.objects[objectKey]; ok {
+		return obj.refCount
+	}
+	return 0
+}
+
+func (r *Repository) CreateJob(ctx context.Context, job *schema.Job) (int64, error) {
+	if err := r.Faults.check("CreateJob"); err != nil {
+		return 0, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.files[job.FileID]; !ok {
+		return 0, fmt.Errorf("%w: файл с id %d", errors.ErrNotFound, job.FileID)
+	}
+	if job.Status == "" {
+		job.Status = schema.JobStatusPending
+	}
+	job.ID = r.nextID("jobs")
+	job.CreatedAt = r.Now()
+	if job.Status != schema.JobStatusPending {
+		finished := job.CreatedAt
+		job.FinishedAt = &finished
+	}
+	stored := *job
+	r.jobs[job.ID] = &stored
+	return job.ID, nil
+}
+
+func (r *Repository) EnqueueJob(ctx context.Context, job *schema.Job, msg *schema.OutboxMessage) (int64, error) {
+	if err := r.Faults.check("EnqueueJob"); err != nil {
+		return 0, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.files[job.FileID]; !ok {
+		return 0, fmt.Errorf("%w: файл с id %d", errors.ErrNotFound, job.FileID)
+	}
+	tenant, ok := r.tenants[job.Project]
+	if !ok {
+		return 0, fmt.Errorf("%w: арендатор %s", errors.ErrNotFound, job.Project)
+	}
+	if tenant.MonthlyJobQuota > 0 {
+		if used := r.jobsThisPeriod(tenant.ID); used >= tenant.MonthlyJobQuota {
+			return 0, fmt.Errorf("%w: арендатор %s выполнил %d из %d обработок в этом месяце",
+				errors.ErrJobQuotaExceeded, tenant.ID, used, tenant.MonthlyJobQuota)
+		}
+	}
+	job.Status = schema.JobStatusPending
+	job.ID = r.nextID("jobs")
+	job.CreatedAt = r.Now()
+	stored := *job
+	r.jobs[job.ID] = &stored
+
+	msg.JobID = job.ID
+	r.insertOutbox(msg)
+	return job.ID, nil
+}
+
+func (r *Repository) RequeueJob(ctx context.Context, jobID int64, from string, msg *schema.OutboxMessage) error {
+	if err := r.Faults.check("RequeueJob"); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	job, ok := r.jobs[jobID]
+	if !ok {
+		return fmt.Errorf("%w: задача с id %d", errors.ErrNotFound, jobID)
+	}
+	if job.Status != from {
+		return errors.ErrConflict.Errorf("задача %d уже в состоянии %s", jobID, job.Status)
+	}
+	job.Status = schema.JobStatusPending
+	job.Error = ""
+	job.FinishedAt = nil
+	job.LeaseExpiresAt = nil
+
+	for id, row := range r.outbox {
+		if row.message.JobID == jobID && row.message.SentAt == nil {
+			delete(r.outbox, id)
+		}
+	}
+	msg.JobID = jobID
+	r.insertOutbox(msg)
+	return nil
+}
+
+func (r *Repository) ResumeJob(ctx context.Context, jobID int64, lease time.Duration) (bool, error) {
+	if err := r.Faults.check("ResumeJob"); err != nil {
+		return false, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	job, ok := r.jobs[jobID]
+	if !ok {
+		return false, fmt.Errorf("%w: задача с id %d", errors.ErrNotFound, jobID)
+	}
+	if job.Status != schema.JobStatusInterrupted {
+		return false, errors.ErrConflict.Errorf("задача %d уже в состоянии %s", jobID, job.Status)
+	}
+	unsent := false
+	for _, row := range r.outbox {
+		unsent = unsent || row.message.JobID == jobID && row.message.SentAt == nil
+	}
+	job.Status = schema.JobStatusPending
+	job.Error = ""
+	job.FinishedAt = nil
+	job.LeaseExpiresAt = nil
+	if !unsent {
+		expires := r.Now().Add(lease)
+		job.LeaseExpiresAt = &expires
+	}
+	return unsent, nil
+}
+
+func (r *Repository) insertOutbox(msg *schema.OutboxMessage) {
+	msg.ID = r.nextID("outbox")
+	msg.CreatedAt = r.Now()
+	r.outbox[msg.ID] = &outboxRow{message: *msg, nextAttemptAt: msg.CreatedAt}
+}
+
+func (r *Repository) ClaimOutbox(ctx context.Context, limit int, lease time.Duration) ([]schema.OutboxMessage, error) {
+	if err := r.Faults.check("ClaimOutbox"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.Now()
+	var messages []schema.OutboxMessage
+	for _, row := range r.outbox {
+		job := r.jobs[row.message.JobID]
+		if row.message.SentAt != nil || row.nextAttemptAt.After(now) || job == nil || !awaitingReply(job) {
+			continue
+		}
+		messages = append(messages, row.message)
+	}
+	sort.Slice(messages, func(i, j int) bool { return messages[i].ID < messages[j].ID })
+	if len(messages) > limit {
+		messages = messages[:limit]
+	}
+	for _, msg := range messages {
+		r.outbox[msg.ID].nextAttemptAt = now.Add(lease)
+	}
+	return messages, nil
+}
+
+func (r *Repository) MarkOutboxSent(ctx context.Context, id int64, lease time.Duration) error {
+	if err := r.Faults.check("MarkOutboxSent"); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	row, ok := r.outbox[id]
+	if !ok {
+		return fmt.Errorf("%w: сообщение outbox с id %d", errors.ErrNotFound, id)
+	}
+	sent := r.Now()
+	row.message.SentAt = &sent
+	if job, ok := r.jobs[row.message.JobID]; ok && awaitingReply(job) {
+		expires := sent.Add(lease)
+		job.Attempts++
+		job.LeaseExpiresAt = &expires
+	}
+	return nil
+}
+
+func (r *Repository) MarkOutboxFailed(ctx context.Context, id int64, errMsg string, retryIn time.Duration) error {
+	if err := r.Faults.check("MarkOutboxFailed"); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	row, ok := r.outbox[id]
+	if !ok {
+		return fmt.Errorf("%w: сообщение outbox с id %d", errors.ErrNotFound, id)
+	}
+	row.message.Attempts++
+	row.message.LastError = errMsg
+	row.nextAttemptAt = r.Now().Add(retryIn)
+	return nil
+}
+
+// Outbox возвращает все сообщения outbox по порядку ID
+func (r *Repository) Outbox() []schema.OutboxMessage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	messages := make([]schema.OutboxMessage, 0, len(r.outbox))
+	for _, row := range r.outbox {
+		messages = append(messages, row.message)
+	}
+	sort.Slice(messages, func(i, j int) bool { return messages[i].ID < messages[j].ID })
+	return messages
+}
+
+func (r *Repository) CompleteJob(ctx context.Context, jobID int64, artifactID int64) error {
+	if err := r.Faults.check("CompleteJob"); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	job, ok := r.jobs[jobID]
+	if !ok {
+		return fmt.Errorf("%w: задача с id %d", errors.ErrNotFound, jobID)
+	}
+	now := r.Now()
+	job.Status = schema.JobStatusDone
+	job.ArtifactID = &artifactID
+	job.FinishedAt = &now
+	job.LeaseExpiresAt = nil
+	return nil
+}
+
+func (r *Repository) FinishJob(ctx context.Context, jobID int64, status string, errMsg string) error {
+	if err := r.Faults.check("FinishJob"); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	job, ok := r.jobs[jobID]
+	if !ok {
+		return fmt.Errorf("%w: задача с id %d", errors.ErrNotFound, jobID)
+	}
+	if job.Status == schema.JobStatusDone || job.Status == schema.JobStatusFailed {
+		return errors.ErrConflict.Errorf("задача %d уже в состоянии %s", jobID, job.Status)
+	}
+	now := r.Now()
+	job.Status = status
+	job.Error = errMsg
+	job.FinishedAt = &now
+	if status == schema.JobStatusFailed {
+		job.LeaseExpiresAt = nil
+	}
+	return nil
+}
+
+// awaitingReply задача еще ждет ответа CV worker'а, и ее аренда имеет смысл
+func awaitingReply(job *schema.Job) bool {
+	return job.Status == schema.JobStatusPending || job.Status == schema.JobStatusTimeout
+}
+
+func (r *Repository) RecordHeartbeat(ctx context.Context, correlationID string, lease time.Duration) (*schema.Job, error) {
+	if err := r.Faults.check("RecordHeartbeat"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, job := range r.jobs {
+		if job.CorrelationID != correlationID || !awaitingReply(job) {
+			continue
+		}
+		now := r.Now()
+		expires := now.Add(lease)
+		job.HeartbeatAt = &now
+		job.LeaseExpiresAt = &expires
+		copied := *job
+		return &copied, nil
+	}
+	return nil, fmt.Errorf("%w: задача, ждущая ответа, с correlation id %s", errors.ErrNotFound, correlationID)
+}
+
+func (r *Repository) ClaimExpiredJobs(ctx context.Context, limit int, lease time.Duration) ([]schema.Job, error) {
+	if err := r.Faults.check("ClaimExpiredJobs"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.Now()
+	var expired []*schema.Job
+	for _, job := range r.jobs {
+		if awaitingReply(job) && job.LeaseExpiresAt != nil && !job.LeaseExpiresAt.After(now) {
+			expired = append(expired, job)
+		}
+	}
+	sort.Slice(expired, func(i, j int) bool { return expired[i].LeaseExpiresAt.Before(*expired[j].LeaseExpiresAt) })
+	if len(expired) > limit {
+		expired = expired[:limit]
+	}
+	jobs := make([]schema.Job, 0, len(expired))
+	for _, job := range expired {
+		expires := now.Add(lease)
+		job.LeaseExpiresAt = &expires
+		jobs = append(jobs, *job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].ID < jobs[j].ID })
+	return jobs, nil
+}
+
+func (r *Repository) GetJob(ctx context.Context, jobID int64) (*schema.Job, error) {
+	if err := r.Faults.check("GetJob"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	job, ok := r.jobs[jobID]
+	if !ok {
+		return nil, fmt.Errorf("%w: задача с id %d", errors.ErrNotFound, jobID)
+	}
+	copied := *job
+	return &copied, nil
+}
+
+func (r *Repository) GetJobByCorrelationID(ctx context.Context, correlationID string) (*schema.Job, error) {
+	if err := r.Faults.check("GetJobByCorrelationID"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, job := range r.jobs {
+		if job.CorrelationID == correlationID {
+			copied := *job
+			return &copied, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: задача с correlation id %s", errors.ErrNotFound, correlationID)
+}
+
+func (r *Repository) ListJobsByStatus(ctx context.Context, status string) ([]schema.Job, error) {
+	if err := r.Faults.check("ListJobsByStatus"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	jobs := make([]schema.Job, 0)
+	for _, job := range r.jobs {
+		if job.Status == status {
+			jobs = append(jobs, *job)
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].ID < jobs[j].ID })
+	return jobs, nil
+}
+
+func (r *Repository) CountJobsByStatus(ctx context.Context, status string) (int64, error) {
+	if err := r.Faults.check("CountJobsByStatus"); err != nil {
+		return 0, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var n int64
+	for _, job := range r.jobs {
+		if job.Status == status {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (r *Repository) JobStatusCounts(ctx context.Context) (map[string]int64, error) {
+	if err := r.Faults.check("JobStatusCounts"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	counts := make(map[string]int64)
+	for _, job := range r.jobs {
+		counts[job.Status]++
+	}
+	return counts, nil
+}
+
+// Job возвращает копию задачи по ID
+func (r *Repository) Job(id int64) (schema.Job, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	job, ok := r.jobs[id]
+	if !ok {
+		return schema.Job{}, false
+	}
+	return *job, true
+}
+
+func (r *Repository) SaveArtifact(ctx context.Context, artifact *schema.Artifact) (int64, error) {
+	if err := r.Faults.check("SaveArtifact"); err != nil {
+		return 0, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.files[artifact.FileID]; !ok {
+		return 0, fmt.Errorf("%w: файл с id %d", errors.ErrNotFound, artifact.FileID)
+	}
+	for _, a := range r.artifacts {
+		if a.ObjectKey == artifact.ObjectKey {
+			return 0, fmt.Errorf("failed to insert artifact: объект %s уже зарегистрирован", artifact.ObjectKey)
+		}
+	}
+	artifact.ID = r.nextID("artifacts")
+	if artifact.Bucket == "" {
+		artifact.Bucket = r.Bucket
+	}
+	artifact.CreatedAt = r.Now()
+	stored := *artifact
+	r.artifacts[artifact.ID] = &stored
+	return artifact.ID, nil
+}
+
+func (r *Repository) GetArtifact(ctx context.Context, id int64) (*schema.Artifact, error) {
+	if err := r.Faults.check("GetArtifact"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	a, ok := r.artifacts[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: артефакт %d", errors.ErrNotFound, id)
+	}
+	artifact := *a
+	return &artifact, nil
+}
+
+func (r *Repository) GetArtifactsByFileID(ctx context.Context, fileID int64) ([]schema.Artifact, error) {
+	if err := r.Faults.check("GetArtifactsByFileID"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	artifacts := make([]schema.Artifact, 0)
+	for _, a := range r.artifacts {
+		if a.FileID == fileID {
+			artifacts = append(artifacts, *a)
+		}
+	}
+	sort.Slice(artifacts, func(i, j int) bool { return artifacts[i].ID < artifacts[j].ID })
+	return artifacts, nil
+}
+
+func (r *Repository) FindCachedResult(ctx context.Context, tenant string, inputSHA256 string, paramsHash string, modelVersion string) (*schema.Artifact, error) {
+	if err := r.Faults.check("FindCachedResult"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id, ok := r.cache[cacheKey{tenant, inputSHA256, paramsHash, modelVersion}]
+	if !ok {
+		return nil, nil
+	}
+	artifact := *r.artifacts[id]
+	return &artifact, nil
+}
+
+func (r *Repository) SaveCachedResult(ctx context.Context, tenant string, inputSHA256 string, paramsHash string, modelVersion string, artifactID int64) error {
+	if err := r.Faults.check("SaveCachedResult"); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.artifacts[artifactID]; !ok {
+		return fmt.Errorf("%w: артефакт %d", errors.ErrNotFound, artifactID)
+	}
+	r.cache[cacheKey{tenant, inputSHA256, paramsHash, modelVersion}] = artifactID
+	return nil
+}
+
+func (r *Repository) InvalidateResultCache(ctx context.Context, modelVersion string) (int64, error) {
+	if err := r.Faults.check("InvalidateResultCache"); err != nil {
+		return 0, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var n int64
+	for key := range r.cache {
+		if key.modelVersion == modelVersion {
+			delete(r.cache, key)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (r *Repository) CreateAPIKey(ctx context.Context, key *schema.APIKey) (int64, error) {
+	if err := r.Faults.check("CreateAPIKey"); err != nil {
+		return 0, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.apiKeys {
+		if existing.Prefix == key.Prefix {
+			return 0, fmt.Errorf("API ключ с префиксом %s уже существует", key.Prefix)
+		}
+	}
+	key.ID = r.nextID("api_keys")
+	key.CreatedAt = r.Now()
+	stored := *key
+	r.apiKeys[key.ID] = &stored
+	return key.ID, nil
+}
+
+func (r *Repository) GetAPIKeyByPrefix(ctx context.Context, prefix string) (*schema.APIKey, error) {
+	if err := r.Faults.check("GetAPIKeyByPrefix"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, key := range r.apiKeys {
+		if key.Prefix == prefix {
+			found := *key
+			return &found, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *Repository) ListAPIKeys(ctx context.Context) ([]schema.APIKey, error) {
+	if err := r.Faults.check("ListAPIKeys"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	keys := make([]schema.APIKey, 0, len(r.apiKeys))
+	for _, key := range r.apiKeys {
+		keys = append(keys, *key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys, nil
+}
+
+func (r *Repository) RevokeAPIKey(ctx context.Context, id int64) error {
+	if err := r.Faults.check("RevokeAPIKey"); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key, ok := r.apiKeys[id]
+	if !ok {
+		return fmt.Errorf("%w: API ключ с id %d", errors.ErrNotFound, id)
+	}
+	if key.RevokedAt == nil {
+		revoked := r.Now()
+		key.RevokedAt = &revoked
+	}
+	return nil
+}
+
+func (r *Repository) TouchAPIKey(ctx context.Context, id int64) error {
+	if err := r.Faults.check("TouchAPIKey"); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key, ok := r.apiKeys[id]
+	if !ok {
+		return nil
+	}
+	now := r.Now()
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > time.Minute {
+		key.LastUsedAt = &now
+	}
+	return nil
+}
+
+func (r *Repository) CreateTenant(ctx context.Context, tenant *schema.Tenant) error {
+	if err := r.Faults.check("CreateTenant"); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.tenants[tenant.ID]; ok {
+		return fmt.Errorf("%w: арендатор %s", errors.ErrConflict, tenant.ID)
+	}
+	tenant.CreatedAt = r.Now()
+	stored := *tenant
+	r.tenants[tenant.ID] = &stored
+	return nil
+}
+
+func (r *Repository) GetTenant(ctx context.Context, id string) (*schema.Tenant, error) {
+	if err := r.Faults.check("GetTenant"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tenant, ok := r.tenants[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: арендатор %s", errors.ErrNotFound, id)
+	}
+	found := *tenant
+	return &found, nil
+}
+
+func (r *Repository) ListTenants(ctx context.Context) ([]schema.Tenant, error) {
+	if err := r.Faults.check("ListTenants"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tenants := make([]schema.Tenant, 0, len(r.tenants))
+	for _, tenant := range r.tenants {
+		tenants = append(tenants, *tenant)
+	}
+	sort.Slice(tenants, func(i, j int) bool { return tenants[i].ID < tenants[j].ID })
+	return tenants, nil
+}
+
+func (r *Repository) UpdateTenant(ctx context.Context, tenant *schema.Tenant) error {
+	if err := r.Faults.check("UpdateTenant"); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.tenants[tenant.ID]
+	if !ok {
+		return fmt.Errorf("%w: арендатор %s", errors.ErrNotFound, tenant.ID)
+	}
+	tenant.CreatedAt = existing.CreatedAt
+	stored := *tenant
+	r.tenants[tenant.ID] = &stored
+	return nil
+}
+
+func (r *Repository) GetTenantUsage(ctx context.Context, id string) (*schema.TenantUsage, error) {
+	if err := r.Faults.check("GetTenantUsage"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tenant, ok := r.tenants[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: арендатор %s", errors.ErrNotFound, id)
+	}
+	files, bytes := r.storageUsage(id)
+	start := r.periodStart()
+	return &schema.TenantUsage{
+		TenantID:          id,
+		Files:             files,
+		StorageBytes:      bytes,
+		StorageQuotaBytes: tenant.StorageQuotaBytes,
+		JobsThisPeriod:    r.jobsThisPeriod(id),
+		MonthlyJobQuota:   tenant.MonthlyJobQuota,
+		PeriodStart:       start,
+		PeriodEnd:         start.AddDate(0, 1, 0),
+	}, nil
+}
+
+// storageUsage считает файлы арендатора и их суммарный размер, включая незавершенные загрузки
+func (r *Repository) storageUsage(tenant string) (int64, int64) {
+	var files, bytes int64
+	for _, row := range r.files {
+		if row.metadata.Project == tenant {
+			files++
+			bytes += row.metadata.Size
+		}
+	}
+	return files, bytes
+}
+
+// jobsThisPeriod считает обработки арендатора CV worker'ом с начала месяца, без попаданий в кэш
+func (r *Repository) jobsThisPeriod(tenant string) int64 {
+	start := r.periodStart()
+	var n int64
+	for _, job := range r.jobs {
+		if job.Project == tenant && !job.CacheHit && !job.CreatedAt.Before(start) {
+			n++
+		}
+	}
+	return n
+}
+
+// periodStart начало текущего календарного месяца по часам репозитория
+func (r *Repository) periodStart() time.Time {
+	now := r.Now()
+	return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+}
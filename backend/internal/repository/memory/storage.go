@@ -0,0 +1,180 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"lct/internal/repository"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ObjectStorage in-memory реализация repository.ObjectStorage
+type ObjectStorage struct {
+	Faults
+
+	Now func() time.Time // Часы для LastModified
+
+	mu      sync.Mutex
+	bucket  string
+	objects map[string]storedObject
+	buckets *bucketSet // Бакеты, общие для хранилищ, полученных через WithBucket
+}
+
+// bucketSet бакеты одного «сервера» хранилища
+type bucketSet struct {
+	mu      sync.Mutex
+	buckets map[string]*ObjectStorage
+}
+
+type storedObject struct {
+	data     []byte
+	modified time.Time
+}
+
+type memoryObject struct {
+	*bytes.Reader
+	info repository.ObjectInfo
+}
+
+func (o *memoryObject) Close() error { return nil }
+
+func (o *memoryObject) Stat() (repository.ObjectInfo, error) { return o.info, nil }
+
+// NewObjectStorage создает пустое хранилище с указанным бакетом
+func NewObjectStorage(bucket string) *ObjectStorage {
+	s := &ObjectStorage{
+		Now:     time.Now,
+		bucket:  bucket,
+		objects: make(map[string]storedObject),
+		buckets: &bucketSet{buckets: make(map[string]*ObjectStorage)},
+	}
+	s.buckets.buckets[bucket] = s
+	return s
+}
+
+func (s *ObjectStorage) WithBucket(bucket string) repository.ObjectStorage {
+	return s.InBucket(bucket)
+}
+
+// InBucket возвращает хранилище другого бакета того же «сервера», создавая его при первом обращении
+func (s *ObjectStorage) InBucket(bucket string) *ObjectStorage {
+	s.buckets.mu.Lock()
+	defer s.buckets.mu.Unlock()
+	if b, ok := s.buckets.buckets[bucket]; ok {
+		return b
+	}
+	b := &ObjectStorage{
+		Now:     s.Now,
+		bucket:  bucket,
+		objects: make(map[string]storedObject),
+		buckets: s.buckets,
+	}
+	s.buckets.buckets[bucket] = b
+	return b
+}
+
+func (s *ObjectStorage) Init(ctx context.Context) error {
+	return s.Faults.check("Init")
+}
+
+func (s *ObjectStorage) Bucket() string {
+	return s.bucket
+}
+
+func (s *ObjectStorage) Ping(ctx context.Context) error {
+	return s.Faults.check("Ping")
+}
+
+func (s *ObjectStorage) CreateOne(ctx context.Context, r io.Reader, size int64, objectKey string) (repository.Object, error) {
+	if err := s.Faults.check("CreateOne"); err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при создании объекта %s: %w", objectKey, err)
+	}
+	if size >= 0 && int64(len(data)) != size {
+		return nil, fmt.Errorf("ошибка при создании объекта %s: записано %d байт из %d", objectKey, len(data), size)
+	}
+	// Как и настоящее хранилище, не сохраняем объект, если загрузку отменили
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при создании объекта %s: %w", objectKey, err)
+	}
+	s.Put(objectKey, data)
+	return s.GetOne(ctx, objectKey)
+}
+
+func (s *ObjectStorage) GetOne(ctx context.Context, objectKey string) (repository.Object, error) {
+	if err := s.Faults.check("GetOne"); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при получении объекта %s: %w", objectKey, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	obj, ok := s.objects[objectKey]
+	if !ok {
+		return nil, fmt.Errorf("ошибка при получении объекта %s: объект не найден", objectKey)
+	}
+	return &memoryObject{
+		Reader: bytes.NewReader(obj.data),
+		info:   repository.ObjectInfo{Key: objectKey, Size: int64(len(obj.data)), LastModified: obj.modified},
+	}, nil
+}
+
+func (s *ObjectStorage) DeleteOne(ctx context.Context, objectKey string) error {
+	if err := s.Faults.check("DeleteOne"); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.objects, objectKey)
+	return nil
+}
+
+func (s *ObjectStorage) List(ctx context.Context) ([]repository.ObjectInfo, error) {
+	if err := s.Faults.check("List"); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	objects := make([]repository.ObjectInfo, 0, len(s.objects))
+	for key, obj := range s.objects {
+		objects = append(objects, repository.ObjectInfo{Key: key, Size: int64(len(obj.data)), LastModified: obj.modified})
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+// Put кладет объект в хранилище напрямую, минуя внедренные ошибки
+func (s *ObjectStorage) Put(objectKey string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[objectKey] = storedObject{data: append([]byte(nil), data...), modified: s.Now()}
+}
+
+// Data возвращает содержимое объекта
+func (s *ObjectStorage) Data(objectKey string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	obj, ok := s.objects[objectKey]
+	return obj.data, ok
+}
+
+// Keys возвращает отсортированный список ключей
+func (s *ObjectStorage) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]string, 0, len(s.objects))
+	for key := range s.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -3,123 +3,180 @@ package minio
 import (
 	//"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
 	"io"
-	"lct/config"
-	"log"
+	"lct/internal/logging"
+	"lct/internal/repository"
+	"log/slog"
 	//"sync"
-	"time"
 )
 
-// minioClient реализация интерфейса MinioClient
+// Config параметры подключения к Minio
+type Config struct {
+	Endpoint  string // Адрес конечной точки Minio
+	AccessKey string // Имя пользователя для доступа к Minio
+	SecretKey string // Пароль для доступа к Minio
+	Bucket    string // Название бакета
+	UseSSL    bool
+}
+
+// minioClient реализация интерфейса repository.ObjectStorage для Minio/S3
 type minioClient struct {
-	mc *minio.Client // Клиент Minio
+	mc  *minio.Client // Клиент Minio
+	cfg Config
+}
+
+// minioObject объект Minio, приведенный к интерфейсу repository.Object
+type minioObject struct {
+	*minio.Object
+}
+
+func (o minioObject) Stat() (repository.ObjectInfo, error) {
+	info, err := o.Object.Stat()
+	if err != nil {
+		return repository.ObjectInfo{}, err
+	}
+	return repository.ObjectInfo{Key: info.Key, Size: info.Size, LastModified: info.LastModified}, nil
 }
 
 // NewMinioClient создает новый экземпляр Minio Client
-func NewMinioClient() Client {
-	return &minioClient{} // Возвращает новый экземпляр minioClient с указанным именем бакета
+func NewMinioClient(cfg Config) repository.ObjectStorage {
+	return &minioClient{cfg: cfg} // Возвращает новый экземпляр minioClient с указанными параметрами подключения
 }
 
-// InitMinio подключается к Minio и создает бакет, если не существует
-// Бакет - это контейнер для хранения объектов в Minio. Он представляет собой пространство имен, в котором можно хранить и организовывать файлы и папки.
-func (m *minioClient) InitMinio() error {
-	ctx := context.Background()
+func (m *minioClient) Bucket() string {
+	return m.cfg.Bucket
+}
 
-	var client *minio.Client
-	var err error
+// WithBucket возвращает клиент другого бакета, использующий уже установленное подключение
+func (m *minioClient) WithBucket(bucket string) repository.ObjectStorage {
+	cfg := m.cfg
+	cfg.Bucket = bucket
+	return &minioClient{mc: m.mc, cfg: cfg}
+}
 
-	// пробуем 5 раз с паузой 3 секунды
-	for i := 0; i < 5; i++ {
-		client, err = minio.New(config.AppConfig.MinioEndpoint, &minio.Options{
-			Creds:  credentials.NewStaticV4(config.AppConfig.MinioRootUser, config.AppConfig.MinioRootPassword, ""),
-			Secure: config.AppConfig.MinioUseSSL,
+// Init подключается к Minio и создает бакет, если не существует.
+// Бакет - это контейнер для хранения объектов в Minio. Он представляет собой пространство имен, в котором можно хранить и организовывать файлы и папки.
+// Делает одну попытку: повторы при запуске, пока Minio поднимается, выполняет пакет bootstrap.
+func (m *minioClient) Init(ctx context.Context) error {
+	if m.mc == nil {
+		client, err := minio.New(m.cfg.Endpoint, &minio.Options{
+			Creds:  credentials.NewStaticV4(m.cfg.AccessKey, m.cfg.SecretKey, ""),
+			Secure: m.cfg.UseSSL,
 		})
-		if err == nil {
-			// сохранили клиент
-			m.mc = client
-
-			// проверяем бакет
-			exists, err := m.mc.BucketExists(ctx, config.AppConfig.BucketName)
-			if err == nil {
-				if !exists {
-					if err := m.mc.MakeBucket(ctx, config.AppConfig.BucketName, minio.MakeBucketOptions{}); err != nil {
-						log.Printf("Ошибка при создании бакета: %v", err)
-						return err
-					}
-					log.Printf("Бакет %s успешно создан", config.AppConfig.BucketName)
-				} else {
-					log.Printf("Бакет %s уже существует", config.AppConfig.BucketName)
-				}
-				return nil // всё успешно
-			}
+		if err != nil {
+			return fmt.Errorf("не удалось создать клиент MinIO: %w", err)
 		}
+		m.mc = client
+	}
 
-		log.Printf("MinIO ещё не готов (попытка %d/10): %v", i+1, err)
-		time.Sleep(3 * time.Second)
+	exists, err := m.mc.BucketExists(ctx, m.cfg.Bucket)
+	if err != nil {
+		return fmt.Errorf("не удалось подключиться к MinIO: %w", err)
 	}
+	if exists {
+		slog.InfoContext(ctx, "Бакет уже существует", slog.String("bucket", m.cfg.Bucket))
+		return nil
+	}
+	if err := m.mc.MakeBucket(ctx, m.cfg.Bucket, minio.MakeBucketOptions{}); err != nil {
+		return fmt.Errorf("ошибка при создании бакета %s: %w", m.cfg.Bucket, err)
+	}
+	slog.InfoContext(ctx, "Бакет создан", slog.String("bucket", m.cfg.Bucket))
+	return nil
+}
 
-	return fmt.Errorf("не удалось подключиться к MinIO после 10 попыток: %w", err)
+// Ping проверяет, что бакет существует и доступен с учетными данными клиента
+func (m *minioClient) Ping(ctx context.Context) error {
+	if m.mc == nil {
+		return errors.New("подключение к MinIO не установлено")
+	}
+	exists, err := m.mc.BucketExists(ctx, m.cfg.Bucket)
+	if err != nil {
+		return fmt.Errorf("бакет %s недоступен: %w", m.cfg.Bucket, err)
+	}
+	if !exists {
+		return fmt.Errorf("бакет %s не существует", m.cfg.Bucket)
+	}
+	return nil
 }
 
 // Контекст используется для передачи сигналов об отмене операции загрузки в случае необходимости.
 
 // CreateOne создает один объект в бакете Minio.
-// Метод принимает структуру fileData, которая содержит имя файла и его данные.
-// В случае успешной загрузки данных в бакет, метод возвращает nil, иначе возвращает ошибку.
+// В случае успешной загрузки данных в бакет, метод возвращает сохраненный объект, иначе возвращает ошибку.
 // Все операции выполняются в контексте задачи.
-func (m *minioClient) CreateOne(r io.Reader, size int64, file FileDataType, objectID string) (*minio.Object, error) {
-
-	// Создание потока данных для загрузки в бакет Minio.
-	//reader := bytes.NewReader(file.Data)
+func (m *minioClient) CreateOne(ctx context.Context, r io.Reader, size int64, objectID string) (repository.Object, error) {
 
 	// Загрузка данных в бакет Minio с использованием контекста для возможности отмены операции.
 	_, err := m.mc.PutObject(
-		context.Background(),
-		config.AppConfig.BucketName,
+		ctx,
+		m.cfg.Bucket,
 		objectID,
 		r,
 		size,
 		minio.PutObjectOptions{},
 	)
 	if err != nil {
-		return nil, fmt.Errorf("ошибка при создании объекта %s: %v", file.FileName, err)
+		return nil, fmt.Errorf("ошибка при создании объекта %s: %v", objectID, err)
 	}
-	info, err := m.mc.StatObject(context.Background(), config.AppConfig.BucketName, objectID, minio.StatObjectOptions{})
-	log.Printf("Saved object size = %d\n", info.Size)
+	info, err := m.mc.StatObject(ctx, m.cfg.Bucket, objectID, minio.StatObjectOptions{})
+	slog.DebugContext(ctx, "Объект сохранен в MinIO", slog.String("object_key", objectID), slog.Int64("size", info.Size))
 
 	object, err := m.mc.GetObject(
-		context.Background(),
-		config.AppConfig.BucketName,
+		ctx,
+		m.cfg.Bucket,
 		objectID,
 		minio.GetObjectOptions{},
 	)
 	if err != nil {
-		return nil, fmt.Errorf("ошибка при получении объекта %s: %v", file.FileName, err)
+		return nil, fmt.Errorf("ошибка при получении объекта %s: %v", objectID, err)
 	}
-	log.Println("файл загружен в minio")
-	return object, nil
+	slog.DebugContext(ctx, "Файл загружен в MinIO", slog.String("object_key", objectID))
+	return minioObject{object}, nil
 }
 
 // GetOne получает один объект из бакета Minio по его идентификатору.
-// Он принимает строку `objectID` в качестве параметра и возвращает срез байт данных объекта и ошибку, если такая возникает.
-func (m *minioClient) GetOne(r io.Reader, size int64, file FileDataType, objectID string) (*minio.Object, error) {
-	// Получение предварительно подписанного URL для доступа к объекту Minio.
+// Он принимает строку `objectID` в качестве параметра и возвращает объект и ошибку, если такая возникает.
+// Чтение объекта прерывается при отмене ctx.
+func (m *minioClient) GetOne(ctx context.Context, objectID string) (repository.Object, error) {
 	object, err := m.mc.GetObject(
-		context.Background(),
-		config.AppConfig.BucketName,
+		ctx,
+		m.cfg.Bucket,
 		objectID,
 		minio.GetObjectOptions{},
 	)
 	if err != nil {
-		log.Printf("ошибка при получении файла")
-		return nil, fmt.Errorf("ошибка при получении объекта %s: %v", file.FileName, err)
+		slog.WarnContext(ctx, "Ошибка при получении файла из MinIO", slog.String("object_key", objectID), logging.Err(err))
+		return nil, fmt.Errorf("ошибка при получении объекта %s: %v", objectID, err)
+	}
+	slog.DebugContext(ctx, "Файл получен из MinIO", slog.String("object_key", objectID))
+	return minioObject{object}, nil
+
+}
+
+// DeleteOne удаляет один объект из бакета Minio по его идентификатору.
+func (m *minioClient) DeleteOne(ctx context.Context, objectID string) error {
+	// Удаление объекта из бакета Minio.
+	err := m.mc.RemoveObject(ctx, m.cfg.Bucket, objectID, minio.RemoveObjectOptions{})
+	if err != nil {
+		return fmt.Errorf("ошибка при удалении объекта %s: %v", objectID, err)
 	}
-	log.Println("файл получен из minio")
-	return object, nil
+	return nil // Возвращаем nil, если объект успешно удалён.
+}
 
+// List перечисляет все объекты бакета Minio, включая вложенные префиксы вида processed/
+func (m *minioClient) List(ctx context.Context) ([]repository.ObjectInfo, error) {
+	var objects []repository.ObjectInfo
+	for info := range m.mc.ListObjects(ctx, m.cfg.Bucket, minio.ListObjectsOptions{Recursive: true}) {
+		if info.Err != nil {
+			return nil, fmt.Errorf("ошибка при получении списка объектов: %v", info.Err)
+		}
+		objects = append(objects, repository.ObjectInfo{Key: info.Key, Size: info.Size, LastModified: info.LastModified})
+	}
+	return objects, nil
 }
 
 // GetMany получает несколько объектов из бакета Minio по их идентификаторам.
@@ -171,17 +228,7 @@ func (m *minioClient) GetOne(r io.Reader, size int64, file FileDataType, objectI
 //
 //	return urls, nil // Возврат массива URL-адресов, если ошибок не возникло
 //}
-//
-//// DeleteOne удаляет один объект из бакета Minio по его идентификатору.
-//func (m *minioClient) DeleteOne(objectID string) error {
-//	// Удаление объекта из бакета Minio.
-//	err := m.mc.RemoveObject(context.Background(), config.AppConfig.BucketName, objectID, minio.RemoveObjectOptions{})
-//	if err != nil {
-//		return err // Возвращаем ошибку, если не удалось удалить объект.
-//	}
-//	return nil // Возвращаем nil, если объект успешно удалён.
-//}
-//
+
 //// DeleteMany удаляет несколько объектов из бакета Minio по их идентификаторам с использованием горутин.
 //func (m *minioClient) DeleteMany(objectIDs []string) error {
 //	// Создание канала для передачи ошибок с размером, равным количеству объектов для удаления
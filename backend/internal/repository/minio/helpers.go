@@ -1,10 +1,5 @@
 package minio
 
-type FileDataType struct {
-	FileName string
-	Data     []byte
-}
-
 type OperationError struct {
 	ObjectID string
 	Error    error
@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// ObjectStorage интерфейс объектного хранилища, в котором лежат исходные и обработанные облака точек.
+// Реализации: Minio/S3 и локальная файловая система с той же раскладкой ключей.
+// Отмена ctx прерывает операцию; объект, полученный через CreateOne или GetOne, читается, пока жив ctx.
+type ObjectStorage interface {
+	Init(ctx context.Context) error                                                           // Подключение к хранилищу и создание бакета, если его нет
+	Bucket() string                                                                           // Имя бакета, в котором хранятся объекты
+	Ping(ctx context.Context) error                                                           // Проверка, что бакет существует и доступен
+	CreateOne(ctx context.Context, r io.Reader, size int64, objectKey string) (Object, error) // Загрузка одного объекта, возвращает сохраненный объект
+	GetOne(ctx context.Context, objectKey string) (Object, error)                             // Получение одного объекта
+	DeleteOne(ctx context.Context, objectKey string) error                                    // Удаление одного объекта
+	List(ctx context.Context) ([]ObjectInfo, error)                                           // Перечисление всех объектов бакета
+	// WithBucket возвращает хранилище с тем же подключением, работающее с другим бакетом.
+	// Бакет не создается: для этого у полученного хранилища вызывается Init.
+	WithBucket(bucket string) ObjectStorage
+}
+
+// Object объект хранилища, открытый на чтение
+type Object interface {
+	io.ReadCloser
+	Stat() (ObjectInfo, error)
+}
+
+// ObjectInfo метаданные объекта хранилища
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
@@ -0,0 +1,113 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"lct/internal/domain/errors"
+	"lct/internal/repository/schema"
+	"time"
+)
+
+func (ps *PostgresStorage) CreateAPIKey(ctx context.Context, key *schema.APIKey) (int64, error) {
+	ctx, cancel := ps.withTimeout(ctx)
+	defer cancel()
+
+	query := `INSERT INTO api_keys (name, prefix, key_hash, role, project) 
+	          VALUES ($1, $2, $3, $4, NULLIF($5, '')) RETURNING id, created_at`
+
+	err := ps.db.QueryRowContext(ctx, query, key.Name, key.Prefix, key.Hash, key.Role, key.Project).Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert api key: %w", err)
+	}
+	return key.ID, nil
+}
+
+func (ps *PostgresStorage) GetAPIKeyByPrefix(ctx context.Context, prefix string) (*schema.APIKey, error) {
+	ctx, cancel := ps.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + apiKeyColumns + ` 
+	          FROM api_keys WHERE prefix = $1`
+
+	key, err := scanAPIKey(ps.db.QueryRowContext(ctx, query, prefix))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("ошибка при поиске API ключа: %w", err)
+	}
+	return key, nil
+}
+
+func (ps *PostgresStorage) ListAPIKeys(ctx context.Context) ([]schema.APIKey, error) {
+	ctx, cancel := ps.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + apiKeyColumns + ` 
+	          FROM api_keys ORDER BY id`
+
+	rows, err := ps.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении API ключей: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []schema.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка при чтении API ключа: %w", err)
+		}
+		keys = append(keys, *key)
+	}
+	return keys, rows.Err()
+}
+
+func (ps *PostgresStorage) RevokeAPIKey(ctx context.Context, id int64) error {
+	ctx, cancel := ps.withTimeout(ctx)
+	defer cancel()
+
+	res, err := ps.db.ExecContext(ctx, `UPDATE api_keys SET revoked_at = COALESCE(revoked_at, now()) WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to revoke api key: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("%w: API ключ с id %d", errors.ErrNotFound, id)
+	}
+	return nil
+}
+
+func (ps *PostgresStorage) TouchAPIKey(ctx context.Context, id int64) error {
+	ctx, cancel := ps.withTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE api_keys SET last_used_at = now() 
+	          WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < now() - interval '1 minute')`
+
+	if _, err := ps.db.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("failed to update api key usage: %w", err)
+	}
+	return nil
+}
+
+const apiKeyColumns = `id, name, prefix, key_hash, role, COALESCE(project, ''), created_at, last_used_at, revoked_at`
+
+// scanAPIKey читает строку api_keys, выбранную по apiKeyColumns
+func scanAPIKey(row interface{ Scan(...interface{}) error }) (*schema.APIKey, error) {
+	var key schema.APIKey
+	var lastUsedAt, revokedAt sql.NullTime
+	if err := row.Scan(&key.ID, &key.Name, &key.Prefix, &key.Hash, &key.Role, &key.Project, &key.CreatedAt, &lastUsedAt, &revokedAt); err != nil {
+		return nil, err
+	}
+	key.LastUsedAt = nullTime(lastUsedAt)
+	key.RevokedAt = nullTime(revokedAt)
+	return &key, nil
+}
+
+func nullTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
@@ -0,0 +1,330 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"lct/internal/domain/errors"
+	"lct/internal/logging"
+	"lct/internal/repository/schema"
+	"log/slog"
+)
+
+func (ps *PostgresStorage) CreateJob(ctx context.Context, job *schema.Job) (int64, error) {
+	ctx, cancel := ps.withTimeout(ctx)
+	defer cancel()
+
+	if err := insertJob(ctx, ps.db, job); err != nil {
+		return 0, err
+	}
+	slog.InfoContext(ctx, "Задача создана", slog.Int64(logging.KeyJobID, job.ID), slog.Int64(logging.KeyFileID, job.FileID), slog.Bool("cache_hit", job.CacheHit))
+	return job.ID, nil
+}
+
+// queryRower общий интерфейс *sql.DB и *sql.Tx для запросов, которые выполняются как отдельно, так и в транзакции
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// insertJob вставляет задачу и заполняет ее ID и время создания
+func insertJob(ctx context.Context, q queryRower, job *schema.Job) error {
+	query := `INSERT INTO jobs (file_id, correlation_id, status, params, params_hash, model_version, cache_hit, artifact_id, owner, project, finished_at) 
+	          VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), $7, $8, NULLIF($9, ''), $10, CASE WHEN $3::text = 'pending' THEN NULL ELSE now() END) 
+	          RETURNING id, created_at`
+
+	var params []byte
+	if job.Params != nil {
+		var err error
+		if params, err = json.Marshal(job.Params); err != nil {
+			return fmt.Errorf("failed to marshal job params: %w", err)
+		}
+	}
+	if job.Status == "" {
+		job.Status = schema.JobStatusPending
+	}
+
+	err := q.QueryRowContext(ctx, query,
+		job.FileID,
+		job.CorrelationID,
+		job.Status,
+		params,
+		job.ParamsHash,
+		job.ModelVersion,
+		job.CacheHit,
+		job.ArtifactID,
+		job.Owner,
+		job.Project,
+	).Scan(&job.ID, &job.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert job: %w", err)
+	}
+	return nil
+}
+
+func (ps *PostgresStorage) CompleteJob(ctx context.Context, jobID int64, artifactID int64) error {
+	ctx, cancel := ps.withTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE jobs SET status = $2, artifact_id = $3, finished_at = now(), lease_expires_at = NULL 
+	          WHERE id = $1`
+
+	res, err := ps.db.ExecContext(ctx, query, jobID, schema.JobStatusDone, artifactID)
+	if err != nil {
+		return fmt.Errorf("failed to complete job: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("%w: задача с id %d", errors.ErrNotFound, jobID)
+	}
+
+	slog.InfoContext(ctx, "Задача выполнена", slog.Int64(logging.KeyJobID, jobID), slog.Int64("artifact_id", artifactID))
+	return nil
+}
+
+func (ps *PostgresStorage) FinishJob(ctx context.Context, jobID int64, status string, errMsg string) error {
+	ctx, cancel := ps.withTimeout(ctx)
+	defer cancel()
+
+	// Задача, которую перестали ждать по таймауту, сохраняет аренду: reaper вернет ее в очередь, если CV worker пропал
+	query := `UPDATE jobs SET status = $2, error = NULLIF($3, ''), finished_at = now(), 
+	          lease_expires_at = CASE WHEN $2::text = $5 THEN NULL ELSE lease_expires_at END 
+	          WHERE id = $1 AND status NOT IN ($4, $5)`
+
+	res, err := ps.db.ExecContext(ctx, query, jobID, status, errMsg, schema.JobStatusDone, schema.JobStatusFailed)
+	if err != nil {
+		return fmt.Errorf("failed to finish job: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return jobNotChanged(ctx, ps.db, jobID)
+	}
+
+	slog.InfoContext(ctx, "Задача завершена", slog.Int64(logging.KeyJobID, jobID), slog.String("status", status))
+	return nil
+}
+
+// jobNotChanged объясняет, почему запрос не изменил задачу: ее нет или по ней уже записан ответ CV worker'а
+func jobNotChanged(ctx context.Context, q queryRower, jobID int64) error {
+	var current string
+	err := q.QueryRowContext(ctx, `SELECT status FROM jobs WHERE id = $1`, jobID).Scan(&current)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("%w: задача с id %d", errors.ErrNotFound, jobID)
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка при получении задачи: %w", err)
+	}
+	return errors.ErrConflict.Errorf("задача %d уже в состоянии %s", jobID, current)
+}
+
+const jobColumns = `id, file_id, correlation_id, status, params, COALESCE(params_hash, ''), COALESCE(model_version, ''), 
+	          cache_hit, artifact_id, COALESCE(error, ''), COALESCE(owner, ''), project, created_at, finished_at, 
+	          attempts, lease_expires_at, heartbeat_at`
+
+// scanJob читает строку jobs, выбранную по jobColumns
+func scanJob(row interface{ Scan(...interface{}) error }) (*schema.Job, error) {
+	var job schema.Job
+	var params []byte
+	var artifactID sql.NullInt64
+	var finishedAt, leaseExpiresAt, heartbeatAt sql.NullTime
+	err := row.Scan(&job.ID, &job.FileID, &job.CorrelationID, &job.Status, &params, &job.ParamsHash, &job.ModelVersion,
+		&job.CacheHit, &artifactID, &job.Error, &job.Owner, &job.Project, &job.CreatedAt, &finishedAt,
+		&job.Attempts, &leaseExpiresAt, &heartbeatAt)
+	if err != nil {
+		return nil, err
+	}
+	if len(params) > 0 {
+		job.Params = &schema.ProcessingParams{}
+		if err := json.Unmarshal(params, job.Params); err != nil {
+			return nil, fmt.Errorf("некорректные параметры задачи %d: %w", job.ID, err)
+		}
+	}
+	if artifactID.Valid {
+		job.ArtifactID = &artifactID.Int64
+	}
+	if finishedAt.Valid {
+		job.FinishedAt = &finishedAt.Time
+	}
+	if leaseExpiresAt.Valid {
+		job.LeaseExpiresAt = &leaseExpiresAt.Time
+	}
+	if heartbeatAt.Valid {
+		job.HeartbeatAt = &heartbeatAt.Time
+	}
+	return &job, nil
+}
+
+func (ps *PostgresStorage) GetJob(ctx context.Context, jobID int64) (*schema.Job, error) {
+	ctx, cancel := ps.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + jobColumns + ` FROM jobs WHERE id = $1`
+
+	job, err := scanJob(ps.db.QueryRowContext(ctx, query, jobID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: задача с id %d", errors.ErrNotFound, jobID)
+		}
+		return nil, fmt.Errorf("ошибка при получении задачи: %w", err)
+	}
+	return job, nil
+}
+
+func (ps *PostgresStorage) GetJobByCorrelationID(ctx context.Context, correlationID string) (*schema.Job, error) {
+	ctx, cancel := ps.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + jobColumns + ` FROM jobs WHERE correlation_id = $1`
+
+	job, err := scanJob(ps.db.QueryRowContext(ctx, query, correlationID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: задача с correlation id %s", errors.ErrNotFound, correlationID)
+		}
+		return nil, fmt.Errorf("ошибка при получении задачи: %w", err)
+	}
+	return job, nil
+}
+
+func (ps *PostgresStorage) ListJobsByStatus(ctx context.Context, status string) ([]schema.Job, error) {
+	ctx, cancel := ps.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + jobColumns + ` FROM jobs WHERE status = $1 ORDER BY id`
+
+	rows, err := ps.db.QueryContext(ctx, query, status)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении задач: %w", err)
+	}
+	defer rows.Close()
+
+	jobs := make([]schema.Job, 0)
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка при чтении задачи: %w", err)
+		}
+		jobs = append(jobs, *job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при получении задач: %w", err)
+	}
+	return jobs, nil
+}
+
+func (ps *PostgresStorage) CountJobsByStatus(ctx context.Context, status string) (int64, error) {
+	ctx, cancel := ps.withTimeout(ctx)
+	defer cancel()
+
+	var n int64
+	if err := ps.db.QueryRowContext(ctx, `SELECT count(*) FROM jobs WHERE status = $1`, status).Scan(&n); err != nil {
+		return 0, fmt.Errorf("ошибка при подсчете задач: %w", err)
+	}
+	return n, nil
+}
+
+func (ps *PostgresStorage) JobStatusCounts(ctx context.Context) (map[string]int64, error) {
+	ctx, cancel := ps.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := ps.db.QueryContext(ctx, `SELECT status, count(*) FROM jobs GROUP BY status`)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при подсчете задач: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int64)
+	for rows.Next() {
+		var (
+			status string
+			n      int64
+		)
+		if err := rows.Scan(&status, &n); err != nil {
+			return nil, fmt.Errorf("ошибка при подсчете задач: %w", err)
+		}
+		counts[status] = n
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при подсчете задач: %w", err)
+	}
+	return counts, nil
+}
+
+func (ps *PostgresStorage) SaveArtifact(ctx context.Context, artifact *schema.Artifact) (int64, error) {
+	ctx, cancel := ps.withTimeout(ctx)
+	defer cancel()
+
+	query := `INSERT INTO artifacts (file_id, job_id, type, bucket, object_key, size, checksum) 
+	          VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, created_at`
+
+	if artifact.Bucket == "" {
+		artifact.Bucket = ps.bucket
+	}
+	err := ps.db.QueryRowContext(ctx, query,
+		artifact.FileID,
+		artifact.JobID,
+		artifact.Type,
+		artifact.Bucket,
+		artifact.ObjectKey,
+		artifact.Size,
+		artifact.Checksum,
+	).Scan(&artifact.ID, &artifact.CreatedAt)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert artifact: %w", err)
+	}
+
+	slog.DebugContext(ctx, "Артефакт сохранен в БД", slog.Int64("artifact_id", artifact.ID), slog.Int64(logging.KeyFileID, artifact.FileID), slog.String("type", artifact.Type))
+	return artifact.ID, nil
+}
+
+func (ps *PostgresStorage) GetArtifact(ctx context.Context, id int64) (*schema.Artifact, error) {
+	ctx, cancel := ps.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT id, file_id, job_id, type, bucket, object_key, size, checksum, created_at 
+	          FROM artifacts WHERE id = $1`
+
+	var a schema.Artifact
+	var jobID sql.NullInt64
+	err := ps.db.QueryRowContext(ctx, query, id).Scan(&a.ID, &a.FileID, &jobID, &a.Type, &a.Bucket, &a.ObjectKey, &a.Size, &a.Checksum, &a.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: артефакт %d", errors.ErrNotFound, id)
+		}
+		return nil, fmt.Errorf("ошибка при получении артефакта: %w", err)
+	}
+	if jobID.Valid {
+		a.JobID = &jobID.Int64
+	}
+	return &a, nil
+}
+
+func (ps *PostgresStorage) GetArtifactsByFileID(ctx context.Context, fileID int64) ([]schema.Artifact, error) {
+	ctx, cancel := ps.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT id, file_id, job_id, type, bucket, object_key, size, checksum, created_at 
+	          FROM artifacts WHERE file_id = $1 ORDER BY id`
+
+	rows, err := ps.db.QueryContext(ctx, query, fileID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении артефактов: %w", err)
+	}
+	defer rows.Close()
+
+	artifacts := make([]schema.Artifact, 0)
+	for rows.Next() {
+		var a schema.Artifact
+		var jobID sql.NullInt64
+		if err := rows.Scan(&a.ID, &a.FileID, &jobID, &a.Type, &a.Bucket, &a.ObjectKey, &a.Size, &a.Checksum, &a.CreatedAt); err != nil {
+			return nil, fmt.Errorf("ошибка при чтении артефакта: %w", err)
+		}
+		if jobID.Valid {
+			a.JobID = &jobID.Int64
+		}
+		artifacts = append(artifacts, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при получении артефактов: %w", err)
+	}
+
+	return artifacts, nil
+}
@@ -0,0 +1,63 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"lct/internal/repository/schema"
+	"log/slog"
+)
+
+func (ps *PostgresStorage) FindCachedResult(ctx context.Context, tenant string, inputSHA256 string, paramsHash string, modelVersion string) (*schema.Artifact, error) {
+	ctx, cancel := ps.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT a.id, a.file_id, a.job_id, a.type, a.bucket, a.object_key, a.size, a.checksum, a.created_at 
+	          FROM result_cache rc JOIN artifacts a ON a.id = rc.artifact_id 
+	          WHERE rc.tenant = $1 AND rc.input_sha256 = $2 AND rc.params_hash = $3 AND rc.model_version = $4`
+
+	var a schema.Artifact
+	var jobID sql.NullInt64
+	err := ps.db.QueryRowContext(ctx, query, tenant, inputSHA256, paramsHash, modelVersion).Scan(
+		&a.ID, &a.FileID, &jobID, &a.Type, &a.Bucket, &a.ObjectKey, &a.Size, &a.Checksum, &a.CreatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("ошибка при поиске в кэше результатов: %w", err)
+	}
+	if jobID.Valid {
+		a.JobID = &jobID.Int64
+	}
+	return &a, nil
+}
+
+func (ps *PostgresStorage) SaveCachedResult(ctx context.Context, tenant string, inputSHA256 string, paramsHash string, modelVersion string, artifactID int64) error {
+	ctx, cancel := ps.withTimeout(ctx)
+	defer cancel()
+
+	query := `INSERT INTO result_cache (tenant, input_sha256, params_hash, model_version, artifact_id) 
+	          VALUES ($1, $2, $3, $4, $5) 
+	          ON CONFLICT (tenant, input_sha256, params_hash, model_version) 
+	          DO UPDATE SET artifact_id = EXCLUDED.artifact_id, created_at = now()`
+
+	if _, err := ps.db.ExecContext(ctx, query, tenant, inputSHA256, paramsHash, modelVersion, artifactID); err != nil {
+		return fmt.Errorf("failed to save cached result: %w", err)
+	}
+	return nil
+}
+
+func (ps *PostgresStorage) InvalidateResultCache(ctx context.Context, modelVersion string) (int64, error) {
+	ctx, cancel := ps.withTimeout(ctx)
+	defer cancel()
+
+	res, err := ps.db.ExecContext(ctx, `DELETE FROM result_cache WHERE model_version = $1`, modelVersion)
+	if err != nil {
+		return 0, fmt.Errorf("failed to invalidate result cache: %w", err)
+	}
+	n, _ := res.RowsAffected()
+
+	slog.InfoContext(ctx, "Кэш результатов очищен", slog.String("model_version", modelVersion), slog.Int64("deleted", n))
+	return n, nil
+}
@@ -0,0 +1,67 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"lct/internal/domain/errors"
+	"lct/internal/repository/schema"
+	"sort"
+	"time"
+)
+
+// RecordHeartbeat продлевает аренду задачи, ждущей ответа CV worker'а. Попытку засчитывает публикация сообщения
+// (MarkOutboxSent), поэтому heartbeat только сдвигает срок аренды.
+func (ps *PostgresStorage) RecordHeartbeat(ctx context.Context, correlationID string, lease time.Duration) (*schema.Job, error) {
+	ctx, cancel := ps.withTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE jobs SET heartbeat_at = now(), lease_expires_at = now() + $2 * interval '1 second'
+	          WHERE correlation_id = $1 AND status IN ($3, $4)
+	          RETURNING ` + jobColumns
+
+	job, err := scanJob(ps.db.QueryRowContext(ctx, query, correlationID, lease.Seconds(), schema.JobStatusPending, schema.JobStatusTimeout))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: задача, ждущая ответа, с correlation id %s", errors.ErrNotFound, correlationID)
+		}
+		return nil, fmt.Errorf("failed to record heartbeat: %w", err)
+	}
+	return job, nil
+}
+
+// ClaimExpiredJobs выбирает до limit задач в состоянии pending или timeout, аренда которых истекла,
+// и продлевает ее на lease. Если reaper не успеет вернуть задачу в очередь, она будет выбрана снова.
+func (ps *PostgresStorage) ClaimExpiredJobs(ctx context.Context, limit int, lease time.Duration) ([]schema.Job, error) {
+	ctx, cancel := ps.withTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE jobs SET lease_expires_at = now() + $3 * interval '1 second'
+	          WHERE id IN (
+	              SELECT id FROM jobs
+	              WHERE status IN ($2, $4) AND lease_expires_at <= now()
+	              ORDER BY lease_expires_at LIMIT $1
+	              FOR UPDATE SKIP LOCKED)
+	          RETURNING ` + jobColumns
+
+	rows, err := ps.db.QueryContext(ctx, query, limit, schema.JobStatusPending, lease.Seconds(), schema.JobStatusTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при выборке задач с истекшей арендой: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []schema.Job
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка при чтении задачи: %w", err)
+		}
+		jobs = append(jobs, *job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// RETURNING не сохраняет порядок подзапроса
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].ID < jobs[j].ID })
+	return jobs, nil
+}
@@ -0,0 +1,248 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"lct/internal/domain/errors"
+	"lct/internal/logging"
+	"lct/internal/repository/schema"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+// EnqueueJob в одной транзакции создает задачу и сообщение для CV worker'а в outbox.
+// Если транзакция зафиксирована, сообщение будет опубликовано relay'ем, даже если RabbitMQ сейчас недоступен.
+// Квота обработок проверяется под блокировкой арендатора, чтобы параллельные задачи не превысили ее вместе.
+func (ps *PostgresStorage) EnqueueJob(ctx context.Context, job *schema.Job, msg *schema.OutboxMessage) (int64, error) {
+	ctx, cancel := ps.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := ps.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	tenant, err := lockTenant(ctx, tx, job.Project)
+	if err != nil {
+		return 0, err
+	}
+	if err := checkJobQuota(ctx, tx, tenant); err != nil {
+		return 0, err
+	}
+
+	job.Status = schema.JobStatusPending
+	if err := insertJob(ctx, tx, job); err != nil {
+		return 0, err
+	}
+	msg.JobID = job.ID
+	if err := insertOutbox(ctx, tx, msg); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	slog.InfoContext(ctx, "Задача создана, сообщение ожидает публикации", slog.Int64(logging.KeyJobID, job.ID), slog.Int64(logging.KeyFileID, job.FileID), slog.Int64("outbox_id", msg.ID))
+	return job.ID, nil
+}
+
+// RequeueJob возвращает задачу из состояния from в pending и в той же транзакции ставит новое сообщение в outbox.
+// Условие на состояние не дает нескольким экземплярам, одновременно возобновляющим задачу, поставить ее дважды.
+// Аренда снимается: новую попытку засчитает и аренду выдаст публикация сообщения (MarkOutboxSent).
+func (ps *PostgresStorage) RequeueJob(ctx context.Context, jobID int64, from string, msg *schema.OutboxMessage) error {
+	ctx, cancel := ps.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := ps.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE jobs SET status = $2, error = NULL, finished_at = NULL, lease_expires_at = NULL 
+	          WHERE id = $1 AND status = $3`
+
+	res, err := tx.ExecContext(ctx, query, jobID, schema.JobStatusPending, from)
+	if err != nil {
+		return fmt.Errorf("failed to requeue job: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return jobNotChanged(ctx, tx, jobID)
+	}
+	// Неопубликованное сообщение прошлой попытки заменяется новым, чтобы задача не ушла CV worker'у дважды
+	if _, err := tx.ExecContext(ctx, `DELETE FROM outbox WHERE job_id = $1 AND sent_at IS NULL`, jobID); err != nil {
+		return fmt.Errorf("failed to requeue job: %w", err)
+	}
+	msg.JobID = jobID
+	if err := insertOutbox(ctx, tx, msg); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	slog.InfoContext(ctx, "Задача возвращена в очередь", slog.Int64(logging.KeyJobID, jobID))
+	return nil
+}
+
+// ResumeJob возвращает прерванную задачу в pending. Неопубликованное сообщение опубликует relay, и аренду выдаст
+// публикация; если сообщение уже опубликовано, CV worker получил задачу, поэтому аренда выдается сразу.
+// Попытка не засчитывается: задача не отдается worker'у повторно.
+func (ps *PostgresStorage) ResumeJob(ctx context.Context, jobID int64, lease time.Duration) (bool, error) {
+	ctx, cancel := ps.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := ps.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Relay не публикует сообщения прерванных задач, поэтому до смены состояния ответ не изменится
+	var unsent bool
+	err = tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM outbox WHERE job_id = $1 AND sent_at IS NULL)`, jobID).Scan(&unsent)
+	if err != nil {
+		return false, fmt.Errorf("failed to resume job: %w", err)
+	}
+
+	query := `UPDATE jobs SET status = $2, error = NULL, finished_at = NULL, 
+	          lease_expires_at = CASE WHEN $4 THEN NULL ELSE now() + $5 * interval '1 second' END 
+	          WHERE id = $1 AND status = $3`
+
+	res, err := tx.ExecContext(ctx, query, jobID, schema.JobStatusPending, schema.JobStatusInterrupted, unsent, lease.Seconds())
+	if err != nil {
+		return false, fmt.Errorf("failed to resume job: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, jobNotChanged(ctx, tx, jobID)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	slog.InfoContext(ctx, "Прерванная задача возобновлена", slog.Int64(logging.KeyJobID, jobID), slog.Bool("republish", unsent))
+	return unsent, nil
+}
+
+// insertOutbox вставляет сообщение и заполняет его ID и время создания
+func insertOutbox(ctx context.Context, q queryRower, msg *schema.OutboxMessage) error {
+	query := `INSERT INTO outbox (job_id, correlation_id, reply_to, routing_key, payload, trace_context) 
+	          VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`
+
+	var traceContext []byte
+	if len(msg.TraceContext) > 0 {
+		var err error
+		if traceContext, err = json.Marshal(msg.TraceContext); err != nil {
+			return fmt.Errorf("failed to marshal trace context: %w", err)
+		}
+	}
+	err := q.QueryRowContext(ctx, query, msg.JobID, msg.CorrelationID, msg.ReplyTo, msg.RoutingKey, msg.Payload, traceContext).
+		Scan(&msg.ID, &msg.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert outbox message: %w", err)
+	}
+	return nil
+}
+
+// ClaimOutbox выбирает до limit неопубликованных сообщений, чье время попытки наступило, и откладывает их на lease,
+// чтобы relay другого экземпляра не взял их одновременно. Задача, которую клиент перестал ждать по таймауту,
+// пока брокер был недоступен, все равно публикуется: ее результат будет записан в фоне.
+// Сообщения выполненных задач и прерванных, которые будут поставлены в очередь заново, пропускаются.
+func (ps *PostgresStorage) ClaimOutbox(ctx context.Context, limit int, lease time.Duration) ([]schema.OutboxMessage, error) {
+	ctx, cancel := ps.withTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE outbox SET next_attempt_at = now() + $3 * interval '1 second' 
+	          WHERE id IN (
+	              SELECT o.id FROM outbox o JOIN jobs j ON j.id = o.job_id 
+	              WHERE o.sent_at IS NULL AND o.next_attempt_at <= now() AND j.status IN ($2, $4) 
+	              ORDER BY o.id LIMIT $1 
+	              FOR UPDATE OF o SKIP LOCKED) 
+	          RETURNING id, job_id, correlation_id, reply_to, routing_key, payload, trace_context, attempts, COALESCE(last_error, ''), created_at`
+
+	rows, err := ps.db.QueryContext(ctx, query, limit, schema.JobStatusPending, lease.Seconds(), schema.JobStatusTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при выборке сообщений outbox: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []schema.OutboxMessage
+	for rows.Next() {
+		var msg schema.OutboxMessage
+		var traceContext []byte
+		if err := rows.Scan(&msg.ID, &msg.JobID, &msg.CorrelationID, &msg.ReplyTo, &msg.RoutingKey, &msg.Payload,
+			&traceContext, &msg.Attempts, &msg.LastError, &msg.CreatedAt); err != nil {
+			return nil, fmt.Errorf("ошибка при чтении сообщения outbox: %w", err)
+		}
+		// Испорченный контекст трассировки не должен мешать доставке задачи
+		if len(traceContext) > 0 {
+			if err := json.Unmarshal(traceContext, &msg.TraceContext); err != nil {
+				slog.WarnContext(ctx, "Некорректный контекст трассировки сообщения outbox", slog.Int64("outbox_id", msg.ID), logging.Err(err))
+			}
+		}
+		messages = append(messages, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// RETURNING не сохраняет порядок подзапроса
+	sort.Slice(messages, func(i, j int) bool { return messages[i].ID < messages[j].ID })
+	return messages, nil
+}
+
+// MarkOutboxSent отмечает сообщение опубликованным после подтверждения брокера. Задаче, которая еще ждет ответа,
+// в той же транзакции засчитывается попытка и выдается аренда на lease: до первого heartbeat'а worker'а
+// reaper отсчитывает ее от публикации.
+func (ps *PostgresStorage) MarkOutboxSent(ctx context.Context, id int64, lease time.Duration) error {
+	ctx, cancel := ps.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := ps.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var jobID int64
+	err = tx.QueryRowContext(ctx, `UPDATE outbox SET sent_at = now() WHERE id = $1 RETURNING job_id`, id).Scan(&jobID)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("%w: сообщение outbox с id %d", errors.ErrNotFound, id)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to mark outbox message sent: %w", err)
+	}
+
+	query := `UPDATE jobs SET attempts = attempts + 1, lease_expires_at = now() + $2 * interval '1 second'
+	          WHERE id = $1 AND status IN ($3, $4)`
+
+	if _, err := tx.ExecContext(ctx, query, jobID, lease.Seconds(), schema.JobStatusPending, schema.JobStatusTimeout); err != nil {
+		return fmt.Errorf("failed to start job lease: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// MarkOutboxFailed фиксирует неудачную попытку публикации и откладывает следующую на retryIn
+func (ps *PostgresStorage) MarkOutboxFailed(ctx context.Context, id int64, errMsg string, retryIn time.Duration) error {
+	ctx, cancel := ps.withTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE outbox SET attempts = attempts + 1, last_error = $2, next_attempt_at = now() + $3 * interval '1 second' 
+	          WHERE id = $1`
+
+	res, err := ps.db.ExecContext(ctx, query, id, errMsg, retryIn.Seconds())
+	if err != nil {
+		return fmt.Errorf("failed to mark outbox message failed: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("%w: сообщение outbox с id %d", errors.ErrNotFound, id)
+	}
+	return nil
+}
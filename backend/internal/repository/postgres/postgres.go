@@ -4,21 +4,30 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
-	"lct/config"
+	"lct/internal/domain/errors"
+	"lct/internal/logging"
 	"lct/internal/repository/schema"
-	"log"
+	"log/slog"
+	"strings"
 	"time"
 )
 
 type PostgresStorage struct {
-	db *sql.DB
+	db           *sql.DB
+	bucket       string        // Бакет, в котором хранятся объекты файлов и артефактов
+	queryTimeout time.Duration // Таймаут одного обращения к базе; 0 — без ограничения
 }
 
 func (ps *PostgresStorage) GetDb() *sql.DB {
 	return ps.db
 }
 
-func NewPostgresStorage(connStr string) (*PostgresStorage, error) {
+// Close закрывает пул соединений с базой
+func (ps *PostgresStorage) Close() error {
+	return ps.db.Close()
+}
+
+func NewPostgresStorage(ctx context.Context, connStr string, bucket string, queryTimeout time.Duration) (*PostgresStorage, error) {
 	db, err := sql.Open("postgres", connStr)
 	if err != nil {
 		return nil, err
@@ -31,45 +40,342 @@ func NewPostgresStorage(connStr string) (*PostgresStorage, error) {
 	db.SetMaxOpenConns(8)
 	db.SetMaxIdleConns(4)
 	db.SetConnMaxLifetime(time.Hour)
-	err = db.Ping()
+	ps := &PostgresStorage{db: db, bucket: bucket, queryTimeout: queryTimeout}
+	pingCtx, cancel := ps.withTimeout(ctx)
+	defer cancel()
+	err = db.PingContext(pingCtx)
 	if err != nil {
 		return nil, err
 	}
-	return &PostgresStorage{db: db}, nil
+	return ps, nil
+}
+
+// withTimeout ограничивает обращение к базе таймаутом queryTimeout поверх дедлайна вызывающего
+func (ps *PostgresStorage) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if ps.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, ps.queryTimeout)
+}
+
+// Ping проверяет соединение с базой
+func (ps *PostgresStorage) Ping(ctx context.Context) error {
+	ctx, cancel := ps.withTimeout(ctx)
+	defer cancel()
+	return ps.db.PingContext(ctx)
 }
 
-func (ps *PostgresStorage) SaveMetaData(ctx *context.Context, fileName string, fileSize int64, objectKey string) (int64, error) {
-	query := `INSERT INTO files (original_filename, size, bucket, object_key) 
-	          VALUES ($1, $2, $3, $4) RETURNING id`
+// CreatePendingFile резервирует запись файла до загрузки объекта и заполняет ее ID, статус и время создания.
+// Пока запись в состоянии pending, файл не виден остальным методам, а сверка считает его объект занятым.
+// Размер файла сразу входит в потребление арендатора, поэтому параллельные загрузки не превысят квоту вместе.
+// Файл без бакета попадает в общий бакет сервиса.
+func (ps *PostgresStorage) CreatePendingFile(ctx context.Context, file *schema.FileMetadata) (int64, error) {
+	ctx, cancel := ps.withTimeout(ctx)
+	defer cancel()
 
-	var ID int64
-	err := ps.db.QueryRowContext(*ctx, query, fileName, fileSize, config.AppConfig.BucketName, objectKey).Scan(&ID)
+	tx, err := ps.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	tenant, err := lockTenant(ctx, tx, file.Project)
+	if err != nil {
+		return 0, err
+	}
+	if err := checkStorageQuota(ctx, tx, tenant, file.Size); err != nil {
+		return 0, err
+	}
+
+	query := `INSERT INTO files (original_filename, size, bucket, object_key, status, owner, project) 
+	          VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7) RETURNING id, created_at`
+
+	if file.Bucket == "" {
+		file.Bucket = ps.bucket
+	}
+	file.Status = schema.FileStatusPending
+	err = tx.QueryRowContext(ctx, query, file.OriginalFilename, file.Size, file.Bucket, file.ObjectKey, file.Status,
+		file.Owner, file.Project).Scan(&file.ID, &file.CreatedAt)
 	if err != nil {
 		return 0, fmt.Errorf("failed to insert metadata: %w", err)
 	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
 
-	log.Printf("Метаданные сохранены в БД, id=%d", ID)
-	return ID, nil
+	slog.DebugContext(ctx, "Метаданные сохранены в БД", slog.Int(logging.KeyFileID, file.ID), slog.String("project", file.Project))
+	return int64(file.ID), nil
 }
 
-func (ps *PostgresStorage) GetMetaDataByID(ctx *context.Context, id int64) (*schema.FileMetadata, error) {
-	query := `SELECT id, original_filename, object_key 
-	          FROM files WHERE id = $1`
+// CommitFile в одной транзакции регистрирует загруженный объект по SHA-256 и переводит запись файла в состояние ready.
+// Если у арендатора файла объект с таким содержимым уже есть, увеличивает его счетчик ссылок, и запись ссылается на существующий ключ.
+// Объекты разных арендаторов не объединяются, даже если совпадают по содержимому.
+// Возвращает ключ объекта, на который ссылается файл.
+func (ps *PostgresStorage) CommitFile(ctx context.Context, id int64, sha256 string) (string, error) {
+	ctx, cancel := ps.withTimeout(ctx)
+	defer cancel()
 
-	var metadata schema.FileMetadata
+	tx, err := ps.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
 
-	err := ps.db.QueryRowContext(*ctx, query, id).Scan(
-		&metadata.ID,
-		&metadata.OriginalFilename,
-		&metadata.ObjectKey,
-	)
+	var objectKey, bucket, project string
+	var size int64
+	err = tx.QueryRowContext(ctx, `SELECT object_key, bucket, project, size FROM files 
+	          WHERE id = $1 AND status = $2 FOR UPDATE`, id, schema.FileStatusPending).Scan(&objectKey, &bucket, &project, &size)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("файл с id %d не найден", id)
+			return "", fmt.Errorf("%w: загрузка файла с id %d", errors.ErrNotFound, id)
+		}
+		return "", fmt.Errorf("failed to lock file: %w", err)
+	}
+
+	var key string
+	err = tx.QueryRowContext(ctx, `INSERT INTO objects (object_key, bucket, sha256, size, tenant) 
+	          VALUES ($1, $2, $3, $4, $5) 
+	          ON CONFLICT (tenant, sha256) DO UPDATE SET ref_count = objects.ref_count + 1 
+	          RETURNING object_key`, objectKey, bucket, sha256, size, project).Scan(&key)
+	if err != nil {
+		return "", fmt.Errorf("failed to acquire object: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE files SET status = $2, object_key = $3, sha256 = $4 
+	          WHERE id = $1`, id, schema.FileStatusReady, key, sha256)
+	if err != nil {
+		return "", fmt.Errorf("failed to commit file: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	if key != objectKey {
+		slog.InfoContext(ctx, "Найден объект с таким же содержимым", slog.Int64(logging.KeyFileID, id), slog.String("object_key", key), slog.String("duplicate_key", objectKey))
+	}
+	return key, nil
+}
+
+// DeletePendingFile удаляет незавершенную загрузку. Возвращает false, если записи в состоянии pending уже нет.
+func (ps *PostgresStorage) DeletePendingFile(ctx context.Context, id int64) (bool, error) {
+	ctx, cancel := ps.withTimeout(ctx)
+	defer cancel()
+
+	res, err := ps.db.ExecContext(ctx, `DELETE FROM files WHERE id = $1 AND status = $2`, id, schema.FileStatusPending)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete pending file: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// ListPendingFiles возвращает незавершенные загрузки, начатые не позже чем olderThan назад.
+// Возраст считается по часам базы, чтобы расхождение часов сервиса и базы не влияло на сверку.
+func (ps *PostgresStorage) ListPendingFiles(ctx context.Context, olderThan time.Duration) ([]schema.FileMetadata, error) {
+	ctx, cancel := ps.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + fileColumns + ` 
+	          FROM files WHERE status = $1 AND created_at <= now() - $2 * interval '1 second' ORDER BY id`
+
+	rows, err := ps.db.QueryContext(ctx, query, schema.FileStatusPending, olderThan.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении незавершенных загрузок: %w", err)
+	}
+	defer rows.Close()
+
+	var files []schema.FileMetadata
+	for rows.Next() {
+		f, err := scanFile(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка при чтении незавершенной загрузки: %w", err)
+		}
+		files = append(files, *f)
+	}
+	return files, rows.Err()
+}
+
+// ListReferencedObjectKeys возвращает ключи всех объектов бакета, на которые ссылаются завершенные загрузки и артефакты
+func (ps *PostgresStorage) ListReferencedObjectKeys(ctx context.Context, bucket string) ([]string, error) {
+	ctx, cancel := ps.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT object_key FROM objects WHERE bucket = $1 
+	          UNION SELECT object_key FROM artifacts WHERE bucket = $1`
+
+	rows, err := ps.db.QueryContext(ctx, query, bucket)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении ключей объектов: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, fmt.Errorf("ошибка при чтении ключа объекта: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func (ps *PostgresStorage) GetMetaDataByID(ctx context.Context, id int64) (*schema.FileMetadata, error) {
+	ctx, cancel := ps.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + fileColumns + ` FROM files WHERE id = $1 AND status = $2`
+
+	metadata, err := scanFile(ps.db.QueryRowContext(ctx, query, id, schema.FileStatusReady))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: файл с id %d", errors.ErrNotFound, id)
 		}
 		return nil, fmt.Errorf("ошибка при получении метаданных: %w", err)
 	}
 
-	log.Printf("Метаданные получены из БД для id=%d", id)
-	return &metadata, nil
+	slog.DebugContext(ctx, "Метаданные получены из БД", slog.Int64(logging.KeyFileID, id))
+	return metadata, nil
+}
+
+// ListFiles ищет завершенные загрузки; условия фильтра собираются в WHERE, имя ищется подстрокой без учета регистра
+func (ps *PostgresStorage) ListFiles(ctx context.Context, filter schema.FileFilter) ([]schema.FileMetadata, int64, error) {
+	ctx, cancel := ps.withTimeout(ctx)
+	defer cancel()
+
+	conds := []string{"status = $1"}
+	args := []interface{}{schema.FileStatusReady}
+	where := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	if filter.Project != "" {
+		where("project = $%d", filter.Project)
+	}
+	if filter.Name != "" {
+		where(`original_filename ILIKE '%%' || $%d || '%%' ESCAPE '\'`, likeEscaper.Replace(filter.Name))
+	}
+	if filter.SHA256 != "" {
+		where("sha256 = $%d", filter.SHA256)
+	}
+	if filter.CreatedAfter != nil {
+		where("created_at >= $%d", *filter.CreatedAfter)
+	}
+	if filter.CreatedBefore != nil {
+		where("created_at < $%d", *filter.CreatedBefore)
+	}
+	whereSQL := strings.Join(conds, " AND ")
+
+	var total int64
+	if err := ps.db.QueryRowContext(ctx, `SELECT count(*) FROM files WHERE `+whereSQL, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("ошибка при подсчете файлов: %w", err)
+	}
+
+	query := `SELECT ` + fileColumns + ` FROM files WHERE ` + whereSQL + ` ORDER BY id DESC`
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+	args = append(args, filter.Offset)
+	query += fmt.Sprintf(" OFFSET $%d", len(args))
+
+	rows, err := ps.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("ошибка при поиске файлов: %w", err)
+	}
+	defer rows.Close()
+
+	var files []schema.FileMetadata
+	for rows.Next() {
+		f, err := scanFile(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("ошибка при чтении файла: %w", err)
+		}
+		files = append(files, *f)
+	}
+	return files, total, rows.Err()
+}
+
+// likeEscaper экранирует спецсимволы шаблона LIKE, чтобы строка поиска совпадала буквально
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+const fileColumns = `id, original_filename, size, bucket, object_key, COALESCE(sha256, ''), status, COALESCE(owner, ''), project, created_at`
+
+// scanFile читает строку files, выбранную по fileColumns
+func scanFile(row interface{ Scan(...interface{}) error }) (*schema.FileMetadata, error) {
+	var f schema.FileMetadata
+	err := row.Scan(&f.ID, &f.OriginalFilename, &f.Size, &f.Bucket, &f.ObjectKey, &f.SHA256, &f.Status, &f.Owner, &f.Project, &f.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// DeleteFile в одной транзакции удаляет завершенную загрузку вместе с ее задачами и артефактами и освобождает ее объект.
+// Возвращает ключ объекта и оставшееся число ссылок на него; объект можно удалить из хранилища, только если ссылок не осталось.
+// Объекты артефактов удаляются сверкой, когда на них перестают ссылаться записи.
+func (ps *PostgresStorage) DeleteFile(ctx context.Context, id int64) (string, int, error) {
+	ctx, cancel := ps.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := ps.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var objectKey string
+	err = tx.QueryRowContext(ctx, `DELETE FROM files WHERE id = $1 AND status = $2 RETURNING object_key`,
+		id, schema.FileStatusReady).Scan(&objectKey)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", 0, fmt.Errorf("%w: файл с id %d", errors.ErrNotFound, id)
+		}
+		return "", 0, fmt.Errorf("failed to delete file: %w", err)
+	}
+
+	// Файлы, загруженные до учета объектов, в objects не записаны и владеют объектом единолично
+	var refCount int
+	err = tx.QueryRowContext(ctx, `UPDATE objects SET ref_count = ref_count - 1 
+	          WHERE object_key = $1 RETURNING ref_count`, objectKey).Scan(&refCount)
+	if err != nil && err != sql.ErrNoRows {
+		return "", 0, fmt.Errorf("failed to release object: %w", err)
+	}
+	if refCount <= 0 {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM objects WHERE object_key = $1`, objectKey); err != nil {
+			return "", 0, fmt.Errorf("failed to delete object: %w", err)
+		}
+		refCount = 0
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	slog.InfoContext(ctx, "Файл удален", slog.Int64(logging.KeyFileID, id), slog.String("object_key", objectKey), slog.Int("refs", refCount))
+	return objectKey, refCount, nil
+}
+
+// ObjectBucket возвращает бакет объекта, если на него ссылается файл проекта, артефакт файла проекта или задача проекта,
+// получившая результат из кэша. Пустой project означает любой проект.
+func (ps *PostgresStorage) ObjectBucket(ctx context.Context, objectKey string, project string) (string, error) {
+	ctx, cancel := ps.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT bucket FROM files WHERE object_key = $1 AND status = $3 AND ($2 = '' OR project = $2) 
+	          UNION ALL SELECT a.bucket FROM artifacts a JOIN files f ON f.id = a.file_id WHERE a.object_key = $1 AND ($2 = '' OR f.project = $2) 
+	          UNION ALL SELECT a.bucket FROM artifacts a JOIN jobs j ON j.artifact_id = a.id WHERE a.object_key = $1 AND j.project = $2 
+	          LIMIT 1`
+
+	var bucket string
+	err := ps.db.QueryRowContext(ctx, query, objectKey, project, schema.FileStatusReady).Scan(&bucket)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return "", fmt.Errorf("ошибка при проверке доступа к объекту: %w", err)
+	}
+	return bucket, nil
 }
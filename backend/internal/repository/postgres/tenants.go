@@ -0,0 +1,178 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"lct/internal/domain/errors"
+	"lct/internal/repository/schema"
+	"log/slog"
+)
+
+const tenantColumns = `id, name, COALESCE(bucket, ''), prefix, routing_key, storage_quota_bytes, monthly_job_quota, created_at`
+
+// scanTenant читает строку tenants, выбранную по tenantColumns
+func scanTenant(row interface{ Scan(...interface{}) error }) (*schema.Tenant, error) {
+	var t schema.Tenant
+	err := row.Scan(&t.ID, &t.Name, &t.Bucket, &t.Prefix, &t.RoutingKey, &t.StorageQuotaBytes, &t.MonthlyJobQuota, &t.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// lockTenant блокирует строку арендатора до конца транзакции, чтобы параллельные загрузки и задачи
+// одного арендатора проверяли квоту по очереди
+func lockTenant(ctx context.Context, q queryRower, id string) (*schema.Tenant, error) {
+	query := `SELECT ` + tenantColumns + ` FROM tenants WHERE id = $1 FOR UPDATE`
+
+	t, err := scanTenant(q.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: арендатор %s", errors.ErrNotFound, id)
+		}
+		return nil, fmt.Errorf("failed to lock tenant: %w", err)
+	}
+	return t, nil
+}
+
+func (ps *PostgresStorage) CreateTenant(ctx context.Context, tenant *schema.Tenant) error {
+	ctx, cancel := ps.withTimeout(ctx)
+	defer cancel()
+
+	query := `INSERT INTO tenants (id, name, bucket, prefix, routing_key, storage_quota_bytes, monthly_job_quota)
+	          VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, $7)
+	          ON CONFLICT (id) DO NOTHING RETURNING created_at`
+
+	err := ps.db.QueryRowContext(ctx, query, tenant.ID, tenant.Name, tenant.Bucket, tenant.Prefix, tenant.RoutingKey,
+		tenant.StorageQuotaBytes, tenant.MonthlyJobQuota).Scan(&tenant.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("%w: арендатор %s", errors.ErrConflict, tenant.ID)
+		}
+		return fmt.Errorf("failed to insert tenant: %w", err)
+	}
+	slog.InfoContext(ctx, "Арендатор создан", slog.String("tenant", tenant.ID))
+	return nil
+}
+
+func (ps *PostgresStorage) GetTenant(ctx context.Context, id string) (*schema.Tenant, error) {
+	ctx, cancel := ps.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + tenantColumns + ` FROM tenants WHERE id = $1`
+
+	t, err := scanTenant(ps.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: арендатор %s", errors.ErrNotFound, id)
+		}
+		return nil, fmt.Errorf("ошибка при получении арендатора: %w", err)
+	}
+	return t, nil
+}
+
+func (ps *PostgresStorage) ListTenants(ctx context.Context) ([]schema.Tenant, error) {
+	ctx, cancel := ps.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := ps.db.QueryContext(ctx, `SELECT `+tenantColumns+` FROM tenants ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении арендаторов: %w", err)
+	}
+	defer rows.Close()
+
+	tenants := make([]schema.Tenant, 0)
+	for rows.Next() {
+		t, err := scanTenant(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка при чтении арендатора: %w", err)
+		}
+		tenants = append(tenants, *t)
+	}
+	return tenants, rows.Err()
+}
+
+func (ps *PostgresStorage) UpdateTenant(ctx context.Context, tenant *schema.Tenant) error {
+	ctx, cancel := ps.withTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE tenants SET name = $2, bucket = NULLIF($3, ''), prefix = $4, routing_key = $5,
+	          storage_quota_bytes = $6, monthly_job_quota = $7
+	          WHERE id = $1 RETURNING created_at`
+
+	err := ps.db.QueryRowContext(ctx, query, tenant.ID, tenant.Name, tenant.Bucket, tenant.Prefix, tenant.RoutingKey,
+		tenant.StorageQuotaBytes, tenant.MonthlyJobQuota).Scan(&tenant.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("%w: арендатор %s", errors.ErrNotFound, tenant.ID)
+		}
+		return fmt.Errorf("failed to update tenant: %w", err)
+	}
+	slog.InfoContext(ctx, "Настройки арендатора обновлены", slog.String("tenant", tenant.ID))
+	return nil
+}
+
+// GetTenantUsage считает загрузки арендатора, включая незавершенные, и обработки CV worker'ом с начала месяца.
+// Задачи, выполненные из кэша, в квоту не входят.
+func (ps *PostgresStorage) GetTenantUsage(ctx context.Context, id string) (*schema.TenantUsage, error) {
+	ctx, cancel := ps.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT t.id, t.storage_quota_bytes, t.monthly_job_quota,
+	              (SELECT count(*) FROM files f WHERE f.project = t.id),
+	              (SELECT COALESCE(sum(f.size), 0) FROM files f WHERE f.project = t.id),
+	              (SELECT count(*) FROM jobs j WHERE j.project = t.id AND NOT j.cache_hit
+	                  AND j.created_at >= date_trunc('month', localtimestamp)),
+	              date_trunc('month', localtimestamp),
+	              date_trunc('month', localtimestamp) + interval '1 month'
+	          FROM tenants t WHERE t.id = $1`
+
+	var u schema.TenantUsage
+	err := ps.db.QueryRowContext(ctx, query, id).Scan(&u.TenantID, &u.StorageQuotaBytes, &u.MonthlyJobQuota,
+		&u.Files, &u.StorageBytes, &u.JobsThisPeriod, &u.PeriodStart, &u.PeriodEnd)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: арендатор %s", errors.ErrNotFound, id)
+		}
+		return nil, fmt.Errorf("ошибка при подсчете потребления арендатора: %w", err)
+	}
+	return &u, nil
+}
+
+// checkStorageQuota проверяет, что файл размером size помещается в квоту хранилища арендатора.
+// Вызывается в транзакции после lockTenant.
+func checkStorageQuota(ctx context.Context, q queryRower, t *schema.Tenant, size int64) error {
+	if t.StorageQuotaBytes <= 0 {
+		return nil
+	}
+	var used int64
+	err := q.QueryRowContext(ctx, `SELECT COALESCE(sum(size), 0) FROM files WHERE project = $1`, t.ID).Scan(&used)
+	if err != nil {
+		return fmt.Errorf("failed to count tenant storage: %w", err)
+	}
+	if used+size > t.StorageQuotaBytes {
+		return fmt.Errorf("%w: арендатор %s занимает %d из %d байт, файл %d байт",
+			errors.ErrStorageQuotaExceeded, t.ID, used, t.StorageQuotaBytes, size)
+	}
+	return nil
+}
+
+// checkJobQuota проверяет, что арендатор не исчерпал квоту обработок за текущий месяц.
+// Вызывается в транзакции после lockTenant.
+func checkJobQuota(ctx context.Context, q queryRower, t *schema.Tenant) error {
+	if t.MonthlyJobQuota <= 0 {
+		return nil
+	}
+	var used int64
+	query := `SELECT count(*) FROM jobs
+	          WHERE project = $1 AND NOT cache_hit AND created_at >= date_trunc('month', localtimestamp)`
+	if err := q.QueryRowContext(ctx, query, t.ID).Scan(&used); err != nil {
+		return fmt.Errorf("failed to count tenant jobs: %w", err)
+	}
+	if used >= t.MonthlyJobQuota {
+		return fmt.Errorf("%w: арендатор %s выполнил %d из %d обработок в этом месяце",
+			errors.ErrJobQuotaExceeded, t.ID, used, t.MonthlyJobQuota)
+	}
+	return nil
+}
@@ -0,0 +1,358 @@
+package rabbitmq
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"lct/internal/bootstrap"
+	"lct/internal/logging"
+	"lct/internal/metrics"
+	"lct/internal/tracing"
+	"log/slog"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Config параметры подключения к RabbitMQ
+type Config struct {
+	URL      string // URL для подключения к RabbitMQ
+	Exchange string // Имя exchange, в который публикуются задачи
+	// Тип exchange: direct (по умолчанию) и topic доставляют задачи по ключу маршрутизации арендатора,
+	// fanout — всем CV worker'ам без учета ключа
+	ExchangeType string
+	Queue        string // Очередь задач CV worker'а; сервис ее не объявляет, а только следит за ее состоянием
+	// Долговечная очередь результатов, общая для всех экземпляров; ее имя уходит CV worker'у в reply_to
+	ResultsQueue string
+	// Задержка между переподключениями к очереди ответов; нулевая — значения bootstrap по умолчанию
+	Backoff bootstrap.Backoff
+}
+
+// Типы exchange задач
+const (
+	ExchangeFanout = "fanout"
+	ExchangeDirect = "direct"
+	ExchangeTopic  = "topic"
+)
+
+// replyPrefetch сколько неподтвержденных ответов брокер передает одному экземпляру
+const replyPrefetch = 16
+
+// rabbitClient реализация интерфейса Client.
+// Задачи публикуются через одно соединение с каналом в режиме подтверждений,
+// ответы читаются из общей очереди результатов на отдельном соединении.
+type rabbitClient struct {
+	cfg Config
+
+	mu      sync.Mutex // Защищает соединение для публикации
+	conn    *amqp.Connection
+	ch      *amqp.Channel
+	returns chan amqp.Return // Сообщения, которые брокер не смог доставить ни в одну очередь
+}
+
+// NewRabbitClient создает новый экземпляр RabbitMQ Client. Соединение открывается при первой публикации.
+func NewRabbitClient(cfg Config) Client {
+	return &rabbitClient{cfg: cfg}
+}
+
+func (r *rabbitClient) ReplyQueue() string {
+	return r.cfg.ResultsQueue
+}
+
+// Publish публикует задачу в exchange и ждет подтверждения брокера.
+// Задача публикуется как mandatory: если ни одна очередь не привязана к ключу арендатора, брокер возвращает ее,
+// и публикация считается неудавшейся, чтобы relay повторил ее, а не потерял задачу.
+// При ошибке соединения соединение сбрасывается и будет открыто заново при следующей публикации.
+// Контекст трассировки ctx передается в заголовках сообщения, чтобы CV worker продолжил ту же трассу.
+func (r *rabbitClient) Publish(ctx context.Context, msg Message) (err error) {
+	ctx, span := tracing.Start(ctx, r.cfg.Exchange+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(messagingAttributes(r.cfg.Exchange, msg.CorrelationID)...),
+		trace.WithAttributes(attribute.String("messaging.rabbitmq.destination.routing_key", msg.RoutingKey)))
+	defer func() { tracing.End(span, err) }()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	start := time.Now()
+	ch, err := r.channel()
+	if err != nil {
+		metrics.AMQPPublish.WithLabelValues(metrics.PublishError).Inc()
+		return err
+	}
+
+	confirm, err := ch.PublishWithDeferredConfirmWithContext(
+		ctx,
+		r.cfg.Exchange, // exchange
+		msg.RoutingKey, // routingKey арендатора, для fanout не учитывается
+		true,           // mandatory
+		false,          // immediate
+		amqp.Publishing{
+			ContentType:   "application/json",
+			DeliveryMode:  amqp.Persistent,
+			MessageId:     fmt.Sprintf("%d", msg.ID),
+			Body:          msg.Body,
+			ReplyTo:       msg.ReplyTo,
+			CorrelationId: msg.CorrelationID,
+			Headers:       injectHeaders(ctx),
+		},
+	)
+	if err != nil {
+		r.reset()
+		metrics.AMQPPublish.WithLabelValues(metrics.PublishError).Inc()
+		return fmt.Errorf("не удалось отправить сообщение в exchange: %w", err)
+	}
+	acked, err := confirm.WaitContext(ctx)
+	if err != nil {
+		r.reset()
+		metrics.AMQPPublish.WithLabelValues(metrics.PublishTimeout).Inc()
+		return fmt.Errorf("не дождались подтверждения публикации: %w", err)
+	}
+	metrics.AMQPPublishDuration.Observe(time.Since(start).Seconds())
+	if !acked {
+		metrics.AMQPPublish.WithLabelValues(metrics.PublishNack).Inc()
+		return fmt.Errorf("брокер отклонил сообщение %d", msg.ID)
+	}
+	if ret, ok := r.returned(msg.ID); ok {
+		metrics.AMQPPublish.WithLabelValues(metrics.PublishReturned).Inc()
+		return fmt.Errorf("сообщение %d не доставлено ни в одну очередь с ключом %q: %s", msg.ID, msg.RoutingKey, ret.ReplyText)
+	}
+	metrics.AMQPPublish.WithLabelValues(metrics.PublishAck).Inc()
+
+	slog.InfoContext(ctx, "Задача отправлена в exchange", slog.String("correlation_id", msg.CorrelationID), slog.String("exchange", r.cfg.Exchange), slog.String("routing_key", msg.RoutingKey))
+	return nil
+}
+
+// returned забирает возврат сообщения id. Брокер отправляет basic.return раньше подтверждения,
+// поэтому к моменту подтверждения возврат уже в канале. Возвраты прежних публикаций, чьих подтверждений не дождались, отбрасываются.
+func (r *rabbitClient) returned(id int64) (amqp.Return, bool) {
+	messageID := fmt.Sprintf("%d", id)
+	for {
+		select {
+		case ret := <-r.returns:
+			if ret.MessageId == messageID {
+				return ret, true
+			}
+		default:
+			return amqp.Return{}, false
+		}
+	}
+}
+
+// Connect открывает соединение для публикации, если оно еще не открыто
+func (r *rabbitClient) Connect(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, err := r.channel()
+	return err
+}
+
+// QueueStats возвращает состояние очереди задач через пассивное объявление на отдельном канале:
+// если очереди нет, брокер закрывает канал, а соединение для публикации остается рабочим.
+func (r *rabbitClient) QueueStats(ctx context.Context) (QueueStats, error) {
+	if r.cfg.Queue == "" {
+		return QueueStats{}, stderrors.New("очередь задач не задана")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := r.channel(); err != nil {
+		return QueueStats{}, err
+	}
+	ch, err := r.conn.Channel()
+	if err != nil {
+		r.reset()
+		return QueueStats{}, fmt.Errorf("ошибка канала RabbitMQ: %w", err)
+	}
+	defer ch.Close()
+
+	// amqp091 не принимает ctx в QueueDeclarePassive, поэтому ответ брокера ждем в отдельной горутине
+	type result struct {
+		q   amqp.Queue
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		q, err := ch.QueueDeclarePassive(r.cfg.Queue, true, false, false, false, nil)
+		done <- result{q, err}
+	}()
+	select {
+	case <-ctx.Done():
+		return QueueStats{}, ctx.Err()
+	case res := <-done:
+		if res.err != nil {
+			return QueueStats{}, fmt.Errorf("очередь %s недоступна: %w", r.cfg.Queue, res.err)
+		}
+		return QueueStats{Name: res.q.Name, Messages: res.q.Messages, Consumers: res.q.Consumers}, nil
+	}
+}
+
+// channel возвращает канал для публикации, при необходимости подключаясь к RabbitMQ
+func (r *rabbitClient) channel() (*amqp.Channel, error) {
+	if r.ch != nil && !r.ch.IsClosed() {
+		return r.ch, nil
+	}
+	r.reset()
+
+	conn, err := amqp.Dial(r.cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось подключиться к RabbitMQ: %w", err)
+	}
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("ошибка канала RabbitMQ: %w", err)
+	}
+	if err := r.declareExchange(ch); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	// Очередь результатов должна существовать до первой задачи: ответ в несуществующую очередь брокер отбросит
+	if err := r.declareResultsQueue(ch); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := ch.Confirm(false); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("не удалось включить подтверждения публикации: %w", err)
+	}
+
+	// Публикации идут по одной и каждая забирает свой возврат, поэтому в канале ждет не больше одного
+	r.returns = ch.NotifyReturn(make(chan amqp.Return, 1))
+	r.conn, r.ch = conn, ch
+	return ch, nil
+}
+
+// reset закрывает соединение для публикации
+func (r *rabbitClient) reset() {
+	if r.conn != nil {
+		r.conn.Close()
+	}
+	r.conn, r.ch = nil, nil
+}
+
+func (r *rabbitClient) declareExchange(ch *amqp.Channel) error {
+	kind := r.cfg.ExchangeType
+	if kind == "" {
+		kind = ExchangeDirect
+	}
+	err := ch.ExchangeDeclare(
+		r.cfg.Exchange, // имя exchange
+		kind,           // тип
+		true,           // durable
+		false,          // autoDelete
+		false,          // internal
+		false,          // noWait
+		nil,
+	)
+	if err != nil {
+		return fmt.Errorf("ошибка объявления exchange: %w", err)
+	}
+	return nil
+}
+
+// declareResultsQueue объявляет долговечную очередь результатов. Она не привязана к соединению экземпляра,
+// поэтому ответы, пришедшие во время перезапуска или обрыва, дожидаются любого экземпляра сервиса.
+func (r *rabbitClient) declareResultsQueue(ch *amqp.Channel) error {
+	if r.cfg.ResultsQueue == "" {
+		return stderrors.New("очередь результатов не задана")
+	}
+	if _, err := ch.QueueDeclare(r.cfg.ResultsQueue, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("ошибка объявления очереди результатов: %w", err)
+	}
+	return nil
+}
+
+// ConsumeReplies читает очередь результатов и переподключается после обрыва, пока не отменен ctx.
+// Задержка между попытками растет экспоненциально и сбрасывается, как только подписка на очередь удалась.
+func (r *rabbitClient) ConsumeReplies(ctx context.Context, handle ReplyHandler) {
+	attempt := 0
+	for {
+		subscribed, err := r.consumeOnce(ctx, handle)
+		if ctx.Err() != nil {
+			return
+		}
+		if subscribed {
+			attempt = 0
+		}
+		attempt++
+		delay := r.cfg.Backoff.Delay(attempt)
+		slog.WarnContext(ctx, "Очередь результатов недоступна, переподключение", slog.String("queue", r.cfg.ResultsQueue),
+			slog.Int("attempt", attempt), slog.Duration("retry_in", delay), logging.Err(err))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// handleReply передает ответ handle и подтверждает его брокеру. Span обработки ответа продолжает трассу из заголовков,
+// которые CV worker скопировал из задачи, поэтому ответ виден в трассе запроса.
+func (r *rabbitClient) handleReply(ctx context.Context, msg amqp.Delivery, handle ReplyHandler) (err error) {
+	ctx, span := tracing.Start(extractHeaders(ctx, msg.Headers), r.cfg.ResultsQueue+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(messagingAttributes(r.cfg.ResultsQueue, msg.CorrelationId)...),
+		trace.WithAttributes(attribute.Bool("messaging.rabbitmq.redelivered", msg.Redelivered)))
+	defer func() { tracing.End(span, err) }()
+
+	if err := handle(ctx, Reply{CorrelationID: msg.CorrelationId, Type: msg.Type, Body: msg.Body, Redelivered: msg.Redelivered}); err != nil {
+		// Ответ вернется в очередь; соединение переоткрывается с задержкой, чтобы не перебирать ответы вхолостую
+		msg.Nack(false, true)
+		return fmt.Errorf("ответ %s не записан: %w", msg.CorrelationId, err)
+	}
+	if err := msg.Ack(false); err != nil {
+		return fmt.Errorf("не удалось подтвердить ответ %s: %w", msg.CorrelationId, err)
+	}
+	return nil
+}
+
+// consumeOnce подписывается на очередь результатов и читает ее до обрыва или ошибки записи ответа;
+// subscribed — удалась ли подписка
+func (r *rabbitClient) consumeOnce(ctx context.Context, handle ReplyHandler) (subscribed bool, err error) {
+	conn, err := amqp.Dial(r.cfg.URL)
+	if err != nil {
+		return false, fmt.Errorf("не удалось подключиться к RabbitMQ: %w", err)
+	}
+	defer conn.Close()
+	closed := conn.NotifyClose(make(chan *amqp.Error, 1))
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return false, fmt.Errorf("ошибка канала RabbitMQ: %w", err)
+	}
+	if err := r.declareResultsQueue(ch); err != nil {
+		return false, err
+	}
+	if err := ch.Qos(replyPrefetch, 0, false); err != nil {
+		return false, fmt.Errorf("ошибка настройки prefetch: %w", err)
+	}
+	// Ответы подтверждаются вручную после записи в базу: неподтвержденные брокер вернет в очередь при обрыве
+	msgs, err := ch.Consume(r.cfg.ResultsQueue, "", false, false, false, false, nil)
+	if err != nil {
+		return false, fmt.Errorf("ошибка подписки на очередь результатов: %w", err)
+	}
+	slog.InfoContext(ctx, "Ожидаем ответы CV worker'а", slog.String("queue", r.cfg.ResultsQueue))
+
+	for {
+		select {
+		case <-ctx.Done():
+			return true, nil
+		case amqpErr := <-closed:
+			if amqpErr == nil {
+				return true, stderrors.New("соединение закрыто")
+			}
+			return true, amqpErr
+		case msg, ok := <-msgs:
+			if !ok {
+				return true, stderrors.New("очередь результатов закрыта")
+			}
+			if err := r.handleReply(ctx, msg, handle); err != nil {
+				return true, err
+			}
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package rabbitmq
+
+import (
+	"context"
+)
+
+// Message задача для CV worker'а, публикуемая в exchange задач
+type Message struct {
+	ID            int64  // ID сообщения в outbox, передается как MessageId
+	CorrelationID string // По нему ответ сопоставляется с задачей
+	ReplyTo       string // Очередь, в которую CV worker пришлет ответ
+	RoutingKey    string // Ключ маршрутизации арендатора; fanout exchange его не учитывает
+	Body          []byte
+}
+
+// ReplyTypeHeartbeat тип (свойство type) сообщения, которым CV worker подтверждает, что задача еще обрабатывается
+const ReplyTypeHeartbeat = "heartbeat"
+
+// Reply ответ CV worker'а из очереди результатов
+type Reply struct {
+	CorrelationID string
+	Type          string // ReplyTypeHeartbeat или пусто для результата обработки
+	Body          []byte
+	Redelivered   bool // Брокер уже доставлял этот ответ, но его не подтвердили
+}
+
+// ReplyHandler записывает ответ CV worker'а. Ответ подтверждается брокеру, только если handler вернул nil;
+// иначе он возвращается в очередь и будет доставлен повторно, возможно другому экземпляру сервиса.
+type ReplyHandler func(ctx context.Context, reply Reply) error
+
+// QueueStats состояние очереди задач CV worker'а
+type QueueStats struct {
+	Name      string
+	Messages  int // Сообщения, ожидающие CV worker'а
+	Consumers int // Подписанные CV worker'ы
+}
+
+// Client интерфейс для взаимодействия с RabbitMQ
+type Client interface {
+	// Connect открывает соединение для публикации и объявляет exchange задач и очередь результатов.
+	// Publish подключается и сам, поэтому Connect нужен только для проверки брокера при запуске.
+	Connect(ctx context.Context) error
+	// Publish публикует задачу и ждет подтверждения брокера (publisher confirm)
+	Publish(ctx context.Context, msg Message) error
+	// ReplyQueue возвращает долговечную очередь результатов, общую для всех экземпляров сервиса
+	ReplyQueue() string
+	// ConsumeReplies читает очередь результатов и передает ответы handle, пока не отменен ctx.
+	// После обрыва соединения или ошибки handle переподключается; неподтвержденные ответы брокер доставит повторно.
+	ConsumeReplies(ctx context.Context, handle ReplyHandler)
+	// QueueStats возвращает число сообщений и подписчиков очереди задач, не объявляя ее
+	QueueStats(ctx context.Context) (QueueStats, error)
+}
@@ -1,7 +1,168 @@
 package schema
 
+import "time"
+
 type FileMetadata struct {
-	ID               int    `json:"id"`
-	OriginalFilename string `json:"filename"`
-	ObjectKey        string `json:"minio_key"`
+	ID               int       `json:"id"`
+	OriginalFilename string    `json:"filename"`
+	Size             int64     `json:"size"`
+	Bucket           string    `json:"bucket"` // Бакет, в котором лежит объект файла
+	ObjectKey        string    `json:"minio_key"`
+	SHA256           string    `json:"sha256,omitempty"`
+	Status           string    `json:"status"`
+	Owner            string    `json:"owner,omitempty"` // Клиент, загрузивший файл
+	Project          string    `json:"project"`         // Проект, которому принадлежит файл
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// FileFilter условия поиска завершенных загрузок; пустые поля не ограничивают выборку
+type FileFilter struct {
+	Project       string     // Пустой — все проекты
+	Name          string     // Подстрока имени файла без учета регистра
+	SHA256        string     // Точное совпадение содержимого
+	CreatedAfter  *time.Time // Не раньше, включительно
+	CreatedBefore *time.Time // Раньше, не включительно
+	Limit         int
+	Offset        int
+}
+
+// Состояния загрузки файла: запись создается в pending до записи объекта и переводится в ready после нее
+const (
+	FileStatusPending = "pending"
+	FileStatusReady   = "ready"
+)
+
+// Статусы задачи обработки
+const (
+	JobStatusPending = "pending"
+	JobStatusDone    = "done"
+	JobStatusFailed  = "failed"
+	JobStatusTimeout = "timeout"
+	// Ожидание прервано остановкой сервиса, задача будет отправлена повторно при запуске
+	JobStatusInterrupted = "interrupted"
+)
+
+// Job задача обработки исходного файла CV worker'ом
+type Job struct {
+	ID            int64             `json:"id"`
+	FileID        int64             `json:"file_id"`
+	CorrelationID string            `json:"correlation_id"`
+	Status        string            `json:"status"`
+	Params        *ProcessingParams `json:"params,omitempty"`
+	ParamsHash    string            `json:"params_hash,omitempty"`
+	ModelVersion  string            `json:"model_version,omitempty"`
+	CacheHit      bool              `json:"cache_hit"`
+	ArtifactID    *int64            `json:"artifact_id,omitempty"`
+	Error         string            `json:"error,omitempty"`
+	Owner         string            `json:"owner,omitempty"` // Клиент, запустивший обработку
+	Project       string            `json:"project"`         // Проект исходного файла
+	CreatedAt     time.Time         `json:"created_at"`
+	FinishedAt    *time.Time        `json:"finished_at,omitempty"`
+	// Сколько раз задача отдавалась CV worker'у; попытку засчитывает публикация сообщения
+	Attempts int `json:"attempts"`
+	// Аренда задачи CV worker'ом выдается при публикации и продлевается heartbeat'ами; по ее истечении задача
+	// возвращается в очередь или завершается ошибкой
+	LeaseExpiresAt *time.Time `json:"lease_expires_at,omitempty"`
+	HeartbeatAt    *time.Time `json:"heartbeat_at,omitempty"` // Последний heartbeat CV worker'а
+}
+
+// ProcessingParams параметры удаления динамических точек, передаваемые CV worker'у
+type ProcessingParams struct {
+	Threshold             float64 `json:"threshold"`
+	VoxelSize             float64 `json:"voxel_size"`
+	UseDownsample         bool    `json:"use_downsample"`
+	EdgeDistanceThreshold float64 `json:"edge_distance_threshold"`
+	ZUpperStaticThreshold float64 `json:"z_upper_static_threshold"`
+	GroundHeightThreshold float64 `json:"ground_height_threshold"`
+	GridDivisions         int     `json:"grid_divisions"`
+}
+
+// DefaultProcessingParams возвращает параметры, с которыми CV worker обрабатывал файлы до их появления в API
+func DefaultProcessingParams() ProcessingParams {
+	return ProcessingParams{
+		Threshold:             0.4,
+		VoxelSize:             0.05,
+		UseDownsample:         true,
+		EdgeDistanceThreshold: 6.0,
+		ZUpperStaticThreshold: 6.5,
+		GroundHeightThreshold: 0.55,
+		GridDivisions:         25,
+	}
+}
+
+// Типы производных объектов
+const (
+	ArtifactTypeProcessed  = "processed"
+	ArtifactTypePreview    = "preview"
+	ArtifactTypeReport     = "report"
+	ArtifactTypeConversion = "conversion"
+)
+
+// Artifact производный объект в Minio, связанный с исходным файлом и задачей
+type Artifact struct {
+	ID        int64     `json:"id"`
+	FileID    int64     `json:"file_id"`
+	JobID     *int64    `json:"job_id,omitempty"`
+	Type      string    `json:"type"`
+	Bucket    string    `json:"bucket"`
+	ObjectKey string    `json:"minio_key"`
+	Size      int64     `json:"size"`
+	Checksum  string    `json:"sha256"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OutboxMessage сообщение для CV worker'а, записанное в одной транзакции с задачей и ожидающее публикации в RabbitMQ
+type OutboxMessage struct {
+	ID            int64      `json:"id"`
+	JobID         int64      `json:"job_id"`
+	CorrelationID string     `json:"correlation_id"`
+	ReplyTo       string     `json:"reply_to"`              // Очередь, в которую CV worker пришлет ответ
+	RoutingKey    string     `json:"routing_key,omitempty"` // Ключ маршрутизации арендатора в exchange задач
+	Payload       []byte     `json:"payload"`               // JSON тело сообщения
+	Attempts      int        `json:"attempts"`              // Число неудачных попыток публикации
+	LastError     string     `json:"last_error,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	SentAt        *time.Time `json:"sent_at,omitempty"` // Когда брокер подтвердил получение
+	// Контекст трассировки запроса, создавшего задачу (traceparent, tracestate); relay публикует сообщение в той же трассе
+	TraceContext map[string]string `json:"trace_context,omitempty"`
+}
+
+// APIKey ключ доступа к API. Сам ключ не хранится, только его хэш.
+type APIKey struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"` // Открытая часть ключа для поиска
+	Hash       string     `json:"-"`      // SHA-256 ключа
+	Role       string     `json:"role"`
+	Project    string     `json:"project,omitempty"` // Пустой только у ключей администраторов
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Tenant арендатор: проект со своим местом в хранилище, ключом маршрутизации задач и квотами.
+// ID совпадает с проектом файлов, задач и API ключей.
+type Tenant struct {
+	ID                string    `json:"id"`
+	Name              string    `json:"name"`
+	Bucket            string    `json:"bucket,omitempty"`      // Отдельный бакет; пустой — общий бакет сервиса
+	Prefix            string    `json:"prefix,omitempty"`      // Префикс ключей объектов в бакете
+	RoutingKey        string    `json:"routing_key,omitempty"` // Ключ маршрутизации задач CV worker'ам арендатора
+	StorageQuotaBytes int64     `json:"storage_quota_bytes"`   // Объем загруженных файлов; 0 — без ограничения
+	MonthlyJobQuota   int64     `json:"monthly_job_quota"`     // Число обработок за календарный месяц; 0 — без ограничения
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+// TenantUsage потребление арендатора относительно его квот.
+// и обработки CV worker'ом с начала календарного месяца по часам базы (в docker-compose — UTC) без попаданий в кэш.
+// и обработки CV worker'ом с начала календарного месяца (UTC) без попаданий в кэш.
+type TenantUsage struct {
+	TenantID          string    `json:"tenant_id"`
+	Files             int64     `json:"files"`
+	StorageBytes      int64     `json:"storage_bytes"`
+	StorageQuotaBytes int64     `json:"storage_quota_bytes"`
+	JobsThisPeriod    int64     `json:"jobs_this_period"`
+	MonthlyJobQuota   int64     `json:"monthly_job_quota"`
+	PeriodStart       time.Time `json:"period_start"`
+	PeriodEnd         time.Time `json:"period_end"`
 }
@@ -3,9 +3,106 @@ package repository
 import (
 	"context"
 	"lct/internal/repository/schema"
+	"time"
 )
 
 type Repository interface {
-	SaveMetaData(ctx *context.Context, fileName string, fileSize int64, objectKey string) (int64, error)
-	GetMetaDataByID(ctx *context.Context, id int64) (*schema.FileMetadata, error)
+	// Ping проверяет, что база доступна
+	Ping(ctx context.Context) error
+	// CreatePendingFile создает запись файла в состоянии pending до загрузки объекта и заполняет ее ID и время создания.
+	// Возвращает errors.ErrStorageQuotaExceeded, если файл не помещается в квоту хранилища арендатора.
+	CreatePendingFile(ctx context.Context, file *schema.FileMetadata) (int64, error)
+	// CommitFile регистрирует объект по SHA-256 и переводит файл в состояние ready, возвращает ключ объекта, который следует использовать.
+	// Если у арендатора файла объект с таким содержимым уже есть, увеличивает его счетчик ссылок и возвращает существующий ключ.
+	CommitFile(ctx context.Context, id int64, sha256 string) (string, error)
+	// DeletePendingFile удаляет незавершенную загрузку, false если записи в состоянии pending нет
+	DeletePendingFile(ctx context.Context, id int64) (bool, error)
+	// GetMetaDataByID возвращает только завершенные загрузки
+	GetMetaDataByID(ctx context.Context, id int64) (*schema.FileMetadata, error)
+	// ListFiles возвращает страницу завершенных загрузок, подходящих под фильтр, от новых к старым, и общее их число
+	ListFiles(ctx context.Context, filter schema.FileFilter) ([]schema.FileMetadata, int64, error)
+	// DeleteFile удаляет завершенную загрузку с ее задачами и артефактами и освобождает объект.
+	// Возвращает ключ объекта и оставшееся число ссылок на него.
+	DeleteFile(ctx context.Context, id int64) (string, int, error)
+	// ObjectBucket возвращает бакет объекта, если он доступен проекту через его файлы, артефакты или задачи,
+	// и пустую строку, если не доступен. Пустой project означает любой проект.
+	ObjectBucket(ctx context.Context, objectKey string, project string) (string, error)
+	// ListPendingFiles возвращает незавершенные загрузки, начатые не позже чем olderThan назад по часам базы
+	ListPendingFiles(ctx context.Context, olderThan time.Duration) ([]schema.FileMetadata, error)
+	// ListReferencedObjectKeys возвращает ключи объектов бакета, на которые ссылаются завершенные загрузки и артефакты
+	ListReferencedObjectKeys(ctx context.Context, bucket string) ([]string, error)
+
+	// CreateJob создает задачу без сообщения CV worker'у, например уже выполненную из кэша
+	CreateJob(ctx context.Context, job *schema.Job) (int64, error)
+	// EnqueueJob создает задачу в состоянии pending и сообщение для CV worker'а в outbox в одной транзакции.
+	// Возвращает errors.ErrJobQuotaExceeded, если арендатор исчерпал квоту обработок за месяц.
+	EnqueueJob(ctx context.Context, job *schema.Job, msg *schema.OutboxMessage) (int64, error)
+	// CompleteJob помечает задачу выполненной и связывает ее с результатом
+	CompleteJob(ctx context.Context, jobID int64, artifactID int64) error
+	// FinishJob завершает задачу без результата. Задачу, по которой уже записан ответ CV worker'а (done или failed),
+	// не меняет и возвращает errors.ErrConflict.
+	FinishJob(ctx context.Context, jobID int64, status string, errMsg string) error
+	GetJob(ctx context.Context, jobID int64) (*schema.Job, error)
+	// GetJobByCorrelationID находит задачу, на которую ответил CV worker
+	GetJobByCorrelationID(ctx context.Context, correlationID string) (*schema.Job, error)
+	ListJobsByStatus(ctx context.Context, status string) ([]schema.Job, error)
+	// CountJobsByStatus считает задачи всех проектов в заданном состоянии
+	CountJobsByStatus(ctx context.Context, status string) (int64, error)
+	// JobStatusCounts считает задачи всех проектов в каждом состоянии
+	JobStatusCounts(ctx context.Context) (map[string]int64, error)
+	// RequeueJob возвращает задачу из состояния from в pending, снимает аренду и ставит новое сообщение в outbox
+	// вместо неопубликованного сообщения прошлой попытки.
+	// Если задача уже не в состоянии from (ее вернул в очередь другой экземпляр или по ней записан ответ CV worker'а),
+	// не меняет ее и возвращает errors.ErrConflict.
+	RequeueJob(ctx context.Context, jobID int64, from string, msg *schema.OutboxMessage) error
+	// ResumeJob возвращает прерванную задачу в pending и сообщает, ждет ли ее сообщение публикации.
+	// Уже опубликованное сообщение повторно не ставится: ответ CV worker'а придет в долговечную очередь результатов,
+	// а задаче выдается аренда на lease. Если задача уже не прервана, не меняет ее и возвращает errors.ErrConflict.
+	ResumeJob(ctx context.Context, jobID int64, lease time.Duration) (bool, error)
+	// RecordHeartbeat продлевает аренду задачи на lease от текущего момента.
+	// Возвращает errors.ErrNotFound, если задачи с correlation id нет или она больше не ждет ответа (pending или timeout).
+	RecordHeartbeat(ctx context.Context, correlationID string, lease time.Duration) (*schema.Job, error)
+	// ClaimExpiredJobs выбирает до limit задач, ждущих ответа, аренда которых истекла, и продлевает ее на lease,
+	// чтобы reaper другого экземпляра не взял их одновременно
+	ClaimExpiredJobs(ctx context.Context, limit int, lease time.Duration) ([]schema.Job, error)
+
+	// ClaimOutbox выбирает сообщения задач, ждущих ответа CV worker'а (pending или timeout), готовые к публикации,
+	// и откладывает их на lease
+	ClaimOutbox(ctx context.Context, limit int, lease time.Duration) ([]schema.OutboxMessage, error)
+	// MarkOutboxSent отмечает сообщение опубликованным. Если задача еще ждет ответа, засчитывает ей попытку и выдает
+	// аренду на lease: задача, которую ни один worker не взял или чей worker погиб до первого heartbeat'а, тоже будет возвращена в очередь.
+	MarkOutboxSent(ctx context.Context, id int64, lease time.Duration) error
+	// MarkOutboxFailed увеличивает счетчик попыток и откладывает следующую попытку на retryIn
+	MarkOutboxFailed(ctx context.Context, id int64, errMsg string, retryIn time.Duration) error
+
+	SaveArtifact(ctx context.Context, artifact *schema.Artifact) (int64, error)
+	GetArtifactsByFileID(ctx context.Context, fileID int64) ([]schema.Artifact, error)
+	// GetArtifact возвращает артефакт по ID или errors.ErrNotFound
+	GetArtifact(ctx context.Context, id int64) (*schema.Artifact, error)
+
+	// FindCachedResult ищет результат обработки арендатора по содержимому, параметрам и версии модели, nil если его нет
+	FindCachedResult(ctx context.Context, tenant string, inputSHA256 string, paramsHash string, modelVersion string) (*schema.Artifact, error)
+	SaveCachedResult(ctx context.Context, tenant string, inputSHA256 string, paramsHash string, modelVersion string, artifactID int64) error
+	// InvalidateResultCache удаляет записи кэша для версии модели и возвращает их количество
+	InvalidateResultCache(ctx context.Context, modelVersion string) (int64, error)
+
+	// CreateAPIKey сохраняет ключ и заполняет его ID и время создания
+	CreateAPIKey(ctx context.Context, key *schema.APIKey) (int64, error)
+	// GetAPIKeyByPrefix ищет ключ по открытому префиксу, nil если его нет
+	GetAPIKeyByPrefix(ctx context.Context, prefix string) (*schema.APIKey, error)
+	ListAPIKeys(ctx context.Context) ([]schema.APIKey, error)
+	// RevokeAPIKey отзывает ключ, повторный отзыв не меняет время отзыва
+	RevokeAPIKey(ctx context.Context, id int64) error
+	// TouchAPIKey обновляет время последнего использования ключа не чаще раза в минуту
+	TouchAPIKey(ctx context.Context, id int64) error
+
+	// CreateTenant сохраняет арендатора и заполняет время создания; errors.ErrConflict, если ID занят
+	CreateTenant(ctx context.Context, tenant *schema.Tenant) error
+	// GetTenant возвращает арендатора или errors.ErrNotFound
+	GetTenant(ctx context.Context, id string) (*schema.Tenant, error)
+	ListTenants(ctx context.Context) ([]schema.Tenant, error)
+	// UpdateTenant меняет настройки арендатора; уже загруженные объекты остаются на прежнем месте
+	UpdateTenant(ctx context.Context, tenant *schema.Tenant) error
+	// GetTenantUsage возвращает потребление арендатора за текущий календарный месяц по часам базы
+	GetTenantUsage(ctx context.Context, id string) (*schema.TenantUsage, error)
 }
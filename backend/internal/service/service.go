@@ -2,15 +2,40 @@ package service
 
 import (
 	"context"
-	"github.com/minio/minio-go/v7"
 	"io"
-	minio2 "lct/internal/repository/minio"
+	"lct/internal/auth"
+	"lct/internal/domain/dto"
+	"lct/internal/repository"
 	"lct/internal/repository/schema"
 )
 
 type ServiceInt interface {
-	InitMinio() error
-	CreateOne(ctx *context.Context, r io.Reader, size int64, file minio2.FileDataType, fileName string, fileSize int64, objectKey string) (*minio.Object, int64, error)
-	GetOne(r io.Reader, size int64, file minio2.FileDataType, objectID string) (*minio.Object, error)
-	GetMetaDataByID(ctx *context.Context, id int64) (*schema.FileMetadata, error)
+	InitStorage(ctx context.Context) error
+	Readiness(ctx context.Context) *dto.Readiness
+	CreateOne(ctx context.Context, r io.Reader, fileName string, fileSize int64, objectKey string) (repository.Object, int64, error)
+	GetOne(ctx context.Context, objectID string) (repository.Object, error)
+	GetMetaDataByID(ctx context.Context, id int64) (*schema.FileMetadata, error)
+	ListFiles(ctx context.Context, filter schema.FileFilter) ([]schema.FileMetadata, int64, error)
+	DeleteFile(ctx context.Context, id int64) error
+
+	ProcessFile(ctx context.Context, fileID int64, params schema.ProcessingParams, useCache bool) (*dto.ProcessResult, error)
+	StartJob(ctx context.Context, fileID int64, params schema.ProcessingParams, useCache bool) (*schema.Job, error)
+	JobResult(ctx context.Context, jobID int64) (*dto.ProcessResult, error)
+	InvalidateResultCache(ctx context.Context, modelVersion string) (int64, error)
+	GetJob(ctx context.Context, jobID int64) (*schema.Job, error)
+
+	GetArtifacts(ctx context.Context, fileID int64) ([]schema.Artifact, error)
+
+	Reconcile(ctx context.Context) (*dto.ReconcileReport, error)
+
+	CreateAPIKey(ctx context.Context, name string, role string, project string) (*dto.NewAPIKey, error)
+	ListAPIKeys(ctx context.Context) ([]schema.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id int64) error
+	AuthenticateAPIKey(ctx context.Context, key string) (*auth.Principal, error)
+
+	CreateTenant(ctx context.Context, tenant *schema.Tenant) error
+	UpdateTenant(ctx context.Context, tenant *schema.Tenant) error
+	GetTenant(ctx context.Context, id string) (*schema.Tenant, error)
+	ListTenants(ctx context.Context) ([]schema.Tenant, error)
+	TenantUsage(ctx context.Context, id string) (*schema.TenantUsage, error)
 }
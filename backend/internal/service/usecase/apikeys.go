@@ -0,0 +1,103 @@
+package usecase
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"lct/internal/auth"
+	"lct/internal/domain/dto"
+	"lct/internal/domain/errors"
+	"lct/internal/logging"
+	"lct/internal/repository/schema"
+	"log/slog"
+)
+
+// bootstrapSubject клиент, пришедший с ключом из конфигурации
+const bootstrapSubject = "bootstrap"
+
+// CreateAPIKey создает API ключ с ролью в проекте. Ключ целиком возвращается только здесь, в базе хранится его хэш.
+// Ключу администратора проект не обязателен, остальным ролям без проекта доступа нет. Проект должен быть арендатором.
+func (s *Service) CreateAPIKey(ctx context.Context, name string, role string, project string) (*dto.NewAPIKey, error) {
+	if _, err := authorize(ctx, auth.PermAdmin); err != nil {
+		return nil, err
+	}
+	if err := auth.ValidateRole(role); err != nil {
+		return nil, fmt.Errorf("%w: %v", errors.ErrInvalidArgument, err)
+	}
+	if role != auth.RoleAdmin && project == "" {
+		return nil, fmt.Errorf("%w: для роли %s нужен проект", errors.ErrInvalidArgument, role)
+	}
+	if project != "" {
+		if _, err := s.PostgresStorage.GetTenant(ctx, project); err != nil {
+			if stderrors.Is(err, errors.ErrNotFound) {
+				return nil, fmt.Errorf("%w: проект %s не зарегистрирован как арендатор", errors.ErrInvalidArgument, project)
+			}
+			return nil, err
+		}
+	}
+
+	key, prefix, err := auth.GenerateAPIKey()
+	if err != nil {
+		return nil, fmt.Errorf("не удалось сгенерировать API ключ: %w", err)
+	}
+	created := &dto.NewAPIKey{
+		APIKey: schema.APIKey{Name: name, Prefix: prefix, Hash: auth.HashAPIKey(key), Role: role, Project: project},
+		Key:    key,
+	}
+	if _, err := s.PostgresStorage.CreateAPIKey(ctx, &created.APIKey); err != nil {
+		return nil, err
+	}
+	slog.InfoContext(ctx, "Создан API ключ", slog.Int64("key_id", created.ID), slog.String("name", name), slog.String("prefix", prefix),
+		slog.String("role", role), slog.String("project", project))
+	return created, nil
+}
+
+func (s *Service) ListAPIKeys(ctx context.Context) ([]schema.APIKey, error) {
+	if _, err := authorize(ctx, auth.PermAdmin); err != nil {
+		return nil, err
+	}
+	return s.PostgresStorage.ListAPIKeys(ctx)
+}
+
+func (s *Service) RevokeAPIKey(ctx context.Context, id int64) error {
+	if _, err := authorize(ctx, auth.PermAdmin); err != nil {
+		return err
+	}
+	if err := s.PostgresStorage.RevokeAPIKey(ctx, id); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "API ключ отозван", slog.Int64("key_id", id))
+	return nil
+}
+
+// AuthenticateAPIKey проверяет API ключ и возвращает его владельца с ролью и проектом ключа.
+// Ключ из конфигурации (BootstrapAPIKey) принимается без обращения к базе с ролью admin, чтобы можно было создать первые ключи.
+func (s *Service) AuthenticateAPIKey(ctx context.Context, key string) (*auth.Principal, error) {
+	if s.cfg.BootstrapAPIKey != "" && auth.MatchAPIKey(key, auth.HashAPIKey(s.cfg.BootstrapAPIKey)) {
+		return &auth.Principal{Subject: bootstrapSubject, Method: auth.MethodAPIKey, Role: auth.RoleAdmin}, nil
+	}
+
+	prefix, ok := auth.APIKeyPrefix(key)
+	if !ok {
+		return nil, errors.ErrUnauthenticated
+	}
+	stored, err := s.PostgresStorage.GetAPIKeyByPrefix(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil || stored.RevokedAt != nil || !auth.MatchAPIKey(key, stored.Hash) {
+		return nil, errors.ErrUnauthenticated
+	}
+
+	if err := s.PostgresStorage.TouchAPIKey(ctx, stored.ID); err != nil {
+		slog.WarnContext(ctx, "Не удалось обновить время использования API ключа", slog.Int64("key_id", stored.ID), logging.Err(err))
+	}
+	return &auth.Principal{
+		Subject:  fmt.Sprintf("api-key:%d", stored.ID),
+		Name:     stored.Name,
+		Method:   auth.MethodAPIKey,
+		APIKeyID: stored.ID,
+		Role:     stored.Role,
+		Project:  stored.Project,
+	}, nil
+}
@@ -0,0 +1,29 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"lct/internal/auth"
+	"lct/internal/domain/errors"
+)
+
+// authorize возвращает клиента из ctx, если его роль разрешает операцию.
+// Вызов без клиента в контексте запрещен: фоновые задачи обращаются к репозиторию напрямую, минуя проверки.
+func authorize(ctx context.Context, perm auth.Permission) (*auth.Principal, error) {
+	p := auth.FromContext(ctx)
+	if p == nil {
+		return nil, errors.ErrUnauthenticated
+	}
+	if !p.Can(perm) {
+		return nil, fmt.Errorf("%w: роли %s не разрешена операция %s", errors.ErrForbidden, p.Role, perm)
+	}
+	return p, nil
+}
+
+// checkProject скрывает записи чужого проекта так же, как отсутствующие, чтобы не раскрывать их существование
+func checkProject(p *auth.Principal, project string, what string) error {
+	if !p.CanAccessProject(project) {
+		return fmt.Errorf("%w: %s", errors.ErrNotFound, what)
+	}
+	return nil
+}
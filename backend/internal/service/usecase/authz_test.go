@@ -0,0 +1,261 @@
+package usecase
+
+import (
+	"context"
+	stderrors "errors"
+	"lct/internal/auth"
+	"lct/internal/domain/errors"
+	"lct/internal/repository/memory"
+	"lct/internal/repository/schema"
+	"strings"
+	"testing"
+)
+
+type authzEnv struct {
+	repo    *memory.Repository
+	storage *memory.ObjectStorage
+	s       *Service
+}
+
+func newAuthzEnv(t *testing.T) *authzEnv {
+	t.Helper()
+	env := &authzEnv{repo: memory.NewRepository(), storage: memory.NewObjectStorage("testbucket")}
+	env.s = NewService(env.repo, env.storage, memory.NewRabbitClient(env.storage), testConfig)
+	for _, id := range []string{"alpha", "beta"} {
+		if err := env.repo.CreateTenant(context.Background(), &schema.Tenant{ID: id, Name: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	startRelay(t, env.s)
+	return env
+}
+
+// upload загружает файл от имени клиента и возвращает его ID
+func (env *authzEnv) upload(t *testing.T, ctx context.Context, content string) int64 {
+	t.Helper()
+	object, id, err := env.s.CreateOne(ctx, strings.NewReader(content), "scan.ply", int64(len(content)), content+"-"+auth.FromContext(ctx).Subject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	object.Close()
+	return id
+}
+
+// process обрабатывает файл от имени клиента и возвращает результат
+func (env *authzEnv) process(t *testing.T, ctx context.Context, fileID int64) (*schema.Job, *schema.Artifact) {
+	t.Helper()
+	result, err := env.s.ProcessFile(ctx, fileID, schema.DefaultProcessingParams(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result.Job, result.Artifact
+}
+
+func TestRolePermissions(t *testing.T) {
+	env := newAuthzEnv(t)
+	operator := asRole(auth.RoleOperator, "alpha")
+	fileID := env.upload(t, operator, "scan")
+	job, artifact := env.process(t, operator, fileID)
+
+	operations := map[string]struct {
+		allowed []string
+		call    func(ctx context.Context) error
+	}{
+		"upload": {[]string{auth.RoleOperator, auth.RoleAdmin}, func(ctx context.Context) error {
+			object, _, err := env.s.CreateOne(ctx, strings.NewReader("other"), "other.ply", 5, "other-"+auth.FromContext(ctx).Role)
+			if err == nil {
+				object.Close()
+			}
+			return err
+		}},
+		"process": {[]string{auth.RoleOperator, auth.RoleAdmin}, func(ctx context.Context) error {
+			_, err := env.s.ProcessFile(ctx, fileID, schema.DefaultProcessingParams(), true)
+			return err
+		}},
+		"read metadata": {[]string{auth.RoleViewer, auth.RoleOperator, auth.RoleAdmin}, func(ctx context.Context) error {
+			_, err := env.s.GetMetaDataByID(ctx, fileID)
+			return err
+		}},
+		"read job": {[]string{auth.RoleViewer, auth.RoleOperator, auth.RoleAdmin}, func(ctx context.Context) error {
+			_, err := env.s.GetJob(ctx, job.ID)
+			return err
+		}},
+		"read artifacts": {[]string{auth.RoleViewer, auth.RoleOperator, auth.RoleAdmin}, func(ctx context.Context) error {
+			_, err := env.s.GetArtifacts(ctx, fileID)
+			return err
+		}},
+		"download result": {[]string{auth.RoleViewer, auth.RoleOperator, auth.RoleAdmin}, func(ctx context.Context) error {
+			object, err := env.s.GetOne(ctx, artifact.ObjectKey)
+			if err == nil {
+				object.Close()
+			}
+			return err
+		}},
+		"invalidate cache": {[]string{auth.RoleAdmin}, func(ctx context.Context) error {
+			_, err := env.s.InvalidateResultCache(ctx, "other-model")
+			return err
+		}},
+		"reconcile": {[]string{auth.RoleAdmin}, func(ctx context.Context) error {
+			_, err := env.s.Reconcile(ctx)
+			return err
+		}},
+		"list api keys": {[]string{auth.RoleAdmin}, func(ctx context.Context) error {
+			_, err := env.s.ListAPIKeys(ctx)
+			return err
+		}},
+	}
+
+	for name, op := range operations {
+		for _, role := range []string{auth.RoleViewer, auth.RoleOperator, auth.RoleAdmin} {
+			allowed := false
+			for _, r := range op.allowed {
+				allowed = allowed || r == role
+			}
+			err := op.call(asRole(role, "alpha"))
+			if allowed && err != nil {
+				t.Errorf("%s as %s: unexpected error %v", name, role, err)
+			}
+			if !allowed && !stderrors.Is(err, errors.ErrForbidden) {
+				t.Errorf("%s as %s: error = %v, want ErrForbidden", name, role, err)
+			}
+		}
+	}
+}
+
+func TestDeleteRequiresOperator(t *testing.T) {
+	env := newAuthzEnv(t)
+	fileID := env.upload(t, asRole(auth.RoleOperator, "alpha"), "scan")
+
+	if err := env.s.DeleteFile(asRole(auth.RoleViewer, "alpha"), fileID); !stderrors.Is(err, errors.ErrForbidden) {
+		t.Fatalf("DeleteFile() as viewer error = %v, want ErrForbidden", err)
+	}
+	if err := env.s.DeleteFile(asRole(auth.RoleOperator, "alpha"), fileID); err != nil {
+		t.Fatal(err)
+	}
+	if keys := env.storage.Keys(); len(keys) != 0 {
+		t.Errorf("stored objects after delete = %v, want none", keys)
+	}
+}
+
+func TestProjectsAreIsolated(t *testing.T) {
+	env := newAuthzEnv(t)
+	alpha := asRole(auth.RoleOperator, "alpha")
+	fileID := env.upload(t, alpha, "scan")
+	job, artifact := env.process(t, alpha, fileID)
+
+	metadata, _ := env.repo.File(fileID)
+	if metadata.Project != "alpha" || metadata.Owner != "operator@alpha" {
+		t.Errorf("file owner/project = %q/%q", metadata.Owner, metadata.Project)
+	}
+	if stored, _ := env.repo.Job(job.ID); stored.Project != "alpha" || stored.Owner != "operator@alpha" {
+		t.Errorf("job owner/project = %q/%q", stored.Owner, stored.Project)
+	}
+
+	beta := asRole(auth.RoleOperator, "beta")
+	calls := map[string]func() error{
+		"metadata":  func() error { _, err := env.s.GetMetaDataByID(beta, fileID); return err },
+		"job":       func() error { _, err := env.s.GetJob(beta, job.ID); return err },
+		"artifacts": func() error { _, err := env.s.GetArtifacts(beta, fileID); return err },
+		"original":  func() error { _, err := env.s.GetOne(beta, metadata.ObjectKey); return err },
+		"result":    func() error { _, err := env.s.GetOne(beta, artifact.ObjectKey); return err },
+		"process": func() error {
+			_, err := env.s.ProcessFile(beta, fileID, schema.DefaultProcessingParams(), true)
+			return err
+		},
+		"delete": func() error { return env.s.DeleteFile(beta, fileID) },
+	}
+	for name, call := range calls {
+		if err := call(); !stderrors.Is(err, errors.ErrNotFound) {
+			t.Errorf("%s from another project: error = %v, want ErrNotFound", name, err)
+		}
+	}
+
+	// Администратор видит все проекты
+	if _, err := env.s.GetJob(asRole(auth.RoleAdmin, ""), job.ID); err != nil {
+		t.Errorf("GetJob() as admin: %v", err)
+	}
+}
+
+func TestResultCacheIsPerTenant(t *testing.T) {
+	env := newAuthzEnv(t)
+	alpha := asRole(auth.RoleOperator, "alpha")
+	_, artifact := env.process(t, alpha, env.upload(t, alpha, "scan"))
+
+	// Тот же скан в другом проекте обрабатывается заново, а повторно — берется из кэша этого проекта
+	beta := asRole(auth.RoleOperator, "beta")
+	fileID := env.upload(t, beta, "scan")
+	job, processed := env.process(t, beta, fileID)
+	if job.CacheHit || processed.ObjectKey == artifact.ObjectKey {
+		t.Fatalf("job = %+v, want cache miss in another project", job)
+	}
+	job, cached := env.process(t, beta, fileID)
+	if !job.CacheHit || cached.ObjectKey != processed.ObjectKey {
+		t.Fatalf("job = %+v, artifact = %+v, want cache hit on %s", job, cached, processed.ObjectKey)
+	}
+	if _, err := env.s.GetOne(beta, artifact.ObjectKey); !stderrors.Is(err, errors.ErrNotFound) {
+		t.Errorf("GetOne() other project's result: error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestSameContentIsNotSharedBetweenProjects(t *testing.T) {
+	env := newAuthzEnv(t)
+	alpha, beta := asRole(auth.RoleOperator, "alpha"), asRole(auth.RoleOperator, "beta")
+	alphaFile := env.upload(t, alpha, "scan")
+	betaFile := env.upload(t, beta, "scan")
+	alphaMeta, _ := env.repo.File(alphaFile)
+	betaMeta, _ := env.repo.File(betaFile)
+	if alphaMeta.ObjectKey == betaMeta.ObjectKey {
+		t.Fatalf("projects share object %s", alphaMeta.ObjectKey)
+	}
+
+	if err := env.s.DeleteFile(alpha, alphaFile); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := env.storage.Data(alphaMeta.ObjectKey); ok {
+		t.Error("deleted file's object was kept")
+	}
+	object, err := env.s.GetOne(beta, betaMeta.ObjectKey)
+	if err != nil {
+		t.Fatalf("GetOne() after other project's delete: %v", err)
+	}
+	object.Close()
+}
+
+func TestCallsWithoutPrincipalAreRejected(t *testing.T) {
+	env := newAuthzEnv(t)
+	if _, _, err := env.s.CreateOne(context.Background(), strings.NewReader("scan"), "scan.ply", 4, "key"); !stderrors.Is(err, errors.ErrUnauthenticated) {
+		t.Errorf("CreateOne() error = %v, want ErrUnauthenticated", err)
+	}
+	if _, err := env.s.GetOne(context.Background(), "key"); !stderrors.Is(err, errors.ErrUnauthenticated) {
+		t.Errorf("GetOne() error = %v, want ErrUnauthenticated", err)
+	}
+}
+
+func TestCachedResultOfDeletedFileIsReportedAsDeleted(t *testing.T) {
+	env := newAuthzEnv(t)
+	operator := asRole(auth.RoleOperator, "alpha")
+	original := env.upload(t, operator, "scan")
+	env.process(t, operator, original)
+
+	// Копия того же скана получает результат из кэша, то есть артефакт исходного файла
+	object, copyID, err := env.s.CreateOne(operator, strings.NewReader("scan"), "copy.ply", 4, "copy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	object.Close()
+	job, _ := env.process(t, operator, copyID)
+	if !job.CacheHit {
+		t.Fatalf("job = %+v, want cache hit", job)
+	}
+
+	if err := env.s.DeleteFile(operator, original); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.s.JobResult(operator, job.ID); !stderrors.Is(err, errors.ErrResultDeleted) {
+		t.Errorf("JobResult() error = %v, want ErrResultDeleted", err)
+	}
+	// Копия обрабатывается заново, а не берет из кэша удаленный результат
+	if job, _ := env.process(t, operator, copyID); job.CacheHit {
+		t.Errorf("job = %+v, want cache miss after the cached result was deleted", job)
+	}
+}
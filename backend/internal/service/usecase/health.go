@@ -0,0 +1,86 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"lct/internal/domain/dto"
+	"sync"
+	"time"
+)
+
+// dependencyCheck проверка одной зависимости; details попадают в ответ и при ошибке
+type dependencyCheck func(ctx context.Context) (details map[string]interface{}, err error)
+
+// Readiness параллельно проверяет зависимости, без которых сервис не может обработать запрос:
+// соединение с PostgreSQL, доступ к бакету хранилища, соединение с RabbitMQ и наличие хотя бы одного
+// CV worker'а, подписанного на очередь задач. Каждая проверка ограничена ReadinessTimeout.
+// При DegradedStart недоступность RabbitMQ не снимает готовность: сервис отдает данные, а задачи ждут брокера в outbox.
+func (s *Service) Readiness(ctx context.Context) *dto.Readiness {
+	optional := map[string]bool{"rabbitmq": s.cfg.DegradedStart}
+	checks := map[string]dependencyCheck{
+		"postgres": func(ctx context.Context) (map[string]interface{}, error) {
+			return nil, s.PostgresStorage.Ping(ctx)
+		},
+		"storage": func(ctx context.Context) (map[string]interface{}, error) {
+			return map[string]interface{}{"bucket": s.ObjectStorage.Bucket()}, s.ObjectStorage.Ping(ctx)
+		},
+		"rabbitmq": s.checkQueue,
+	}
+
+	res := &dto.Readiness{Status: dto.ReadinessOK, Checks: make(map[string]dto.DependencyCheck, len(checks))}
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for name, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := withTimeout(ctx, s.cfg.ReadinessTimeout)
+			defer cancel()
+			start := time.Now()
+			details, err := check(ctx)
+			result := dto.DependencyCheck{
+				Status:    dto.CheckOK,
+				LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+				Details:   details,
+			}
+			if err != nil {
+				result.Status, result.Error = dto.CheckFailed, err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			res.Checks[name] = result
+			switch {
+			case err == nil:
+			case optional[name]:
+				if res.Status == dto.ReadinessOK {
+					res.Status = dto.ReadinessDegraded
+				}
+			default:
+				res.Status = dto.ReadinessUnavailable
+			}
+		}()
+	}
+	wg.Wait()
+	return res
+}
+
+// checkQueue проверяет соединение с RabbitMQ и наличие CV worker'ов у очереди задач:
+// без них задачи будут копиться в очереди до истечения ProcessingTimeout.
+func (s *Service) checkQueue(ctx context.Context) (map[string]interface{}, error) {
+	stats, err := s.RabbitClient.QueueStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	details := map[string]interface{}{
+		"queue":     stats.Name,
+		"messages":  stats.Messages,
+		"consumers": stats.Consumers,
+	}
+	if stats.Consumers == 0 {
+		return details, fmt.Errorf("нет CV worker'ов, подписанных на очередь %s", stats.Name)
+	}
+	return details, nil
+}
@@ -0,0 +1,79 @@
+package usecase
+
+import (
+	"context"
+	stderrors "errors"
+	"lct/internal/domain/dto"
+	"lct/internal/repository/memory"
+	"testing"
+	"time"
+)
+
+func TestReadinessWithoutBroker(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		degraded bool
+		want     string
+	}{
+		{name: "required", degraded: false, want: dto.ReadinessUnavailable},
+		{name: "degraded start", degraded: true, want: dto.ReadinessDegraded},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			storage := memory.NewObjectStorage("testbucket")
+			rabbit := memory.NewRabbitClient(storage)
+			cfg := testConfig
+			cfg.DegradedStart = tc.degraded
+			s := NewService(memory.NewRepository(), storage, rabbit, cfg)
+			rabbit.FailOn("QueueStats", stderrors.New("connection refused"))
+
+			res := s.Readiness(context.Background())
+			if res.Status != tc.want {
+				t.Errorf("status = %q, want %q", res.Status, tc.want)
+			}
+			if c := res.Checks["rabbitmq"]; c.Status != dto.CheckFailed || c.Error != "connection refused" {
+				t.Errorf("rabbitmq = %+v", c)
+			}
+		})
+	}
+}
+
+func TestReadinessDegradedDoesNotHideRequiredFailure(t *testing.T) {
+	repo := memory.NewRepository()
+	storage := memory.NewObjectStorage("testbucket")
+	rabbit := memory.NewRabbitClient(storage)
+	cfg := testConfig
+	cfg.DegradedStart = true
+	s := NewService(repo, storage, rabbit, cfg)
+	rabbit.Consumers = 0
+	storage.FailOn("Ping", stderrors.New("bucket not found"))
+
+	if res := s.Readiness(context.Background()); res.Status != dto.ReadinessUnavailable {
+		t.Errorf("status = %q, want %q", res.Status, dto.ReadinessUnavailable)
+	}
+}
+
+func TestReadinessTimesOutSlowDependency(t *testing.T) {
+	storage := memory.NewObjectStorage("testbucket")
+	cfg := testConfig
+	cfg.ReadinessTimeout = 20 * time.Millisecond
+	s := NewService(&slowPing{Repository: memory.NewRepository()}, storage, memory.NewRabbitClient(storage), cfg)
+
+	start := time.Now()
+	res := s.Readiness(context.Background())
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("readiness took %s", elapsed)
+	}
+	if c := res.Checks["postgres"]; c.Status != dto.CheckFailed || c.Error != context.DeadlineExceeded.Error() {
+		t.Errorf("postgres = %+v", c)
+	}
+}
+
+// slowPing база, которая отвечает на ping только по отмене контекста
+type slowPing struct {
+	*memory.Repository
+}
+
+func (r *slowPing) Ping(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
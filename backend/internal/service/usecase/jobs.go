@@ -0,0 +1,389 @@
+package usecase
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"lct/internal/auth"
+	"lct/internal/domain/dto"
+	"lct/internal/domain/errors"
+	"lct/internal/logging"
+	"lct/internal/metrics"
+	"lct/internal/repository/schema"
+	"lct/internal/tracing"
+	"log/slog"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ProcessFile обрабатывает загруженный файл CV worker'ом и ждет результат.
+// Перед публикацией задачи в RabbitMQ проверяется кэш результатов по содержимому файла, параметрам и версии модели;
+// попадание или промах в кэш фиксируется на задаче. Если задача успела создаться, она возвращается и вместе с ошибкой.
+// Задача принадлежит проекту исходного файла; кэш у каждого арендатора свой.
+// Если арендатор исчерпал квоту обработок, возвращается errors.ErrJobQuotaExceeded; попадания в кэш в квоту не входят.
+// Если в очереди CV worker'а уже MaxPendingJobs задач, возвращается errors.ErrTooManyPendingJobs.
+// Контекст трассировки запроса уходит CV worker'у вместе с задачей, и его span'ы попадают в ту же трассу.
+func (s *Service) ProcessFile(ctx context.Context, fileID int64, params schema.ProcessingParams, useCache bool) (_ *dto.ProcessResult, err error) {
+	ctx, span := tracing.Start(ctx, "Service.ProcessFile", trace.WithAttributes(attribute.Int64("file.id", fileID)))
+	defer func() { tracing.End(span, err) }()
+	ctx = logging.WithFileID(ctx, fileID)
+
+	runCtx, done, err := s.beginJob()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	sub, err := s.submitJob(ctx, fileID, params, useCache)
+	if err != nil {
+		return nil, err
+	}
+	if sub.cached != nil {
+		return &dto.ProcessResult{Job: sub.job, Artifact: sub.cached, FileName: sub.metadata.OriginalFilename}, nil
+	}
+	ctx = logging.WithJobID(ctx, sub.job.ID)
+
+	// Ожидание ответа не прерывается вместе с запросом клиента, а только при остановке сервиса;
+	// трасса запроса и идентификаторы в логах сохраняются
+	jobCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancel()
+	defer context.AfterFunc(runCtx, cancel)()
+	return s.runJob(jobCtx, sub.job, sub.metadata, sub.waiter)
+}
+
+// StartJob ставит файл в обработку и возвращает задачу, не дожидаясь CV worker'а: результат сохраняется в фоне,
+// а состояние задачи отслеживается через GetJob. Задача, результат которой найден в кэше, возвращается уже выполненной.
+// Проверки доступа, квот и очереди те же, что в ProcessFile.
+func (s *Service) StartJob(ctx context.Context, fileID int64, params schema.ProcessingParams, useCache bool) (_ *schema.Job, err error) {
+	ctx, span := tracing.Start(ctx, "Service.StartJob", trace.WithAttributes(attribute.Int64("file.id", fileID)))
+	defer func() { tracing.End(span, err) }()
+	ctx = logging.WithFileID(ctx, fileID)
+
+	runCtx, done, err := s.beginJob()
+	if err != nil {
+		return nil, err
+	}
+	sub, err := s.submitJob(ctx, fileID, params, useCache)
+	if err != nil {
+		done()
+		return nil, err
+	}
+	// Копия для клиента: задачу, которую ждет фоновая горутина, она же и меняет
+	job := *sub.job
+	if sub.cached != nil {
+		done()
+		return &job, nil
+	}
+
+	jobCtx, cancel := context.WithCancel(context.WithoutCancel(logging.WithJobID(ctx, job.ID)))
+	stop := context.AfterFunc(runCtx, cancel)
+	go func() {
+		defer done()
+		defer cancel()
+		defer stop()
+		if _, err := s.runJob(jobCtx, sub.job, sub.metadata, sub.waiter); err != nil {
+			slog.WarnContext(jobCtx, "Задача завершилась с ошибкой", logging.Err(err))
+		}
+	}()
+	return &job, nil
+}
+
+// submission задача, созданная submitJob
+type submission struct {
+	job      *schema.Job
+	metadata *schema.FileMetadata
+	waiter   *resultWaiter    // Ожидание ответа CV worker'а; nil, если результат взят из кэша
+	cached   *schema.Artifact // Результат из кэша, задача уже выполнена
+}
+
+// submitJob проверяет доступ и параметры, ищет результат в кэше и, если его нет, ставит задачу в outbox
+func (s *Service) submitJob(ctx context.Context, fileID int64, params schema.ProcessingParams, useCache bool) (*submission, error) {
+	span := trace.SpanFromContext(ctx)
+	p, err := authorize(ctx, auth.PermProcess)
+	if err != nil {
+		return nil, err
+	}
+	metadata, err := s.fileInProject(ctx, p, fileID)
+	if err != nil {
+		return nil, err
+	}
+
+	params, paramsHash, err := normalizeParams(params)
+	if err != nil {
+		return nil, err
+	}
+
+	job := &schema.Job{
+		FileID:        fileID,
+		CorrelationID: uuid.New().String(),
+		Params:        &params,
+		ParamsHash:    paramsHash,
+		ModelVersion:  s.cfg.ModelVersion,
+		Owner:         p.Subject,
+		Project:       metadata.Project,
+	}
+	span.SetAttributes(attribute.String("job.correlation_id", job.CorrelationID))
+
+	if useCache && metadata.SHA256 != "" {
+		cached, err := s.PostgresStorage.FindCachedResult(ctx, metadata.Project, metadata.SHA256, paramsHash, job.ModelVersion)
+		switch {
+		case err != nil:
+			slog.WarnContext(ctx, "Ошибка при поиске в кэше результатов", logging.Err(err))
+		case cached == nil:
+			metrics.CacheLookups.WithLabelValues(metrics.CacheMiss).Inc()
+		default:
+			metrics.CacheLookups.WithLabelValues(metrics.CacheHit).Inc()
+			job.Status = schema.JobStatusDone
+			job.CacheHit = true
+			span.SetAttributes(attribute.Bool("job.cache_hit", true))
+			job.ArtifactID = &cached.ID
+			if _, err := s.PostgresStorage.CreateJob(ctx, job); err != nil {
+				return nil, err
+			}
+			slog.InfoContext(logging.WithJobID(ctx, job.ID), "Результат найден в кэше", slog.String("object_key", cached.ObjectKey))
+			return &submission{job: job, metadata: metadata, cached: cached}, nil
+		}
+	}
+
+	if err := s.checkPendingJobs(ctx); err != nil {
+		return nil, err
+	}
+	waiter, err := s.enqueueJob(ctx, job, metadata)
+	if err != nil {
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int64("job.id", job.ID))
+	return &submission{job: job, metadata: metadata, waiter: waiter}, nil
+}
+
+// withJob добавляет к записям лога ID задачи и ее файла
+func withJob(ctx context.Context, job *schema.Job) context.Context {
+	return logging.WithJobID(logging.WithFileID(ctx, job.FileID), job.ID)
+}
+
+// checkPendingJobs не дает очереди CV worker'а расти без ограничения. Проверка не атомарна с созданием задачи,
+// поэтому при одновременных запросах очередь может превысить предел на число этих запросов.
+func (s *Service) checkPendingJobs(ctx context.Context) error {
+	if s.cfg.MaxPendingJobs <= 0 {
+		return nil
+	}
+	pending, err := s.PostgresStorage.CountJobsByStatus(ctx, schema.JobStatusPending)
+	if err != nil {
+		return err
+	}
+	if pending >= s.cfg.MaxPendingJobs {
+		return fmt.Errorf("%w: %d из %d", errors.ErrTooManyPendingJobs, pending, s.cfg.MaxPendingJobs)
+	}
+	return nil
+}
+
+// enqueueJob создает задачу вместе с сообщением для CV worker'а в outbox и будит relay.
+// Ожидание ответа регистрируется до записи, чтобы ответ не пришел раньше, чем его начнут ждать.
+// Сообщение публикуется с ключом маршрутизации арендатора; результат CV worker кладет в бакет файла под префиксом арендатора.
+func (s *Service) enqueueJob(ctx context.Context, job *schema.Job, metadata *schema.FileMetadata) (*resultWaiter, error) {
+	msg, err := s.jobMessage(ctx, job, metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	waiter := s.results.expect(job.CorrelationID)
+	if _, err := s.PostgresStorage.EnqueueJob(ctx, job, msg); err != nil {
+		waiter.cancel()
+		return nil, err
+	}
+	job.Status = schema.JobStatusPending
+	s.wakeRelay()
+	return waiter, nil
+}
+
+// jobMessage собирает сообщение задачи для CV worker'а с ключом маршрутизации и префиксом результата арендатора
+func (s *Service) jobMessage(ctx context.Context, job *schema.Job, metadata *schema.FileMetadata) (*schema.OutboxMessage, error) {
+	tenant, err := s.PostgresStorage.GetTenant(ctx, metadata.Project)
+	if err != nil {
+		return nil, err
+	}
+	// Сообщение с метаданными; job_id в нем нет, так как задача получает ID в той же транзакции
+	body, err := json.Marshal(map[string]interface{}{
+		"id":             fmt.Sprintf("%d", job.FileID),
+		"correlation_id": job.CorrelationID,
+		"filename":       metadata.OriginalFilename,
+		"bucket":         metadata.Bucket,
+		"minio_key":      metadata.ObjectKey,
+		"output_prefix":  tenant.Prefix,
+		"tenant":         tenant.ID,
+		"params":         job.Params,
+		"model_version":  job.ModelVersion,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &schema.OutboxMessage{
+		CorrelationID: job.CorrelationID,
+		ReplyTo:       s.RabbitClient.ReplyQueue(),
+		RoutingKey:    tenant.RoutingKey,
+		Payload:       body,
+		TraceContext:  tracing.Inject(ctx),
+	}, nil
+}
+
+// runJob ждет, пока ответ CV worker'а на опубликованную задачу не запишет RunResultConsumer.
+// Ожидание прерывается при остановке сервиса: тогда задача получает статус interrupted и будет возобновлена при следующем запуске.
+// Задача, которую перестали ждать по таймауту или остановке, не теряет результат: пришедший позже ответ все равно будет записан.
+func (s *Service) runJob(runCtx context.Context, job *schema.Job, metadata *schema.FileMetadata, waiter *resultWaiter) (*dto.ProcessResult, error) {
+	// Длительность считается по итоговому состоянию задачи: done, failed, timeout или interrupted
+	defer func(start time.Time) {
+		metrics.JobDuration.WithLabelValues(job.Status).Observe(time.Since(start).Seconds())
+	}(time.Now())
+	// Учет состояния задачи не должен прерываться вместе с ожиданием ответа
+	ctx := context.WithoutCancel(runCtx)
+	// Задача уже создана, поэтому даже при ошибке возвращаем ее, чтобы клиент мог узнать ее ID
+	fail := func(status string, cause error) (*dto.ProcessResult, error) {
+		return &dto.ProcessResult{Job: job}, s.failJob(ctx, job, status, cause)
+	}
+
+	waitCtx, waitSpan := tracing.Start(runCtx, "CV worker", trace.WithAttributes(attribute.Int64("job.id", job.ID)))
+	result, err := s.awaitResult(waitCtx, job, waiter)
+	tracing.End(waitSpan, err)
+	if err != nil {
+		switch {
+		case stderrors.Is(err, errors.ErrProcessingTimeout):
+			return fail(schema.JobStatusTimeout, err)
+		case runCtx.Err() != nil:
+			return fail(schema.JobStatusInterrupted, errors.ErrJobInterrupted)
+		}
+		return fail(schema.JobStatusFailed, err)
+	}
+
+	job.Status = result.job.Status
+	job.ArtifactID = result.job.ArtifactID
+	job.Error = result.job.Error
+	job.FinishedAt = result.job.FinishedAt
+	if result.err != nil {
+		return &dto.ProcessResult{Job: job}, result.err
+	}
+	slog.InfoContext(ctx, "Задача обработана", slog.Int64("artifact_id", result.artifact.ID))
+	return &dto.ProcessResult{Job: job, Artifact: result.artifact, FileName: result.fileName}, nil
+}
+
+// GetJob возвращает задачу обработки по ID, если она принадлежит проекту клиента
+func (s *Service) GetJob(ctx context.Context, jobID int64) (*schema.Job, error) {
+	p, err := authorize(ctx, auth.PermDownload)
+	if err != nil {
+		return nil, err
+	}
+	job, err := s.PostgresStorage.GetJob(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkProject(p, job.Project, fmt.Sprintf("задача с id %d", jobID)); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// JobResult возвращает выполненную задачу вместе с результатом и именем исходного файла.
+// Если задача еще не выполнена или завершилась ошибкой, возвращается errors.ErrJobNotDone.
+// Если результат взят из кэша и удален вместе с чужим файлом, возвращается errors.ErrResultDeleted.
+func (s *Service) JobResult(ctx context.Context, jobID int64) (*dto.ProcessResult, error) {
+	job, err := s.GetJob(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status == schema.JobStatusDone && job.ArtifactID == nil {
+		// jobs.artifact_id обнуляется при удалении артефакта (ON DELETE SET NULL)
+		return nil, errors.ErrResultDeleted.Errorf("задача %d", job.ID)
+	}
+	if job.Status != schema.JobStatusDone {
+		return nil, errors.ErrJobNotDone.Errorf("задача %d в состоянии %s", job.ID, job.Status)
+	}
+	artifact, err := s.PostgresStorage.GetArtifact(ctx, *job.ArtifactID)
+	if err != nil {
+		return nil, err
+	}
+	metadata, err := s.PostgresStorage.GetMetaDataByID(ctx, job.FileID)
+	if err != nil {
+		return nil, err
+	}
+	return &dto.ProcessResult{Job: job, Artifact: artifact, FileName: metadata.OriginalFilename}, nil
+}
+
+// InvalidateResultCache сбрасывает кэш результатов, полученных указанной версией модели
+func (s *Service) InvalidateResultCache(ctx context.Context, modelVersion string) (int64, error) {
+	if _, err := authorize(ctx, auth.PermAdmin); err != nil {
+		return 0, err
+	}
+	return s.PostgresStorage.InvalidateResultCache(ctx, modelVersion)
+}
+
+// failJob фиксирует неуспешное завершение задачи и возвращает исходную ошибку.
+// Если ответ CV worker'а успели записать, задача остается в записанном состоянии.
+func (s *Service) failJob(ctx context.Context, job *schema.Job, status string, cause error) error {
+	err := s.PostgresStorage.FinishJob(ctx, job.ID, status, cause.Error())
+	switch {
+	case stderrors.Is(err, errors.ErrConflict):
+		slog.InfoContext(ctx, "Ответ на задачу записан раньше, чем истекло ожидание", slog.String("status", status))
+		return cause
+	case err != nil:
+		slog.ErrorContext(ctx, "Ошибка при завершении задачи", slog.String("status", status), logging.Err(err))
+	}
+	job.Status = status
+	job.Error = cause.Error()
+	return cause
+}
+
+// normalizeParams проверяет параметры обработки и приводит их к каноническому виду,
+// чтобы одинаковые по смыслу параметры давали одинаковый ключ кэша.
+func normalizeParams(p schema.ProcessingParams) (schema.ProcessingParams, string, error) {
+	// NaN не попадает ни под одну проверку диапазона, а NaN и Inf не кодируются в JSON
+	floats := []struct {
+		name  string
+		value float64
+	}{
+		{"threshold", p.Threshold},
+		{"voxel_size", p.VoxelSize},
+		{"edge_distance_threshold", p.EdgeDistanceThreshold},
+		{"z_upper_static_threshold", p.ZUpperStaticThreshold},
+		{"ground_height_threshold", p.GroundHeightThreshold},
+	}
+	for _, f := range floats {
+		if math.IsNaN(f.value) || math.IsInf(f.value, 0) {
+			return p, "", fmt.Errorf("%w: %s должен быть конечным числом", errors.ErrInvalidParams, f.name)
+		}
+	}
+
+	switch {
+	case p.Threshold <= 0 || p.Threshold >= 1:
+		return p, "", fmt.Errorf("%w: threshold должен быть в интервале (0, 1)", errors.ErrInvalidParams)
+	case p.VoxelSize <= 0:
+		return p, "", fmt.Errorf("%w: voxel_size должен быть положительным", errors.ErrInvalidParams)
+	case p.GridDivisions <= 0:
+		return p, "", fmt.Errorf("%w: grid_divisions должен быть положительным", errors.ErrInvalidParams)
+	case p.EdgeDistanceThreshold < 0 || p.ZUpperStaticThreshold < 0 || p.GroundHeightThreshold < 0:
+		return p, "", fmt.Errorf("%w: пороги расстояний не могут быть отрицательными", errors.ErrInvalidParams)
+	}
+
+	p.Threshold = roundParam(p.Threshold)
+	p.VoxelSize = roundParam(p.VoxelSize)
+	p.EdgeDistanceThreshold = roundParam(p.EdgeDistanceThreshold)
+	p.ZUpperStaticThreshold = roundParam(p.ZUpperStaticThreshold)
+	p.GroundHeightThreshold = roundParam(p.GroundHeightThreshold)
+
+	b, err := json.Marshal(p)
+	if err != nil {
+		return p, "", err
+	}
+	sum := sha256.Sum256(b)
+	return p, hex.EncodeToString(sum[:]), nil
+}
+
+// roundParam отбрасывает шум представления float, не влияющий на обработку
+func roundParam(v float64) float64 {
+	return math.Round(v*1e6) / 1e6
+}
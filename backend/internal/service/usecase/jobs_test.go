@@ -0,0 +1,46 @@
+package usecase
+
+import (
+	stderrors "errors"
+	"lct/internal/domain/errors"
+	"lct/internal/repository/schema"
+	"testing"
+)
+
+func TestNormalizeParamsHashIsStable(t *testing.T) {
+	a := schema.DefaultProcessingParams()
+	b := schema.DefaultProcessingParams()
+	b.Threshold = 0.4000000001
+
+	_, hashA, err := normalizeParams(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, hashB, err := normalizeParams(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hashA != hashB {
+		t.Errorf("hashes differ for equivalent params: %s, %s", hashA, hashB)
+	}
+
+	b.Threshold = 0.5
+	if _, hashC, _ := normalizeParams(b); hashC == hashA {
+		t.Error("different thresholds produced the same hash")
+	}
+}
+
+func TestNormalizeParamsRejectsInvalid(t *testing.T) {
+	for name, mutate := range map[string]func(*schema.ProcessingParams){
+		"threshold":      func(p *schema.ProcessingParams) { p.Threshold = 0 },
+		"voxel_size":     func(p *schema.ProcessingParams) { p.VoxelSize = -1 },
+		"grid_divisions": func(p *schema.ProcessingParams) { p.GridDivisions = 0 },
+		"edge_distance":  func(p *schema.ProcessingParams) { p.EdgeDistanceThreshold = -0.1 },
+	} {
+		p := schema.DefaultProcessingParams()
+		mutate(&p)
+		if _, _, err := normalizeParams(p); !stderrors.Is(err, errors.ErrInvalidParams) {
+			t.Errorf("%s: err = %v, want ErrInvalidParams", name, err)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package usecase
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"lct/internal/domain/errors"
+	"lct/internal/logging"
+	"lct/internal/metrics"
+	"lct/internal/repository/schema"
+	"log/slog"
+	"time"
+)
+
+// reapBatch сколько задач с истекшей арендой reaper разбирает за один запрос к базе
+const reapBatch = 100
+
+// recordHeartbeat продлевает аренду задачи на JobLeaseTimeout. Heartbeat, который не удалось записать, только
+// логируется: следующий придет через интервал heartbeat'ов, а аренда рассчитана на пропуск нескольких.
+func (s *Service) recordHeartbeat(ctx context.Context, correlationID string) {
+	metrics.AMQPReplies.WithLabelValues(metrics.ReplyHeartbeat).Inc()
+	job, err := s.PostgresStorage.RecordHeartbeat(ctx, correlationID, s.cfg.JobLeaseTimeout)
+	switch {
+	case stderrors.Is(err, errors.ErrNotFound):
+		// Задача уже завершена или возвращена в очередь по истекшей аренде, а прежний worker еще работает
+		slog.DebugContext(ctx, "Heartbeat задачи, которая не ждет ответа, пропускаем")
+	case err != nil:
+		slog.WarnContext(ctx, "Не удалось записать heartbeat CV worker'а", logging.Err(err))
+	default:
+		slog.DebugContext(withJob(ctx, job), "Аренда задачи продлена", slog.Int("attempt", job.Attempts), slog.Time("lease_expires_at", *job.LeaseExpiresAt))
+	}
+}
+
+// RunJobReaper периодически возвращает в очередь задачи, аренда которых истекла без heartbeat'ов CV worker'а,
+// пока не отменен ctx. Так задача, чей worker завис или был убит (например, по OOM), не ждет PROCESSING_TIMEOUT,
+// а отдается другому worker'у; после JobMaxAttempts попыток она завершается ошибкой.
+// Reaper'ы нескольких экземпляров сервиса не мешают друг другу: задача выбирается под блокировкой.
+func (s *Service) RunJobReaper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.reapJobs(ctx); err != nil && ctx.Err() == nil {
+				slog.ErrorContext(ctx, "Ошибка обработки задач с истекшей арендой", logging.Err(err))
+			}
+		}
+	}
+}
+
+// reapJobs разбирает все задачи с истекшей арендой и возвращает их число
+func (s *Service) reapJobs(ctx context.Context) (int, error) {
+	reaped := 0
+	for {
+		jobs, err := s.PostgresStorage.ClaimExpiredJobs(ctx, reapBatch, s.cfg.JobLeaseTimeout)
+		if err != nil {
+			return reaped, err
+		}
+		for i := range jobs {
+			if err := s.reapJob(withJob(ctx, &jobs[i]), &jobs[i]); err != nil {
+				// Аренда продлена при выборе, поэтому задача будет разобрана снова после JobLeaseTimeout
+				slog.ErrorContext(withJob(ctx, &jobs[i]), "Не удалось обработать задачу с истекшей арендой", logging.Err(err))
+				continue
+			}
+			reaped++
+		}
+		if len(jobs) < reapBatch {
+			return reaped, nil
+		}
+	}
+}
+
+// reapJob возвращает задачу с истекшей арендой в очередь с тем же correlation id, чтобы ее результат
+// дождались те же клиенты, или завершает ее ошибкой, если попытки исчерпаны.
+// Ответ, записанный одновременно с этим, не затирается: задача остается в записанном состоянии.
+func (s *Service) reapJob(ctx context.Context, job *schema.Job) error {
+	if s.cfg.JobMaxAttempts > 0 && int64(job.Attempts) >= s.cfg.JobMaxAttempts {
+		cause := fmt.Sprintf("CV worker не ответил ни в одной из %d попыток", job.Attempts)
+		err := s.PostgresStorage.FinishJob(ctx, job.ID, schema.JobStatusFailed, cause)
+		if stderrors.Is(err, errors.ErrConflict) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		metrics.JobsReaped.WithLabelValues(metrics.ReapFailed).Inc()
+		slog.WarnContext(ctx, "Аренда задачи истекла, попытки исчерпаны", slog.Int("attempts", job.Attempts))
+		return nil
+	}
+
+	metadata, err := s.PostgresStorage.GetMetaDataByID(ctx, job.FileID)
+	if err != nil {
+		return err
+	}
+	msg, err := s.jobMessage(ctx, job, metadata)
+	if err != nil {
+		return err
+	}
+	err = s.PostgresStorage.RequeueJob(ctx, job.ID, job.Status, msg)
+	if stderrors.Is(err, errors.ErrConflict) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	s.wakeRelay()
+	metrics.JobsReaped.WithLabelValues(metrics.ReapRequeued).Inc()
+	slog.WarnContext(ctx, "Аренда задачи истекла, задача возвращена в очередь", slog.Int("attempts", job.Attempts))
+	return nil
+}
@@ -0,0 +1,158 @@
+package usecase
+
+import (
+	"context"
+	stderrors "errors"
+	"lct/internal/auth"
+	"lct/internal/domain/errors"
+	"lct/internal/repository/memory"
+	"lct/internal/repository/schema"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestExpiredLeaseRequeuesJobUntilAttemptsRunOut(t *testing.T) {
+	repo := memory.NewRepository()
+	storage := memory.NewObjectStorage("testbucket")
+	rabbit := memory.NewRabbitClient(storage)
+	cfg := testConfig
+	cfg.ProcessingTimeout = 10 * time.Second
+	cfg.JobLeaseTimeout = time.Minute
+	cfg.JobPickupTimeout = 5 * time.Minute
+	cfg.JobMaxAttempts = 2
+	s := NewService(repo, storage, rabbit, cfg)
+	startRelay(t, s)
+	ctx := asRole(auth.RoleAdmin, "")
+
+	// Часы репозитория переводятся вперед, чтобы аренда истекала без ожидания
+	var offset atomic.Int64
+	repo.Now = func() time.Time { return time.Now().Add(time.Duration(offset.Load())) }
+	advance := func(d time.Duration) { offset.Add(int64(d)) }
+
+	object, fileID, err := s.CreateOne(ctx, strings.NewReader("scan"), "scan.ply", 4, "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	object.Close()
+
+	// CV worker берет задачу и погибает, не ответив, как при OOM
+	rabbit.Worker = func(body []byte) ([]byte, error) {
+		return nil, stderrors.New("killed")
+	}
+	finished := make(chan error, 1)
+	go func() {
+		_, err := s.ProcessFile(ctx, fileID, schema.DefaultProcessingParams(), false)
+		finished <- err
+	}()
+	waitFor(t, func() bool { return len(rabbit.Messages()) == 1 })
+	msg := repo.Outbox()[0]
+	job := func() schema.Job {
+		job, _ := repo.Job(msg.JobID)
+		return job
+	}
+
+	// Публикация засчитывает попытку и выдает аренду на время ожидания первого heartbeat'а
+	waitFor(t, func() bool { return job().LeaseExpiresAt != nil })
+	if got := job(); got.Attempts != 1 || got.HeartbeatAt != nil {
+		t.Fatalf("published job = %+v, want attempt 1 without heartbeats", got)
+	}
+	advance(4 * time.Minute)
+	if n, err := s.reapJobs(context.Background()); err != nil || n != 0 {
+		t.Fatalf("reapJobs() while waiting for first heartbeat = %d, %v", n, err)
+	}
+
+	// Worker погиб, не успев прислать ни одного heartbeat'а: задача возвращается в очередь с тем же correlation id
+	advance(2 * time.Minute)
+	if n, err := s.reapJobs(context.Background()); err != nil || n != 1 {
+		t.Fatalf("reapJobs() without heartbeats = %d, %v", n, err)
+	}
+	waitFor(t, func() bool { return len(rabbit.Messages()) == 2 })
+	if outbox := repo.Outbox(); len(outbox) != 2 || outbox[1].CorrelationID != msg.CorrelationID {
+		t.Errorf("outbox after requeue = %+v", outbox)
+	}
+	waitFor(t, func() bool { return job().Attempts == 2 })
+
+	// Heartbeat'ы второй попытки продлевают аренду на JobLeaseTimeout
+	rabbit.Heartbeat(msg.CorrelationID)
+	waitFor(t, func() bool { return job().HeartbeatAt != nil })
+	first := *job().LeaseExpiresAt
+	advance(40 * time.Second)
+	rabbit.Heartbeat(msg.CorrelationID)
+	waitFor(t, func() bool { return job().LeaseExpiresAt.After(first) })
+	advance(40 * time.Second)
+	if n, err := s.reapJobs(context.Background()); err != nil || n != 0 {
+		t.Fatalf("reapJobs() with live lease = %d, %v", n, err)
+	}
+	if got := job(); got.Attempts != 2 {
+		t.Fatalf("job after heartbeats = %+v, want attempt 2", got)
+	}
+
+	// Heartbeat'ы прекратились, попытки исчерпаны: клиент получает ошибку, не дожидаясь таймаута
+	advance(2 * time.Minute)
+	if n, err := s.reapJobs(context.Background()); err != nil || n != 1 {
+		t.Fatalf("reapJobs() after last attempt = %d, %v", n, err)
+	}
+	select {
+	case err := <-finished:
+		if !stderrors.Is(err, errors.ErrWorkerFailed) {
+			t.Errorf("ProcessFile() = %v, want worker failure", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("ProcessFile() did not notice the failed job")
+	}
+	if got := job(); got.Status != schema.JobStatusFailed || got.Error == "" {
+		t.Errorf("job after attempts ran out = %+v, want failed", got)
+	}
+	if n := len(rabbit.Messages()); n != 2 {
+		t.Errorf("published %d messages, want 2", n)
+	}
+}
+
+func TestReaperDoesNotRequeueAnsweredJob(t *testing.T) {
+	repo := memory.NewRepository()
+	storage := memory.NewObjectStorage("testbucket")
+	cfg := testConfig
+	cfg.JobLeaseTimeout = time.Minute
+	cfg.JobMaxAttempts = 3
+	s := NewService(repo, storage, memory.NewRabbitClient(storage), cfg)
+	ctx := asRole(auth.RoleAdmin, "")
+
+	object, fileID, err := s.CreateOne(ctx, strings.NewReader("scan"), "scan.ply", 4, "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	object.Close()
+	job := &schema.Job{FileID: fileID, CorrelationID: "c1", Project: auth.DefaultProject}
+	if _, err := repo.EnqueueJob(context.Background(), job, &schema.OutboxMessage{CorrelationID: "c1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.RecordHeartbeat(context.Background(), "c1", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	// Ответ записан после того, как reaper выбрал задачу: возврат в очередь его не затирает
+	repo.Now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	expired, err := repo.ClaimExpiredJobs(context.Background(), reapBatch, time.Minute)
+	if err != nil || len(expired) != 1 {
+		t.Fatalf("ClaimExpiredJobs() = %v, %v", expired, err)
+	}
+	if err := repo.CompleteJob(context.Background(), job.ID, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.reapJob(context.Background(), &expired[0]); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := repo.Job(job.ID); got.Status != schema.JobStatusDone {
+		t.Errorf("job status = %s, want done", got.Status)
+	}
+	if n := len(repo.Outbox()); n != 1 {
+		t.Errorf("outbox messages = %d, want 1", n)
+	}
+
+	// Heartbeat уже выполненной задачи ничего не меняет
+	if _, err := repo.RecordHeartbeat(context.Background(), "c1", time.Minute); !stderrors.Is(err, errors.ErrNotFound) {
+		t.Errorf("RecordHeartbeat() on done job = %v, want not found", err)
+	}
+}
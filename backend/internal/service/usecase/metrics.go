@@ -0,0 +1,62 @@
+package usecase
+
+import (
+	"context"
+	"lct/internal/logging"
+	"lct/internal/metrics"
+	"lct/internal/repository/schema"
+	"log/slog"
+	"time"
+)
+
+// jobStatuses состояния задач, для которых всегда отдается число задач, даже нулевое
+var jobStatuses = []string{
+	schema.JobStatusPending,
+	schema.JobStatusDone,
+	schema.JobStatusFailed,
+	schema.JobStatusTimeout,
+	schema.JobStatusInterrupted,
+}
+
+// RunMetricsSampler периодически снимает число задач по состояниям и глубину очереди CV worker'а, пока не отменен ctx.
+// Эти значения дороже считать при каждом обращении к /metrics, поэтому они обновляются в фоне.
+func (s *Service) RunMetricsSampler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.sampleMetrics(ctx, interval)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sampleMetrics обновляет метрики задач и очереди; каждое обращение ограничено периодом опроса
+func (s *Service) sampleMetrics(ctx context.Context, timeout time.Duration) {
+	jobsCtx, cancel := withTimeout(ctx, timeout)
+	counts, err := s.PostgresStorage.JobStatusCounts(jobsCtx)
+	cancel()
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.WarnContext(ctx, "Не удалось посчитать задачи для метрик", logging.Err(err))
+		}
+	} else {
+		for _, status := range jobStatuses {
+			metrics.Jobs.WithLabelValues(status).Set(float64(counts[status]))
+		}
+	}
+
+	queueCtx, cancel := withTimeout(ctx, timeout)
+	stats, err := s.RabbitClient.QueueStats(queueCtx)
+	cancel()
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.WarnContext(ctx, "Не удалось получить состояние очереди задач", logging.Err(err))
+		}
+		return
+	}
+	metrics.QueueMessages.Set(float64(stats.Messages))
+	metrics.QueueConsumers.Set(float64(stats.Consumers))
+}
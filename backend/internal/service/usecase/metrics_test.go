@@ -0,0 +1,41 @@
+package usecase
+
+import (
+	"context"
+	"lct/internal/auth"
+	"lct/internal/metrics"
+	"lct/internal/repository/memory"
+	"lct/internal/repository/schema"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestSampleMetrics(t *testing.T) {
+	env := newAuthzEnv(t)
+	alpha := asRole(auth.RoleOperator, "alpha")
+	fileID := env.upload(t, alpha, "scan")
+	env.process(t, alpha, fileID)
+	env.process(t, alpha, fileID)
+	queued := &schema.Job{FileID: fileID, CorrelationID: "queued", Project: "alpha"}
+	if _, err := env.repo.EnqueueJob(context.Background(), queued, &schema.OutboxMessage{CorrelationID: "queued"}); err != nil {
+		t.Fatal(err)
+	}
+	env.s.RabbitClient.(*memory.RabbitClient).Consumers = 3
+
+	env.s.sampleMetrics(context.Background(), time.Second)
+
+	for status, want := range map[string]float64{
+		schema.JobStatusDone:    2,
+		schema.JobStatusPending: 1,
+		schema.JobStatusFailed:  0,
+	} {
+		if got := testutil.ToFloat64(metrics.Jobs.WithLabelValues(status)); got != want {
+			t.Errorf("jobs{status=%q} = %v, want %v", status, got, want)
+		}
+	}
+	if got := testutil.ToFloat64(metrics.QueueConsumers); got != 3 {
+		t.Errorf("queue consumers = %v, want 3", got)
+	}
+}
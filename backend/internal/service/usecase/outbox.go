@@ -0,0 +1,117 @@
+package usecase
+
+import (
+	"context"
+	"lct/internal/logging"
+	"lct/internal/repository/rabbitmq"
+	"lct/internal/repository/schema"
+	"lct/internal/tracing"
+	"log/slog"
+	"math/rand"
+	"time"
+)
+
+const (
+	outboxBatchSize   = 100             // Сколько сообщений relay забирает за раз
+	outboxLease       = time.Minute     // На сколько забранные сообщения скрываются от других relay
+	outboxBaseBackoff = time.Second     // Задержка перед первой повторной публикацией
+	outboxMaxBackoff  = 5 * time.Minute // Задержка по умолчанию, если OutboxMaxBackoff не задан
+	outboxJitter      = 0.2             // Разброс задержки, чтобы повторы не шли волной
+)
+
+// wakeRelay сообщает relay, что в outbox появилось сообщение, не дожидаясь очередного опроса
+func (s *Service) wakeRelay() {
+	select {
+	case s.relayWake <- struct{}{}:
+	default:
+	}
+}
+
+// RunOutboxRelay публикует сообщения из outbox, пока не отменен ctx.
+// Outbox просматривается раз в interval и сразу после создания задачи. Сообщение отмечается отправленным
+// только после подтверждения брокера, поэтому каждая принятая задача доставляется хотя бы один раз.
+func (s *Service) RunOutboxRelay(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.relayOutbox(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.relayWake:
+		}
+	}
+}
+
+// relayOutbox публикует все готовые к отправке сообщения.
+// После первой неудачи остаток пачки не трогается: брокер, скорее всего, недоступен, и сообщения вернутся по истечении lease.
+func (s *Service) relayOutbox(ctx context.Context) {
+	for {
+		messages, err := s.PostgresStorage.ClaimOutbox(ctx, outboxBatchSize, outboxLease)
+		if err != nil {
+			if ctx.Err() == nil {
+				slog.ErrorContext(ctx, "Ошибка выборки сообщений outbox", logging.Err(err))
+			}
+			return
+		}
+		for _, msg := range messages {
+			if !s.publishOutbox(ctx, msg) {
+				return
+			}
+		}
+		if len(messages) < outboxBatchSize {
+			return
+		}
+	}
+}
+
+// publishOutbox публикует одно сообщение и фиксирует результат; false, если публикация не удалась.
+// Публикация продолжает трассу запроса, создавшего задачу.
+func (s *Service) publishOutbox(ctx context.Context, msg schema.OutboxMessage) bool {
+	ctx = logging.WithJobID(tracing.Extract(ctx, msg.TraceContext), msg.JobID)
+	publishCtx, cancel := withTimeout(ctx, s.cfg.PublishTimeout)
+	defer cancel()
+
+	err := s.RabbitClient.Publish(publishCtx, rabbitmq.Message{
+		ID:            msg.ID,
+		CorrelationID: msg.CorrelationID,
+		// Ответ всегда идет в текущую очередь результатов: сообщения, записанные до ее появления,
+		// хранят имя исключительной очереди экземпляра, которой уже нет
+		ReplyTo:    s.RabbitClient.ReplyQueue(),
+		RoutingKey: msg.RoutingKey,
+		Body:       msg.Payload,
+	})
+	// Результат фиксируем, даже если relay уже останавливается
+	ctx = context.WithoutCancel(ctx)
+	if err != nil {
+		delay := outboxBackoff(msg.Attempts+1, s.cfg.OutboxMaxBackoff)
+		slog.WarnContext(ctx, "Не удалось опубликовать задачу", slog.Int("attempt", msg.Attempts+1), slog.Duration("retry_in", delay), logging.Err(err))
+		if err := s.PostgresStorage.MarkOutboxFailed(ctx, msg.ID, err.Error(), delay); err != nil {
+			slog.ErrorContext(ctx, "Ошибка при сохранении попытки публикации", slog.Int64("outbox_id", msg.ID), logging.Err(err))
+		}
+		return false
+	}
+
+	// Если отметка не сохранится, сообщение будет опубликовано повторно: CV worker получит задачу дважды, ответ — один.
+	// С публикации отсчитывается аренда задачи: если worker не пришлет heartbeat за JobPickupTimeout, reaper вернет ее в очередь
+	if err := s.PostgresStorage.MarkOutboxSent(ctx, msg.ID, s.cfg.JobPickupTimeout); err != nil {
+		slog.ErrorContext(ctx, "Ошибка при отметке сообщения отправленным", slog.Int64("outbox_id", msg.ID), logging.Err(err))
+	}
+	return true
+}
+
+// outboxBackoff задержка перед попыткой номер attempt: экспоненциальный рост от секунды до max с разбросом ±20%
+func outboxBackoff(attempt int, max time.Duration) time.Duration {
+	if max <= 0 {
+		max = outboxMaxBackoff
+	}
+	delay := max
+	if attempt <= 30 {
+		if d := outboxBaseBackoff << (attempt - 1); d > 0 && d < max {
+			delay = d
+		}
+	}
+	jitter := 1 + outboxJitter*(2*rand.Float64()-1)
+	return time.Duration(float64(delay) * jitter)
+}
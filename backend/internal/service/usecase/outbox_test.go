@@ -0,0 +1,174 @@
+package usecase
+
+import (
+	stderrors "errors"
+	"lct/internal/auth"
+	"lct/internal/domain/errors"
+	"lct/internal/repository/memory"
+	"lct/internal/repository/schema"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestOutboxRelayRetriesUntilBrokerIsBack(t *testing.T) {
+	repo := memory.NewRepository()
+	storage := memory.NewObjectStorage("testbucket")
+	rabbit := memory.NewRabbitClient(storage)
+	cfg := testConfig
+	cfg.ProcessingTimeout = 5 * time.Second
+	s := NewService(repo, storage, rabbit, cfg)
+	startRelay(t, s)
+	ctx := asRole(auth.RoleAdmin, "")
+
+	// Часы репозитория можно перевести вперед, чтобы не ждать задержку повторной публикации
+	var offset atomic.Int64
+	repo.Now = func() time.Time { return time.Now().Add(time.Duration(offset.Load())) }
+
+	object, fileID, err := s.CreateOne(ctx, strings.NewReader("scan"), "scan.ply", 4, "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	object.Close()
+
+	rabbit.FailOn("Publish", stderrors.New("connection refused"))
+	type outcome struct {
+		job *schema.Job
+		err error
+	}
+	finished := make(chan outcome, 1)
+	go func() {
+		result, err := s.ProcessFile(ctx, fileID, schema.DefaultProcessingParams(), false)
+		if err != nil {
+			finished <- outcome{nil, err}
+			return
+		}
+		finished <- outcome{result.Job, nil}
+	}()
+
+	// Задача принята и записана в outbox, хотя брокер недоступен
+	waitFor(t, func() bool {
+		messages := repo.Outbox()
+		return len(messages) == 1 && messages[0].Attempts > 0
+	})
+	msg := repo.Outbox()[0]
+	if msg.SentAt != nil || msg.LastError == "" || msg.ReplyTo != rabbit.ReplyQueue() {
+		t.Errorf("outbox message after failed publish = %+v", msg)
+	}
+	if job, _ := repo.Job(msg.JobID); job.Status != schema.JobStatusPending {
+		t.Errorf("job status = %s, want pending", job.Status)
+	}
+
+	rabbit.Clear("Publish")
+	offset.Store(int64(time.Hour))
+
+	select {
+	case out := <-finished:
+		if out.err != nil || out.job.Status != schema.JobStatusDone {
+			t.Fatalf("ProcessFile() = %+v, %v", out.job, out.err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("job was not published after broker recovered")
+	}
+	if msg := repo.Outbox()[0]; msg.SentAt == nil {
+		t.Errorf("outbox message was not marked sent: %+v", msg)
+	}
+	if n := len(rabbit.Messages()); n != 1 {
+		t.Errorf("published %d messages, want 1", n)
+	}
+}
+
+func TestEnqueueJobFailurePublishesNothing(t *testing.T) {
+	repo := memory.NewRepository()
+	storage := memory.NewObjectStorage("testbucket")
+	rabbit := memory.NewRabbitClient(storage)
+	s := NewService(repo, storage, rabbit, testConfig)
+	startRelay(t, s)
+	ctx := asRole(auth.RoleAdmin, "")
+
+	object, fileID, err := s.CreateOne(ctx, strings.NewReader("scan"), "scan.ply", 4, "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	object.Close()
+
+	repo.FailOn("EnqueueJob", stderrors.New("db is down"))
+	if _, err := s.ProcessFile(ctx, fileID, schema.DefaultProcessingParams(), false); err == nil {
+		t.Fatal("expected error")
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := len(rabbit.Messages()); n != 0 {
+		t.Errorf("published %d messages, want 0", n)
+	}
+}
+
+func TestOutboxBackoff(t *testing.T) {
+	for _, tc := range []struct {
+		attempt  int
+		min, max time.Duration
+	}{
+		{1, 800 * time.Millisecond, 1200 * time.Millisecond},
+		{4, 6400 * time.Millisecond, 9600 * time.Millisecond},
+		{10, 48 * time.Second, 72 * time.Second},
+		{100, 48 * time.Second, 72 * time.Second},
+	} {
+		if d := outboxBackoff(tc.attempt, time.Minute); d < tc.min || d > tc.max {
+			t.Errorf("outboxBackoff(%d) = %s, want in [%s, %s]", tc.attempt, d, tc.min, tc.max)
+		}
+	}
+}
+
+// waitFor ждет выполнения условия не дольше секунды
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition was not met in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestOutboxPublishesJobThatTimedOutWhileBrokerWasDown(t *testing.T) {
+	repo := memory.NewRepository()
+	storage := memory.NewObjectStorage("testbucket")
+	rabbit := memory.NewRabbitClient(storage)
+	cfg := testConfig
+	cfg.ProcessingTimeout = 50 * time.Millisecond
+	s := NewService(repo, storage, rabbit, cfg)
+	startRelay(t, s)
+	ctx := asRole(auth.RoleAdmin, "")
+
+	var offset atomic.Int64
+	repo.Now = func() time.Time { return time.Now().Add(time.Duration(offset.Load())) }
+
+	object, fileID, err := s.CreateOne(ctx, strings.NewReader("scan"), "scan.ply", 4, "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	object.Close()
+
+	// Брокер недоступен дольше, чем клиент ждет результат
+	rabbit.FailOn("Publish", stderrors.New("connection refused"))
+	result, err := s.ProcessFile(ctx, fileID, schema.DefaultProcessingParams(), false)
+	if !stderrors.Is(err, errors.ErrProcessingTimeout) {
+		t.Fatalf("ProcessFile() = %v, want processing timeout", err)
+	}
+	jobID := result.Job.ID
+	if job, _ := repo.Job(jobID); job.Status != schema.JobStatusTimeout {
+		t.Fatalf("job status = %s, want timeout", job.Status)
+	}
+
+	// Брокер вернулся: задача все равно публикуется, и ее результат записывается
+	rabbit.Clear("Publish")
+	offset.Store(int64(time.Hour))
+	waitFor(t, func() bool {
+		job, _ := repo.Job(jobID)
+		return job.Status == schema.JobStatusDone && job.ArtifactID != nil
+	})
+	if n := len(rabbit.Messages()); n != 1 {
+		t.Errorf("published %d messages, want 1", n)
+	}
+}
@@ -0,0 +1,147 @@
+package usecase
+
+import (
+	"context"
+	"lct/internal/auth"
+	"lct/internal/domain/dto"
+	"lct/internal/logging"
+	"log/slog"
+	"time"
+)
+
+// Reconcile сверяет записи файлов с объектами хранилища:
+// удаляет загрузки, зависшие в состоянии pending дольше ReconcileGracePeriod, и объекты, на которые ничто не ссылается.
+// Объекты моложе ReconcileGracePeriod не трогаются: они могут принадлежать загрузке или задаче, которая еще идет.
+// Записи, объекты которых пропали из хранилища, не удаляются, а попадают в отчет.
+// Сверяются общий бакет сервиса и отдельные бакеты арендаторов. Доступно только администраторам.
+func (s *Service) Reconcile(ctx context.Context) (*dto.ReconcileReport, error) {
+	if _, err := authorize(ctx, auth.PermAdmin); err != nil {
+		return nil, err
+	}
+	return s.reconcile(ctx)
+}
+
+func (s *Service) reconcile(ctx context.Context) (*dto.ReconcileReport, error) {
+	report := &dto.ReconcileReport{
+		RemovedUploads: []int64{},
+		RemovedObjects: []string{},
+		MissingObjects: []string{},
+	}
+
+	stale, err := s.PostgresStorage.ListPendingFiles(ctx, s.cfg.ReconcileGracePeriod)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range stale {
+		deleted, err := s.PostgresStorage.DeletePendingFile(ctx, int64(f.ID))
+		if err != nil {
+			return nil, err
+		}
+		if !deleted {
+			continue // загрузка успела завершиться
+		}
+		if err := s.deleteObject(ctx, f.Bucket, f.ObjectKey); err != nil {
+			slog.WarnContext(ctx, "Сверка: не удалось удалить объект зависшей загрузки", slog.Int(logging.KeyFileID, f.ID), slog.String("object_key", f.ObjectKey), logging.Err(err))
+		}
+		report.RemovedUploads = append(report.RemovedUploads, int64(f.ID))
+	}
+
+	buckets, err := s.reconciledBuckets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, bucket := range buckets {
+		if err := s.reconcileBucket(ctx, bucket, report); err != nil {
+			return nil, err
+		}
+	}
+
+	slog.InfoContext(ctx, "Сверка хранилища завершена", slog.Int("removed_uploads", len(report.RemovedUploads)),
+		slog.Int("removed_objects", len(report.RemovedObjects)), slog.Int("missing_objects", len(report.MissingObjects)))
+	for _, key := range report.MissingObjects {
+		slog.WarnContext(ctx, "Сверка: объект отсутствует в хранилище", slog.String("object_key", key))
+	}
+	return report, nil
+}
+
+// reconciledBuckets возвращает общий бакет сервиса и отдельные бакеты арендаторов
+func (s *Service) reconciledBuckets(ctx context.Context) ([]string, error) {
+	tenants, err := s.PostgresStorage.ListTenants(ctx)
+	if err != nil {
+		return nil, err
+	}
+	buckets := []string{s.ObjectStorage.Bucket()}
+	seen := map[string]bool{s.ObjectStorage.Bucket(): true}
+	for _, t := range tenants {
+		if t.Bucket != "" && !seen[t.Bucket] {
+			seen[t.Bucket] = true
+			buckets = append(buckets, t.Bucket)
+		}
+	}
+	return buckets, nil
+}
+
+// reconcileBucket удаляет объекты бакета без ссылок и дописывает в отчет объекты, пропавшие из бакета
+func (s *Service) reconcileBucket(ctx context.Context, bucket string, report *dto.ReconcileReport) error {
+	// Список объектов читаем до ссылок на них: запись загрузки создается раньше объекта,
+	// поэтому у любого перечисленного объекта запись к этому моменту уже есть
+	objects, err := s.storageIn(bucket).List(ctx)
+	if err != nil {
+		return err
+	}
+	referencedKeys, err := s.PostgresStorage.ListReferencedObjectKeys(ctx, bucket)
+	if err != nil {
+		return err
+	}
+	pending, err := s.PostgresStorage.ListPendingFiles(ctx, 0)
+	if err != nil {
+		return err
+	}
+
+	used := make(map[string]bool, len(referencedKeys)+len(pending))
+	for _, key := range referencedKeys {
+		used[key] = true
+	}
+	for _, f := range pending {
+		if f.Bucket == bucket {
+			used[f.ObjectKey] = true
+		}
+	}
+
+	cutoff := time.Now().Add(-s.cfg.ReconcileGracePeriod)
+	stored := make(map[string]bool, len(objects))
+	for _, obj := range objects {
+		stored[obj.Key] = true
+		if used[obj.Key] || obj.LastModified.After(cutoff) {
+			continue
+		}
+		if err := s.deleteObject(ctx, bucket, obj.Key); err != nil {
+			slog.WarnContext(ctx, "Сверка: не удалось удалить объект", slog.String("object_key", obj.Key), slog.String("bucket", bucket), logging.Err(err))
+			continue
+		}
+		report.RemovedObjects = append(report.RemovedObjects, obj.Key)
+	}
+
+	for _, key := range referencedKeys {
+		if !stored[key] {
+			report.MissingObjects = append(report.MissingObjects, key)
+		}
+	}
+	return nil
+}
+
+// RunReconciler периодически запускает сверку, пока не отменен ctx
+func (s *Service) RunReconciler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.reconcile(ctx); err != nil && ctx.Err() == nil {
+				slog.ErrorContext(ctx, "Ошибка сверки хранилища", logging.Err(err))
+			}
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package usecase
+
+import (
+	"lct/internal/auth"
+	"lct/internal/repository/memory"
+	"lct/internal/repository/schema"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReconcile(t *testing.T) {
+	repo := memory.NewRepository()
+	storage := memory.NewObjectStorage("testbucket")
+	cfg := testConfig
+	cfg.ReconcileGracePeriod = time.Hour
+	s := NewService(repo, storage, memory.NewRabbitClient(storage), cfg)
+	ctx := asRole(auth.RoleAdmin, "")
+
+	old := time.Now().Add(-2 * time.Hour)
+	clock := func(t time.Time) func() time.Time { return func() time.Time { return t } }
+	repo.Now, storage.Now = clock(old), clock(old)
+
+	// Завершенные загрузки: объект одной из них потерян хранилищем
+	for _, key := range []string{"kept", "lost"} {
+		object, _, err := s.CreateOne(ctx, strings.NewReader(key), key+".ply", int64(len(key)), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		object.Close()
+	}
+	storage.DeleteOne(ctx, "lost")
+
+	// Зависшая загрузка и брошенный объект
+	staleID, _ := repo.CreatePendingFile(ctx, &schema.FileMetadata{OriginalFilename: "stale.ply", Size: 5, Bucket: "testbucket", ObjectKey: "stale", Project: auth.DefaultProject})
+	storage.Put("stale", []byte("stale"))
+	storage.Put("orphan", []byte("orphan"))
+
+	// Загрузка, которая еще идет, и свежий объект без ссылок
+	repo.Now, storage.Now = time.Now, time.Now
+	repo.CreatePendingFile(ctx, &schema.FileMetadata{OriginalFilename: "fresh.ply", Size: 5, Bucket: "testbucket", ObjectKey: "fresh", Project: auth.DefaultProject})
+	storage.Put("fresh", []byte("fresh"))
+	storage.Put("processed/recent.ply", []byte("recent"))
+
+	report, err := s.Reconcile(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(report.RemovedUploads, []int64{staleID}) {
+		t.Errorf("RemovedUploads = %v, want [%d]", report.RemovedUploads, staleID)
+	}
+	if !reflect.DeepEqual(report.RemovedObjects, []string{"orphan"}) {
+		t.Errorf("RemovedObjects = %v, want [orphan]", report.RemovedObjects)
+	}
+	if !reflect.DeepEqual(report.MissingObjects, []string{"lost"}) {
+		t.Errorf("MissingObjects = %v, want [lost]", report.MissingObjects)
+	}
+	if keys := storage.Keys(); !reflect.DeepEqual(keys, []string{"fresh", "kept", "processed/recent.ply"}) {
+		t.Errorf("stored objects = %v", keys)
+	}
+	if _, ok := repo.File(staleID); ok {
+		t.Error("stale pending file was not removed")
+	}
+}
@@ -0,0 +1,257 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"lct/internal/domain/errors"
+	"lct/internal/logging"
+	"lct/internal/metrics"
+	"lct/internal/repository/rabbitmq"
+	"lct/internal/repository/schema"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// resultPollInterval как часто ожидающая задача проверяет базу: ответ мог записать другой экземпляр сервиса
+const resultPollInterval = time.Second
+
+// workerReply ответ CV worker'а на задачу обработки
+type workerReply struct {
+	FileName string `json:"filename"`
+	MinioKey string `json:"minio_key"`
+	Error    string `json:"error"`
+}
+
+// jobResult итог задачи, записанный по ответу CV worker'а
+type jobResult struct {
+	job      *schema.Job      // Задача в итоговом состоянии
+	artifact *schema.Artifact // Результат; nil, если задача завершилась ошибкой
+	fileName string
+	err      error // Причина неуспеха
+}
+
+// RunResultConsumer читает очередь результатов и записывает ответы CV worker'а в задачи, пока не отменен ctx.
+// Результат сохраняется независимо от того, ждет ли его еще какой-нибудь клиент: ответ подтверждается брокеру
+// только после записи, поэтому ни перезапуск сервиса, ни закрытый браузер его не теряют.
+func (s *Service) RunResultConsumer(ctx context.Context) {
+	s.RabbitClient.ConsumeReplies(ctx, s.handleReply)
+}
+
+// handleReply записывает ответ CV worker'а или продлевает аренду задачи по его heartbeat'у.
+// Повторный ответ на задачу, по которой ответ уже записан, и ответ на неизвестную задачу подтверждаются
+// без изменений; их объекты без ссылок удалит сверка.
+// Ошибка означает, что ответ не записан и брокер доставит его повторно.
+func (s *Service) handleReply(ctx context.Context, reply rabbitmq.Reply) error {
+	ctx = logging.With(ctx, slog.String("correlation_id", reply.CorrelationID))
+	if reply.Type == rabbitmq.ReplyTypeHeartbeat {
+		s.recordHeartbeat(ctx, reply.CorrelationID)
+		return nil
+	}
+	job, err := s.PostgresStorage.GetJobByCorrelationID(ctx, reply.CorrelationID)
+	if stderrors.Is(err, errors.ErrNotFound) {
+		metrics.AMQPReplies.WithLabelValues(metrics.ReplyUnexpected).Inc()
+		slog.WarnContext(ctx, "Ответ на неизвестную задачу, пропускаем")
+		return nil
+	}
+	if err != nil {
+		metrics.AMQPReplies.WithLabelValues(metrics.ReplyRetry).Inc()
+		return err
+	}
+	ctx = withJob(ctx, job)
+	if jobAnswered(job.Status) {
+		metrics.AMQPReplies.WithLabelValues(metrics.ReplyDuplicate).Inc()
+		slog.InfoContext(ctx, "Ответ на задачу уже записан, повторный пропускаем", slog.String("status", job.Status), slog.Bool("redelivered", reply.Redelivered))
+		return nil
+	}
+
+	result, err := s.recordResult(ctx, job, reply.Body)
+	if err != nil {
+		metrics.AMQPReplies.WithLabelValues(metrics.ReplyRetry).Inc()
+		slog.WarnContext(ctx, "Не удалось записать ответ, он будет доставлен повторно", logging.Err(err))
+		return err
+	}
+	metrics.AMQPReplies.WithLabelValues(metrics.ReplyRecorded).Inc()
+	s.results.deliver(reply.CorrelationID, result)
+	return nil
+}
+
+// jobAnswered по задаче уже записан ответ CV worker'а. Задачи timeout и interrupted ответа еще ждут:
+// результат, пришедший после таймаута ожидания или перезапуска сервиса, сохраняется.
+func jobAnswered(status string) bool {
+	return status == schema.JobStatusDone || status == schema.JobStatusFailed
+}
+
+// recordResult связывает обработанный объект с исходным файлом и задачей или фиксирует ошибку CV worker'а.
+// Ошибка возвращается, только если итог не удалось сохранить; тогда ответ обрабатывается повторно.
+func (s *Service) recordResult(ctx context.Context, job *schema.Job, body []byte) (*jobResult, error) {
+	metadata, err := s.PostgresStorage.GetMetaDataByID(ctx, job.FileID)
+	if err != nil {
+		return nil, err
+	}
+
+	var reply workerReply
+	if err := json.Unmarshal(body, &reply); err != nil {
+		return s.rejectResult(ctx, job, fmt.Errorf("cannot unmarshal response: %w", err))
+	}
+	if reply.Error != "" {
+		return s.rejectResult(ctx, job, fmt.Errorf("%w: %s", errors.ErrWorkerFailed, reply.Error))
+	}
+
+	// Прошлая попытка могла сохранить результат, но не успеть завершить задачу
+	artifact, err := s.findJobArtifact(ctx, job, reply.MinioKey)
+	if err != nil {
+		return nil, err
+	}
+	if artifact == nil {
+		artifact, err = s.RegisterArtifact(ctx, metadata.Bucket, job.FileID, job.ID, schema.ArtifactTypeProcessed, reply.MinioKey)
+		if err != nil {
+			return s.rejectResult(ctx, job, err)
+		}
+	}
+	if metadata.SHA256 != "" {
+		if err := s.PostgresStorage.SaveCachedResult(ctx, metadata.Project, metadata.SHA256, job.ParamsHash, job.ModelVersion, artifact.ID); err != nil {
+			slog.WarnContext(ctx, "Ошибка при сохранении результата в кэш", logging.Err(err))
+		}
+	}
+	if err := s.PostgresStorage.CompleteJob(ctx, job.ID, artifact.ID); err != nil {
+		return nil, err
+	}
+	job.Status = schema.JobStatusDone
+	job.ArtifactID = &artifact.ID
+	job.Error = ""
+	slog.InfoContext(ctx, "Ответ CV worker'а записан", slog.Int64("artifact_id", artifact.ID))
+
+	fileName := reply.FileName
+	if fileName == "" {
+		fileName = metadata.OriginalFilename
+	}
+	return &jobResult{job: job, artifact: artifact, fileName: fileName}, nil
+}
+
+// findJobArtifact ищет результат задачи с ключом objectKey среди производных объектов ее файла
+func (s *Service) findJobArtifact(ctx context.Context, job *schema.Job, objectKey string) (*schema.Artifact, error) {
+	artifacts, err := s.PostgresStorage.GetArtifactsByFileID(ctx, job.FileID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range artifacts {
+		if a := &artifacts[i]; a.ObjectKey == objectKey && a.JobID != nil && *a.JobID == job.ID {
+			return a, nil
+		}
+	}
+	return nil, nil
+}
+
+// rejectResult завершает задачу ошибкой CV worker'а или ошибкой записи его результата
+func (s *Service) rejectResult(ctx context.Context, job *schema.Job, cause error) (*jobResult, error) {
+	if err := s.PostgresStorage.FinishJob(ctx, job.ID, schema.JobStatusFailed, cause.Error()); err != nil && !stderrors.Is(err, errors.ErrConflict) {
+		return nil, err
+	}
+	job.Status = schema.JobStatusFailed
+	job.Error = cause.Error()
+	slog.WarnContext(ctx, "Задача завершилась ошибкой", logging.Err(cause))
+	return &jobResult{job: job, err: cause}, nil
+}
+
+// loadResult возвращает итог задачи, если ответ по ней уже записан, иначе nil
+func (s *Service) loadResult(ctx context.Context, jobID int64) (*jobResult, error) {
+	job, err := s.PostgresStorage.GetJob(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case !jobAnswered(job.Status):
+		return nil, nil
+	case job.Status == schema.JobStatusFailed || job.ArtifactID == nil:
+		return &jobResult{job: job, err: fmt.Errorf("%w: %s", errors.ErrWorkerFailed, job.Error)}, nil
+	}
+	artifact, err := s.PostgresStorage.GetArtifact(ctx, *job.ArtifactID)
+	if err != nil {
+		return nil, err
+	}
+	metadata, err := s.PostgresStorage.GetMetaDataByID(ctx, job.FileID)
+	if err != nil {
+		return nil, err
+	}
+	return &jobResult{job: job, artifact: artifact, fileName: metadata.OriginalFilename}, nil
+}
+
+// awaitResult ждет, пока по задаче не будет записан ответ, не дольше ProcessingTimeout.
+// Ответ, записанный этим экземпляром, приходит сразу, записанный другим — при очередной проверке базы.
+// По таймауту возвращает ErrProcessingTimeout, при отмене ctx — ctx.Err().
+func (s *Service) awaitResult(ctx context.Context, job *schema.Job, waiter *resultWaiter) (*jobResult, error) {
+	defer waiter.cancel()
+
+	timer := time.NewTimer(s.cfg.ProcessingTimeout)
+	defer timer.Stop()
+	poll := time.NewTicker(resultPollInterval)
+	defer poll.Stop()
+	for {
+		select {
+		case result := <-waiter.ch:
+			return result, nil
+		case <-poll.C:
+			result, err := s.loadResult(ctx, job.ID)
+			if err != nil {
+				slog.WarnContext(ctx, "Ошибка при проверке состояния задачи", logging.Err(err))
+				continue
+			}
+			if result != nil {
+				return result, nil
+			}
+		case <-timer.C:
+			return nil, errors.ErrProcessingTimeout
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// resultWaiters задачи, итог которых ждут в этом экземпляре, по correlation id
+type resultWaiters struct {
+	mu      sync.Mutex
+	waiters map[string]chan *jobResult
+}
+
+func newResultWaiters() *resultWaiters {
+	return &resultWaiters{waiters: make(map[string]chan *jobResult)}
+}
+
+// expect регистрирует ожидание итога задачи с correlation id
+func (r *resultWaiters) expect(correlationID string) *resultWaiter {
+	ch := make(chan *jobResult, 1)
+	r.mu.Lock()
+	r.waiters[correlationID] = ch
+	r.mu.Unlock()
+	return &resultWaiter{waiters: r, correlationID: correlationID, ch: ch}
+}
+
+// deliver передает итог ожидающей задаче, если ее ждут в этом экземпляре
+func (r *resultWaiters) deliver(correlationID string, result *jobResult) {
+	r.mu.Lock()
+	ch, ok := r.waiters[correlationID]
+	delete(r.waiters, correlationID)
+	r.mu.Unlock()
+	if ok {
+		ch <- result
+	}
+}
+
+// resultWaiter ожидание итога одной задачи
+type resultWaiter struct {
+	waiters       *resultWaiters
+	correlationID string
+	ch            chan *jobResult
+}
+
+// cancel снимает ожидание, если итог еще не пришел
+func (w *resultWaiter) cancel() {
+	w.waiters.mu.Lock()
+	defer w.waiters.mu.Unlock()
+	if w.waiters.waiters[w.correlationID] == w.ch {
+		delete(w.waiters.waiters, w.correlationID)
+	}
+}
@@ -0,0 +1,118 @@
+package usecase
+
+import (
+	"context"
+	stderrors "errors"
+	"lct/internal/auth"
+	"lct/internal/domain/errors"
+	"lct/internal/repository/memory"
+	"lct/internal/repository/rabbitmq"
+	"lct/internal/repository/schema"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResultIsRecordedAfterClientStopsWaiting(t *testing.T) {
+	repo := memory.NewRepository()
+	storage := memory.NewObjectStorage("testbucket")
+	rabbit := memory.NewRabbitClient(storage)
+	cfg := testConfig
+	cfg.ProcessingTimeout = 20 * time.Millisecond
+	s := NewService(repo, storage, rabbit, cfg)
+	startRelay(t, s)
+	ctx := asRole(auth.RoleAdmin, "")
+
+	object, fileID, err := s.CreateOne(ctx, strings.NewReader("scan"), "scan.ply", 4, "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	object.Close()
+
+	release := make(chan struct{})
+	copyWorker := rabbit.Worker
+	rabbit.Worker = func(body []byte) ([]byte, error) {
+		<-release
+		return copyWorker(body)
+	}
+
+	result, err := s.ProcessFile(ctx, fileID, schema.DefaultProcessingParams(), false)
+	if !stderrors.Is(err, errors.ErrProcessingTimeout) {
+		t.Fatalf("ProcessFile() = %v, want processing timeout", err)
+	}
+	jobID := result.Job.ID
+
+	// Ответ приходит, когда его уже никто не ждет, и все равно записывается в задачу
+	close(release)
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		job, _ := repo.Job(jobID)
+		if job.Status == schema.JobStatusDone && job.ArtifactID != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("late reply was not recorded: %+v", job)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRepliesAreRecordedOnce(t *testing.T) {
+	repo := memory.NewRepository()
+	storage := memory.NewObjectStorage("testbucket")
+	s := NewService(repo, storage, memory.NewRabbitClient(storage), testConfig)
+	ctx := asRole(auth.RoleAdmin, "")
+
+	object, fileID, err := s.CreateOne(ctx, strings.NewReader("scan"), "scan.ply", 4, "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	object.Close()
+	job := &schema.Job{FileID: fileID, CorrelationID: "c1", Project: auth.DefaultProject}
+	if _, err := repo.EnqueueJob(context.Background(), job, &schema.OutboxMessage{CorrelationID: "c1"}); err != nil {
+		t.Fatal(err)
+	}
+	storage.Put("processed/1.ply", []byte("clean"))
+	storage.Put("processed/2.ply", []byte("clean again"))
+	reply := rabbitmq.Reply{CorrelationID: "c1", Body: []byte(`{"minio_key": "processed/1.ply"}`)}
+
+	// Результат сохранен, а задача не завершена: ответ возвращается в очередь
+	repo.FailOn("CompleteJob", stderrors.New("db is down"))
+	if err := s.handleReply(context.Background(), reply); err == nil {
+		t.Fatal("handleReply() succeeded while the job could not be completed")
+	}
+	if got, _ := repo.Job(job.ID); got.Status != schema.JobStatusPending {
+		t.Errorf("job status after failed write = %s, want pending", got.Status)
+	}
+	repo.Clear("CompleteJob")
+
+	// Повторная доставка того же ответа использует уже сохраненный результат
+	if err := s.handleReply(context.Background(), rabbitmq.Reply{CorrelationID: "c1", Body: reply.Body, Redelivered: true}); err != nil {
+		t.Fatal(err)
+	}
+	done, _ := repo.Job(job.ID)
+	if done.Status != schema.JobStatusDone || done.ArtifactID == nil {
+		t.Fatalf("job = %+v, want done with artifact", done)
+	}
+
+	// Повторные ответы на выполненную задачу и ответы на неизвестные задачи ее не меняют
+	for _, duplicate := range []rabbitmq.Reply{
+		{CorrelationID: "c1", Body: []byte(`{"minio_key": "processed/2.ply"}`)},
+		{CorrelationID: "c1", Body: []byte(`{"error": "out of memory"}`)},
+		{CorrelationID: "unknown", Body: reply.Body},
+	} {
+		if err := s.handleReply(context.Background(), duplicate); err != nil {
+			t.Errorf("handleReply(%s) = %v", duplicate.Body, err)
+		}
+	}
+	if got, _ := repo.Job(job.ID); got.Status != schema.JobStatusDone || *got.ArtifactID != *done.ArtifactID {
+		t.Errorf("job after duplicates = %+v, want unchanged", got)
+	}
+	artifacts, err := repo.GetArtifactsByFileID(context.Background(), fileID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(artifacts) != 1 {
+		t.Errorf("artifacts = %d, want 1", len(artifacts))
+	}
+}
@@ -2,45 +2,341 @@ package usecase
 
 import (
 	"context"
-	"github.com/minio/minio-go/v7"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"io"
+	"lct/internal/auth"
+	"lct/internal/domain/errors"
+	"lct/internal/logging"
+	"lct/internal/metrics"
 	"lct/internal/repository"
-	minio2 "lct/internal/repository/minio"
+	"lct/internal/repository/rabbitmq"
 	"lct/internal/repository/schema"
+	"lct/internal/tracing"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// Config параметры сервисного слоя
+type Config struct {
+	ModelVersion      string        // Версия модели CV worker'а, входит в ключ кэша результатов
+	ProcessingTimeout time.Duration // Время ожидания ответа CV worker'а
+	// Насколько heartbeat CV worker'а продлевает аренду задачи; должно быть в несколько раз больше интервала heartbeat'ов
+	JobLeaseTimeout time.Duration
+	// Сколько опубликованная задача ждет первого heartbeat'а; должно покрывать время ожидания в очереди RabbitMQ
+	JobPickupTimeout time.Duration
+	JobMaxAttempts   int64         // Сколько раз задача отдается CV worker'у, прежде чем завершиться ошибкой
+	StorageTimeout   time.Duration // Таймаут служебных операций с хранилищем: проверка бакета, удаление
+	UploadTimeout    time.Duration // Таймаут загрузки файла, включая чтение сохраненного объекта
+	DownloadTimeout  time.Duration // Таймаут чтения объекта из хранилища
+	// Сколько ждать, прежде чем считать загрузку зависшей, а объект без ссылок — брошенным
+	ReconcileGracePeriod time.Duration
+	PublishTimeout       time.Duration // Сколько ждать подтверждения публикации от брокера
+	OutboxMaxBackoff     time.Duration // Максимальная задержка между попытками публикации сообщения из outbox
+	BootstrapAPIKey      string        // API ключ из конфигурации для создания первых ключей; пустой — не принимается
+	MaxPendingJobs       int64         // Сколько задач всех проектов может ждать CV worker'а одновременно; 0 — без ограничения
+	ReadinessTimeout     time.Duration // Таймаут проверки каждой зависимости в Readiness; 0 — без ограничения
+	DegradedStart        bool          // Сервис работает без RabbitMQ: его недоступность делает Readiness degraded, а не unavailable
+}
+
 type Service struct {
 	PostgresStorage repository.Repository
-	MinioStorage    minio2.Client
+	ObjectStorage   repository.ObjectStorage
+	RabbitClient    rabbitmq.Client
+	cfg             Config
+	drain           *drainer
+	relayWake       chan struct{} // Будит relay outbox после создания задачи
+	results         *resultWaiters
 }
 
-func NewService(postgres repository.Repository, minio minio2.Client) *Service {
+func NewService(postgres repository.Repository, objects repository.ObjectStorage, rabbit rabbitmq.Client, cfg Config) *Service {
 	return &Service{
 		PostgresStorage: postgres,
-		MinioStorage:    minio,
+		ObjectStorage:   objects,
+		RabbitClient:    rabbit,
+		cfg:             cfg,
+		drain:           newDrainer(),
+		relayWake:       make(chan struct{}, 1),
+		results:         newResultWaiters(),
+	}
+}
+
+// timedObject объект хранилища, контекст чтения которого освобождается при закрытии
+type timedObject struct {
+	repository.Object
+	cancel context.CancelFunc
+}
+
+func (o timedObject) Close() error {
+	defer o.cancel()
+	return o.Object.Close()
+}
+
+// withTimeout ограничивает операцию таймаутом из конфигурации; нулевой таймаут означает отсутствие ограничения
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
 	}
+	return context.WithTimeout(ctx, timeout)
 }
 
-func (s *Service) InitMinio() error {
-	return s.MinioStorage.InitMinio()
+func (s *Service) InitStorage(ctx context.Context) error {
+	ctx, cancel := withTimeout(ctx, s.cfg.StorageTimeout)
+	defer cancel()
+	return s.ObjectStorage.Init(ctx)
 }
 
-func (s *Service) CreateOne(ctx *context.Context, r io.Reader, size int64, file minio2.FileDataType, fileName string, fileSize int64, objectKey string) (*minio.Object, int64, error) {
-	id, err := s.PostgresStorage.SaveMetaData(ctx, fileName, fileSize, objectKey)
+// CreateOne загружает файл в объектное хранилище, попутно вычисляя SHA-256 содержимого, и сохраняет метаданные.
+// Загрузка проходит в три шага: запись в состоянии pending, запись объекта, фиксация в одной транзакции.
+// При ошибке на любом шаге запись и объект удаляются; то, что не удалось удалить, подберет сверка (Reconcile).
+// Если объект с таким же содержимым уже загружался, новая копия удаляется, а запись ссылается на существующий объект.
+// Загрузка прерывается при отмене ctx (например, когда клиент оборвал запрос) или по истечении UploadTimeout.
+// Файл принадлежит загрузившему его клиенту и попадает в его проект: в бакет арендатора под его префиксом.
+// Если файл не помещается в квоту хранилища арендатора, возвращается errors.ErrStorageQuotaExceeded.
+func (s *Service) CreateOne(ctx context.Context, r io.Reader, fileName string, fileSize int64, objectKey string) (_ repository.Object, _ int64, err error) {
+	ctx, span := tracing.Start(ctx, "Service.CreateOne", trace.WithAttributes(attribute.Int64("file.size", fileSize)))
+	defer func() { tracing.End(span, err) }()
+
+	p, err := authorize(ctx, auth.PermUpload)
 	if err != nil {
 		return nil, 0, err
 	}
+	tenant, err := s.tenantOf(ctx, p.HomeProject())
+	if err != nil {
+		return nil, 0, err
+	}
+	bucket := s.bucketOf(tenant)
+	objectKey = tenant.Prefix + objectKey
+	id, err := s.PostgresStorage.CreatePendingFile(ctx, &schema.FileMetadata{
+		OriginalFilename: fileName,
+		Size:             fileSize,
+		Bucket:           bucket,
+		ObjectKey:        objectKey,
+		Owner:            p.Subject,
+		Project:          tenant.ID,
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	// Компенсация должна выполниться, даже если запрос клиента уже отменен
+	cleanupCtx := context.WithoutCancel(ctx)
+
+	uploadCtx, cancel := withTimeout(ctx, s.cfg.UploadTimeout)
+	hash := sha256.New()
+	object, err := s.storageIn(bucket).CreateOne(uploadCtx, io.TeeReader(r, hash), fileSize, objectKey)
+	if err != nil {
+		cancel()
+		s.abortUpload(cleanupCtx, id, bucket, objectKey)
+		return nil, 0, err
+	}
+	object = timedObject{Object: object, cancel: cancel}
+	sum := hex.EncodeToString(hash.Sum(nil))
+	span.SetAttributes(attribute.Int64("file.id", id))
+
+	key, err := s.PostgresStorage.CommitFile(ctx, id, sum)
+	if err != nil {
+		object.Close()
+		s.abortUpload(cleanupCtx, id, bucket, objectKey)
+		return nil, 0, err
+	}
+	metrics.Uploads.Inc()
+	metrics.UploadBytes.Add(float64(fileSize))
+	if key != objectKey {
+		// Дубликат: оставляем только уже существующий объект
+		object.Close()
+		if err := s.deleteObject(cleanupCtx, bucket, objectKey); err != nil {
+			slog.WarnContext(ctx, "Не удалось удалить дубликат", slog.String("object_key", objectKey), logging.Err(err))
+		}
+		object, err = s.openObject(ctx, bucket, key)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return object, id, nil
+}
+
+// abortUpload удаляет запись незавершенной загрузки и ее объект.
+// Объект удаляется, только если удалось удалить запись: иначе фиксация могла пройти, и объект уже используется.
+func (s *Service) abortUpload(ctx context.Context, id int64, bucket string, objectKey string) {
+	deleted, err := s.PostgresStorage.DeletePendingFile(ctx, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "Не удалось удалить незавершенную загрузку", slog.Int64(logging.KeyFileID, id), logging.Err(err))
+		return
+	}
+	if !deleted {
+		return
+	}
+	if err := s.deleteObject(ctx, bucket, objectKey); err != nil {
+		slog.WarnContext(ctx, "Не удалось удалить объект незавершенной загрузки", slog.String("object_key", objectKey), logging.Err(err))
+	}
+}
+
+// GetOne открывает объект на чтение, если он доступен проекту клиента через файл, артефакт или задачу.
+// Чтение ограничено DownloadTimeout и прерывается при отмене ctx.
+func (s *Service) GetOne(ctx context.Context, objectID string) (_ repository.Object, err error) {
+	ctx, span := tracing.Start(ctx, "Service.GetOne", trace.WithAttributes(attribute.String("object.key", objectID)))
+	defer func() { tracing.End(span, err) }()
+
+	p, err := authorize(ctx, auth.PermDownload)
+	if err != nil {
+		return nil, err
+	}
+	project := p.Project
+	if p.Role == auth.RoleAdmin {
+		project = ""
+	}
+	bucket, err := s.PostgresStorage.ObjectBucket(ctx, objectID, project)
+	if err != nil {
+		return nil, err
+	}
+	if bucket == "" {
+		return nil, fmt.Errorf("%w: объект %s", errors.ErrNotFound, objectID)
+	}
+	return s.openObject(ctx, bucket, objectID)
+}
+
+// openObject открывает объект бакета без проверки доступа; чтение ограничено DownloadTimeout
+func (s *Service) openObject(ctx context.Context, bucket string, objectID string) (repository.Object, error) {
+	ctx, cancel := withTimeout(ctx, s.cfg.DownloadTimeout)
+	object, err := s.storageIn(bucket).GetOne(ctx, objectID)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return timedObject{Object: object, cancel: cancel}, nil
+}
 
-	object, err := s.MinioStorage.CreateOne(r, size, file, objectKey)
+// deleteObject удаляет объект из бакета с таймаутом StorageTimeout
+func (s *Service) deleteObject(ctx context.Context, bucket string, objectKey string) error {
+	ctx, cancel := withTimeout(ctx, s.cfg.StorageTimeout)
+	defer cancel()
+	return s.storageIn(bucket).DeleteOne(ctx, objectKey)
+}
 
-	return object, id, err
+func (s *Service) GetMetaDataByID(ctx context.Context, id int64) (*schema.FileMetadata, error) {
+	p, err := authorize(ctx, auth.PermDownload)
+	if err != nil {
+		return nil, err
+	}
+	return s.fileInProject(ctx, p, id)
 }
 
-func (s *Service) GetOne(r io.Reader, size int64, file minio2.FileDataType, objectID string) (*minio.Object, error) {
-	return s.MinioStorage.GetOne(r, size, file, objectID)
+// Размер страницы списка файлов
+const (
+	DefaultFileListLimit = 50
+	MaxFileListLimit     = 500
+)
+
+// ListFiles ищет файлы, видимые клиенту. Клиенту, кроме администратора, видны только файлы его проекта,
+// администратор может ограничить поиск проектом. Нулевой Limit означает DefaultFileListLimit.
+func (s *Service) ListFiles(ctx context.Context, filter schema.FileFilter) ([]schema.FileMetadata, int64, error) {
+	p, err := authorize(ctx, auth.PermDownload)
+	if err != nil {
+		return nil, 0, err
+	}
+	if filter.Limit == 0 {
+		filter.Limit = DefaultFileListLimit
+	}
+	if filter.Limit < 0 || filter.Limit > MaxFileListLimit || filter.Offset < 0 {
+		return nil, 0, errors.ErrInvalidArgument.Errorf("limit должен быть от 1 до %d, offset — неотрицательным", MaxFileListLimit)
+	}
+	if p.Role != auth.RoleAdmin {
+		filter.Project = p.Project
+	}
+	files, total, err := s.PostgresStorage.ListFiles(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	if files == nil {
+		files = []schema.FileMetadata{}
+	}
+	return files, total, nil
 }
 
-func (s *Service) GetMetaDataByID(ctx *context.Context, id int64) (*schema.FileMetadata, error) {
+// fileInProject возвращает метаданные файла, если он принадлежит проекту клиента
+func (s *Service) fileInProject(ctx context.Context, p *auth.Principal, id int64) (*schema.FileMetadata, error) {
 	// Получаем метаданные из PostgreSQl
-	return s.PostgresStorage.GetMetaDataByID(ctx, id)
+	metadata, err := s.PostgresStorage.GetMetaDataByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkProject(p, metadata.Project, fmt.Sprintf("файл с id %d", id)); err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
+
+// DeleteFile удаляет файл вместе с его задачами и артефактами.
+// Объект удаляется из хранилища, если на него больше не ссылаются другие файлы; объекты артефактов удалит сверка.
+func (s *Service) DeleteFile(ctx context.Context, id int64) (err error) {
+	ctx, span := tracing.Start(ctx, "Service.DeleteFile", trace.WithAttributes(attribute.Int64("file.id", id)))
+	defer func() { tracing.End(span, err) }()
+
+	p, err := authorize(ctx, auth.PermDelete)
+	if err != nil {
+		return err
+	}
+	metadata, err := s.fileInProject(ctx, p, id)
+	if err != nil {
+		return err
+	}
+	objectKey, refs, err := s.PostgresStorage.DeleteFile(ctx, id)
+	if err != nil {
+		return err
+	}
+	if refs == 0 {
+		if err := s.deleteObject(context.WithoutCancel(ctx), metadata.Bucket, objectKey); err != nil {
+			slog.WarnContext(ctx, "Не удалось удалить объект удаленного файла", slog.String("object_key", objectKey), logging.Err(err))
+		}
+	}
+	slog.InfoContext(ctx, "Файл удален клиентом", slog.String("subject", p.Subject))
+	return nil
+}
+
+// RegisterArtifact записывает производный объект, созданный CV worker'ом в бакете исходного файла,
+// и связывает его с исходным файлом и задачей.
+// Размер и контрольная сумма считаются по фактическому содержимому объекта в хранилище.
+func (s *Service) RegisterArtifact(ctx context.Context, bucket string, fileID int64, jobID int64, artifactType string, objectKey string) (*schema.Artifact, error) {
+	object, err := s.openObject(ctx, bucket, objectKey)
+	if err != nil {
+		return nil, err
+	}
+	defer object.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, object)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при чтении объекта %s: %w", objectKey, err)
+	}
+
+	artifact := &schema.Artifact{
+		FileID:    fileID,
+		JobID:     &jobID,
+		Type:      artifactType,
+		Bucket:    bucket,
+		ObjectKey: objectKey,
+		Size:      size,
+		Checksum:  hex.EncodeToString(hash.Sum(nil)),
+	}
+	if _, err := s.PostgresStorage.SaveArtifact(ctx, artifact); err != nil {
+		return nil, err
+	}
+	return artifact, nil
+}
+
+func (s *Service) GetArtifacts(ctx context.Context, fileID int64) ([]schema.Artifact, error) {
+	p, err := authorize(ctx, auth.PermDownload)
+	if err != nil {
+		return nil, err
+	}
+	// Проверяем, что исходный файл существует и виден клиенту
+	if _, err := s.fileInProject(ctx, p, fileID); err != nil {
+		return nil, err
+	}
+	return s.PostgresStorage.GetArtifactsByFileID(ctx, fileID)
 }
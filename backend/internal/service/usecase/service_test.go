@@ -0,0 +1,118 @@
+package usecase
+
+import (
+	"context"
+	stderrors "errors"
+	"lct/internal/auth"
+	"lct/internal/repository/memory"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCreateOneDeletesDuplicateObject(t *testing.T) {
+	repo := memory.NewRepository()
+	storage := memory.NewObjectStorage("testbucket")
+	s := NewService(repo, storage, memory.NewRabbitClient(storage), testConfig)
+	ctx := asRole(auth.RoleAdmin, "")
+
+	for _, key := range []string{"first", "second"} {
+		object, _, err := s.CreateOne(ctx, strings.NewReader("scan"), "scan.ply", 4, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		object.Close()
+	}
+
+	if keys := storage.Keys(); len(keys) != 1 || keys[0] != "first" {
+		t.Errorf("stored objects = %v, want [first]", keys)
+	}
+	if n := repo.RefCount("first"); n != 2 {
+		t.Errorf("ref count = %d, want 2", n)
+	}
+}
+
+func TestCreateOneMetadataFailure(t *testing.T) {
+	repo := memory.NewRepository()
+	storage := memory.NewObjectStorage("testbucket")
+	s := NewService(repo, storage, memory.NewRabbitClient(storage), testConfig)
+	ctx := asRole(auth.RoleAdmin, "")
+
+	repo.FailOn("CreatePendingFile", stderrors.New("db is down"))
+	if _, _, err := s.CreateOne(ctx, strings.NewReader("scan"), "scan.ply", 4, "key"); err == nil {
+		t.Fatal("expected error")
+	}
+	if keys := storage.Keys(); len(keys) != 0 {
+		t.Errorf("stored objects = %v, want none", keys)
+	}
+}
+
+func TestCreateOneCompensatesFailedSteps(t *testing.T) {
+	for _, step := range []struct {
+		name string
+		fail func(repo *memory.Repository, storage *memory.ObjectStorage)
+	}{
+		{name: "upload", fail: func(_ *memory.Repository, storage *memory.ObjectStorage) {
+			storage.FailOn("CreateOne", stderrors.New("minio is down"))
+		}},
+		{name: "commit", fail: func(repo *memory.Repository, _ *memory.ObjectStorage) {
+			repo.FailOn("CommitFile", stderrors.New("db is down"))
+		}},
+	} {
+		t.Run(step.name, func(t *testing.T) {
+			repo := memory.NewRepository()
+			storage := memory.NewObjectStorage("testbucket")
+			s := NewService(repo, storage, memory.NewRabbitClient(storage), testConfig)
+
+			step.fail(repo, storage)
+			if _, _, err := s.CreateOne(asRole(auth.RoleAdmin, ""), strings.NewReader("scan"), "scan.ply", 4, "key"); err == nil {
+				t.Fatal("expected error")
+			}
+			if _, ok := repo.File(1); ok {
+				t.Error("pending file row was not removed")
+			}
+			if keys := storage.Keys(); len(keys) != 0 {
+				t.Errorf("stored objects = %v, want none", keys)
+			}
+		})
+	}
+}
+
+func TestCreateOneCanceledUpload(t *testing.T) {
+	repo := memory.NewRepository()
+	storage := memory.NewObjectStorage("testbucket")
+	s := NewService(repo, storage, memory.NewRabbitClient(storage), testConfig)
+
+	// Клиент оборвал запрос: объект не должен остаться в хранилище, а метаданные — в базе
+	ctx, cancel := context.WithCancel(asRole(auth.RoleAdmin, ""))
+	cancel()
+	if _, _, err := s.CreateOne(ctx, strings.NewReader("scan"), "scan.ply", 4, "key"); !stderrors.Is(err, context.Canceled) {
+		t.Fatalf("CreateOne() error = %v, want context.Canceled", err)
+	}
+	if keys := storage.Keys(); len(keys) != 0 {
+		t.Errorf("stored objects = %v, want none", keys)
+	}
+	if _, err := repo.GetMetaDataByID(context.Background(), 1); err == nil {
+		t.Error("metadata saved for canceled upload")
+	}
+}
+
+var testConfig = Config{ModelVersion: "test-model", ProcessingTimeout: time.Second}
+
+// asRole возвращает контекст запроса клиента с ролью в проекте
+func asRole(role string, project string) context.Context {
+	return auth.WithPrincipal(context.Background(), &auth.Principal{
+		Subject: role + "@" + project,
+		Role:    role,
+		Project: project,
+	})
+}
+
+// startRelay запускает relay outbox и запись результатов на время теста
+func startRelay(t *testing.T, s *Service) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go s.RunOutboxRelay(ctx, 10*time.Millisecond)
+	go s.RunResultConsumer(ctx)
+}
@@ -0,0 +1,129 @@
+package usecase
+
+import (
+	"context"
+	stderrors "errors"
+	"lct/internal/domain/errors"
+	"lct/internal/logging"
+	"lct/internal/repository/schema"
+	"log/slog"
+	"sync"
+)
+
+// drainer отслеживает выполняющиеся задачи, чтобы при остановке дождаться их или прервать
+type drainer struct {
+	mu      sync.Mutex
+	closing bool
+	wg      sync.WaitGroup
+	ctx     context.Context // Отменяется, когда ждать задачи больше нельзя
+	cancel  context.CancelFunc
+}
+
+func newDrainer() *drainer {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &drainer{ctx: ctx, cancel: cancel}
+}
+
+// beginJob регистрирует выполняющуюся задачу. Возвращает контекст, отменяемый при принудительной остановке,
+// и функцию, которую нужно вызвать по завершении задачи.
+func (s *Service) beginJob() (context.Context, func(), error) {
+	s.drain.mu.Lock()
+	defer s.drain.mu.Unlock()
+	if s.drain.closing {
+		return nil, nil, errors.ErrShuttingDown
+	}
+	s.drain.wg.Add(1)
+	return s.drain.ctx, s.drain.wg.Done, nil
+}
+
+// Shutdown перестает принимать новые задачи и ждет завершения текущих.
+// Если ctx истекает раньше, ожидание ответов CV worker'а прерывается, а задачи сохраняются со статусом interrupted,
+// чтобы ResumeInterruptedJobs подхватил их при следующем запуске.
+func (s *Service) Shutdown(ctx context.Context) error {
+	s.drain.mu.Lock()
+	s.drain.closing = true
+	s.drain.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.drain.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		slog.InfoContext(ctx, "Все задачи обработки завершены")
+		return nil
+	case <-ctx.Done():
+		slog.WarnContext(ctx, "Время ожидания задач истекло, прерываем оставшиеся")
+		s.drain.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// ResumeInterruptedJobs возобновляет задачи, прерванные остановкой сервиса. Сообщение публикуется повторно, только если
+// прерванная попытка не успела его опубликовать: иначе CV worker уже обрабатывает задачу, и ответ ждется
+// из долговечной очереди результатов, чтобы не обрабатывать файл дважды.
+// Задачи выполняются в фоне; их состояние можно отслеживать через GetJob.
+func (s *Service) ResumeInterruptedJobs(ctx context.Context) (int, error) {
+	jobs, err := s.PostgresStorage.ListJobsByStatus(ctx, schema.JobStatusInterrupted)
+	if err != nil {
+		return 0, err
+	}
+
+	resumed := 0
+	for i := range jobs {
+		job := jobs[i]
+		jobCtx := withJob(ctx, &job)
+		metadata, err := s.PostgresStorage.GetMetaDataByID(jobCtx, job.FileID)
+		if err != nil {
+			slog.ErrorContext(jobCtx, "Не удалось возобновить задачу", logging.Err(err))
+			continue
+		}
+		runCtx, done, err := s.beginJob()
+		if err != nil {
+			return resumed, err
+		}
+		// Ожидание регистрируется до смены состояния, чтобы не пропустить ответ
+		waiter := s.results.expect(job.CorrelationID)
+		republish, err := s.PostgresStorage.ResumeJob(jobCtx, job.ID, s.cfg.JobPickupTimeout)
+		if err != nil {
+			waiter.cancel()
+		}
+		if stderrors.Is(err, errors.ErrConflict) {
+			// Задачу уже возобновил другой экземпляр, запущенный одновременно с этим
+			done()
+			slog.InfoContext(jobCtx, "Задача уже возобновлена другим экземпляром")
+			continue
+		}
+		if err != nil {
+			done()
+			slog.ErrorContext(jobCtx, "Не удалось возобновить задачу", logging.Err(err))
+			continue
+		}
+		job.Status = schema.JobStatusPending
+		job.Error = ""
+		if republish {
+			s.wakeRelay()
+		} else {
+			slog.InfoContext(jobCtx, "Сообщение задачи уже опубликовано, ждем ответ CV worker'а")
+		}
+
+		go func() {
+			defer done()
+			runCtx := withJob(runCtx, &job)
+			if _, err := s.runJob(runCtx, &job, metadata, waiter); err != nil {
+				slog.WarnContext(runCtx, "Возобновленная задача завершилась с ошибкой", logging.Err(err))
+				return
+			}
+			slog.InfoContext(runCtx, "Возобновленная задача выполнена")
+		}()
+		resumed++
+	}
+
+	if resumed > 0 {
+		slog.InfoContext(ctx, "Возобновлены прерванные задачи", slog.Int("count", resumed))
+	}
+	return resumed, nil
+}
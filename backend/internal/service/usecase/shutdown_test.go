@@ -0,0 +1,144 @@
+package usecase
+
+import (
+	"context"
+	stderrors "errors"
+	"lct/internal/auth"
+	"lct/internal/domain/errors"
+	"lct/internal/repository/memory"
+	"lct/internal/repository/schema"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShutdownInterruptsAndResumesJobs(t *testing.T) {
+	repo := memory.NewRepository()
+	storage := memory.NewObjectStorage("testbucket")
+	rabbit := memory.NewRabbitClient(storage)
+	s := NewService(repo, storage, rabbit, testConfig)
+	// Фоновые компоненты первого экземпляра останавливаются вместе с ним
+	background, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	go s.RunOutboxRelay(background, 10*time.Millisecond)
+	go s.RunResultConsumer(background)
+	ctx := asRole(auth.RoleAdmin, "")
+
+	object, fileID, err := s.CreateOne(ctx, strings.NewReader("scan"), "scan.ply", 4, "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	object.Close()
+
+	// Воркер «зависает», пока его не отпустят
+	release := make(chan struct{})
+	copyWorker := rabbit.Worker
+	rabbit.Worker = func(body []byte) ([]byte, error) {
+		<-release
+		return copyWorker(body)
+	}
+
+	type outcome struct {
+		jobID int64
+		err   error
+	}
+	finished := make(chan outcome, 1)
+	go func() {
+		result, err := s.ProcessFile(ctx, fileID, schema.DefaultProcessingParams(), false)
+		var jobID int64
+		if result != nil && result.Job != nil {
+			jobID = result.Job.ID
+		}
+		finished <- outcome{jobID, err}
+	}()
+	for len(rabbit.Messages()) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := s.Shutdown(shutdownCtx); !stderrors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown() = %v, want deadline exceeded", err)
+	}
+
+	out := <-finished
+	if !stderrors.Is(out.err, errors.ErrJobInterrupted) || out.jobID == 0 {
+		t.Fatalf("ProcessFile() = job %d, %v, want interrupted job", out.jobID, out.err)
+	}
+	if job, _ := repo.Job(out.jobID); job.Status != schema.JobStatusInterrupted {
+		t.Errorf("job status = %s, want interrupted", job.Status)
+	}
+	if _, err := s.ProcessFile(ctx, fileID, schema.DefaultProcessingParams(), false); !stderrors.Is(err, errors.ErrShuttingDown) {
+		t.Errorf("ProcessFile() after shutdown = %v, want ErrShuttingDown", err)
+	}
+
+	stopBackground()
+
+	// После перезапуска опубликованная задача не отправляется повторно: ответ зависшего worker'а
+	// приходит в долговечную очередь результатов и завершает ее
+	restarted := NewService(repo, storage, rabbit, testConfig)
+	startRelay(t, restarted)
+	if n, err := restarted.ResumeInterruptedJobs(ctx); err != nil || n != 1 {
+		t.Fatalf("ResumeInterruptedJobs() = %d, %v", n, err)
+	}
+	close(release)
+	if err := restarted.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if job, _ := repo.Job(out.jobID); job.Status != schema.JobStatusDone || job.ArtifactID == nil {
+		t.Errorf("resumed job = %+v, want done with artifact", job)
+	}
+	if n := len(rabbit.Messages()); n != 1 {
+		t.Errorf("published %d messages, want 1: resumed job was sent to the worker again", n)
+	}
+}
+
+func TestInterruptedJobIsResumedOnceByConcurrentReplicas(t *testing.T) {
+	repo := memory.NewRepository()
+	storage := memory.NewObjectStorage("testbucket")
+	rabbit := memory.NewRabbitClient(storage)
+	ctx := asRole(auth.RoleAdmin, "")
+
+	first := NewService(repo, storage, rabbit, testConfig)
+	object, fileID, err := first.CreateOne(ctx, strings.NewReader("scan"), "scan.ply", 4, "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	object.Close()
+	job := &schema.Job{FileID: fileID, CorrelationID: "c1", Project: auth.DefaultProject}
+	if _, err := repo.EnqueueJob(context.Background(), job, &schema.OutboxMessage{CorrelationID: "c1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.FinishJob(context.Background(), job.ID, schema.JobStatusInterrupted, errors.ErrJobInterrupted.Error()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Реплики запускаются одновременно и видят одну и ту же прерванную задачу
+	replicas := []*Service{first, NewService(repo, storage, rabbit, testConfig), NewService(repo, storage, rabbit, testConfig)}
+	counts := make(chan int, len(replicas))
+	for _, s := range replicas {
+		go func(s *Service) {
+			n, err := s.ResumeInterruptedJobs(context.Background())
+			if err != nil {
+				t.Error(err)
+			}
+			counts <- n
+		}(s)
+	}
+	resumed := 0
+	for range replicas {
+		resumed += <-counts
+	}
+	if resumed != 1 {
+		t.Errorf("resumed %d times, want 1", resumed)
+	}
+	// Неопубликованное сообщение прерванной попытки ждет публикации, новое не ставится
+	if outbox := repo.Outbox(); len(outbox) != 1 || outbox[0].ID != 1 || outbox[0].SentAt != nil {
+		t.Errorf("outbox = %+v, want the interrupted attempt's message still unsent", outbox)
+	}
+	for _, s := range replicas {
+		if err := s.Shutdown(context.Background()); err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package usecase
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"lct/internal/auth"
+	"lct/internal/domain/errors"
+	"lct/internal/repository"
+	"lct/internal/repository/schema"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+var (
+	tenantIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+	// Имя бакета по правилам S3
+	bucketPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
+	prefixPattern = regexp.MustCompile(`^[a-zA-Z0-9._-]+(/[a-zA-Z0-9._-]+)*/$`)
+)
+
+// maxRoutingKeyLength ограничение AMQP на длину ключа маршрутизации
+const maxRoutingKeyLength = 255
+
+// CreateTenant регистрирует арендатора. Если у арендатора свой бакет, он создается в хранилище.
+func (s *Service) CreateTenant(ctx context.Context, tenant *schema.Tenant) error {
+	if _, err := authorize(ctx, auth.PermAdmin); err != nil {
+		return err
+	}
+	if err := normalizeTenant(tenant); err != nil {
+		return err
+	}
+	if err := s.initTenantBucket(ctx, tenant); err != nil {
+		return err
+	}
+	if err := s.PostgresStorage.CreateTenant(ctx, tenant); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "Арендатор зарегистрирован", slog.String("tenant", tenant.ID), slog.String("bucket", s.bucketOf(tenant)),
+		slog.String("prefix", tenant.Prefix), slog.String("routing_key", tenant.RoutingKey))
+	return nil
+}
+
+// UpdateTenant меняет настройки арендатора. Новые бакет и префикс действуют для следующих загрузок,
+// уже загруженные файлы и результаты остаются на прежнем месте.
+func (s *Service) UpdateTenant(ctx context.Context, tenant *schema.Tenant) error {
+	if _, err := authorize(ctx, auth.PermAdmin); err != nil {
+		return err
+	}
+	if err := normalizeTenant(tenant); err != nil {
+		return err
+	}
+	if err := s.initTenantBucket(ctx, tenant); err != nil {
+		return err
+	}
+	return s.PostgresStorage.UpdateTenant(ctx, tenant)
+}
+
+func (s *Service) GetTenant(ctx context.Context, id string) (*schema.Tenant, error) {
+	if _, err := authorize(ctx, auth.PermAdmin); err != nil {
+		return nil, err
+	}
+	return s.PostgresStorage.GetTenant(ctx, id)
+}
+
+func (s *Service) ListTenants(ctx context.Context) ([]schema.Tenant, error) {
+	if _, err := authorize(ctx, auth.PermAdmin); err != nil {
+		return nil, err
+	}
+	return s.PostgresStorage.ListTenants(ctx)
+}
+
+// TenantUsage возвращает потребление арендатора относительно его квот.
+// Пустой id означает арендатора клиента; чужие арендаторы видны только администраторам.
+func (s *Service) TenantUsage(ctx context.Context, id string) (*schema.TenantUsage, error) {
+	p, err := authorize(ctx, auth.PermDownload)
+	if err != nil {
+		return nil, err
+	}
+	if id == "" {
+		id = p.HomeProject()
+	}
+	if err := checkProject(p, id, "арендатор "+id); err != nil {
+		return nil, err
+	}
+	return s.PostgresStorage.GetTenantUsage(ctx, id)
+}
+
+// tenantOf возвращает арендатора проекта. Проект, не зарегистрированный как арендатор, не может
+// загружать и обрабатывать файлы: у него нет ни места в хранилище, ни квот.
+func (s *Service) tenantOf(ctx context.Context, project string) (*schema.Tenant, error) {
+	tenant, err := s.PostgresStorage.GetTenant(ctx, project)
+	if err != nil {
+		if stderrors.Is(err, errors.ErrNotFound) {
+			return nil, fmt.Errorf("%w: проект %s не зарегистрирован как арендатор", errors.ErrForbidden, project)
+		}
+		return nil, err
+	}
+	return tenant, nil
+}
+
+// bucketOf бакет, в который попадают новые объекты арендатора
+func (s *Service) bucketOf(tenant *schema.Tenant) string {
+	if tenant.Bucket != "" {
+		return tenant.Bucket
+	}
+	return s.ObjectStorage.Bucket()
+}
+
+// storageIn возвращает хранилище бакета; пустой бакет означает общий бакет сервиса
+func (s *Service) storageIn(bucket string) repository.ObjectStorage {
+	if bucket == "" || bucket == s.ObjectStorage.Bucket() {
+		return s.ObjectStorage
+	}
+	return s.ObjectStorage.WithBucket(bucket)
+}
+
+// initTenantBucket создает отдельный бакет арендатора, если он задан
+func (s *Service) initTenantBucket(ctx context.Context, tenant *schema.Tenant) error {
+	if tenant.Bucket == "" {
+		return nil
+	}
+	ctx, cancel := withTimeout(ctx, s.cfg.StorageTimeout)
+	defer cancel()
+	if err := s.storageIn(tenant.Bucket).Init(ctx); err != nil {
+		return fmt.Errorf("не удалось подготовить бакет %s арендатора %s: %w", tenant.Bucket, tenant.ID, err)
+	}
+	return nil
+}
+
+// normalizeTenant проверяет настройки арендатора и дополняет префикс завершающим «/»
+func normalizeTenant(t *schema.Tenant) error {
+	t.ID = strings.TrimSpace(t.ID)
+	t.Name = strings.TrimSpace(t.Name)
+	if t.Name == "" {
+		t.Name = t.ID
+	}
+	if t.Prefix != "" && !strings.HasSuffix(t.Prefix, "/") {
+		t.Prefix += "/"
+	}
+
+	switch {
+	case !tenantIDPattern.MatchString(t.ID):
+		return fmt.Errorf("%w: id арендатора должен состоять из строчных латинских букв, цифр, «-» и «_»", errors.ErrInvalidArgument)
+	case t.Bucket != "" && !bucketPattern.MatchString(t.Bucket):
+		return fmt.Errorf("%w: недопустимое имя бакета %q", errors.ErrInvalidArgument, t.Bucket)
+	case t.Prefix != "" && (!prefixPattern.MatchString(t.Prefix) || strings.Contains(t.Prefix, "..")):
+		return fmt.Errorf("%w: недопустимый префикс %q", errors.ErrInvalidArgument, t.Prefix)
+	case len(t.RoutingKey) > maxRoutingKeyLength:
+		return fmt.Errorf("%w: ключ маршрутизации длиннее %d байт", errors.ErrInvalidArgument, maxRoutingKeyLength)
+	case t.StorageQuotaBytes < 0 || t.MonthlyJobQuota < 0:
+		return fmt.Errorf("%w: квоты не могут быть отрицательными", errors.ErrInvalidArgument)
+	}
+	return nil
+}
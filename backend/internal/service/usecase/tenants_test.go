@@ -0,0 +1,174 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"lct/internal/auth"
+	"lct/internal/domain/errors"
+	"lct/internal/repository/schema"
+	"strings"
+	"testing"
+)
+
+func TestTenantBucketPrefixAndRoutingKey(t *testing.T) {
+	env := newAuthzEnv(t)
+	admin := asRole(auth.RoleAdmin, "")
+	tenant := &schema.Tenant{ID: "gamma", Bucket: "gamma-bucket", Prefix: "scans/gamma", RoutingKey: "tenant.gamma"}
+	if err := env.s.CreateTenant(admin, tenant); err != nil {
+		t.Fatal(err)
+	}
+	if tenant.Prefix != "scans/gamma/" || tenant.Name != "gamma" {
+		t.Errorf("normalized tenant = %+v", tenant)
+	}
+
+	gamma := asRole(auth.RoleOperator, "gamma")
+	fileID := env.upload(t, gamma, "scan")
+	_, artifact := env.process(t, gamma, fileID)
+
+	metadata, _ := env.repo.File(fileID)
+	bucket := env.storage.InBucket("gamma-bucket")
+	for _, key := range []string{metadata.ObjectKey, artifact.ObjectKey} {
+		if !strings.HasPrefix(key, "scans/gamma/") {
+			t.Errorf("object key %s has no tenant prefix", key)
+		}
+		if _, ok := bucket.Data(key); !ok {
+			t.Errorf("object %s is not in tenant bucket", key)
+		}
+	}
+	if len(env.storage.Keys()) != 0 {
+		t.Errorf("shared bucket objects = %v, want none", env.storage.Keys())
+	}
+	if metadata.Bucket != "gamma-bucket" || artifact.Bucket != "gamma-bucket" {
+		t.Errorf("recorded buckets = %s/%s", metadata.Bucket, artifact.Bucket)
+	}
+
+	outbox := env.repo.Outbox()
+	if len(outbox) != 1 || outbox[0].RoutingKey != "tenant.gamma" {
+		t.Fatalf("outbox = %+v, want one message with tenant routing key", outbox)
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(outbox[0].Payload, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload["bucket"] != "gamma-bucket" || payload["output_prefix"] != "scans/gamma/" {
+		t.Errorf("payload = %v", payload)
+	}
+
+	object, err := env.s.GetOne(gamma, artifact.ObjectKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	object.Close()
+
+	// Сверка проходит и по бакету арендатора: объекты без ссылок удаляются, остальные остаются
+	bucket.Put("scans/gamma/orphan", []byte("orphan"))
+	report, err := env.s.Reconcile(admin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.RemovedObjects) != 1 || report.RemovedObjects[0] != "scans/gamma/orphan" || len(report.MissingObjects) != 0 {
+		t.Errorf("report = %+v", report)
+	}
+	if keys := bucket.Keys(); len(keys) != 2 {
+		t.Errorf("tenant bucket keys after reconcile = %v", keys)
+	}
+}
+
+func TestStorageQuota(t *testing.T) {
+	env := newAuthzEnv(t)
+	admin := asRole(auth.RoleAdmin, "")
+	if err := env.s.CreateTenant(admin, &schema.Tenant{ID: "small", StorageQuotaBytes: 10}); err != nil {
+		t.Fatal(err)
+	}
+	small := asRole(auth.RoleOperator, "small")
+	env.upload(t, small, "123456")
+
+	_, _, err := env.s.CreateOne(small, strings.NewReader("abcdef"), "scan.ply", 6, "over")
+	if !stderrors.Is(err, errors.ErrStorageQuotaExceeded) {
+		t.Fatalf("CreateOne() over quota: error = %v, want ErrStorageQuotaExceeded", err)
+	}
+	if keys := env.storage.Keys(); len(keys) != 1 {
+		t.Errorf("stored objects = %v, want only the first upload", keys)
+	}
+
+	usage, err := env.s.TenantUsage(small, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.TenantID != "small" || usage.Files != 1 || usage.StorageBytes != 6 || usage.StorageQuotaBytes != 10 {
+		t.Errorf("usage = %+v", usage)
+	}
+}
+
+func TestJobQuota(t *testing.T) {
+	env := newAuthzEnv(t)
+	admin := asRole(auth.RoleAdmin, "")
+	if err := env.s.CreateTenant(admin, &schema.Tenant{ID: "limited", MonthlyJobQuota: 1}); err != nil {
+		t.Fatal(err)
+	}
+	limited := asRole(auth.RoleOperator, "limited")
+	fileID := env.upload(t, limited, "scan")
+	env.process(t, limited, fileID)
+
+	// Результат из кэша не расходует квоту, новая обработка — расходует
+	if job, _ := env.process(t, limited, fileID); !job.CacheHit {
+		t.Fatalf("job = %+v, want cache hit", job)
+	}
+	_, err := env.s.ProcessFile(limited, fileID, schema.DefaultProcessingParams(), false)
+	if !stderrors.Is(err, errors.ErrJobQuotaExceeded) {
+		t.Fatalf("ProcessFile() over quota: error = %v, want ErrJobQuotaExceeded", err)
+	}
+
+	usage, err := env.s.TenantUsage(admin, "limited")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.JobsThisPeriod != 1 || usage.MonthlyJobQuota != 1 || !usage.PeriodEnd.After(usage.PeriodStart) {
+		t.Errorf("usage = %+v", usage)
+	}
+}
+
+func TestTenantAccess(t *testing.T) {
+	env := newAuthzEnv(t)
+
+	if _, err := env.s.TenantUsage(asRole(auth.RoleViewer, "alpha"), "beta"); !stderrors.Is(err, errors.ErrNotFound) {
+		t.Errorf("TenantUsage() of another project: error = %v, want ErrNotFound", err)
+	}
+	if err := env.s.CreateTenant(asRole(auth.RoleOperator, "alpha"), &schema.Tenant{ID: "delta"}); !stderrors.Is(err, errors.ErrForbidden) {
+		t.Errorf("CreateTenant() as operator: error = %v, want ErrForbidden", err)
+	}
+
+	// Проект, не зарегистрированный как арендатор, не может загружать файлы
+	_, _, err := env.s.CreateOne(asRole(auth.RoleOperator, "unknown"), strings.NewReader("scan"), "scan.ply", 4, "key")
+	if !stderrors.Is(err, errors.ErrForbidden) {
+		t.Errorf("CreateOne() in unregistered project: error = %v, want ErrForbidden", err)
+	}
+	if _, err := env.s.CreateAPIKey(asRole(auth.RoleAdmin, ""), "key", auth.RoleViewer, "unknown"); !stderrors.Is(err, errors.ErrInvalidArgument) {
+		t.Errorf("CreateAPIKey() for unregistered project: error = %v, want ErrInvalidArgument", err)
+	}
+}
+
+func TestCreateTenantValidates(t *testing.T) {
+	env := newAuthzEnv(t)
+	admin := asRole(auth.RoleAdmin, "")
+	for name, tenant := range map[string]schema.Tenant{
+		"empty id":       {},
+		"uppercase id":   {ID: "Alpha"},
+		"bucket":         {ID: "t1", Bucket: "Bad_Bucket"},
+		"absolute path":  {ID: "t2", Prefix: "/abs/"},
+		"parent prefix":  {ID: "t3", Prefix: "a/../b/"},
+		"negative quota": {ID: "t4", StorageQuotaBytes: -1},
+		"routing key":    {ID: "t5", RoutingKey: strings.Repeat("k", 256)},
+	} {
+		if err := env.s.CreateTenant(admin, &tenant); !stderrors.Is(err, errors.ErrInvalidArgument) {
+			t.Errorf("%s: error = %v, want ErrInvalidArgument", name, err)
+		}
+	}
+	if err := env.s.CreateTenant(admin, &schema.Tenant{ID: "alpha"}); !stderrors.Is(err, errors.ErrConflict) {
+		t.Errorf("duplicate tenant: error = %v, want ErrConflict", err)
+	}
+	if _, err := env.repo.GetTenant(context.Background(), "t1"); !stderrors.Is(err, errors.ErrNotFound) {
+		t.Errorf("invalid tenant was saved: %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package usecase
+
+import (
+	"context"
+	"lct/internal/auth"
+	"lct/internal/repository/instrumented"
+	"lct/internal/repository/memory"
+	"lct/internal/repository/schema"
+	"lct/internal/tracing"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordSpans записывает span'ы глобального трассировщика до конца теста
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	if _, err := tracing.Setup(context.Background(), tracing.Config{Exporter: tracing.ExporterNone}); err != nil {
+		t.Fatal(err)
+	}
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+func TestProcessFileIsTracedEndToEnd(t *testing.T) {
+	recorder := recordSpans(t)
+	repo, storage := memory.NewRepository(), memory.NewObjectStorage("testbucket")
+	rabbit := memory.NewRabbitClient(storage)
+	s := NewService(instrumented.NewRepository(repo), instrumented.NewObjectStorage(storage, "memory"), rabbit, testConfig)
+	if err := repo.CreateTenant(context.Background(), &schema.Tenant{ID: "alpha", Name: "alpha"}); err != nil {
+		t.Fatal(err)
+	}
+	startRelay(t, s)
+
+	ctx, root := tracing.Start(asRole(auth.RoleOperator, "alpha"), "client")
+	object, fileID, err := s.CreateOne(ctx, strings.NewReader("scan"), "scan.ply", 4, "scan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	object.Close()
+	if _, err := s.ProcessFile(ctx, fileID, schema.DefaultProcessingParams(), true); err != nil {
+		t.Fatal(err)
+	}
+	root.End()
+	traceID := root.SpanContext().TraceID()
+
+	// Сообщение публикует relay в своей горутине, но в трассе запроса
+	traces := rabbit.TraceContexts()
+	if len(traces) != 1 || !strings.Contains(traces[0]["traceparent"], traceID.String()) {
+		t.Fatalf("published trace contexts = %v, want trace %s", traces, traceID)
+	}
+
+	inTrace := make(map[string]bool)
+	for _, span := range recorder.Ended() {
+		if span.SpanContext().TraceID() == traceID {
+			inTrace[span.Name()] = true
+		}
+	}
+	for _, name := range []string{
+		"Service.CreateOne",
+		"storage.CreateOne",
+		"db.CommitFile",
+		"Service.ProcessFile",
+		"db.EnqueueJob",
+		"CV worker",
+		"db.SaveArtifact",
+		"db.CompleteJob",
+	} {
+		if !inTrace[name] {
+			t.Errorf("span %s is missing from the request trace; got %v", name, inTrace)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// Package tracing трассировка запросов OpenTelemetry: настройка экспорта и распространение контекста W3C Trace Context
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Экспортеры span'ов
+const (
+	ExporterNone   = "none"   // Span'ы не записываются, контекст трассировки только передается дальше
+	ExporterOTLP   = "otlp"   // OTLP/HTTP в коллектор
+	ExporterStdout = "stdout" // JSON в stdout, для локальной отладки
+)
+
+// instrumentationName имя трассировщика сервиса
+const instrumentationName = "lct"
+
+// Config параметры трассировки
+type Config struct {
+	Exporter    string  // none, otlp или stdout
+	Endpoint    string  // URL коллектора OTLP/HTTP, например http://otel-collector:4318
+	ServiceName string  // Имя сервиса в span'ах
+	SampleRatio float64 // Доля записываемых трасс, начатых этим сервисом; решение вызывающего соблюдается
+}
+
+// Setup настраивает глобальные трассировщик и пропагатор. Пропагатор W3C Trace Context настраивается всегда,
+// чтобы контекст вызывающего доходил до CV worker'а, даже если сам сервис span'ы не записывает.
+// Возвращенная функция выгружает накопленные span'ы и должна быть вызвана при остановке.
+func Setup(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch cfg.Exporter {
+	case ExporterNone, "":
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		exporter, err = otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+	case ExporterStdout:
+		exporter, err = stdouttrace.New()
+	default:
+		return nil, fmt.Errorf("неизвестный экспортер трассировки %q", cfg.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания экспортера трассировки: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(cfg.ServiceName))),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Start открывает span в трассе ctx
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, opts...)
+}
+
+// End закрывает span; ошибка записывается в span и отмечает его неуспешным
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Inject возвращает заголовки traceparent/tracestate текущего span'а ctx; nil, если трассы нет
+func Inject(ctx context.Context) map[string]string {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return nil
+	}
+	return carrier
+}
+
+// Extract восстанавливает контекст трассировки из заголовков, сохраненных Inject
+func Extract(ctx context.Context, headers map[string]string) context.Context {
+	if len(headers) == 0 {
+		return ctx
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(headers))
+}
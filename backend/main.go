@@ -1,69 +1,255 @@
+// Command app сервер обработки облаков точек. Подкоманды:
+//
+//	app [serve] [флаги]                  HTTP API и фоновые компоненты (по умолчанию)
+//	app worker [флаги]                   только фоновые компоненты: relay outbox, запись результатов, reaper, сверка, метрики
+//	app migrate up [N] [флаги]           применить N следующих миграций, по умолчанию все
+//	app migrate down [N|all] [флаги]     откатить N последних миграций, по умолчанию одну
+//	app migrate status [флаги]           версия схемы и непримененные миграции
+//	app migrate force VERSION [флаги]    записать версию схемы после ручного исправления
+//
+// Флаги и переменные окружения у всех подкоманд общие, см. пакет config.
 package main
 
 import (
+	"context"
+	"fmt"
 	"lct/config"
-	"lct/internal/handlers"
+	"lct/internal/bootstrap"
+	"lct/internal/dbmigrate"
+	"lct/internal/logging"
+	"lct/internal/repository"
+	"lct/internal/repository/instrumented"
+	"lct/internal/repository/localfs"
 	"lct/internal/repository/minio"
 	"lct/internal/repository/postgres"
+	"lct/internal/repository/rabbitmq"
 	"lct/internal/service/usecase"
-	"log"
-
-	"github.com/gin-gonic/gin"
-	"github.com/golang-migrate/migrate/v4"
-	_ "github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"lct/internal/tracing"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
 )
 
-func main() {
-	//Загрузка конфигурации из файла .env
-	config.LoadConfig()
-	log.Printf("Config: %+v\n", config.AppConfig)
-	DatabaseURL := config.AppConfig.DatabaseURL
+// commands подкоманды по имени; args — позиционные аргументы после имени
+var commands = map[string]func(cfg *config.Config, args []string) error{
+	"serve":   serve,
+	"worker":  worker,
+	"migrate": runMigrate,
+}
 
-	//Миграции
-	m, err := migrate.New("file://migrations", DatabaseURL)
+func main() {
+	name, args, flags := splitCommand(os.Args[1:])
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "неизвестная команда %q, допустимо serve, worker или migrate\n", name)
+		os.Exit(2)
+	}
 
+	//Загрузка конфигурации: значения по умолчанию, файл, окружение, флаги
+	cfg, err := config.LoadConfig(flags)
 	if err != nil {
-		log.Fatalf("unable to create migrations instance: %v", err)
+		fatal("Ошибка загрузки конфигурации", err)
+	}
+	if err := logging.Setup(os.Stderr, logging.Config{Level: cfg.LogLevel, Format: cfg.LogFormat}); err != nil {
+		fatal("Ошибка настройки логирования", err)
 	}
+	slog.Info("Конфигурация загружена", slog.String("command", name), slog.String("config", cfg.String()))
 
-	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
-		log.Fatalf("unable to run migrations: %v", err)
+	if err := cmd(cfg, args); err != nil {
+		fatal("Ошибка выполнения команды "+name, err)
 	}
-	log.Println("migrations successfully created")
+}
+
+// splitCommand отделяет имя подкоманды и ее позиционные аргументы от флагов конфигурации.
+// Без имени выполняется serve, чтобы запуск без аргументов работал как раньше.
+func splitCommand(argv []string) (name string, args []string, flags []string) {
+	if len(argv) == 0 || strings.HasPrefix(argv[0], "-") {
+		return "serve", nil, argv
+	}
+	name, argv = argv[0], argv[1:]
+	for len(argv) > 0 && !strings.HasPrefix(argv[0], "-") {
+		args, argv = append(args, argv[0]), argv[1:]
+	}
+	return name, args, argv
+}
 
-	// Инициализация соединения с Minio
-	minioClient := minio.NewMinioClient()
-	err = minioClient.InitMinio()
+// fatal пишет ошибку запуска и завершает процесс
+func fatal(msg string, err error) {
+	slog.Error(msg, logging.Err(err))
+	os.Exit(1)
+}
+
+// runtime подключенные зависимости и сервисный слой, общие для serve и worker
+type runtime struct {
+	cfg      *config.Config
+	service  *usecase.Service
+	postgres *postgres.PostgresStorage
+	// Фоновые компоненты (relay outbox, очередь результатов, reaper, сверка) работают, пока не отменен background
+	background      context.Context
+	stopBackground  context.CancelFunc
+	shutdownTracing func(context.Context) error
+}
+
+// start подключается к зависимостям, проверяет схему базы и собирает сервисный слой; при ошибке завершает процесс
+func start(cfg *config.Config) *runtime {
+	ctx := context.Background()
+
+	// Трассировка настраивается первой, чтобы span'ы получили все компоненты
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Config{
+		Exporter:    cfg.TracingExporter,
+		Endpoint:    cfg.TracingEndpoint,
+		ServiceName: cfg.TracingServiceName,
+		SampleRatio: cfg.TracingSampleRatio,
+	})
 	if err != nil {
-		log.Fatalf("Ошибка инициализации Minio: %v", err)
+		fatal("Ошибка инициализации трассировки", err)
 	}
-	//Инициализация слоя хранилища
-	postgresRepo, err := postgres.NewPostgresStorage(DatabaseURL)
+
+	// Объектное хранилище (Minio или локальная файловая система)
+	objectStorage, err := newObjectStorage(cfg)
+	if err != nil {
+		fatal("Ошибка инициализации хранилища", err)
+	}
+	objectStorage = instrumented.NewObjectStorage(objectStorage, cfg.StorageBackend)
+
+	backgroundCtx, stopBackground := context.WithCancel(ctx)
+
+	backoff := bootstrap.Backoff{Max: cfg.StartupMaxBackoff}
+	rabbitClient := rabbitmq.NewRabbitClient(rabbitmq.Config{
+		URL:          cfg.RabbitMQURL,
+		Exchange:     cfg.RabbitMQExchange,
+		ExchangeType: cfg.RabbitMQExchangeType,
+		Queue:        cfg.RabbitMQQueue,
+		ResultsQueue: cfg.RabbitMQResultsQueue,
+		Backoff:      backoff,
+	})
+
+	// Зависимости поднимаются вместе с сервисом (docker-compose, Kubernetes), поэтому их ждем с повторными попытками.
+	// Без RabbitMQ сервис может работать в деградированном режиме: чтение доступно, задачи ждут брокера в outbox.
+	var postgresRepo *postgres.PostgresStorage
+	degraded, err := bootstrap.Start(backgroundCtx, bootstrap.Policy{Backoff: backoff, MaxWait: cfg.StartupMaxWait},
+		bootstrap.Dependency{Name: "postgres", Connect: func(ctx context.Context) (err error) {
+			postgresRepo, err = postgres.NewPostgresStorage(ctx, cfg.DatabaseURL, cfg.BucketName, cfg.DBTimeout)
+			return err
+		}},
+		bootstrap.Dependency{Name: "storage", Connect: func(ctx context.Context) error {
+			ctx, cancel := context.WithTimeout(ctx, cfg.StorageTimeout)
+			defer cancel()
+			return objectStorage.Init(ctx)
+		}},
+		bootstrap.Dependency{Name: "rabbitmq", Connect: rabbitClient.Connect, Optional: cfg.DegradedStart},
+	)
 	if err != nil {
-		log.Fatalf("error init postgres storage: %v", err)
+		fatal("Не удалось подключиться к зависимостям", err)
+	}
+	if len(degraded) > 0 {
+		slog.Warn("Сервис запущен в деградированном режиме", slog.Any("unavailable", degraded))
 	}
 
-	//Инициализация сервисного слоя
-	service := usecase.NewService(postgresRepo, minioClient)
+	if err := prepareSchema(cfg); err != nil {
+		fatal("Схема базы не готова", err)
+	}
 
-	// Инициализация маршрутизатора Gin
-	router := gin.Default()
-	h := handlers.NewMinioHandler(service) // условно
-	h.RegisterRoutes(router)
+	// Метрики и span'ы снимаются с каждой операции базы и хранилища
+	service := usecase.NewService(instrumented.NewRepository(postgresRepo), objectStorage, rabbitClient, usecase.Config{
+		ModelVersion:         cfg.ModelVersion,
+		ProcessingTimeout:    cfg.ProcessingTimeout,
+		JobLeaseTimeout:      cfg.JobLeaseTimeout,
+		JobPickupTimeout:     cfg.JobPickupTimeout,
+		JobMaxAttempts:       cfg.JobMaxAttempts,
+		StorageTimeout:       cfg.StorageTimeout,
+		UploadTimeout:        cfg.UploadTimeout,
+		DownloadTimeout:      cfg.DownloadTimeout,
+		ReconcileGracePeriod: cfg.ReconcileGracePeriod,
+		PublishTimeout:       cfg.PublishTimeout,
+		OutboxMaxBackoff:     cfg.OutboxMaxBackoff,
+		BootstrapAPIKey:      cfg.BootstrapAPIKey,
+		MaxPendingJobs:       cfg.MaxPendingJobs,
+		ReadinessTimeout:     cfg.ReadinessTimeout,
+		DegradedStart:        cfg.DegradedStart,
+	})
+	return &runtime{
+		cfg:             cfg,
+		service:         service,
+		postgres:        postgresRepo,
+		background:      backgroundCtx,
+		stopBackground:  stopBackground,
+		shutdownTracing: shutdownTracing,
+	}
+}
 
-	// CV worker запускается отдельно в docker-compose
-	// go func() {
-	//	err := h.StartRabbitWorker()
-	//	if err != nil {
-	//		log.Fatalf("Failed to start RabbitMQ worker: %v", err)
-	//	}
-	// }()
+// runBackground запускает relay outbox, запись результатов, reaper задач, сверку с хранилищем и сбор метрик
+func (rt *runtime) runBackground() {
+	// Relay публикует задачи из outbox, ответы и heartbeat'ы CV worker'а записываются в задачи,
+	// reaper возвращает в очередь задачи с истекшей арендой, сверка приводит в соответствие записи файлов и хранилище
+	go rt.service.RunOutboxRelay(rt.background, rt.cfg.OutboxPollInterval)
+	go rt.service.RunResultConsumer(rt.background)
+	if rt.cfg.ReaperInterval > 0 {
+		go rt.service.RunJobReaper(rt.background, rt.cfg.ReaperInterval)
+	}
+	if rt.cfg.ReconcileInterval > 0 {
+		go rt.service.RunReconciler(rt.background, rt.cfg.ReconcileInterval)
+	}
+	go rt.service.RunMetricsSampler(rt.background, rt.cfg.MetricsInterval)
+}
 
-	// Запуск сервера Gin
-	port := config.AppConfig.Port // Мы берем
-	err = router.Run(":" + port)
+// close останавливает фоновые компоненты, закрывает соединение с базой и выгружает трассы
+func (rt *runtime) close() {
+	rt.stopBackground()
+	if err := rt.postgres.Close(); err != nil {
+		slog.Error("Ошибка закрытия соединения с PostgreSQL", logging.Err(err))
+	}
+	// Выгружаем span'ы, накопленные к остановке
+	tracingCtx, cancelTracing := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelTracing()
+	if err := rt.shutdownTracing(tracingCtx); err != nil {
+		slog.Error("Ошибка выгрузки трасс", logging.Err(err))
+	}
+}
+
+// prepareSchema применяет миграции при MigrateOnStart и проверяет, что схема не отстает от сервиса.
+// Реплики, запущенные одновременно, применяют миграции по очереди под advisory lock'ом.
+func prepareSchema(cfg *config.Config) error {
+	m, err := dbmigrate.New(cfg.DatabaseURL, cfg.MigrationLockTimeout)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	if cfg.MigrateOnStart {
+		if err := m.Up(0); err != nil {
+			return fmt.Errorf("не удалось применить миграции: %w", err)
+		}
+	}
+	status, err := m.Status()
 	if err != nil {
-		log.Fatalf("Ошибка запуска сервера Gin: %v", err)
+		return err
+	}
+	if err := status.Check(); err != nil {
+		return err
+	}
+	if status.Version > status.Latest {
+		slog.Warn("Схема базы новее сервиса", slog.Uint64("version", uint64(status.Version)), slog.Uint64("latest", uint64(status.Latest)))
+	}
+	slog.Info("Схема базы актуальна", slog.Uint64("version", uint64(status.Version)))
+	return nil
+}
+
+// newObjectStorage выбирает реализацию объектного хранилища по конфигурации
+func newObjectStorage(cfg *config.Config) (repository.ObjectStorage, error) {
+	switch cfg.StorageBackend {
+	case "minio", "s3":
+		return minio.NewMinioClient(minio.Config{
+			Endpoint:  cfg.MinioEndpoint,
+			AccessKey: cfg.MinioRootUser,
+			SecretKey: cfg.MinioRootPassword,
+			Bucket:    cfg.BucketName,
+			UseSSL:    cfg.MinioUseSSL,
+		}), nil
+	case "local":
+		return localfs.NewLocalStorage(cfg.LocalStoragePath, cfg.BucketName), nil
+	default:
+		return nil, fmt.Errorf("неизвестное хранилище %q, допустимо minio или local", cfg.StorageBackend)
 	}
 }
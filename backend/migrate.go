@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"lct/config"
+	"lct/internal/bootstrap"
+	"lct/internal/dbmigrate"
+	"os"
+	"strconv"
+)
+
+// runMigrate управляет схемой базы: migrate up [N] | down [N|all] | status | force VERSION
+func runMigrate(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New("укажите действие: migrate up [N] | down [N|all] | status | force VERSION")
+	}
+	action, args := args[0], args[1:]
+	if len(args) > 1 {
+		return fmt.Errorf("migrate %s: лишние аргументы %q", action, args[1:])
+	}
+	arg := ""
+	if len(args) == 1 {
+		arg = args[0]
+	}
+
+	// База может подниматься одновременно с командой (docker compose run), поэтому ждем ее как при запуске сервиса
+	var m *dbmigrate.Migrator
+	policy := bootstrap.Policy{Backoff: bootstrap.Backoff{Max: cfg.StartupMaxBackoff}, MaxWait: cfg.StartupMaxWait}
+	err := bootstrap.Retry(context.Background(), "postgres", policy, func(context.Context) (err error) {
+		m, err = dbmigrate.New(cfg.DatabaseURL, cfg.MigrationLockTimeout)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	switch action {
+	case "up":
+		n, err := migrateSteps(arg, 0)
+		if err != nil {
+			return err
+		}
+		if err := m.Up(n); err != nil {
+			return err
+		}
+	case "down":
+		n := 1
+		if arg == "all" {
+			n = 0
+		} else if n, err = migrateSteps(arg, 1); err != nil {
+			return err
+		}
+		if err := m.Down(n); err != nil {
+			return err
+		}
+	case "force":
+		if arg == "" {
+			return errors.New("migrate force: укажите версию")
+		}
+		version, err := strconv.Atoi(arg)
+		if err != nil || version < -1 {
+			return fmt.Errorf("migrate force: некорректная версия %q", arg)
+		}
+		if err := m.Force(version); err != nil {
+			return err
+		}
+	case "status":
+		if arg != "" {
+			return errors.New("migrate status не принимает аргументов")
+		}
+	default:
+		return fmt.Errorf("неизвестное действие migrate %q, допустимо up, down, status или force", action)
+	}
+	return printMigrationStatus(m)
+}
+
+// migrateSteps разбирает число миграций; пустой аргумент — значение по умолчанию
+func migrateSteps(arg string, def int) (int, error) {
+	if arg == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(arg)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("некорректное число миграций %q", arg)
+	}
+	return n, nil
+}
+
+// printMigrationStatus печатает версию схемы и список миграций с отметкой о применении
+func printMigrationStatus(m *dbmigrate.Migrator) error {
+	status, err := m.Status()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "version: %d\ndirty: %t\nlatest: %d\n", status.Version, status.Dirty, status.Latest)
+	for _, mig := range m.Migrations() {
+		state := "applied"
+		if mig.Version > status.Version {
+			state = "pending"
+		}
+		fmt.Fprintf(os.Stdout, "%-8s %d %s\n", state, mig.Version, mig.Name)
+	}
+	return nil
+}
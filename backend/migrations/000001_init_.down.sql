@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS files;
+
+-- Расширение pgcrypto не удаляется: оно могло быть установлено до миграций и нужно другим схемам базы
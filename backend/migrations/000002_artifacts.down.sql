@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS artifacts;
+DROP TABLE IF EXISTS jobs;
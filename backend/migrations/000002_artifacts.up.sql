@@ -0,0 +1,23 @@
+CREATE TABLE jobs (
+id SERIAL PRIMARY KEY,
+file_id INTEGER NOT NULL REFERENCES files(id) ON DELETE CASCADE,
+correlation_id TEXT NOT NULL UNIQUE,
+status TEXT NOT NULL DEFAULT 'pending',
+error TEXT,
+created_at TIMESTAMP DEFAULT now(),
+finished_at TIMESTAMP
+);
+
+CREATE TABLE artifacts (
+id SERIAL PRIMARY KEY,
+file_id INTEGER NOT NULL REFERENCES files(id) ON DELETE CASCADE,
+job_id INTEGER REFERENCES jobs(id) ON DELETE SET NULL,
+type TEXT NOT NULL,
+bucket TEXT NOT NULL,
+object_key TEXT NOT NULL UNIQUE,
+size BIGINT NOT NULL,
+checksum TEXT NOT NULL,
+created_at TIMESTAMP DEFAULT now()
+);
+
+CREATE INDEX artifacts_file_id_idx ON artifacts (file_id);
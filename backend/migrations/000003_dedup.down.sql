@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS objects;
+DROP INDEX IF EXISTS files_sha256_idx;
+
+-- После дедупликации несколько записей файлов могут ссылаться на один объект.
+-- Откат оставляет самую раннюю из них, остальные удаляются вместе с их задачами и артефактами (ON DELETE CASCADE)
+DELETE FROM files a USING files b
+WHERE a.object_key = b.object_key AND a.id > b.id;
+ALTER TABLE files ADD CONSTRAINT files_object_key_key UNIQUE (object_key);
+ALTER TABLE files DROP COLUMN IF EXISTS sha256;
@@ -0,0 +1,12 @@
+ALTER TABLE files ADD COLUMN sha256 TEXT;
+ALTER TABLE files DROP CONSTRAINT files_object_key_key;
+CREATE INDEX files_sha256_idx ON files (sha256);
+
+CREATE TABLE objects (
+object_key TEXT PRIMARY KEY,
+bucket TEXT NOT NULL,
+sha256 TEXT NOT NULL UNIQUE,
+size BIGINT NOT NULL,
+ref_count INTEGER NOT NULL DEFAULT 1,
+created_at TIMESTAMP DEFAULT now()
+);
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS result_cache;
+ALTER TABLE jobs DROP COLUMN IF EXISTS artifact_id;
+ALTER TABLE jobs DROP COLUMN IF EXISTS cache_hit;
+ALTER TABLE jobs DROP COLUMN IF EXISTS model_version;
+ALTER TABLE jobs DROP COLUMN IF EXISTS params_hash;
+ALTER TABLE jobs DROP COLUMN IF EXISTS params;
@@ -0,0 +1,16 @@
+ALTER TABLE jobs ADD COLUMN params JSONB;
+ALTER TABLE jobs ADD COLUMN params_hash TEXT;
+ALTER TABLE jobs ADD COLUMN model_version TEXT;
+ALTER TABLE jobs ADD COLUMN cache_hit BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE jobs ADD COLUMN artifact_id INTEGER REFERENCES artifacts(id) ON DELETE SET NULL;
+
+CREATE TABLE result_cache (
+input_sha256 TEXT NOT NULL,
+params_hash TEXT NOT NULL,
+model_version TEXT NOT NULL,
+artifact_id INTEGER NOT NULL REFERENCES artifacts(id) ON DELETE CASCADE,
+created_at TIMESTAMP DEFAULT now(),
+PRIMARY KEY (input_sha256, params_hash, model_version)
+);
+
+CREATE INDEX result_cache_model_version_idx ON result_cache (model_version);
@@ -0,0 +1,4 @@
+DELETE FROM files WHERE status = 'pending';
+DROP INDEX IF EXISTS files_pending_idx;
+ALTER TABLE files DROP CONSTRAINT IF EXISTS files_status_check;
+ALTER TABLE files DROP COLUMN IF EXISTS status;
@@ -0,0 +1,3 @@
+ALTER TABLE files ADD COLUMN status TEXT NOT NULL DEFAULT 'ready';
+ALTER TABLE files ADD CONSTRAINT files_status_check CHECK (status IN ('pending', 'ready'));
+CREATE INDEX files_pending_idx ON files (created_at) WHERE status = 'pending';
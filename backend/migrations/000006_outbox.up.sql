@@ -0,0 +1,15 @@
+CREATE TABLE outbox (
+id BIGSERIAL PRIMARY KEY,
+job_id INTEGER NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+correlation_id TEXT NOT NULL,
+reply_to TEXT NOT NULL,
+payload JSONB NOT NULL,
+attempts INTEGER NOT NULL DEFAULT 0,
+last_error TEXT,
+next_attempt_at TIMESTAMP NOT NULL DEFAULT now(),
+created_at TIMESTAMP DEFAULT now(),
+sent_at TIMESTAMP
+);
+
+CREATE INDEX outbox_unsent_idx ON outbox (next_attempt_at) WHERE sent_at IS NULL;
+CREATE INDEX outbox_job_id_idx ON outbox (job_id);
@@ -0,0 +1,9 @@
+CREATE TABLE api_keys (
+id SERIAL PRIMARY KEY,
+name TEXT NOT NULL,
+prefix TEXT NOT NULL UNIQUE,
+key_hash TEXT NOT NULL,
+created_at TIMESTAMP NOT NULL DEFAULT now(),
+last_used_at TIMESTAMP,
+revoked_at TIMESTAMP
+);
@@ -0,0 +1,10 @@
+ALTER TABLE api_keys DROP COLUMN IF EXISTS project;
+ALTER TABLE api_keys DROP COLUMN IF EXISTS role;
+
+DROP INDEX IF EXISTS jobs_project_idx;
+ALTER TABLE jobs DROP COLUMN IF EXISTS project;
+ALTER TABLE jobs DROP COLUMN IF EXISTS owner;
+
+DROP INDEX IF EXISTS files_project_idx;
+ALTER TABLE files DROP COLUMN IF EXISTS project;
+ALTER TABLE files DROP COLUMN IF EXISTS owner;
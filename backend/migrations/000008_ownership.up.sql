@@ -0,0 +1,15 @@
+-- Файлы и задачи, созданные до появления проектов, попадают в проект default
+ALTER TABLE files ADD COLUMN owner TEXT;
+ALTER TABLE files ADD COLUMN project TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE files ALTER COLUMN project DROP DEFAULT;
+CREATE INDEX files_project_idx ON files (project);
+
+ALTER TABLE jobs ADD COLUMN owner TEXT;
+ALTER TABLE jobs ADD COLUMN project TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE jobs ALTER COLUMN project DROP DEFAULT;
+CREATE INDEX jobs_project_idx ON jobs (project);
+
+-- Ключи, созданные до появления ролей, давали полный доступ
+ALTER TABLE api_keys ADD COLUMN role TEXT NOT NULL DEFAULT 'admin' CHECK (role IN ('viewer', 'operator', 'admin'));
+ALTER TABLE api_keys ALTER COLUMN role DROP DEFAULT;
+ALTER TABLE api_keys ADD COLUMN project TEXT;
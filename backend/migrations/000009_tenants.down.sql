@@ -0,0 +1,19 @@
+DROP INDEX IF EXISTS jobs_project_created_idx;
+
+ALTER TABLE outbox DROP COLUMN IF EXISTS routing_key;
+
+-- Из одинаковых результатов разных арендаторов остается один
+DELETE FROM result_cache a USING result_cache b
+WHERE a.input_sha256 = b.input_sha256 AND a.params_hash = b.params_hash AND a.model_version = b.model_version
+  AND a.tenant > b.tenant;
+ALTER TABLE result_cache DROP CONSTRAINT result_cache_pkey;
+ALTER TABLE result_cache ADD PRIMARY KEY (input_sha256, params_hash, model_version);
+ALTER TABLE result_cache DROP COLUMN IF EXISTS tenant;
+
+-- Откат не пройдет, если одно и то же содержимое загружено разными арендаторами:
+-- такие файлы нужно удалить или перенести вручную
+ALTER TABLE objects DROP CONSTRAINT objects_tenant_sha256_key;
+ALTER TABLE objects ADD CONSTRAINT objects_sha256_key UNIQUE (sha256);
+ALTER TABLE objects DROP COLUMN IF EXISTS tenant;
+
+DROP TABLE IF EXISTS tenants;
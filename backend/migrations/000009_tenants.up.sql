@@ -0,0 +1,43 @@
+-- Арендатор — проект со своим бакетом или префиксом ключей, ключом маршрутизации задач и квотами.
+-- Пустой bucket означает общий бакет сервиса, 0 в квоте — отсутствие ограничения.
+CREATE TABLE tenants (
+id TEXT PRIMARY KEY,
+name TEXT NOT NULL,
+bucket TEXT,
+prefix TEXT NOT NULL DEFAULT '',
+routing_key TEXT NOT NULL DEFAULT '',
+storage_quota_bytes BIGINT NOT NULL DEFAULT 0 CHECK (storage_quota_bytes >= 0),
+monthly_job_quota INTEGER NOT NULL DEFAULT 0 CHECK (monthly_job_quota >= 0),
+created_at TIMESTAMP DEFAULT now()
+);
+
+-- Проекты, в которых уже есть файлы, задачи или ключи, становятся арендаторами без ограничений
+INSERT INTO tenants (id, name) VALUES ('default', 'default');
+INSERT INTO tenants (id, name)
+SELECT project, project FROM (
+    SELECT project FROM files
+    UNION SELECT project FROM jobs
+    UNION SELECT project FROM api_keys WHERE project IS NOT NULL
+) p
+ON CONFLICT (id) DO NOTHING;
+
+-- Дедупликация объектов работает в пределах арендатора, чтобы данные не пересекали границы бакетов
+ALTER TABLE objects ADD COLUMN tenant TEXT;
+UPDATE objects o SET tenant = (SELECT min(f.project) FROM files f WHERE f.object_key = o.object_key);
+UPDATE objects SET tenant = 'default' WHERE tenant IS NULL;
+ALTER TABLE objects ALTER COLUMN tenant SET NOT NULL;
+ALTER TABLE objects DROP CONSTRAINT objects_sha256_key;
+ALTER TABLE objects ADD CONSTRAINT objects_tenant_sha256_key UNIQUE (tenant, sha256);
+
+-- Кэш результатов тоже свой у каждого арендатора
+ALTER TABLE result_cache ADD COLUMN tenant TEXT;
+UPDATE result_cache rc SET tenant = f.project FROM artifacts a JOIN files f ON f.id = a.file_id WHERE a.id = rc.artifact_id;
+DELETE FROM result_cache WHERE tenant IS NULL;
+ALTER TABLE result_cache ALTER COLUMN tenant SET NOT NULL;
+ALTER TABLE result_cache DROP CONSTRAINT result_cache_pkey;
+ALTER TABLE result_cache ADD PRIMARY KEY (tenant, input_sha256, params_hash, model_version);
+
+ALTER TABLE outbox ADD COLUMN routing_key TEXT NOT NULL DEFAULT '';
+
+-- Подсчет задач арендатора за месяц для квоты обработки
+CREATE INDEX jobs_project_created_idx ON jobs (project, created_at) WHERE NOT cache_hit;
@@ -0,0 +1,2 @@
+-- Число задач в очереди CV worker'а проверяется перед каждой новой обработкой
+CREATE INDEX jobs_pending_idx ON jobs (created_at) WHERE status = 'pending';
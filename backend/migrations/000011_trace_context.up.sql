@@ -0,0 +1,2 @@
+-- Контекст трассировки запроса, создавшего задачу: relay публикует сообщение в той же трассе
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS trace_context JSONB;
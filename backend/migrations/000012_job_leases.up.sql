@@ -0,0 +1,5 @@
+-- Аренда задачи CV worker'ом: выдается при публикации, heartbeat'ы продлевают ее, а задачи с истекшей арендой возвращаются в очередь
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS lease_expires_at TIMESTAMP;
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS heartbeat_at TIMESTAMP;
+CREATE INDEX IF NOT EXISTS jobs_lease_idx ON jobs (lease_expires_at) WHERE lease_expires_at IS NOT NULL;
@@ -0,0 +1,10 @@
+// Package migrations миграции схемы PostgreSQL, встроенные в бинарник.
+// Файлы именуются по правилам golang-migrate: <версия>_<имя>.up.sql и парный .down.sql для отката.
+package migrations
+
+import "embed"
+
+// FS SQL файлы миграций
+//
+//go:embed *.sql
+var FS embed.FS
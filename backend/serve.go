@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"lct/config"
+	"lct/internal/auth"
+	"lct/internal/handlers"
+	"lct/internal/logging"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// serve запускает HTTP API вместе с фоновыми компонентами и работает до SIGINT/SIGTERM
+func serve(cfg *config.Config, args []string) error {
+	if len(args) > 0 {
+		return errors.New("serve не принимает аргументов")
+	}
+	rt := start(cfg)
+	service := rt.service
+
+	jwtVerifier, err := auth.NewJWTVerifier(auth.JWTConfig{
+		Secret:   cfg.JWTSecret,
+		JWKSFile: cfg.JWTJWKSFile,
+		Issuer:   cfg.JWTIssuer,
+		Audience: cfg.JWTAudience,
+	})
+	if err != nil {
+		fatal("Ошибка инициализации проверки JWT", err)
+	}
+	if cfg.AuthDisabled {
+		slog.Warn("Аутентификация отключена, API доступен без учетных данных")
+	}
+
+	// Инициализация маршрутизатора Gin; запросы логирует LogRequests, а не логгер Gin
+	router := gin.New()
+	router.Use(gin.Recovery())
+	h := handlers.NewMinioHandler(service, handlers.AuthConfig{
+		Disabled: cfg.AuthDisabled,
+		JWT:      jwtVerifier,
+	}, handlers.LimitsConfig{
+		RequestsPerSecond:    cfg.RateLimitRPS,
+		Burst:                int(cfg.RateLimitBurst),
+		UploadBytesPerSecond: cfg.UploadBandwidthLimit,
+	})
+	h.RegisterRoutes(router)
+
+	// Задачи, прерванные прошлой остановкой, отправляем CV worker'у повторно
+	if _, err := service.ResumeInterruptedJobs(context.Background()); err != nil {
+		slog.Error("Ошибка возобновления прерванных задач", logging.Err(err))
+	}
+	rt.runBackground()
+
+	// Запуск сервера Gin
+	srv := &http.Server{
+		Addr:    ":" + cfg.Port,
+		Handler: router,
+	}
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- srv.ListenAndServe()
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+
+	select {
+	case err := <-serverErr:
+		fatal("Ошибка запуска сервера Gin", err)
+	case sig := <-stop:
+		slog.Info("Получен сигнал, останавливаем сервис", slog.String("signal", sig.String()))
+	}
+
+	// Сначала снимаем готовность, чтобы балансировщик перестал присылать новые запросы
+	h.SetReady(false)
+
+	// Текущие загрузки и ожидания ответов CV worker'а получают ShutdownTimeout на завершение.
+	// Задачи, не успевшие завершиться, сохраняются как interrupted и будут возобновлены при следующем запуске.
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancelDrain()
+	drained := make(chan error, 1)
+	go func() {
+		drained <- service.Shutdown(drainCtx)
+	}()
+
+	// HTTP серверу даем немного больше времени, чтобы прерванные запросы успели ответить клиентам
+	httpCtx, cancelHTTP := context.WithTimeout(context.Background(), cfg.ShutdownTimeout+5*time.Second)
+	defer cancelHTTP()
+	if err := srv.Shutdown(httpCtx); err != nil {
+		slog.Warn("HTTP сервер остановлен принудительно", logging.Err(err))
+	}
+	if err := <-drained; err != nil {
+		slog.Warn("Не все задачи успели завершиться", logging.Err(err))
+	}
+	// Relay и очередь результатов нужны до конца ожидания задач
+	rt.close()
+	slog.Info("Сервис остановлен")
+	return nil
+}
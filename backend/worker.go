@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"lct/config"
+	"lct/internal/handlers"
+	"lct/internal/logging"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/gin-gonic/gin"
+)
+
+// worker запускает только фоновые компоненты: relay outbox, запись результатов, reaper задач, сверку с хранилищем и сбор метрик.
+// API не обслуживается; на PORT доступны /livez, /readyz и /metrics для оркестратора и Prometheus.
+// Позволяет масштабировать API отдельно от фоновой работы: у реплик serve тогда задается RECONCILE_INTERVAL=0.
+func worker(cfg *config.Config, args []string) error {
+	if len(args) > 0 {
+		return errors.New("worker не принимает аргументов")
+	}
+	rt := start(cfg)
+	rt.runBackground()
+
+	router := gin.New()
+	router.Use(gin.Recovery())
+	h := handlers.NewMinioHandler(rt.service, handlers.AuthConfig{}, handlers.LimitsConfig{})
+	h.RegisterProbes(router)
+	srv := &http.Server{
+		Addr:    ":" + cfg.Port,
+		Handler: router,
+	}
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- srv.ListenAndServe()
+	}()
+	slog.Info("Worker запущен")
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case err := <-serverErr:
+		fatal("Ошибка запуска сервера проверок", err)
+	case sig := <-stop:
+		slog.Info("Получен сигнал, останавливаем worker", slog.String("signal", sig.String()))
+	}
+
+	h.SetReady(false)
+	httpCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(httpCtx); err != nil {
+		slog.Warn("HTTP сервер остановлен принудительно", logging.Err(err))
+	}
+	rt.close()
+	slog.Info("Worker остановлен")
+	return nil
+}
@@ -9,6 +9,8 @@ import FormData from "form-data";
 // Можно переопределить через переменную окружения при старте:
 // BACKEND_URL=http://localhost:9000 npm run dev
 const BACKEND_URL = process.env.BACKEND_URL || "http://localhost:8000";
+// API ключ или JWT для backend: BACKEND_API_KEY=lct_... npm run dev
+const BACKEND_API_KEY = process.env.BACKEND_API_KEY || "";
 
 function broadcastLog(payload: unknown) {
   try {
@@ -21,7 +23,10 @@ function broadcastLog(payload: unknown) {
 }
 
 // Shared axios instance with interceptors for logging
-const api = axios.create({ baseURL: BACKEND_URL });
+const api = axios.create({
+  baseURL: BACKEND_URL,
+  headers: BACKEND_API_KEY ? { Authorization: `Bearer ${BACKEND_API_KEY}` } : {},
+});
 
 api.interceptors.request.use((config) => {
   const rid = Math.random().toString(36).slice(2, 10);